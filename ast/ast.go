@@ -8,6 +8,7 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/Rishabh570/csvlang/token"
@@ -17,6 +18,15 @@ import (
 type Node interface {
 	TokenLiteral() string // returns the literal value of the token as a string
 	String() string       // returns the string representation of the node
+	Pos() token.Position  // returns where the node starts in the source
+}
+
+// startPos returns where a node built around an operator starts, eg. `a + b` starts at a and not at the + token
+func startPos(left Node, operator token.Token) token.Position {
+	if left == nil {
+		return operator.Pos
+	}
+	return left.Pos()
 }
 
 // Statement interface defines the behaviour of the statements in the AST
@@ -45,6 +55,14 @@ func (p *Program) String() string {
 	return out.String()
 }
 
+// Pos returns the position of the first statement in the program
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{Line: 1, Column: 1}
+}
+
 // TokenLiteral() method returns the literal value of the first statement in the program
 func (p *Program) TokenLiteral() string {
 	if len(p.Statements) > 0 {
@@ -62,6 +80,7 @@ type Identifier struct {
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
 func (i *Identifier) String() string       { return i.Value }
 
 // ExpressionStatement struct represents the expression statement in the program
@@ -72,6 +91,7 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -81,37 +101,138 @@ func (es *ExpressionStatement) String() string {
 
 // AssignmentStatement struct represents the assignment statement in the program
 type AssignmentStatement struct {
-	Token token.Token // the identifier token
-	Name  *Identifier
-	Value Expression
+	Token    token.Token // the identifier token
+	Name     *Identifier
+	Operator string // "=" or a compound assignment operator such as "+="
+	Value    Expression
 }
 
 func (as *AssignmentStatement) statementNode()       {}
 func (as *AssignmentStatement) TokenLiteral() string { return as.Token.Literal }
+func (as *AssignmentStatement) Pos() token.Position  { return as.Token.Pos }
 func (as *AssignmentStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(as.Name.String())
-	out.WriteString(" = ")
+	out.WriteString(" " + as.assignmentOperator() + " ")
 	if as.Value != nil {
 		out.WriteString(as.Value.String())
 	}
 	return out.String()
 }
 
+// assignmentOperator defaults to "=" for statements built without an explicit operator
+func (as *AssignmentStatement) assignmentOperator() string {
+	if as.Operator == "" {
+		return "="
+	}
+	return as.Operator
+}
+
 // LoadStatement struct represents the load statement in the program
 type LoadStatement struct {
 	Token    token.Token // the token.LOAD token
 	Filename Expression
+	Trim     bool // trim the whitespace around headers and cells, eg. load data.csv trim
+
+	// SourceFile adds a _source_file column naming the file of each row, eg. load "logs/*.csv" source_file
+	SourceFile bool
+
+	// NumberLocale is the locale numbers are written in, eg. "de" for load data.csv numbers "de"
+	NumberLocale string
+
+	// Database is "pg" or "mysql" when rows are loaded by a query, eg. load pg "SELECT * FROM orders",
+	// Filename is then the query
+	Database string
+
+	// StateFile remembers how far the file was read, so only the rows appended since the last run are loaded,
+	// eg. load delta of big.csv since state ".csvlang-state"
+	StateFile string
+
+	// Quarantine names the variable the rows that can't be read are set aside in, instead of failing the load,
+	// eg. load data.csv quarantine bad_rows. QuarantineFile also writes them to a file, eg. quarantine bad_rows to "bad.csv"
+	Quarantine     string
+	QuarantineFile string
+
+	// NAValues are the cells that mean missing, loaded as empty cells, eg. load data.csv na values ["NA", "-"]
+	NAValues []string
+
+	// ReadOnly loads rows that can't be changed, eg. load data.csv readonly.
+	// Mmap maps the file in memory instead of loading it, its rows are parsed as they are read, eg. load big.csv readonly mmap
+	ReadOnly bool
+	Mmap     bool
 }
 
 func (ls *LoadStatement) statementNode()       {}
+func (ls *LoadStatement) expressionNode()      {} // a load can start a pipeline, eg. load in.csv |> unique()
 func (ls *LoadStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LoadStatement) Pos() token.Position  { return ls.Token.Pos }
 func (ls *LoadStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
+	if ls.Database != "" {
+		out.WriteString(ls.Database + " ")
+	}
+	if ls.StateFile != "" {
+		out.WriteString("delta of ")
+	}
 	if ls.Filename != nil {
 		out.WriteString(ls.Filename.String())
 	}
+	if ls.Trim {
+		out.WriteString(" trim")
+	}
+	if ls.SourceFile {
+		out.WriteString(" source_file")
+	}
+	if ls.NumberLocale != "" {
+		out.WriteString(` numbers "` + ls.NumberLocale + `"`)
+	}
+	if ls.StateFile != "" {
+		out.WriteString(` since state "` + ls.StateFile + `"`)
+	}
+	if ls.Quarantine != "" {
+		out.WriteString(" quarantine " + ls.Quarantine)
+	}
+	if ls.QuarantineFile != "" {
+		out.WriteString(` to "` + ls.QuarantineFile + `"`)
+	}
+	if len(ls.NAValues) > 0 {
+		values := make([]string, len(ls.NAValues))
+		for i, value := range ls.NAValues {
+			values[i] = strconv.Quote(value)
+		}
+		out.WriteString(" na values [" + strings.Join(values, ", ") + "]")
+	}
+	if ls.ReadOnly {
+		out.WriteString(" readonly")
+	}
+	if ls.Mmap {
+		out.WriteString(" mmap")
+	}
+
+	return out.String()
+}
+
+// ImportStatement evaluates another script file, eg. import "lib/cleaning.cl"
+// With an alias (import "lib/cleaning.cl" as cleaning) the script's definitions are kept in their own namespace
+type ImportStatement struct {
+	Token token.Token // the token.IMPORT token
+	Path  Expression
+	Alias *Identifier
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) Pos() token.Position  { return is.Token.Pos }
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(is.TokenLiteral() + " ")
+	if is.Path != nil {
+		out.WriteString(is.Path.String())
+	}
+	if is.Alias != nil {
+		out.WriteString(" as " + is.Alias.String())
+	}
 
 	return out.String()
 }
@@ -120,14 +241,19 @@ func (ls *LoadStatement) String() string {
 // It can be used as an expression
 type ReadExpression struct {
 	Token    token.Token
+	Source   *Identifier // the CSV variable to read from, nil for the loaded csv file
 	Location LocationExpression
 }
 
 func (re *ReadExpression) expressionNode()      {}
 func (re *ReadExpression) TokenLiteral() string { return re.Token.Literal }
+func (re *ReadExpression) Pos() token.Position  { return re.Token.Pos }
 func (re *ReadExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString(re.TokenLiteral() + " ")
+	if re.Source != nil {
+		out.WriteString("from " + re.Source.String() + " ")
+	}
 	if re.Location.String() != "" {
 		out.WriteString(re.Location.String())
 	}
@@ -143,9 +269,13 @@ type ReadStatement struct {
 
 func (rs *ReadStatement) statementNode()       {}
 func (rs *ReadStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReadStatement) Pos() token.Position  { return rs.Token.Pos }
 func (rs *ReadStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(rs.TokenLiteral() + " ")
+	if rs.Source != nil {
+		out.WriteString("from " + rs.Source.String() + " ")
+	}
 	if rs.Location.String() != "" {
 		out.WriteString(rs.Location.String())
 	}
@@ -162,6 +292,7 @@ type ReadFilterExpression struct {
 
 func (le *ReadFilterExpression) expressionNode()      {}
 func (le *ReadFilterExpression) TokenLiteral() string { return le.Token.Literal }
+func (le *ReadFilterExpression) Pos() token.Position  { return le.Token.Pos }
 func (le *ReadFilterExpression) String() string {
 	return fmt.Sprintf("Column: %s, Operator: %s, Value: %s", le.ColumnName, le.Operator, le.Value)
 }
@@ -172,24 +303,26 @@ type LocationExpression struct {
 	RowIndex int
 	// ColIndex int16
 	ColIndex string
-	Filter   *ReadFilterExpression
+	Filter   Expression // a ReadFilterExpression, optionally combined using and/or/not
 }
 
 func (le *LocationExpression) expressionNode()      {}
 func (le *LocationExpression) TokenLiteral() string { return le.Token.Literal }
+func (le *LocationExpression) Pos() token.Position  { return le.Token.Pos }
 func (le *LocationExpression) String() string {
 	return fmt.Sprintf("Row: %d, Column: %s", le.RowIndex, le.ColIndex)
 }
 
 // LetStatement struct holds the let statement AST node
 type LetStatement struct {
-	Token token.Token // the token.LET token
+	Token token.Token // the token.LET token, or token.CONST for a constant declaration
 	Name  *Identifier
 	Value Expression
 }
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
@@ -203,6 +336,28 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// FunctionStatement struct holds a named function declaration, eg. fn clean(row) { ... }
+// It is sugar for `let clean = fn(row) { ... }`
+type FunctionStatement struct {
+	Token    token.Token // the token.FUNCTION token
+	Name     *Identifier
+	Function *FunctionLiteral
+}
+
+func (fs *FunctionStatement) statementNode()       {}
+func (fs *FunctionStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *FunctionStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *FunctionStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(fs.TokenLiteral() + " ")
+	out.WriteString(fs.Name.String())
+	out.WriteString("(")
+	out.WriteString(FormatParameters(fs.Function.Parameters, fs.Function.Defaults, fs.Function.Rest))
+	out.WriteString(") ")
+	out.WriteString(fs.Function.Body.String())
+	return out.String()
+}
+
 // ReturnStatement struct holds the return statement AST node
 type ReturnStatement struct {
 	Token       token.Token // the 'return' token
@@ -211,6 +366,7 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(rs.TokenLiteral() + " ")
@@ -229,8 +385,20 @@ type IntegerLiteral struct {
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+// FloatLiteral struct represents the float literal in the program
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 /*
 *
 Prefix expression
@@ -244,10 +412,15 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
 	out.WriteString(pe.Operator)
+	// keyword operators need a space to stay readable, eg. (not x)
+	if pe.Operator == "not" {
+		out.WriteString(" ")
+	}
 	out.WriteString(pe.Right.String())
 	out.WriteString(")")
 	return out.String()
@@ -263,6 +436,7 @@ type InfixExpression struct {
 
 func (oe *InfixExpression) expressionNode()      {}
 func (oe *InfixExpression) TokenLiteral() string { return oe.Token.Literal }
+func (oe *InfixExpression) Pos() token.Position  { return startPos(oe.Left, oe.Token) }
 func (oe *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -274,6 +448,40 @@ func (oe *InfixExpression) String() string {
 	return out.String()
 }
 
+// PipeExpression passes the value of Left as the first argument of Right, eg. rows |> fill_empty("name", "-")
+// Right is a call expression, or a function called with Left as its only argument
+type PipeExpression struct {
+	Token token.Token // the |> token
+	Left  Expression
+	Right Expression
+}
+
+func (pe *PipeExpression) expressionNode()      {}
+func (pe *PipeExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PipeExpression) Pos() token.Position  { return startPos(pe.Left, pe.Token) }
+func (pe *PipeExpression) String() string {
+	return "(" + pe.Left.String() + " |> " + pe.Right.String() + ")"
+}
+
+// MethodCallExpression calls a function with the receiver as its first argument, eg. csv.filter(f).head(10)
+type MethodCallExpression struct {
+	Token     token.Token // the . token
+	Receiver  Expression
+	Method    *Identifier
+	Arguments []Expression
+}
+
+func (mc *MethodCallExpression) expressionNode()      {}
+func (mc *MethodCallExpression) TokenLiteral() string { return mc.Token.Literal }
+func (mc *MethodCallExpression) Pos() token.Position  { return startPos(mc.Receiver, mc.Token) }
+func (mc *MethodCallExpression) String() string {
+	args := []string{}
+	for _, a := range mc.Arguments {
+		args = append(args, a.String())
+	}
+	return mc.Receiver.String() + "." + mc.Method.String() + "(" + strings.Join(args, ", ") + ")"
+}
+
 // Boolean struct represents the boolean in the program
 type Boolean struct {
 	Token token.Token
@@ -282,8 +490,19 @@ type Boolean struct {
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
+// NullLiteral struct represents the null literal in the program
+type NullLiteral struct {
+	Token token.Token
+}
+
+func (n *NullLiteral) expressionNode()      {}
+func (n *NullLiteral) TokenLiteral() string { return n.Token.Literal }
+func (n *NullLiteral) Pos() token.Position  { return n.Token.Pos }
+func (n *NullLiteral) String() string       { return "null" }
+
 // IfExpression struct represents the if expression in the program
 type IfExpression struct {
 	Token       token.Token // The 'if' token
@@ -294,6 +513,7 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -308,6 +528,165 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// TryExpression struct represents the try expression in the program
+// eg. try { load extra.csv } catch (e) { print(e) }
+type TryExpression struct {
+	Token   token.Token // The 'try' token
+	Body    *BlockStatement
+	Param   *Identifier // bound to the error message in the handler, nil when omitted
+	Handler *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) Pos() token.Position  { return te.Token.Pos }
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("try ")
+	out.WriteString(te.Body.String())
+	out.WriteString(" catch ")
+	if te.Param != nil {
+		out.WriteString("(" + te.Param.String() + ") ")
+	}
+	out.WriteString(te.Handler.String())
+	return out.String()
+}
+
+// MatchExpression struct represents the match expression in the program
+// eg. match (status) { "ok" => 1, "failed", "error" => 2, _ => 0 }
+type MatchExpression struct {
+	Token   token.Token // The 'match' token
+	Subject Expression
+	Arms    []*MatchArm
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) Pos() token.Position  { return me.Token.Pos }
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+	out.WriteString("match")
+	out.WriteString(me.Subject.String())
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(arms, "; "))
+	out.WriteString(" }")
+	return out.String()
+}
+
+// MatchArm holds the patterns of a single match arm and the value it evaluates to.
+// An arm without patterns is the default (_) arm.
+type MatchArm struct {
+	Patterns []Expression
+	Value    Expression
+}
+
+func (ma *MatchArm) String() string {
+	patterns := []string{}
+	for _, p := range ma.Patterns {
+		patterns = append(patterns, p.String())
+	}
+	if len(patterns) == 0 {
+		patterns = append(patterns, "_")
+	}
+	return strings.Join(patterns, ", ") + " => " + ma.Value.String()
+}
+
+// GenerateExpression struct represents a CSV made from scratch, each cell evaluated once per row
+// eg. generate 1000 rows with (id: row_number, name: fake("name"))
+type GenerateExpression struct {
+	Token   token.Token // The 'generate' token
+	Count   Expression
+	Columns []*GenerateColumn
+}
+
+func (ge *GenerateExpression) expressionNode()      {}
+func (ge *GenerateExpression) TokenLiteral() string { return ge.Token.Literal }
+func (ge *GenerateExpression) Pos() token.Position  { return ge.Token.Pos }
+func (ge *GenerateExpression) String() string {
+	columns := []string{}
+	for _, column := range ge.Columns {
+		columns = append(columns, column.String())
+	}
+	return "generate " + ge.Count.String() + " rows with (" + strings.Join(columns, ", ") + ")"
+}
+
+// GenerateColumn holds the name of a generated column and the value of its cells
+type GenerateColumn struct {
+	Name  string
+	Value Expression
+}
+
+func (gc *GenerateColumn) String() string {
+	return gc.Name + ": " + gc.Value.String()
+}
+
+// ValidateExpression struct represents the rules the rows of a CSV are checked against
+// eg. validate rows with (age: int > 0, email: matches(".+@.+"), name: required)
+type ValidateExpression struct {
+	Token  token.Token // The 'validate' token
+	Source Expression
+	Rules  []*ValidateRule
+}
+
+func (ve *ValidateExpression) expressionNode()      {}
+func (ve *ValidateExpression) TokenLiteral() string { return ve.Token.Literal }
+func (ve *ValidateExpression) Pos() token.Position  { return ve.Token.Pos }
+func (ve *ValidateExpression) String() string {
+	rules := []string{}
+	for _, rule := range ve.Rules {
+		rules = append(rules, rule.String())
+	}
+	return "validate " + ve.Source.String() + " with (" + strings.Join(rules, ", ") + ")"
+}
+
+// ValidateRule holds a column and the rule its cells must follow, the rule is checked, not evaluated
+type ValidateRule struct {
+	Column string
+	Rule   Expression
+}
+
+func (vr *ValidateRule) String() string {
+	return vr.Column + ": " + vr.Rule.String()
+}
+
+// UpdateExpression struct represents cells changed in place in the rows of a CSV matching a where clause, it evaluates
+// to the number of rows matched, eg. update csv set (status: "adult") where age > 17
+type UpdateExpression struct {
+	Token   token.Token // The 'update' token
+	Target  Expression
+	Columns []*UpdateColumn
+	Where   Expression // nil to change every row
+}
+
+func (ue *UpdateExpression) expressionNode()      {}
+func (ue *UpdateExpression) TokenLiteral() string { return ue.Token.Literal }
+func (ue *UpdateExpression) Pos() token.Position  { return ue.Token.Pos }
+func (ue *UpdateExpression) String() string {
+	columns := []string{}
+	for _, column := range ue.Columns {
+		columns = append(columns, column.String())
+	}
+	out := "update " + ue.Target.String() + " set (" + strings.Join(columns, ", ") + ")"
+	if ue.Where != nil {
+		out += " where " + ue.Where.String()
+	}
+	return out
+}
+
+// UpdateColumn holds the name of a changed column and the new value of its cells, evaluated once per row
+type UpdateColumn struct {
+	Name  string
+	Value Expression
+}
+
+func (uc *UpdateColumn) String() string {
+	return uc.Name + ": " + uc.Value.String()
+}
+
 // BlockStatement struct represents the block statement in the program
 type BlockStatement struct {
 	Token      token.Token // the { token
@@ -316,6 +695,7 @@ type BlockStatement struct {
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 	for _, s := range bs.Statements {
@@ -328,25 +708,40 @@ func (bs *BlockStatement) String() string {
 type FunctionLiteral struct {
 	Token      token.Token // The 'fn' token
 	Parameters []*Identifier
+	Defaults   []Expression // default value of each parameter, nil when the parameter has none
+	Rest       *Identifier  // variadic parameter collecting the remaining arguments, eg. ...parts
 	Body       *BlockStatement
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
-	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(FormatParameters(fl.Parameters, fl.Defaults, fl.Rest))
 	out.WriteString(") ")
 	out.WriteString(fl.Body.String())
 	return out.String()
 }
 
+// FormatParameters formats a function parameter list, eg. "value, factor = 100, ...rest"
+func FormatParameters(params []*Identifier, defaults []Expression, rest *Identifier) string {
+	formatted := []string{}
+	for i, p := range params {
+		if i < len(defaults) && defaults[i] != nil {
+			formatted = append(formatted, p.String()+" = "+defaults[i].String())
+		} else {
+			formatted = append(formatted, p.String())
+		}
+	}
+	if rest != nil {
+		formatted = append(formatted, "..."+rest.String())
+	}
+	return strings.Join(formatted, ", ")
+}
+
 // CallExpression struct represents the call expression in the program
 type CallExpression struct {
 	Token     token.Token // The '(' token
@@ -356,6 +751,7 @@ type CallExpression struct {
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return startPos(ce.Function, ce.Token) }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 	args := []string{}
@@ -377,6 +773,7 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
 // ArrayLiteral struct represents the array literal in the program
@@ -387,6 +784,7 @@ type ArrayLiteral struct {
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 	elements := []string{}
@@ -406,6 +804,7 @@ type ArrayLiteralStatement struct {
 
 func (rs *ArrayLiteralStatement) statementNode()       {}
 func (rs *ArrayLiteralStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ArrayLiteralStatement) Pos() token.Position  { return rs.Token.Pos }
 
 // IndexExpression for accessing array elements
 type IndexExpression struct {
@@ -416,6 +815,7 @@ type IndexExpression struct {
 
 func (al *IndexExpression) expressionNode()      {}
 func (al *IndexExpression) TokenLiteral() string { return al.Token.Literal }
+func (al *IndexExpression) Pos() token.Position  { return startPos(al.Left, al.Token) }
 func (al *IndexExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -426,6 +826,34 @@ func (al *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression for taking a part of an array, string or CSV
+// Start and End are optional, eg. arr[2:5], arr[:10] or arr[-3:]
+type SliceExpression struct {
+	Token token.Token // The '[' token
+	Left  Expression  // The value being sliced
+	Start Expression  // nil means from the beginning
+	End   Expression  // nil means until the end
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position  { return startPos(se.Left, se.Token) }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	out.WriteString("])")
+	return out.String()
+}
+
 // SaveStatement struct represents the save statement AST in the program.
 // It is used to save the data to a file
 type SaveStatement struct {
@@ -433,20 +861,77 @@ type SaveStatement struct {
 	Source   Expression  // Optional: identifier for custom rows
 	Filename string
 	Format   string // "csv" or "json"
+	// Filenames holds every destination of `save rows as a.csv, a.json`, Filename is the first of them
+	Filenames []string
+	// SplitBy is the column of `save rows split by region as "out_{region}.csv"`, one file is written per value
+	SplitBy string
+	// Bundle holds the entries of `save {summary: stats, detail: rows} as report/`, Filename is then the directory
+	Bundle []*BundleEntry
+	// From is the file of `save from big.csv where status == "failed" as failed.csv`, its rows are copied one at a time
+	// without loading the file, the rows matching Where when there is one
+	From  string
+	Where Expression
+}
+
+// BundleEntry is one file of a report bundle, eg. `summary: stats` is written as summary.csv
+type BundleEntry struct {
+	Name  string
+	Value Expression
 }
 
 func (al *SaveStatement) statementNode()       {}
 func (al *SaveStatement) TokenLiteral() string { return al.Token.Literal }
+func (al *SaveStatement) Pos() token.Position  { return al.Token.Pos }
 func (ss *SaveStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ss.TokenLiteral() + " ")
 	if ss.Source != nil {
-		out.WriteString(ss.Source.String() + " as ")
+		out.WriteString(ss.Source.String() + " ")
+	}
+	if len(ss.Bundle) > 0 {
+		entries := make([]string, len(ss.Bundle))
+		for i, entry := range ss.Bundle {
+			entries[i] = entry.Name + ": " + entry.Value.String()
+		}
+		out.WriteString("{" + strings.Join(entries, ", ") + "} as ")
+	}
+	if ss.SplitBy != "" {
+		out.WriteString("split by " + ss.SplitBy + " ")
+	}
+	if ss.From != "" {
+		out.WriteString("from " + ss.From + " ")
+	}
+	if ss.Where != nil {
+		out.WriteString("where " + ss.Where.String() + " ")
+	}
+	if ss.Source != nil || ss.SplitBy != "" || ss.From != "" {
+		out.WriteString("as ")
+	}
+	if len(ss.Filenames) > 0 {
+		out.WriteString(strings.Join(ss.Filenames, ", "))
+	} else {
+		out.WriteString(ss.Filename)
 	}
-	out.WriteString(ss.Filename)
 	return out.String()
 }
 
+// UnloadStatement struct represents `unload csv, rows`, it drops variables so the CSVs they hold can be freed
+type UnloadStatement struct {
+	Token token.Token // the unload identifier token
+	Names []*Identifier
+}
+
+func (us *UnloadStatement) statementNode()       {}
+func (us *UnloadStatement) TokenLiteral() string { return us.Token.Literal }
+func (us *UnloadStatement) Pos() token.Position  { return us.Token.Pos }
+func (us *UnloadStatement) String() string {
+	names := make([]string, len(us.Names))
+	for i, name := range us.Names {
+		names[i] = name.String()
+	}
+	return "unload " + strings.Join(names, ", ")
+}
+
 // ForLoopExpression for iterating over arrays
 type ForLoopExpression struct {
 	Token       token.Token
@@ -458,6 +943,7 @@ type ForLoopExpression struct {
 
 func (fl *ForLoopExpression) expressionNode()      {}
 func (fl *ForLoopExpression) TokenLiteral() string { return fl.Token.Literal }
+func (fl *ForLoopExpression) Pos() token.Position  { return fl.Token.Pos }
 func (fl *ForLoopExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("for ")
@@ -478,6 +964,7 @@ type ForLoopStatement struct {
 
 func (fl *ForLoopStatement) statementNode()       {}
 func (fl *ForLoopStatement) TokenLiteral() string { return fl.Token.Literal }
+func (fl *ForLoopStatement) Pos() token.Position  { return fl.Token.Pos }
 func (fl *ForLoopStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString("for ")
@@ -493,17 +980,18 @@ func (fl *ForLoopStatement) String() string {
 
 // IndexAssignmentExpression for re-assigning values
 type IndexAssignmentExpression struct {
-	Token token.Token // The '=' token
+	Token token.Token // The '=' token or a compound assignment token such as '+='
 	Left  *IndexExpression
 	Value Expression
 }
 
 func (iae *IndexAssignmentExpression) expressionNode()      {}
 func (iae *IndexAssignmentExpression) TokenLiteral() string { return iae.Token.Literal }
+func (iae *IndexAssignmentExpression) Pos() token.Position  { return startPos(iae.Left, iae.Token) }
 func (iae *IndexAssignmentExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString(iae.Left.String())
-	out.WriteString(" = ")
+	out.WriteString(" " + iae.Token.Literal + " ")
 	out.WriteString(iae.Value.String())
 	return out.String()
 }
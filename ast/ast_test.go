@@ -26,3 +26,21 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestProgramFromJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"version": 2, "program": {"node": "Program"}}`, "unsupported AST version 2, expected 1"},
+		{`{"version": 1, "program": {"node": "Identifier"}}`, "expected Program node, got \"Identifier\""},
+		{`{"version": 1, "program": {"node": "Program", "statements": [{"node": "Loop"}]}}`, "Program.Statements: unknown node type \"Loop\""},
+		{`{"version": 1, "program": {"node": "Program", "statements": [{"node": "Identifier"}]}}`, "Program.Statements: Identifier can't be used as Statement"},
+	}
+	for _, tt := range tests {
+		_, err := ProgramFromJSON([]byte(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %s. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
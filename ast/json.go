@@ -0,0 +1,222 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Rishabh570/csvlang/token"
+)
+
+// JSONVersion is the version of the JSON schema written by ProgramToJSON, it changes when a node is changed incompatibly
+const JSONVersion = 1
+
+// The JSON schema follows the structs of this package, every node is an object holding
+//   - "node", the name of the struct, eg. "InfixExpression"
+//   - "token", the token of the node with its type, literal, line and column, if the node has one
+//   - the fields of the struct named in lowerCamelCase, eg. "operator", "left" and "right"
+//
+// Missing nodes (eg. an if without else) are null. The program is wrapped with the version of the schema:
+//
+//	{"version": 1, "program": {"node": "Program", "statements": [...]}}
+
+// nodeTypes holds the structs that can appear in a program, new nodes must be added here to be read back
+var nodeTypes = map[string]reflect.Type{}
+
+func init() {
+	for _, node := range []interface{}{
+		&Program{}, &Identifier{}, &ExpressionStatement{}, &AssignmentStatement{}, &LoadStatement{}, &ImportStatement{},
+		&ReadExpression{}, &ReadStatement{}, &ReadFilterExpression{}, &LocationExpression{}, &LetStatement{},
+		&FunctionStatement{}, &ReturnStatement{}, &IntegerLiteral{}, &FloatLiteral{}, &PrefixExpression{},
+		&InfixExpression{}, &PipeExpression{}, &MethodCallExpression{}, &Boolean{}, &NullLiteral{}, &IfExpression{},
+		&TryExpression{}, &MatchExpression{}, &MatchArm{}, &BlockStatement{}, &FunctionLiteral{}, &CallExpression{},
+		&StringLiteral{}, &ArrayLiteral{}, &ArrayLiteralStatement{}, &IndexExpression{}, &SliceExpression{},
+		&SaveStatement{}, &BundleEntry{}, &ForLoopExpression{}, &ForLoopStatement{}, &IndexAssignmentExpression{},
+		&GenerateExpression{}, &GenerateColumn{}, &ValidateExpression{}, &ValidateRule{}, &UnloadStatement{},
+		&UpdateExpression{}, &UpdateColumn{},
+	} {
+		t := reflect.TypeOf(node).Elem()
+		nodeTypes[t.Name()] = t
+	}
+}
+
+type tokenJSON struct {
+	Type    token.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+	Line    int             `json:"line"`
+	Column  int             `json:"column"`
+}
+
+type programJSON struct {
+	Version int             `json:"version"`
+	Program json.RawMessage `json:"program"`
+}
+
+var tokenType = reflect.TypeOf(token.Token{})
+
+// ProgramToJSON serializes a program, so tools can inspect it or generate programs to run with ProgramFromJSON.
+func ProgramToJSON(program *Program) ([]byte, error) {
+	encoded, err := encodeValue(reflect.ValueOf(program))
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(programJSON{Version: JSONVersion, Program: raw}, "", "  ")
+}
+
+// ProgramFromJSON reads a program serialized by ProgramToJSON.
+func ProgramFromJSON(data []byte) (*Program, error) {
+	var wrapper programJSON
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return nil, err
+	}
+	if wrapper.Version != JSONVersion {
+		return nil, fmt.Errorf("unsupported AST version %d, expected %d", wrapper.Version, JSONVersion)
+	}
+
+	program := &Program{}
+	if err := decodeStruct(wrapper.Program, reflect.ValueOf(program).Elem()); err != nil {
+		return nil, err
+	}
+	return program, nil
+}
+
+func encodeValue(v reflect.Value) (interface{}, error) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return encodeValue(v.Elem())
+	case reflect.Struct:
+		if v.Type() == tokenType {
+			tok := v.Interface().(token.Token)
+			return tokenJSON{Type: tok.Type, Literal: tok.Literal, Line: tok.Pos.Line, Column: tok.Pos.Column}, nil
+		}
+		return encodeStruct(v)
+	case reflect.Slice:
+		values := make([]interface{}, v.Len())
+		for i := range values {
+			value, err := encodeValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+		return v.Interface(), nil
+	default:
+		return nil, fmt.Errorf("cannot encode %s", v.Type())
+	}
+}
+
+func encodeStruct(v reflect.Value) (map[string]interface{}, error) {
+	if _, ok := nodeTypes[v.Type().Name()]; !ok {
+		return nil, fmt.Errorf("unknown node type %q", v.Type().Name())
+	}
+
+	fields := map[string]interface{}{"node": v.Type().Name()}
+	for i := 0; i < v.NumField(); i++ {
+		value, err := encodeValue(v.Field(i))
+		if err != nil {
+			return nil, err
+		}
+		fields[fieldKey(v.Type().Field(i))] = value
+	}
+	return fields, nil
+}
+
+// decodeValue reads a value into a settable field, interfaces are filled with the node named in the JSON
+func decodeValue(raw json.RawMessage, target reflect.Value) error {
+	if string(raw) == "null" {
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.Interface:
+		var header struct {
+			Node string `json:"node"`
+		}
+		if err := json.Unmarshal(raw, &header); err != nil {
+			return err
+		}
+		t, ok := nodeTypes[header.Node]
+		if !ok {
+			return fmt.Errorf("unknown node type %q", header.Node)
+		}
+		node := reflect.New(t)
+		if !node.Type().Implements(target.Type()) {
+			return fmt.Errorf("%s can't be used as %s", header.Node, target.Type().Name())
+		}
+		if err := decodeStruct(raw, node.Elem()); err != nil {
+			return err
+		}
+		target.Set(node)
+	case reflect.Ptr:
+		node := reflect.New(target.Type().Elem())
+		if err := decodeValue(raw, node.Elem()); err != nil {
+			return err
+		}
+		target.Set(node)
+	case reflect.Struct:
+		if target.Type() == tokenType {
+			var tok tokenJSON
+			if err := json.Unmarshal(raw, &tok); err != nil {
+				return err
+			}
+			target.Set(reflect.ValueOf(token.Token{Type: tok.Type, Literal: tok.Literal, Pos: token.Position{Line: tok.Line, Column: tok.Column}}))
+			return nil
+		}
+		return decodeStruct(raw, target)
+	case reflect.Slice:
+		var elements []json.RawMessage
+		if err := json.Unmarshal(raw, &elements); err != nil {
+			return err
+		}
+		slice := reflect.MakeSlice(target.Type(), len(elements), len(elements))
+		for i, element := range elements {
+			if err := decodeValue(element, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		target.Set(slice)
+	default:
+		return json.Unmarshal(raw, target.Addr().Interface())
+	}
+	return nil
+}
+
+func decodeStruct(raw json.RawMessage, target reflect.Value) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+	var name string
+	if err := json.Unmarshal(fields["node"], &name); err != nil || name != target.Type().Name() {
+		return fmt.Errorf("expected %s node, got %s", target.Type().Name(), fields["node"])
+	}
+
+	for i := 0; i < target.NumField(); i++ {
+		field := target.Type().Field(i)
+		value, ok := fields[fieldKey(field)]
+		if !ok {
+			continue
+		}
+		if err := decodeValue(value, target.Field(i)); err != nil {
+			return fmt.Errorf("%s.%s: %w", name, field.Name, err)
+		}
+	}
+	return nil
+}
+
+// fieldKey returns the JSON key of a field, eg. ColumnName becomes columnName
+func fieldKey(field reflect.StructField) string {
+	first, size := utf8.DecodeRuneInString(field.Name)
+	return string(unicode.ToLower(first)) + field.Name[size:]
+}
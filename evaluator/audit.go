@@ -0,0 +1,91 @@
+package evaluator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Rishabh570/csvlang/token"
+)
+
+// AuditEntry is a file read or written by a script, see AuditLog
+type AuditEntry struct {
+	Operation string `json:"operation"` // "read" or "write"
+	Path      string `json:"path"`
+	Line      int    `json:"line,omitempty"` // the line of the statement, 0 for files of builtins, eg. save(rows, "out.csv")
+	Rows      int    `json:"rows"`           // -1 when they aren't counted, eg. a mapped file
+	Bytes     int64  `json:"bytes"`
+	SHA256    string `json:"sha256,omitempty"` // empty when the path isn't a file, eg. a database or the clipboard
+	DryRun    bool   `json:"dryRun,omitempty"` // the file would have been written
+}
+
+// AuditLog records the files scripts read and write, with their rows, size and checksum, eg. for compliance reviews.
+// It is a Tracer, set it as Trace and write it with WriteJSON once the scripts are done.
+type AuditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+}
+
+// NewAuditLog returns an empty audit log
+func NewAuditLog() *AuditLog {
+	return &AuditLog{}
+}
+
+func (a *AuditLog) OnStatement(pos token.Position, statement string) {}
+
+func (a *AuditLog) OnLoad(pos token.Position, filename string, rows int) {
+	a.record(AuditEntry{Operation: "read", Path: filename, Line: pos.Line, Rows: rows})
+}
+
+func (a *AuditLog) OnSave(pos token.Position, filename string, rows int) {
+	a.record(AuditEntry{Operation: "write", Path: filename, Line: pos.Line, Rows: rows, DryRun: DryRun})
+}
+
+func (a *AuditLog) OnError(pos token.Position, message string) {}
+
+// record adds an entry with the size and checksum of its file, nothing is read for files a dry run didn't write
+func (a *AuditLog) record(entry AuditEntry) {
+	if !entry.DryRun {
+		entry.Bytes, entry.SHA256 = fileChecksum(entry.Path)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries = append(a.entries, entry)
+}
+
+// Entries returns the files read and written so far, in order
+func (a *AuditLog) Entries() []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]AuditEntry{}, a.entries...)
+}
+
+// WriteJSON writes the audit log as a JSON object holding the files read and written, eg. {"files": [{"operation": "read", ...}]}
+func (a *AuditLog) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Files []AuditEntry `json:"files"`
+	}{a.Entries()})
+}
+
+// fileChecksum returns the size and hex SHA-256 of a file, nothing when the path isn't a readable file
+func fileChecksum(path string) (int64, string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, ""
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err != nil || !info.Mode().IsRegular() {
+		return 0, ""
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, ""
+	}
+	return size, hex.EncodeToString(hash.Sum(nil))
+}
@@ -3,10 +3,16 @@ package evaluator
 import (
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/Rishabh570/csvlang/object"
+	"github.com/Rishabh570/csvlang/token"
 )
 
 var builtins = map[string]*object.Builtin{
@@ -20,7 +26,7 @@ var builtins = map[string]*object.Builtin{
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			default:
 				return newError("argument to `len` not supported, got %s",
 					args[0].Type())
@@ -164,7 +170,7 @@ var builtins = map[string]*object.Builtin{
 				if len(csv.Rows) == 0 {
 					return newError("cannot pop from empty CSV")
 				}
-				newRows := csv.Rows[:len(csv.Rows)-1]
+				newRows := csv.ShareRows()[:len(csv.Rows)-1]
 				return &object.CSV{
 					Headers:     csv.Headers,
 					ColumnTypes: csv.ColumnTypes,
@@ -186,6 +192,116 @@ var builtins = map[string]*object.Builtin{
 			return &object.Array{Elements: newElements}
 		},
 	},
+	"reverse": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Array:
+				length := len(arg.Elements)
+				reversed := make([]object.Object, length)
+				for i, element := range arg.Elements {
+					reversed[length-1-i] = element
+				}
+				return &object.Array{Elements: reversed}
+			case *object.String:
+				runes := []rune(arg.Value)
+				for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+					runes[i], runes[j] = runes[j], runes[i]
+				}
+				return &object.String{Value: string(runes)}
+			default:
+				return newError("argument to `reverse` must be ARRAY or STRING, got %s", args[0].Type())
+			}
+		},
+	},
+	"index_of": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments: got=%d, want=2", len(args))
+			}
+			return indexOf("index_of", args[0], args[1])
+		},
+	},
+	"contains": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments: got=%d, want=2", len(args))
+			}
+			index := indexOf("contains", args[0], args[1])
+			if isError(index) {
+				return index
+			}
+			return nativeBoolToBooleanObject(index.(*object.Integer).Value >= 0)
+		},
+	},
+	"slice": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError("wrong number of arguments: got=%d, want=2 or 3", len(args))
+			}
+
+			length, ok := sliceLength(args[0])
+			if !ok {
+				return newError("first argument to `slice` must be ARRAY, STRING or CSV, got %s", args[0].Type())
+			}
+
+			// slice(arr, start) or slice(arr, start, end), same bounds as arr[start:end]
+			bounds := []int64{0, length}
+			for i, arg := range args[1:] {
+				integer, ok := arg.(*object.Integer)
+				if !ok {
+					return newError("slice bound must be INTEGER, got %s", arg.Type())
+				}
+				bounds[i] = clampSliceIndex(integer.Value, length)
+			}
+
+			return sliceObject(args[0], bounds[0], bounds[1])
+		},
+	},
+	"head": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			return headOrTail("head", args)
+		},
+	},
+	"tail": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			return headOrTail("tail", args)
+		},
+	},
+	"zip": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 2 {
+				return newError("wrong number of arguments: got=%d, want at least 2", len(args))
+			}
+
+			// the result is as long as the shortest array
+			arrays := make([]*object.Array, len(args))
+			length := -1
+			for i, arg := range args {
+				arr, ok := arg.(*object.Array)
+				if !ok {
+					return newError("arguments to `zip` must be ARRAY, got %s", arg.Type())
+				}
+				arrays[i] = arr
+				if length == -1 || len(arr.Elements) < length {
+					length = len(arr.Elements)
+				}
+			}
+
+			zipped := make([]object.Object, length)
+			for i := range zipped {
+				tuple := make([]object.Object, len(arrays))
+				for j, arr := range arrays {
+					tuple[j] = arr.Elements[i]
+				}
+				zipped[i] = &object.Array{Elements: tuple}
+			}
+			return &object.Array{Elements: zipped}
+		},
+	},
 	"unique": &object.Builtin{
 		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
@@ -217,8 +333,16 @@ var builtins = map[string]*object.Builtin{
 	},
 	"sum": &object.Builtin{
 		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			args = withSingleColumn(args)
+			if len(args) == 2 {
+				values, columnType, errObj := numericColumn("sum", args[0], args[1])
+				if errObj != nil {
+					return errObj
+				}
+				return sumColumn(values, columnType)
+			}
 			if len(args) != 1 {
-				return newError("wrong number of arguments: got=%d, want=1", len(args))
+				return newError("wrong number of arguments: got=%d, want=1 or 2", len(args))
 			}
 
 			// Check if argument is array
@@ -243,8 +367,26 @@ var builtins = map[string]*object.Builtin{
 	},
 	"avg": &object.Builtin{
 		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			args = withSingleColumn(args)
+			if len(args) == 2 {
+				values, columnType, errObj := numericColumn("avg", args[0], args[1])
+				if errObj != nil {
+					return errObj
+				}
+				if len(values) == 0 {
+					return newError("cannot calculate average of empty column")
+				}
+				switch total := sumColumn(values, columnType).(type) {
+				case *object.Integer:
+					return &object.Integer{Value: total.Value / int64(len(values))}
+				case *object.Float:
+					return &object.Float{Value: total.Value / float64(len(values))}
+				default:
+					return total
+				}
+			}
 			if len(args) != 1 {
-				return newError("wrong number of arguments: got=%d, want=1", len(args))
+				return newError("wrong number of arguments: got=%d, want=1 or 2", len(args))
 			}
 
 			// Check if argument is array
@@ -305,6 +447,258 @@ var builtins = map[string]*object.Builtin{
 			}
 		},
 	},
+	"save": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments: got=%d, want=2", len(args))
+			}
+			csv, ok := args[0].(*object.CSV)
+			if !ok {
+				return newError("first argument to `save` must be CSV, got %s", args[0].Type())
+			}
+			filename, ok := args[1].(*object.String)
+			if !ok {
+				return newError("filename of `save` must be STRING, got %s", args[1].Type())
+			}
+			result := saveAs(csv, filename.Value)
+			if !isError(result) {
+				traceSave(token.Position{}, filename.Value, len(csv.Rows))
+			}
+			return result
+		},
+	},
+	"print": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			values := make([]string, len(args))
+			for i, arg := range args {
+				values[i] = arg.Inspect()
+			}
+			fmt.Println(strings.Join(values, " "))
+			return NULL
+		},
+	},
+	"assert": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments: got=%d, want=1 or 2", len(args))
+			}
+			if isTruthy(args[0]) {
+				return NULL
+			}
+			if len(args) == 2 {
+				return newError("assertion failed: %s", args[1].Inspect())
+			}
+			return newError("assertion failed")
+		},
+	},
+	"exit": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments: got=%d, want=0 or 1", len(args))
+			}
+			if len(args) == 0 {
+				return &object.Exit{Code: 0}
+			}
+			code, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument to `exit` must be INTEGER, got %s", args[0].Type())
+			}
+			if code.Value < 0 || code.Value > 255 {
+				return newError("exit code must be between 0 and 255, got %d", code.Value)
+			}
+			return &object.Exit{Code: int(code.Value)}
+		},
+	},
+	"abs": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				if arg.Value == math.MinInt64 {
+					return newError("integer overflow: abs(%d)", arg.Value)
+				}
+				if arg.Value < 0 {
+					return &object.Integer{Value: -arg.Value}
+				}
+				return arg
+			case *object.Float:
+				return &object.Float{Value: math.Abs(arg.Value)}
+			default:
+				return newError("argument to `abs` must be INTEGER or FLOAT, got %s", args[0].Type())
+			}
+		},
+	},
+	"floor": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			return roundToInteger("floor", args[0], math.Floor)
+		},
+	},
+	"ceil": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			return roundToInteger("ceil", args[0], math.Ceil)
+		},
+	},
+	"round": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments: got=%d, want=1 or 2", len(args))
+			}
+
+			// round(x) rounds to an integer, halves away from zero
+			if len(args) == 1 {
+				return roundToInteger("round", args[0], math.Round)
+			}
+
+			// round(x, precision) keeps the given number of decimals
+			precision, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("precision of `round` must be INTEGER, got %s", args[1].Type())
+			}
+			if precision.Value < 0 || precision.Value > 15 {
+				return newError("precision of `round` must be between 0 and 15, got %d", precision.Value)
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Float:
+				scale := math.Pow(10, float64(precision.Value))
+				return &object.Float{Value: math.Round(arg.Value*scale) / scale}
+			default:
+				return newError("argument to `round` must be INTEGER or FLOAT, got %s", args[0].Type())
+			}
+		},
+	},
+	"regex_match": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments: got=%d, want=2", len(args))
+			}
+			value, re, errObj := regexArgs("regex_match", args[0], args[1])
+			if errObj != nil {
+				return errObj
+			}
+			return nativeBoolToBooleanObject(re.MatchString(value))
+		},
+	},
+	"regex_extract": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError("wrong number of arguments: got=%d, want=2 or 3", len(args))
+			}
+			value, re, errObj := regexArgs("regex_extract", args[0], args[1])
+			if errObj != nil {
+				return errObj
+			}
+
+			// the whole match by default, or the given capture group
+			group := int64(0)
+			if len(args) == 3 {
+				groupArg, ok := args[2].(*object.Integer)
+				if !ok {
+					return newError("group of `regex_extract` must be INTEGER, got %s", args[2].Type())
+				}
+				group = groupArg.Value
+			}
+			if group < 0 || group > int64(re.NumSubexp()) {
+				return newError("pattern %s has no group %d", re.String(), group)
+			}
+
+			match := re.FindStringSubmatchIndex(value)
+			if match == nil || match[2*group] < 0 {
+				return NULL
+			}
+			return &object.String{Value: value[match[2*group]:match[2*group+1]]}
+		},
+	},
+	"regex_replace": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments: got=%d, want=3", len(args))
+			}
+			value, re, errObj := regexArgs("regex_replace", args[0], args[1])
+			if errObj != nil {
+				return errObj
+			}
+			replacement, ok := args[2].(*object.String)
+			if !ok {
+				return newError("replacement of `regex_replace` must be STRING, got %s", args[2].Type())
+			}
+
+			// $1 or ${name} in the replacement refer to capture groups
+			return &object.String{Value: re.ReplaceAllString(value, replacement.Value)}
+		},
+	},
+	"is_null": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			return nativeBoolToBooleanObject(args[0] == NULL)
+		},
+	},
+	"type": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			return &object.String{Value: string(args[0].Type())}
+		},
+	},
+	"is_csv":    isTypeBuiltin(object.CSV_OBJ),
+	"is_array":  isTypeBuiltin(object.ARRAY),
+	"is_int":    isTypeBuiltin(object.INTEGER_OBJ),
+	"is_float":  isTypeBuiltin(object.FLOAT_OBJ),
+	"is_string": isTypeBuiltin(object.STRING_OBJ),
+	"is_bool":   isTypeBuiltin(object.BOOLEAN_OBJ),
+	"range": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			start, end, step, errObj := rangeArguments("range", args)
+			if errObj != nil {
+				return errObj
+			}
+			return integerRange(start, end, step)
+		},
+	},
+	"format": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments: got=%d, want at least 1", len(args))
+			}
+
+			template, ok := args[0].(*object.String)
+			if !ok {
+				return newError("first argument to `format` must be STRING, got %s", args[0].Type())
+			}
+
+			// every {} placeholder is replaced by the next argument
+			values := args[1:]
+			placeholders := strings.Count(template.Value, "{}")
+			if placeholders != len(values) {
+				return newError("format string has %d placeholders but got %d values", placeholders, len(values))
+			}
+
+			var out strings.Builder
+			parts := strings.Split(template.Value, "{}")
+			for i, part := range parts {
+				out.WriteString(part)
+				if i < len(values) {
+					out.WriteString(values[i].Inspect())
+				}
+			}
+
+			return &object.String{Value: out.String()}
+		},
+	},
 	"fill_empty": &object.Builtin{
 		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 3 {
@@ -322,17 +716,14 @@ var builtins = map[string]*object.Builtin{
 				return newError(err.Error())
 			}
 
+			// rows without an empty cell are shared with csv, only the filled rows are copied
 			fieldName := args[1].Inspect()
-			for i, row := range csv.Rows {
-				newRow := make(map[string]string)
-				for _, header := range csv.Headers {
-					if header == fieldName && row[header] == "" {
-						newRow[header] = fieldValue
-					} else {
-						newRow[header] = row[header]
-					}
+			for i, row := range csv.ShareRows() {
+				newRows[i] = row
+				if row[fieldName] == "" && containsString(csv.Headers, fieldName) {
+					newRows[i] = copyRow(row)
+					newRows[i][fieldName] = fieldValue
 				}
-				newRows[i] = newRow
 			}
 
 			modifiedCSV := &object.CSV{
@@ -341,12 +732,371 @@ var builtins = map[string]*object.Builtin{
 				Rows:        newRows,
 			}
 			// save to env
-			env.Set("csv", modifiedCSV)
+			env.SetOutsideBlocks("csv", modifiedCSV)
 			return modifiedCSV
 		},
 	},
 }
 
+// compareObjects is the default ordering of sort: numbers by value and strings alphabetically.
+// It returns an error for other types, eg. rows need a comparator function.
+func compareObjects(a, b object.Object) (bool, object.Object) {
+	switch {
+	case isNumeric(a) && isNumeric(b):
+		if a.Type() == object.INTEGER_OBJ && b.Type() == object.INTEGER_OBJ {
+			return a.(*object.Integer).Value < b.(*object.Integer).Value, nil
+		}
+		return toFloat(a) < toFloat(b), nil
+	case a.Type() == object.STRING_OBJ && b.Type() == object.STRING_OBJ:
+		return a.(*object.String).Value < b.(*object.String).Value, nil
+	default:
+		return false, newError("cannot sort %s and %s without a comparator", a.Type(), b.Type())
+	}
+}
+
+// applyComparator calls a sort comparator with two elements.
+// The comparator either returns a BOOLEAN (true if a comes first) or an INTEGER (negative if a comes first).
+func applyComparator(comparator, a, b object.Object, env *object.Environment) (bool, object.Object) {
+	result := applyFunction(comparator, []object.Object{a, b}, env)
+	switch result := result.(type) {
+	case *object.Boolean:
+		return result.Value, nil
+	case *object.Integer:
+		return result.Value < 0, nil
+	default:
+		if isError(result) {
+			return false, result
+		}
+		return false, newError("sort comparator must return BOOLEAN or INTEGER, got %s", result.Type())
+	}
+}
+
+// indexOf returns the position of value in an array, or of a substring in a string, and -1 if it's not found.
+// name is the builtin it is called from, used in error messages.
+func indexOf(name string, container, value object.Object) object.Object {
+	switch container := container.(type) {
+	case *object.Array:
+		for i, element := range container.Elements {
+			if objectsEqual(element, value) {
+				return &object.Integer{Value: int64(i)}
+			}
+		}
+		return &object.Integer{Value: -1}
+	case *object.String:
+		substring, ok := value.(*object.String)
+		if !ok {
+			return newError("second argument to `%s` must be STRING when searching a STRING, got %s", name, value.Type())
+		}
+		return &object.Integer{Value: int64(strings.Index(container.Value, substring.Value))}
+	default:
+		return newError("first argument to `%s` must be ARRAY or STRING, got %s", name, container.Type())
+	}
+}
+
+// roundToInteger applies a rounding function (eg. math.Floor) to a number and returns the result as an INTEGER.
+// name is the builtin it is called from, used in error messages.
+func roundToInteger(name string, arg object.Object, round func(float64) float64) object.Object {
+	switch arg := arg.(type) {
+	case *object.Integer:
+		return arg
+	case *object.Float:
+		rounded := round(arg.Value)
+		if math.IsNaN(rounded) || rounded < math.MinInt64 || rounded >= math.MaxInt64 {
+			return newError("%s(%s) does not fit in an INTEGER", name, arg.Inspect())
+		}
+		return &object.Integer{Value: int64(rounded)}
+	default:
+		return newError("argument to `%s` must be INTEGER or FLOAT, got %s", name, arg.Type())
+	}
+}
+
+// regexCache holds compiled patterns keyed by the pattern string, so a pattern used in a loop is compiled once
+var (
+	regexCache   = map[string]*regexp.Regexp{}
+	regexCacheMu sync.Mutex
+)
+
+// compileRegex compiles a pattern or returns the cached compiled pattern.
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	regexCacheMu.Lock()
+	defer regexCacheMu.Unlock()
+
+	if re, ok := regexCache[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexCache[pattern] = re
+	return re, nil
+}
+
+// regexArgs validates the value and pattern arguments of the regex builtins and compiles the pattern.
+// Integer values (eg. zip codes read from a CSV) are matched by their text.
+func regexArgs(name string, valueArg, patternArg object.Object) (string, *regexp.Regexp, object.Object) {
+	var value string
+	switch arg := valueArg.(type) {
+	case *object.String:
+		value = arg.Value
+	case *object.Integer, *object.Float:
+		value = arg.Inspect()
+	default:
+		return "", nil, newError("first argument to `%s` must be STRING, got %s", name, valueArg.Type())
+	}
+
+	pattern, ok := patternArg.(*object.String)
+	if !ok {
+		return "", nil, newError("pattern of `%s` must be STRING, got %s", name, patternArg.Type())
+	}
+	re, err := compileRegex(pattern.Value)
+	if err != nil {
+		return "", nil, newError("invalid pattern %s: %s", pattern.Value, err)
+	}
+	return value, re, nil
+}
+
+// filterCSV keeps the rows for which the predicate returns a truthy value
+// Example: filter_rows(csv, fn(row) { row.age > 18 })
+func filterCSV(csv *object.CSV, predicate object.Object, env *object.Environment) object.Object {
+	filtered := []map[string]string{}
+	for i, row := range csv.Rows {
+		result := applyFunction(predicate, []object.Object{&object.Row{Headers: csv.Headers, Values: row, CSV: csv, Index: i}}, env)
+		if isError(result) {
+			return result
+		}
+		// the predicate may have changed the row
+		if isTruthy(result) {
+			filtered = append(filtered, csv.Rows[i])
+		}
+	}
+
+	// once the predicate changed no more rows, the kept rows are shared with csv
+	csv.ShareRows()
+	return &object.CSV{
+		Headers:     csv.Headers,
+		ColumnTypes: csv.ColumnTypes,
+		Rows:        filtered,
+	}
+}
+
+// numericColumn returns the non-empty cells of a CSV column, eg. for sum(csv, "amount").
+// The column must be typed INTEGER or FLOAT and every cell must parse as that type, cells are parsed once, see object.CSV.Cells.
+func numericColumn(name string, csvArgument, columnArgument object.Object) ([]object.Cell, object.ObjectType, object.Object) {
+	csv, ok := csvArgument.(*object.CSV)
+	if !ok {
+		return nil, "", newError("first argument to `%s` must be CSV, got %s", name, csvArgument.Type())
+	}
+	column, ok := columnArgument.(*object.String)
+	if !ok {
+		return nil, "", newError("column name of `%s` must be STRING, got %s", name, columnArgument.Type())
+	}
+
+	var columnType object.ObjectType
+	found := false
+	for i, header := range csv.Headers {
+		if header != column.Value {
+			continue
+		}
+		found = true
+		if i < len(csv.ColumnTypes) {
+			columnType = csv.ColumnTypes[i].DataType
+		}
+	}
+	if !found {
+		return nil, "", newError("column not found: %s", column.Value)
+	}
+	if columnType != object.INTEGER_OBJ && columnType != object.FLOAT_OBJ {
+		return nil, "", newError("cannot %s non-numeric column %s of type %s", name, column.Value, columnType)
+	}
+
+	values := []object.Cell{}
+	for i, cell := range csv.Cells(column.Value) {
+		switch {
+		case cell.Kind == object.NullCell:
+			continue
+		case cell.Kind == object.IntegerCell, cell.Kind == object.FloatCell && columnType == object.FLOAT_OBJ:
+			values = append(values, cell)
+		default:
+			return nil, "", newError("invalid %s %q in column %s at row %d", columnType, cell.Text, column.Value, i)
+		}
+	}
+	return values, columnType, nil
+}
+
+// cellNumber returns the number of a cell, cells of INTEGER and FLOAT columns are parsed once, see object.CSV.Cells, other cells here
+func cellNumber(cell object.Cell) (float64, bool) {
+	if cell.Kind == object.IntegerCell || cell.Kind == object.FloatCell {
+		return cell.Float, true
+	}
+	value, err := strconv.ParseFloat(cell.Text, 64)
+	return value, err == nil
+}
+
+// withSingleColumn names the column of a one column CSV argument, eg. sum(read row * col amount) becomes sum(rows, "amount")
+func withSingleColumn(args []object.Object) []object.Object {
+	if len(args) != 1 {
+		return args
+	}
+	if csv, ok := args[0].(*object.CSV); ok && len(csv.Headers) == 1 {
+		return []object.Object{csv, &object.String{Value: csv.Headers[0]}}
+	}
+	return args
+}
+
+// sumColumn adds up the values returned by numericColumn, INTEGER columns sum to an INTEGER and FLOAT columns to a FLOAT
+func sumColumn(values []object.Cell, columnType object.ObjectType) object.Object {
+	if columnType == object.INTEGER_OBJ {
+		sum := int64(0)
+		for _, value := range values {
+			sum += value.Int
+		}
+		return &object.Integer{Value: sum}
+	}
+
+	sum := 0.0
+	for _, value := range values {
+		sum += value.Float
+	}
+	return &object.Float{Value: sum}
+}
+
+// headOrTail returns the first (head) or last (tail) n elements of an array or rows of a CSV, 10 when n is not given.
+// The head of an iterator is an iterator stopping after its first n values.
+func headOrTail(name string, args []object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("wrong number of arguments: got=%d, want=1 or 2", len(args))
+	}
+	it, isIterator := args[0].(*object.Iterator)
+	if !(isIterator && name == "head") && args[0].Type() != object.ARRAY && args[0].Type() != object.CSV_OBJ {
+		return newError("first argument to `%s` must be ARRAY or CSV, got %s", name, args[0].Type())
+	}
+
+	n := int64(10)
+	if len(args) == 2 {
+		integer, ok := args[1].(*object.Integer)
+		if !ok || integer.Value < 0 {
+			return newError("count of `%s` must be a non-negative INTEGER, got %s", name, args[1].Inspect())
+		}
+		n = integer.Value
+	}
+	if isIterator {
+		return iteratorHead(it, n)
+	}
+	length, _ := sliceLength(args[0])
+	n = min(n, length)
+
+	if name == "head" {
+		return sliceObject(args[0], 0, n)
+	}
+	return sliceObject(args[0], length-n, length)
+}
+
+// isTypeBuiltin creates a builtin checking if its argument is of the given type, eg. is_int(5)
+func isTypeBuiltin(objectType object.ObjectType) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			return nativeBoolToBooleanObject(args[0].Type() == objectType)
+		},
+	}
+}
+
+// filter_rows and sort call back into the evaluator, so they are registered in init to avoid an initialization cycle with builtins
+func init() {
+	builtins["sort"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			// sort(csv, "age") or sort(csv, "age", "desc") sorts rows by a column
+			if len(args) > 0 {
+				if csv, ok := args[0].(*object.CSV); ok {
+					return sortCSV(csv, args[1:])
+				}
+			}
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments: got=%d, want=1 or 2", len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("first argument to `sort` must be ARRAY, got %s", args[0].Type())
+			}
+
+			less := compareObjects
+			if len(args) == 2 {
+				comparator := args[1]
+				less = func(a, b object.Object) (bool, object.Object) {
+					return applyComparator(comparator, a, b, env)
+				}
+			}
+
+			// the sort is stable, the first error stops comparing and is returned
+			sorted := make([]object.Object, len(arr.Elements))
+			copy(sorted, arr.Elements)
+			var errObj object.Object
+			sort.SliceStable(sorted, func(i, j int) bool {
+				if errObj != nil {
+					return false
+				}
+				isLess, err := less(sorted[i], sorted[j])
+				if err != nil {
+					errObj = err
+				}
+				return isLess
+			})
+			if errObj != nil {
+				return errObj
+			}
+
+			return &object.Array{Elements: sorted}
+		},
+	}
+
+	builtins["filter_rows"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments: got=%d, want=2", len(args))
+			}
+
+			csv, ok := args[0].(*object.CSV)
+			if !ok {
+				return newError("first argument to `filter_rows` must be CSV, got %s", args[0].Type())
+			}
+			return filterCSV(csv, args[1], env)
+		},
+	}
+
+	// filter keeps the elements of an array or the rows of a CSV for which the function returns a truthy value
+	// Example: filter([1, 2, 3], fn(x) { x > 1 }) or csv |> filter(fn(row) { row.age > 18 })
+	builtins["filter"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments: got=%d, want=2", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.CSV:
+				return filterCSV(arg, args[1], env)
+			case *object.Array:
+				filtered := []object.Object{}
+				for _, element := range arg.Elements {
+					result := applyFunction(args[1], []object.Object{element}, env)
+					if isError(result) {
+						return result
+					}
+					if isTruthy(result) {
+						filtered = append(filtered, element)
+					}
+				}
+				return &object.Array{Elements: filtered}
+			default:
+				return newError("first argument to `filter` must be ARRAY or CSV, got %s", args[0].Type())
+			}
+		},
+	}
+}
+
 // object.CSV is our primary data type; it's best to implicitly convert the data type
 func removeDuplicatesFrom2dArray(arr *object.Array, env *object.Environment) *object.CSV {
 	// Handle empty array
@@ -369,8 +1119,7 @@ func removeDuplicatesFrom2dArray(arr *object.Array, env *object.Environment) *ob
 	var headers []string
 	var columnTypes []object.ColumnType
 
-	if csvObj, ok := env.Get("csv"); ok {
-		currentCSV := csvObj.(*object.CSV)
+	if currentCSV, ok := env.LoadedCSV(); ok {
 		headers = currentCSV.Headers
 		columnTypes = currentCSV.ColumnTypes
 		// Validate row length matches headers
@@ -392,7 +1141,7 @@ func removeDuplicatesFrom2dArray(arr *object.Array, env *object.Environment) *ob
 	uniqueRows := []map[string]string{}
 
 	// Create key slice for deduplication
-	key := make([]string, len(arr.Elements))
+	key := make([]string, rowLength)
 	for _, oneDArr := range arr.Elements {
 		row, ok := oneDArr.(*object.Array)
 		if !ok || len(row.Elements) != rowLength {
@@ -402,7 +1151,7 @@ func removeDuplicatesFrom2dArray(arr *object.Array, env *object.Environment) *ob
 		for i, ele := range row.Elements {
 			key[i] = ele.Inspect()
 		}
-		rowKey := strings.Join(key, "|")
+		rowKey := uniqueKey(key)
 
 		if !seen[rowKey] {
 			seen[rowKey] = true
@@ -425,17 +1174,21 @@ func removeDuplicatesFrom2dArray(arr *object.Array, env *object.Environment) *ob
 	}
 }
 
+// uniqueKey joins the cells of a row into a key identifying it, each cell is quoted so "a|b","c" and "a","b|c" get different keys.
+func uniqueKey(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = strconv.Quote(value)
+	}
+	return strings.Join(quoted, ",")
+}
+
 func removeDuplicates(csv *object.CSV) *object.CSV {
 	seen := make(map[string]bool)
 	uniqueRows := []map[string]string{}
 
-	for _, row := range csv.Rows {
-		// Create a unique key for the row
-		key := make([]string, len(csv.Headers))
-		for i, header := range csv.Headers {
-			key[i] = row[header]
-		}
-		rowKey := strings.Join(key, "|")
+	for _, row := range csv.ShareRows() {
+		rowKey := uniqueKey(csv.Values(row))
 
 		if !seen[rowKey] {
 			seen[rowKey] = true
@@ -467,8 +1220,8 @@ func mergeCSVs(target, source *object.CSV) object.Object {
 
 	// Merge rows
 	newRows := make([]map[string]string, len(target.Rows)+len(source.Rows))
-	copy(newRows, target.Rows)
-	copy(newRows[len(target.Rows):], source.Rows)
+	copy(newRows, target.ShareRows())
+	copy(newRows[len(target.Rows):], source.ShareRows())
 
 	return &object.CSV{
 		Headers:     target.Headers,
@@ -495,3 +1248,23 @@ func convertToString(value any) (string, error) {
 		return "", errors.New("unsupported type: only integers are supported")
 	}
 }
+
+// BuiltinNames returns the sorted names of the builtin functions, eg. for editor completions.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterBuiltin adds a builtin function, eg. from a plugin. Builtins can't be replaced, so scripts behave the same with any plugin.
+// It must be called before scripts are evaluated.
+func RegisterBuiltin(name string, builtin *object.Builtin) error {
+	if _, ok := builtins[name]; ok {
+		return fmt.Errorf("builtin %s already exists", name)
+	}
+	builtins[name] = builtin
+	return nil
+}
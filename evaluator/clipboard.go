@@ -0,0 +1,107 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// clipboardName stands for the clipboard in load and save statements, eg. `load clipboard` or `save rows as clipboard`
+const clipboardName = "clipboard"
+
+// clipboardReaders and clipboardWriters are the commands tried, in order, to read and write the system clipboard.
+// The first one installed is used: macOS, Wayland, X11 and then Windows (also from WSL).
+var (
+	clipboardReaders = [][]string{
+		{"pbpaste"},
+		{"wl-paste", "--no-newline"},
+		{"xclip", "-selection", "clipboard", "-out"},
+		{"xsel", "--clipboard", "--output"},
+		{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard -Raw"},
+	}
+	clipboardWriters = [][]string{
+		{"pbcopy"},
+		{"wl-copy"},
+		{"xclip", "-selection", "clipboard", "-in"},
+		{"xsel", "--clipboard", "--input"},
+		{"clip.exe"},
+	}
+)
+
+// clipboardCommand returns the first installed command of a list
+func clipboardCommand(commands [][]string) (*exec.Cmd, error) {
+	for _, command := range commands {
+		if _, err := exec.LookPath(command[0]); err == nil {
+			return exec.Command(command[0], command[1:]...), nil
+		}
+	}
+	names := make([]string, len(commands))
+	for i, command := range commands {
+		names[i] = command[0]
+	}
+	return nil, fmt.Errorf("no clipboard tool found, install one of %s", strings.Join(names, ", "))
+}
+
+// loadFromClipboard loads the rows on the clipboard like a loaded file.
+// Rows copied out of a spreadsheet are separated by tabs, so the cells are split on tabs when the first line has one.
+func loadFromClipboard(ls *ast.LoadStatement, env *object.Environment) object.Object {
+	cmd, err := clipboardCommand(clipboardReaders)
+	if err != nil {
+		return newError("could not read the clipboard: %s", err)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	content, err := cmd.Output()
+	if err != nil {
+		return newError("could not read the clipboard: %s", commandError(err, stderr))
+	}
+
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	reader := csv.NewReader(strings.NewReader(text))
+	if firstLine, _, _ := strings.Cut(text, "\n"); strings.Contains(firstLine, "\t") {
+		reader.Comma = '\t'
+	}
+	csvObj, err := readCSV(reader, ls.Trim, ls.NumberLocale)
+	if err != nil {
+		return newError("could not read the clipboard: %s", err)
+	}
+
+	env.SetOutsideBlocks("filename", &object.String{Value: clipboardName})
+	env.SetOutsideBlocks("csv", csvObj)
+	return csvObj
+}
+
+// saveToClipboard copies rows to the clipboard separated by tabs, so they paste into the cells of a spreadsheet
+func saveToClipboard(data *object.CSV) object.Object {
+	cmd, err := clipboardCommand(clipboardWriters)
+	if err != nil {
+		return newError("could not write the clipboard: %s", err)
+	}
+	var text bytes.Buffer
+	writer := csv.NewWriter(&text)
+	writer.Comma = '\t'
+	if err := writeCSV(writer, data); err != nil {
+		return newError("%s", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stdin = &text
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return newError("could not write the clipboard: %s", commandError(err, stderr))
+	}
+	return NULL
+}
+
+// commandError adds what a failed command printed to its error
+func commandError(err error, stderr bytes.Buffer) string {
+	if output := strings.TrimSpace(stderr.String()); output != "" {
+		return fmt.Sprintf("%s: %s", err, output)
+	}
+	return err.Error()
+}
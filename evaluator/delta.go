@@ -0,0 +1,163 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// deltaState is the content of a state file, it can track several files
+type deltaState struct {
+	Files map[string]deltaPosition `json:"files"`
+}
+
+// deltaPosition is how far a file was read by the last delta load
+type deltaPosition struct {
+	Offset int64  `json:"offset"` // the byte offset after the last row loaded
+	Rows   int    `json:"rows"`   // the number of rows loaded so far
+	Header string `json:"header"` // the header line, a different header means the file was replaced
+}
+
+// loadDelta loads the rows appended to a file since the last delta load, the new offset is recorded in the state file
+// once the run succeeds, see EndRun, so a run failing after the load loads the same rows again.
+// The first load, and loads of a file that was truncated or whose header changed, read every row.
+// A last line without a newline may still be written, it is left for the next run.
+func loadDelta(ls *ast.LoadStatement, env *object.Environment) object.Object {
+	filename := ls.Filename.String()
+	csvObj, position, err := readDelta(filename, ls.StateFile, ls.Trim, ls.NumberLocale)
+	if err != nil {
+		return newError("could not load delta of %s: %s", filename, err)
+	}
+	stateFile := ls.StateFile
+	if DryRun {
+		fmt.Fprintf(DryRunOutput, "dry run: would record %d rows of %s in %s\n", position.Rows, filename, stateFile)
+	} else {
+		env.AtEnd(func() error {
+			if err := saveDeltaPosition(stateFile, filename, position); err != nil {
+				return fmt.Errorf("could not save state %s: %s", stateFile, err)
+			}
+			return nil
+		})
+	}
+
+	env.SetOutsideBlocks("filename", &object.String{Value: filename})
+	env.SetOutsideBlocks("csv", csvObj)
+	return csvObj
+}
+
+// EndRun ends a run once its result is known, eg. the result of a script or of a line typed in interactive mode:
+// what the run leaves for the next one, such as the offsets of its delta loads, is recorded if it succeeded,
+// ie. it didn't fail or exit with a code other than 0. It returns the result, or an error if recording fails.
+func EndRun(env *object.Environment, result object.Object) object.Object {
+	succeeded := !isError(result)
+	if exit, ok := result.(*object.Exit); ok {
+		succeeded = exit.Code == 0
+	}
+	if err := env.End(succeeded); err != nil {
+		return newError("%s", err)
+	}
+	return result
+}
+
+// readDelta reads the rows after the offset in the state file, and returns them with the new position
+func readDelta(filename, stateFile string, trim bool, locale string) (*object.CSV, deltaPosition, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, deltaPosition{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, deltaPosition{}, err
+	}
+
+	headerReader := csv.NewReader(file)
+	if _, err := headerReader.Read(); err != nil {
+		return nil, deltaPosition{}, fmt.Errorf("could not read CSV headers: %w", err)
+	}
+	header := make([]byte, headerReader.InputOffset())
+	if _, err := file.ReadAt(header, 0); err != nil {
+		return nil, deltaPosition{}, err
+	}
+
+	state, err := readDeltaState(stateFile)
+	if err != nil {
+		return nil, deltaPosition{}, err
+	}
+	position := deltaPosition{Offset: int64(len(header)), Header: strings.TrimRight(string(header), "\r\n")}
+	if last, ok := state.Files[deltaKey(filename)]; ok && last.Header == position.Header && last.Offset <= info.Size() {
+		position = last
+	}
+
+	if _, err := file.Seek(position.Offset, io.SeekStart); err != nil {
+		return nil, deltaPosition{}, err
+	}
+	appended, err := io.ReadAll(file)
+	if err != nil {
+		return nil, deltaPosition{}, err
+	}
+	appended = appended[:bytes.LastIndexByte(appended, '\n')+1]
+
+	// the header is read again so the rows are read like a loaded file
+	csvObj, err := readCSV(csv.NewReader(io.MultiReader(bytes.NewReader(header), bytes.NewReader(appended))), trim, locale)
+	if err != nil {
+		return nil, deltaPosition{}, err
+	}
+	position.Offset += int64(len(appended))
+	position.Rows += len(csvObj.Rows)
+	return csvObj, position, nil
+}
+
+func readDeltaState(stateFile string) (*deltaState, error) {
+	state := &deltaState{Files: map[string]deltaPosition{}}
+	content, err := os.ReadFile(stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, fmt.Errorf("%s is not a state file: %s", stateFile, err)
+	}
+	if state.Files == nil {
+		state.Files = map[string]deltaPosition{}
+	}
+	return state, nil
+}
+
+// saveDeltaPosition updates the position of a file in the state file, which is replaced at once
+// so an interrupted run doesn't leave it half written
+func saveDeltaPosition(stateFile, filename string, position deltaPosition) error {
+	state, err := readDeltaState(stateFile)
+	if err != nil {
+		return err
+	}
+	state.Files[deltaKey(filename)] = position
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp := stateFile + ".tmp"
+	if err := os.WriteFile(temp, append(content, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, stateFile)
+}
+
+// deltaKey is the absolute path of a file, so a state file works from any directory
+func deltaKey(filename string) string {
+	if path, err := filepath.Abs(filename); err == nil {
+		return path
+	}
+	return filename
+}
@@ -0,0 +1,46 @@
+package evaluator
+
+import (
+	"time"
+
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// Now is the time of now() and today(), `csvlang -deterministic` fixes it, see Deterministic
+var Now = time.Now
+
+// deterministic is set when uuid() must draw from the seeded random source instead of the system's
+var deterministic bool
+
+// Deterministic makes reruns of scripts print and write the same bytes, eg. for reproducible reports and golden tests.
+// The random builtins start from seed, uuid() included, and now() and today() return now.
+// Rows and keys already come out in a stable order: transforms keep the order of the rows and headers,
+// sorts are stable and keys that aren't headers are sorted.
+func Deterministic(seed int64, now time.Time) {
+	random.Lock()
+	random.Seed(seed)
+	random.Unlock()
+	Now = func() time.Time { return now }
+	deterministic = true
+}
+
+func init() {
+	// now() returns the current time in RFC 3339, eg. "2024-05-01T09:30:00Z"
+	builtins["now"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments: got=%d, want=0", len(args))
+			}
+			return &object.String{Value: Now().Format(time.RFC3339)}
+		},
+	}
+	// today() returns the current date, eg. "2024-05-01"
+	builtins["today"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments: got=%d, want=0", len(args))
+			}
+			return &object.String{Value: Now().Format(time.DateOnly)}
+		},
+	}
+}
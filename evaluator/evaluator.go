@@ -5,14 +5,26 @@
 package evaluator
 
 import (
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/lexer"
 	"github.com/Rishabh570/csvlang/object"
+	"github.com/Rishabh570/csvlang/parser"
+	"github.com/Rishabh570/csvlang/token"
 )
 
 var (
@@ -21,11 +33,41 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
+// LenientNumbers makes where clauses skip cells that can't be parsed as numbers instead of failing, eg. `age > 18` on a cell "n/a".
+// By default a read fails with an error identifying the row and value, so typos in the data don't silently vanish from the results.
+var LenientNumbers = false
+
+// DryRun makes saves report the files they would write, and how many rows, to DryRunOutput instead of writing them.
+// The script is otherwise run as usual, so a pipeline can be checked against production paths safely.
+var DryRun = false
+
+// DryRunOutput is where the saves of a dry run are reported
+var DryRunOutput io.Writer = os.Stdout
+
+// FileAccess lets scripts load, import and save files. It is turned off to run untrusted scripts, eg. by `csvlang serve`,
+// which then only see the CSV they are given.
+var FileAccess = true
+
 // Eval function is the entry point to the evaluator package.
 // It takes an AST node and an environment object as input and returns the evaluated object.
 // The environment object is used to store and retrieve variables and their values.
 // The Eval function is a recursive function that evaluates the AST nodes and returns the evaluated object.
+// Errors get the position of the innermost node that produced them.
+// Statements and errors are reported to Trace when it is set.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	if Trace != nil {
+		traceStatement(node)
+	}
+	result := evalNode(node, env)
+	if err, ok := result.(*object.Error); ok && err.Pos.Line == 0 && node != nil {
+		err.Pos = node.Pos()
+		traceError(err)
+	}
+	return result
+}
+
+// evalNode evaluates a node of any type, see Eval
+func evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	// ================ Statements ================
 	case *ast.Program:
@@ -34,12 +76,16 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return Eval(node.Expression, env)
 	case *ast.LoadStatement:
 		return evalLoadStatement(node, env)
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
 	case *ast.ReadStatement:
 		return evalReadStatement(node.ReadExpression, env)
 	case *ast.ReadExpression:
 		return evalReadStatement(node, env)
 	case *ast.SaveStatement:
 		return evalSaveStatement(node, env)
+	case *ast.UnloadStatement:
+		return evalUnloadStatement(node, env)
 	case *ast.BlockStatement:
 		return evalBlockStatement(node, env)
 	case *ast.ReturnStatement:
@@ -49,32 +95,41 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
-			return val
+		// constants can be shadowed in nested scopes but not redeclared in their own
+		if env.IsLocalConst(node.Name.Value) {
+			return newError("cannot redeclare constant %s", node.Name.Value)
 		}
-		env.Set(node.Name.Value, val)
-	case *ast.AssignmentStatement:
 		val := Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
-		// Check if variable exists
-		if _, ok := env.Get(node.Name.Value); !ok {
-			return newError("identifier not found: " + node.Name.Value)
+		if node.Token.Type == token.CONST {
+			env.SetConst(node.Name.Value, val)
+		} else {
+			env.Set(node.Name.Value, val)
 		}
-		env.Set(node.Name.Value, val)
-		return val
+	case *ast.FunctionStatement:
+		if env.IsLocalConst(node.Name.Value) {
+			return newError("cannot redeclare constant %s", node.Name.Value)
+		}
+		function := &object.Function{Parameters: node.Function.Parameters, Defaults: node.Function.Defaults, Rest: node.Function.Rest, Env: env, Body: node.Function.Body}
+		env.Set(node.Name.Value, function)
+	case *ast.AssignmentStatement:
+		return evalAssignmentStatement(node, env)
 	case *ast.ForLoopStatement:
 		return evalForLoopStatement(node.ForLoopExpression, env)
 
 	// ================ Expressions ================
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
+	case *ast.NullLiteral:
+		return NULL
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
 		if isError(right) {
@@ -82,6 +137,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
+		// and/or short-circuit, so the right operand must not be evaluated upfront
+		if isLogicalOperator(node.Operator) {
+			return evalLogicalExpression(node, env)
+		}
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
@@ -94,6 +153,16 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalInfixExpression(node.Operator, left, right)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+	case *ast.GenerateExpression:
+		return evalGenerateExpression(node, env)
+	case *ast.UpdateExpression:
+		return evalUpdateExpression(node, env)
+	case *ast.ValidateExpression:
+		return evalValidateExpression(node, env)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.ArrayLiteral:
@@ -112,11 +181,24 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, Defaults: node.Defaults, Rest: node.Rest, Env: env, Body: body}
+	case *ast.PipeExpression:
+		return evalPipeExpression(node, env)
+	case *ast.MethodCallExpression:
+		receiver := Eval(node.Receiver, env)
+		if isError(receiver) {
+			return receiver
+		}
+		return evalMethodCall(receiver, node.Method, node.Arguments, env)
 	case *ast.CallExpression:
+		if receiver, method, ok := methodReceiver(node.Function, env); ok {
+			return evalMethodCall(receiver, method, node.Arguments, env)
+		}
 		function := Eval(node.Function, env)
 		if isError(function) {
 			return function
@@ -136,11 +218,61 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	return nil
 }
 
+// evalAssignmentStatement evaluates an assignment to an existing variable.
+// Compound assignments apply their operator to the current value first.
+// Example: `x = 5`, `total += amount`.
+func evalAssignmentStatement(node *ast.AssignmentStatement, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	// Dot syntax assigns a row field, eg. row.age = 30
+	if base, field, ok := strings.Cut(node.Name.Value, "."); ok {
+		if row, ok := env.Get(base); ok {
+			return evalFieldAssignment(row, field, node.Operator, val)
+		}
+	}
+
+	// Check if variable exists
+	current, ok := env.Get(node.Name.Value)
+	if !ok {
+		return newError("identifier not found: " + node.Name.Value)
+	}
+
+	if env.IsConst(node.Name.Value) {
+		return newError("cannot assign to constant %s", node.Name.Value)
+	}
+
+	if operator, ok := compoundOperator(node.Operator); ok {
+		val = evalInfixExpression(operator, current, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	env.Assign(node.Name.Value, val)
+	return val
+}
+
+// compoundOperator returns the infix operator behind a compound assignment operator, eg. "+" for "+=".
+// The second return value is false for a plain "=" assignment.
+func compoundOperator(assignment string) (string, bool) {
+	switch assignment {
+	case "+=", "-=", "*=", "/=":
+		return assignment[:1], true
+	default:
+		return "", false
+	}
+}
+
 // evalIndexAssignmentExpression evaluates an index assignment expression.
-// Example: `array[index] = value`.
+// Example: `array[index] = value`, or `csv[3]["age"] = 42` to change a cell in place.
 func evalIndexAssignmentExpression(node *ast.IndexAssignmentExpression, env *object.Environment) object.Object {
 	// Evaluate the array
-	array := Eval(node.Left.Left, env)
+	// For nested targets like `matrix[1][2] = 5` this evaluates `matrix[1]`, which yields the inner array itself,
+	// so the assignment below updates it in place. Likewise `csv[3]` yields a row backed by the CSV.
+	array := evalIndexTarget(node.Left.Left, env)
 	if isError(array) {
 		return array
 	}
@@ -157,6 +289,11 @@ func evalIndexAssignmentExpression(node *ast.IndexAssignmentExpression, env *obj
 		return value
 	}
 
+	// Rows are indexed by column name
+	if row, ok := array.(*object.Row); ok {
+		return evalRowIndexAssignment(node, row, index, value)
+	}
+
 	// Check if we're working with an array
 	arr, ok := array.(*object.Array)
 	if !ok {
@@ -174,15 +311,176 @@ func evalIndexAssignmentExpression(node *ast.IndexAssignmentExpression, env *obj
 		return newError("array index out of bounds: %d", idx.Value)
 	}
 
+	if operator, ok := compoundOperator(node.Token.Literal); ok {
+		value = evalInfixExpression(operator, arr.Elements[idx.Value], value)
+		if isError(value) {
+			return value
+		}
+	}
+
 	// Update array element
 	arr.Elements[idx.Value] = value
 	return value
 }
 
+// evalIndexTarget evaluates the object an index assignment changes, eg. csv[3] in csv[3]["age"] = 42.
+// Reading a row out of range of a CSV is null, but there is no row to change, so it's an error here.
+func evalIndexTarget(target ast.Expression, env *object.Environment) object.Object {
+	ie, ok := target.(*ast.IndexExpression)
+	if !ok {
+		return Eval(target, env)
+	}
+	left := Eval(ie.Left, env)
+	if isError(left) {
+		return left
+	}
+	index := Eval(ie.Index, env)
+	if isError(index) {
+		return index
+	}
+	if csv, ok := left.(*object.CSV); ok {
+		if idx, ok := index.(*object.Integer); ok && (idx.Value < 0 || idx.Value >= int64(len(csv.Rows))) {
+			return newError("row index out of bounds: %d", idx.Value)
+		}
+	}
+	return evalIndexExpression(left, index)
+}
+
+// evalRowIndexAssignment assigns a value to a cell of a row.
+// Example: `row["age"] = 30`.
+func evalRowIndexAssignment(node *ast.IndexAssignmentExpression, row *object.Row, index, value object.Object) object.Object {
+	column, ok := index.(*object.String)
+	if !ok {
+		return newError("row index must be STRING, got %s", index.Type())
+	}
+	return assignRowCell(row, column.Value, node.Token.Literal, value)
+}
+
+// evalFieldAccess reads a field with dot syntax.
+// Example: `row.age` is the same as `row["age"]`, `cleaning.trim_all` reads a definition of an imported module.
+func evalFieldAccess(obj object.Object, field string) object.Object {
+	if module, ok := obj.(*object.Module); ok {
+		if val, ok := module.Env.Get(field); ok {
+			return val
+		}
+		return newError("%s is not defined in module %s", field, module.Name)
+	}
+
+	row, ok := obj.(*object.Row)
+	if !ok {
+		return newError("field access not supported for type: %s", obj.Type())
+	}
+	return evalRowIndexExpression(row, field)
+}
+
+// evalFieldAssignment assigns a field with dot syntax.
+// Example: `row.age = 30` is the same as `row["age"] = 30`.
+func evalFieldAssignment(obj object.Object, field, operator string, value object.Object) object.Object {
+	row, ok := obj.(*object.Row)
+	if !ok {
+		return newError("field assignment not supported for type: %s", obj.Type())
+	}
+	return assignRowCell(row, field, operator, value)
+}
+
+// assignRowCell stores a value in the given column of a row, applying compound operators like +=.
+// The row is backed by its CSV, so the CSV is updated as well. Assigning null clears the cell.
+func assignRowCell(row *object.Row, column, assignment string, value object.Object) object.Object {
+	if !containsString(row.Headers, column) {
+		return newError("column not found: %s", column)
+	}
+	if row.CSV != nil && row.CSV.ReadOnly {
+		return newError("cannot change %s: the file was loaded readonly", column)
+	}
+
+	if operator, ok := compoundOperator(assignment); ok {
+		value = evalInfixExpression(operator, evalRowIndexExpression(row, column), value)
+		if isError(value) {
+			return value
+		}
+	}
+
+	if errObj := checkColumnType(row.CSV, column, value); errObj != nil {
+		return errObj
+	}
+
+	// the row may be shared with CSVs derived from its CSV, which keep their values
+	if value == NULL {
+		delete(row.Writable(), column)
+		return value
+	}
+
+	row.Writable()[column] = value.Inspect()
+	return value
+}
+
+// checkColumnType returns an error when a value doesn't fit the type of a column of a CSV, eg. "abc" in an INTEGER column.
+// Numbers and dates written as strings fit, and null always does as it clears the cell.
+func checkColumnType(csv *object.CSV, column string, value object.Object) object.Object {
+	if csv == nil || value == NULL {
+		return nil
+	}
+	for _, columnType := range csv.ColumnTypes {
+		if columnType.Name != column {
+			continue
+		}
+		text := value.Inspect()
+		switch {
+		case columnType.DataType == object.INTEGER_OBJ && value.Type() != object.INTEGER_OBJ:
+			if _, err := strconv.Atoi(text); value.Type() != object.STRING_OBJ || err != nil {
+				return newError("cannot change %s to %s: the column is INTEGER", column, quoteValue(value))
+			}
+		case columnType.DataType == object.FLOAT_OBJ && value.Type() != object.INTEGER_OBJ && value.Type() != object.FLOAT_OBJ:
+			if _, err := strconv.ParseFloat(text, 64); value.Type() != object.STRING_OBJ || err != nil {
+				return newError("cannot change %s to %s: the column is FLOAT", column, quoteValue(value))
+			}
+		case columnType.DataType == object.BOOLEAN_OBJ && value.Type() != object.BOOLEAN_OBJ:
+			if _, err := strconv.ParseBool(text); value.Type() != object.STRING_OBJ || err != nil {
+				return newError("cannot change %s to %s: the column is BOOLEAN", column, quoteValue(value))
+			}
+		case columnType.DataType == object.STRING_OBJ && columnType.Format != "":
+			if _, err := time.Parse(columnType.Format, text); err != nil {
+				return newError("cannot change %s to %s: the column holds dates like %s", column, quoteValue(value), columnType.Format)
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// quoteValue writes a value in an error message, strings are quoted so empty and blank strings show
+func quoteValue(value object.Object) string {
+	if value.Type() == object.STRING_OBJ {
+		return strconv.Quote(value.Inspect())
+	}
+	return value.Inspect()
+}
+
+// containsString checks if the slice contains the given string.
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
 // evalSaveStatement evaluates a save statement.
 // It saves the CSV data to a file in the specified format (CSV or JSON).
 // Example: `save csv as "output.csv"` or `save json as "output.json"`.
 func evalSaveStatement(node *ast.SaveStatement, env *object.Environment) object.Object {
+	if err := checkFileAccess("save", node.Filename); err != nil {
+		return err
+	}
+	if len(node.Bundle) > 0 {
+		return saveBundle(node, env)
+	}
+
+	if node.From != "" {
+		return saveFrom(node, env)
+	}
+
 	var dataToSave *object.CSV
 
 	if node.Source != nil {
@@ -204,17 +502,172 @@ func evalSaveStatement(node *ast.SaveStatement, env *object.Environment) object.
 		if !ok {
 			return newError("no CSV data to save")
 		}
+		if mapped, ok := value.(*object.MappedCSV); ok {
+			return newError("cannot save mapped file %s, save from it instead, eg. save from %q as out.csv", mapped.Filename, mapped.Filename)
+		}
 		dataToSave = value.(*object.CSV)
 	}
 
-	// Save based on format
-	switch node.Format {
-	case "csv":
-		return saveAsCSV(dataToSave, node.Filename)
-	case "json":
-		return saveAsJSON(dataToSave, node.Filename)
+	filenames := append([]string{}, node.Filenames...)
+	if len(filenames) == 0 {
+		filenames = []string{node.Filename}
+	}
+	for i, filename := range filenames {
+		filenames[i] = expandBatchFilename(filename, env)
+	}
+
+	if node.SplitBy != "" {
+		return saveSplit(dataToSave, node.SplitBy, filenames, node.Pos())
+	}
+
+	for _, filename := range filenames {
+		if result := saveAs(dataToSave, filename); isError(result) {
+			return result
+		}
+		traceSave(node.Pos(), filename, len(dataToSave.Rows))
+	}
+	return NULL
+}
+
+// saveBundle writes every entry of a report bundle to its own file in a directory.
+// Example: `save {summary: stats, detail: rows} as report/` writes report/summary.csv and report/detail.csv
+// The files are written to a temporary directory first which then replaces the directory, so readers never see half a report.
+func saveBundle(node *ast.SaveStatement, env *object.Environment) object.Object {
+	parts := make([]*object.CSV, len(node.Bundle))
+	for i, entry := range node.Bundle {
+		value := Eval(entry.Value, env)
+		if isError(value) {
+			return value
+		}
+		csv, ok := value.(*object.CSV)
+		if !ok {
+			return newError("cannot save %s in bundle: expected CSV, got %s", entry.Name, value.Type())
+		}
+		parts[i] = csv
+	}
+
+	dir := filepath.Clean(node.Filename)
+	if DryRun {
+		for i, entry := range node.Bundle {
+			if result := saveAs(parts[i], filepath.Join(dir, entry.Name)); isError(result) {
+				return result
+			}
+			traceSave(node.Pos(), filepath.Join(dir, entry.Name), len(parts[i].Rows))
+		}
+		return NULL
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), "."+filepath.Base(dir)+".tmp-")
+	if err != nil {
+		return newError("could not create directory: %s", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	for i, entry := range node.Bundle {
+		if result := saveAs(parts[i], filepath.Join(tmp, entry.Name)); isError(result) {
+			return result
+		}
+	}
+
+	// move the previous report out of the way, it is only removed once the new one is in place
+	old := ""
+	if _, err := os.Stat(dir); err == nil {
+		old = tmp + ".old"
+		if err := os.Rename(dir, old); err != nil {
+			return newError("could not replace directory: %s", err)
+		}
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		if old != "" {
+			os.Rename(old, dir)
+		}
+		return newError("could not replace directory: %s", err)
+	}
+	if old != "" {
+		os.RemoveAll(old)
+	}
+	for i, entry := range node.Bundle {
+		traceSave(node.Pos(), filepath.Join(dir, entry.Name), len(parts[i].Rows))
+	}
+	return NULL
+}
+
+// saveSplit writes one file per distinct value of a column, the `{column}` placeholder of each filename is replaced with the value.
+// Example: `save rows split by region as "out_{region}.csv"` writes out_EU.csv, out_US.csv, ...
+func saveSplit(csvData *object.CSV, column string, filenames []string, pos token.Position) object.Object {
+	if !containsString(csvData.Headers, column) {
+		return newError("column not found: %s", column)
+	}
+	placeholder := "{" + column + "}"
+	for _, filename := range filenames {
+		if !strings.Contains(filename, placeholder) {
+			return newError("filename %s must contain %s to split by %s", filename, placeholder, column)
+		}
+	}
+
+	// group the rows by value, keeping the order in which values first appear
+	var values []string
+	groups := map[string][]map[string]string{}
+	for _, row := range csvData.Rows {
+		value := row[column]
+		if _, ok := groups[value]; !ok {
+			values = append(values, value)
+		}
+		groups[value] = append(groups[value], row)
+	}
+
+	for _, value := range values {
+		part := &object.CSV{Headers: csvData.Headers, ColumnTypes: csvData.ColumnTypes, Rows: groups[value]}
+		for _, filename := range filenames {
+			filename = strings.ReplaceAll(filename, placeholder, filenamePart(value))
+			if result := saveAs(part, filename); isError(result) {
+				return result
+			}
+			traceSave(pos, filename, len(part.Rows))
+		}
+	}
+	return NULL
+}
+
+// filenamePart makes a cell value safe to use in a filename, path separators are replaced and empty values become "empty".
+func filenamePart(value string) string {
+	if value == "" {
+		return "empty"
+	}
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(value)
+}
+
+// saveAs saves the CSV data to a file, the format is picked from the extension of the filename (.csv or .json).
+// In a dry run nothing is written, the file is reported to DryRunOutput.
+func saveAs(csvData *object.CSV, filename string) object.Object {
+	if err := checkFileAccess("save", filename); err != nil {
+		return err
+	}
+	if DryRun && filename == clipboardName {
+		fmt.Fprintf(DryRunOutput, "dry run: would copy %d rows to the clipboard\n", len(csvData.Rows))
+		return NULL
+	}
+	if DryRun && (strings.HasSuffix(filename, ".csv") || strings.HasSuffix(filename, ".json")) {
+		fmt.Fprintf(DryRunOutput, "dry run: would write %d rows to %s\n", len(csvData.Rows), filename)
+		return NULL
+	}
+
+	if DryRun && strings.HasPrefix(filename, googleSheetPrefix) {
+		fmt.Fprintf(DryRunOutput, "dry run: would write %d rows to %s\n", len(csvData.Rows), filename)
+		return NULL
+	}
+
+	switch {
+	case filename == clipboardName:
+		return saveToClipboard(csvData)
+	case strings.HasPrefix(filename, googleSheetPrefix):
+		return saveToGoogleSheet(csvData, filename)
+	case strings.HasSuffix(filename, ".csv"):
+		return saveAsCSV(csvData, filename)
+	case strings.HasSuffix(filename, ".json"):
+		return saveAsJSON(csvData, filename)
 	default:
-		return newError("unsupported format: %s", node.Format)
+		return newError("unsupported file format: %s", filename)
 	}
 }
 
@@ -226,121 +679,233 @@ func saveAsCSV(csvData *object.CSV, filename string) object.Object {
 	}
 	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	if err := WriteCSV(file, csvData); err != nil {
+		return newError("%s", err)
+	}
+	return NULL
+}
+
+// WriteCSV writes the headers and rows of a CSV in CSV format.
+func WriteCSV(w io.Writer, csvData *object.CSV) error {
+	return writeCSV(csv.NewWriter(w), csvData)
+}
 
+// writeCSV writes a CSV with a configured writer, eg. one separating cells with tabs
+func writeCSV(writer *csv.Writer, csvData *object.CSV) error {
 	// Write headers
 	if err := writer.Write(csvData.Headers); err != nil {
-		return newError("error writing headers: %s", err)
+		return fmt.Errorf("error writing headers: %s", err)
 	}
 
 	// Write rows
 	for _, row := range csvData.Rows {
-		record := make([]string, len(csvData.Headers))
-		for i, header := range csvData.Headers {
-			record[i] = row[header]
-		}
-		if err := writer.Write(record); err != nil {
-			return newError("error writing row: %s", err)
+		if err := writer.Write(csvData.Values(row)); err != nil {
+			return fmt.Errorf("error writing row: %s", err)
 		}
 	}
 
-	return NULL
+	writer.Flush()
+	return writer.Error()
 }
 
 // saveAsJSON saves the CSV data to a file in JSON format.
 func saveAsJSON(csv *object.CSV, filename string) object.Object {
+	var out bytes.Buffer
+	if err := WriteJSON(&out, csv); err != nil {
+		return newError("%s", err)
+	}
+
+	if err := os.WriteFile(filename, out.Bytes(), 0644); err != nil {
+		return newError("error writing file: %s", err)
+	}
+
+	return NULL
+}
+
+// WriteJSON writes a CSV as a JSON object holding its headers, its rows, each row an object keyed by header,
+// and its schema, the type and metadata of each column.
+func WriteJSON(w io.Writer, csv *object.CSV) error {
+	rows := make([]jsonRow, len(csv.Rows))
+	for i, row := range csv.Rows {
+		rows[i] = jsonRow{headers: csv.Headers, values: row}
+	}
+	schema := make([]jsonColumn, len(csv.Headers))
+	for i, header := range csv.Headers {
+		columnType := csv.TypeOf(header)
+		schema[i] = jsonColumn{
+			Name:        header,
+			Type:        string(columnType.DataType),
+			Nullable:    columnType.Nullable,
+			Format:      columnType.Format,
+			Description: columnType.Description,
+		}
+	}
 	data := map[string]interface{}{
 		"headers": csv.Headers,
-		"rows":    csv.Rows,
+		"rows":    rows,
+		"schema":  schema,
 	}
 
 	jsonData, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
-		return newError("error converting to JSON: %s", err)
+		return fmt.Errorf("error converting to JSON: %s", err)
 	}
+	_, err = w.Write(jsonData)
+	return err
+}
 
-	if err := os.WriteFile(filename, jsonData, 0644); err != nil {
-		return newError("error writing file: %s", err)
-	}
+// jsonColumn is a column of the schema of a JSON file
+type jsonColumn struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Nullable    bool   `json:"nullable"`
+	Format      string `json:"format,omitempty"`
+	Description string `json:"description,omitempty"`
+}
 
-	return NULL
+// jsonRow writes the fields of a row in header order, cells of columns that aren't in the headers follow sorted by name.
+type jsonRow struct {
+	headers []string
+	values  map[string]string
+}
+
+func (r jsonRow) MarshalJSON() ([]byte, error) {
+	keys := []string{}
+	for _, header := range r.headers {
+		if _, ok := r.values[header]; ok {
+			keys = append(keys, header)
+		}
+	}
+	extra := []string{}
+	for key := range r.values {
+		if !containsString(r.headers, key) {
+			extra = append(extra, key)
+		}
+	}
+	sort.Strings(extra)
+	keys = append(keys, extra...)
+
+	var out bytes.Buffer
+	out.WriteString("{")
+	for i, key := range keys {
+		if i > 0 {
+			out.WriteString(",")
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(r.values[key])
+		if err != nil {
+			return nil, err
+		}
+		out.Write(name)
+		out.WriteString(":")
+		out.Write(value)
+	}
+	out.WriteString("}")
+	return out.Bytes(), nil
 }
 
 // evalForLoopStatement evaluates a for loop statement.
-// Example: `for i in array { ... }`.
+// Example: `for i, item in array { ... }`.
 // It iterates over the elements of the array and executes the body of the loop for each element.
+//
+// Every iteration runs in its own scope holding fresh index and element bindings:
+//   - closures created in the body capture the bindings of their own iteration
+//   - variables declared with let in the body are local to the iteration and may shadow outer ones
+//   - assigning to an outer variable (eg. total += item) updates it where it was declared
+//   - reassigning the element variable does not change the array, use `array[i] = value` for that
+//
+// A return inside the body stops the loop and returns from the enclosing function.
 func evalForLoopStatement(fl *ast.ForLoopExpression, env *object.Environment) object.Object {
 	iterableObj := Eval(fl.Iterable, env)
 	if isError(iterableObj) {
 		return iterableObj
 	}
 
-	var elements []object.Object
-	var arr *object.Array
-	var ok bool
-
-	// Get array reference and its elements
-	if arr, ok = iterableObj.(*object.Array); !ok {
-		return newError("for loop iterable must be ARRAY, got %s", iterableObj.Type())
+	// CSV rows are iterated in place
+	if csv, ok := iterableObj.(*object.CSV); ok {
+		return evalCSVForLoop(fl, csv, env)
+	}
+	// iterators produce their values as the loop asks for them
+	if it, ok := iterableObj.(*object.Iterator); ok {
+		return evalIteratorForLoop(fl, it, env)
 	}
-	elements = arr.Elements
 
-	// Get the name of the iterable variable (if it's an identifier)
-	var iterableName string
-	if ident, ok := fl.Iterable.(*ast.Identifier); ok {
-		iterableName = ident.Value
+	arr, ok := iterableObj.(*object.Array)
+	if !ok {
+		return newError("for loop iterable must be ARRAY, CSV or ITERATOR, got %s", iterableObj.Type())
 	}
 
-	for i, element := range elements {
+	for i, element := range arr.Elements {
+		if errObj := checkStopped(env); errObj != nil {
+			return errObj
+		}
 		// Create new scope for each iteration
-		loopEnv := object.NewEnclosedEnvironment(env)
+		loopEnv := object.NewBlockEnvironment(env)
 
 		// Bind index and element
 		loopEnv.Set(fl.IndexName.Value, &object.Integer{Value: int64(i)})
-
-		// Store original element value for later comparison
-		originalElement := element
 		loopEnv.Set(fl.ElementName.Value, element)
 
-		// Evaluate body
 		result := Eval(fl.Body, loopEnv)
-		if isError(result) {
+		if isLoopExit(result) {
 			return result
 		}
+	}
 
-		// CASE 1: Handle direct element variable modification
-		if elementVal, ok := loopEnv.Get(fl.ElementName.Value); ok && elementVal != originalElement {
-			arr.Elements[i] = elementVal
-		}
+	return NULL
+}
 
-		// CASE 2: Handle index-based assignment (val[i] = ...)
-		// This updates the array in the parent environment
-		if iterableName != "" {
-			if arrayVal, exists := env.Get(iterableName); exists {
-				if updatedArray, ok := arrayVal.(*object.Array); ok {
-					// Make sure any array modifications are preserved
-					arr = updatedArray
-				}
-			}
-		}
+// isLoopExit checks if the result of a loop body stops the loop, ie. it's an error or a return value.
+func isLoopExit(result object.Object) bool {
+	if result == nil {
+		return false
 	}
+	rt := result.Type()
+	return rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.EXIT_OBJ
+}
+
+// evalCSVForLoop iterates over the rows of a CSV object.
+// Example: `for i, row in csv { row["age"] = row["age"] + 1 }`.
+// Each row is bound as a Row object backed by the CSV row, so assigning to `row["column"]` updates the CSV.
+func evalCSVForLoop(fl *ast.ForLoopExpression, csv *object.CSV, env *object.Environment) object.Object {
+	for i, row := range csv.Rows {
+		if errObj := checkStopped(env); errObj != nil {
+			return errObj
+		}
+		// Create new scope for each iteration
+		loopEnv := object.NewBlockEnvironment(env)
+
+		// Bind index and row
+		loopEnv.Set(fl.IndexName.Value, &object.Integer{Value: int64(i)})
+		loopEnv.Set(fl.ElementName.Value, &object.Row{Headers: csv.Headers, Values: row, CSV: csv, Index: i})
 
-	// Ensure the modified array is saved back to parent environment
-	if iterableName != "" {
-		env.Set(iterableName, arr)
+		result := Eval(fl.Body, loopEnv)
+		if isLoopExit(result) {
+			return result
+		}
 	}
 
 	return NULL
 }
 
-// evalIndexExpression evaluates an index expression by calling evalArrayIndexExpression.
-// Example: `array[index]`.
-// It retrieves the element at the specified index from the array.
+// evalIndexExpression evaluates an index expression by calling evalArrayIndexExpression, evalCSVIndexExpression or evalRowIndexExpression.
+// Example: `array[index]`, `csv[index]` or `row["column"]`.
+// It retrieves the element at the specified index from the array, the row at the index from the CSV, or the cell of the column from the row.
+// Indexing a CSV with a column name, eg. `csv["amount"]`, returns that column as a one column CSV.
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.CSV_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalCSVIndexExpression(left.(*object.CSV), index.(*object.Integer).Value)
+	case left.Type() == object.CSV_OBJ && index.Type() == object.STRING_OBJ:
+		csv := left.(*object.CSV)
+		return extractColumn(csv, csv.Rows, index.(*object.String).Value)
+	case left.Type() == object.CSV_ROW && index.Type() == object.STRING_OBJ:
+		return evalRowIndexExpression(left.(*object.Row), index.(*object.String).Value)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
@@ -360,57 +925,426 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
+// evalCSVIndexExpression retrieves the row at the specified index from the CSV.
+// The row is backed by the CSV, so `csv[0]["age"] = 30` updates the CSV.
+func evalCSVIndexExpression(csv *object.CSV, idx int64) object.Object {
+	if idx < 0 || idx >= int64(len(csv.Rows)) {
+		return NULL
+	}
+	return &object.Row{Headers: csv.Headers, Values: csv.Rows[idx], CSV: csv, Index: int(idx)}
+}
+
+// evalRowIndexExpression retrieves the cell of the given column from a row.
+// Integer cells are returned as INTEGER, other cells as STRING.
+// It returns NULL if the row has no value for the column.
+func evalRowIndexExpression(row *object.Row, column string) object.Object {
+	value, ok := row.Fields()[column]
+	if !ok {
+		return NULL
+	}
+	return cellToObject(value)
+}
+
+// cellToObject converts a raw CSV cell to an INTEGER if it holds an integer, otherwise to a STRING.
+func cellToObject(value string) object.Object {
+	if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return &object.Integer{Value: intValue}
+	}
+	return &object.String{Value: value}
+}
+
+// evalSliceExpression evaluates a slice expression on an array, string or CSV.
+// Negative bounds count from the end and out of range bounds are clamped, so slicing never fails on bounds.
+// Example: `array[2:5]`, `array[:10]`, `array[-3:]`.
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	length, ok := sliceLength(left)
+	if !ok {
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	start, errObj := evalSliceBound(node.Start, 0, length, env)
+	if errObj != nil {
+		return errObj
+	}
+	end, errObj := evalSliceBound(node.End, length, length, env)
+	if errObj != nil {
+		return errObj
+	}
+
+	return sliceObject(left, start, end)
+}
+
+// sliceLength returns the number of elements of a sliceable object (array, string or CSV), the characters of a string.
+// The second return value is false if the object can't be sliced.
+func sliceLength(obj object.Object) (int64, bool) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return int64(len(obj.Elements)), true
+	case *object.String:
+		return int64(utf8.RuneCountInString(obj.Value)), true
+	case *object.CSV:
+		return int64(len(obj.Rows)), true
+	default:
+		return 0, false
+	}
+}
+
+// clampSliceIndex resolves a negative index from the end and clamps it to [0, length].
+func clampSliceIndex(idx, length int64) int64 {
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 {
+		idx = 0
+	}
+	if idx > length {
+		idx = length
+	}
+	return idx
+}
+
+// sliceObject copies the elements between the clamped start and end bounds of a sliceable object.
+func sliceObject(left object.Object, start, end int64) object.Object {
+	if start > end {
+		start = end
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		elements := make([]object.Object, end-start)
+		copy(elements, left.Elements[start:end])
+		return &object.Array{Elements: elements}
+	case *object.String:
+		// strings are sliced by characters, eg. "héllo"[0:2] is "hé"
+		return &object.String{Value: string([]rune(left.Value)[start:end])}
+	default:
+		csv := left.(*object.CSV)
+		rows := make([]map[string]string, end-start)
+		copy(rows, csv.ShareRows()[start:end])
+		return &object.CSV{Headers: csv.Headers, ColumnTypes: csv.ColumnTypes, Rows: rows}
+	}
+}
+
+// evalSliceBound evaluates a slice bound and clamps it to [0, length].
+// A nil bound evaluates to the given fallback.
+func evalSliceBound(bound ast.Expression, fallback, length int64, env *object.Environment) (int64, object.Object) {
+	if bound == nil {
+		return fallback, nil
+	}
+
+	value := Eval(bound, env)
+	if isError(value) {
+		return 0, value
+	}
+	integer, ok := value.(*object.Integer)
+	if !ok {
+		return 0, newError("slice bound must be INTEGER, got %s", value.Type())
+	}
+
+	return clampSliceIndex(integer.Value, length), nil
+}
+
+// evalPipeExpression evaluates a pipe, the left value is passed as the first argument of the right side.
+// Example: `rows |> fill_empty("name", "-") |> unique` is the same as `unique(fill_empty(rows, "name", "-"))`.
+func evalPipeExpression(pe *ast.PipeExpression, env *object.Environment) object.Object {
+	left := Eval(pe.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	call, ok := pe.Right.(*ast.CallExpression)
+	if !ok {
+		function := Eval(pe.Right, env)
+		if isError(function) {
+			return function
+		}
+		return applyFunction(function, []object.Object{left}, env)
+	}
+
+	function := Eval(call.Function, env)
+	if isError(function) {
+		return function
+	}
+	return applyWithReceiver(function, left, call.Arguments, env)
+}
+
+// evalMethodCall evaluates a method call, the receiver is passed as the first argument of the function named by the method.
+// Example: `csv.filter(f).head(10)` is the same as `head(filter(csv, f), 10)`.
+func evalMethodCall(receiver object.Object, method *ast.Identifier, arguments []ast.Expression, env *object.Environment) object.Object {
+	function := evalIdentifier(method, env)
+	if isError(function) {
+		return newError("unknown method %s for %s", method.Value, receiver.Type())
+	}
+	return applyWithReceiver(function, receiver, arguments, env)
+}
+
+// methodReceiver checks if a called identifier is a method call on a variable, eg. csv.filter(f).
+// Calls of module members, eg. stats.top_rows(csv), are not method calls.
+func methodReceiver(function ast.Expression, env *object.Environment) (object.Object, *ast.Identifier, bool) {
+	ident, ok := function.(*ast.Identifier)
+	if !ok {
+		return nil, nil, false
+	}
+	base, method, ok := strings.Cut(ident.Value, ".")
+	if !ok || strings.Contains(method, ".") {
+		return nil, nil, false
+	}
+	if _, defined := env.Get(ident.Value); defined {
+		return nil, nil, false
+	}
+	receiver, ok := env.Get(base)
+	if !ok || receiver.Type() == object.MODULE_OBJ {
+		return nil, nil, false
+	}
+	return receiver, &ast.Identifier{Token: ident.Token, Value: method}, true
+}
+
+// applyWithReceiver calls the function with the receiver followed by the evaluated arguments.
+func applyWithReceiver(function, receiver object.Object, arguments []ast.Expression, env *object.Environment) object.Object {
+	args := evalExpressions(arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	return applyFunction(function, append([]object.Object{receiver}, args...), env)
+}
+
+// evalImportStatement evaluates an import statement.
+// Example: `import "lib/cleaning.cl"` or `import "lib/cleaning.cl" as cleaning`.
+// The imported script is evaluated into the current environment, or into its own module environment when an alias is given.
+func evalImportStatement(is *ast.ImportStatement, env *object.Environment) object.Object {
+	path := is.Path.String()
+	if err := checkFileAccess("import", path); err != nil {
+		return err
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return newError("could not resolve import %s: %s", path, err)
+	}
+	if !env.StartImport(absPath) {
+		return newError("import cycle: %s is already being imported", path)
+	}
+	defer env.EndImport(absPath)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return newError("could not open file: %s", err)
+	}
+
+	p := parser.New(lexer.New(string(content)))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		messages := make([]string, len(p.Errors))
+		for i, parserErr := range p.Errors {
+			messages[i] = parserErr.Message
+		}
+		return newError("could not parse %s: %s", path, strings.Join(messages, "; "))
+	}
+
+	targetEnv := env
+	if is.Alias != nil {
+		targetEnv = object.NewModuleEnvironment(env)
+	}
+
+	result := Eval(program, targetEnv)
+	if errObj, ok := result.(*object.Error); ok {
+		return newError("%s: %s", path, errObj.Message)
+	}
+	if isError(result) {
+		return result
+	}
+
+	if is.Alias != nil {
+		env.Set(is.Alias.Value, &object.Module{Name: is.Alias.Value, Env: targetEnv})
+	}
+	return nil
+}
+
 // evalLoadStatement evaluates a load statement.
 // It loads a CSV file and stores its data in the environment.
-// Example: `load "data.csv"`.
+// Example: `load "data.csv"`, `load clipboard` for rows copied out of a spreadsheet or `load "gsheet://<sheet-id>/Sheet1"`.
+// Cells holding one of the NA values of the load are emptied, so every builtin sees them as missing.
 func evalLoadStatement(ls *ast.LoadStatement, env *object.Environment) object.Object {
+	result := evalLoad(ls, env)
+	if csvObj, ok := result.(*object.CSV); ok && len(ls.NAValues) > 0 {
+		clearNAValues(csvObj, ls.NAValues)
+	}
+	switch result := result.(type) {
+	case *object.CSV:
+		traceLoad(ls.Pos(), expandBatchFilename(ls.Filename.String(), env), len(result.Rows))
+	case *object.MappedCSV:
+		traceLoad(ls.Pos(), result.Filename, -1)
+	}
+	return result
+}
+
+func evalLoad(ls *ast.LoadStatement, env *object.Environment) object.Object {
+	if filename := expandBatchFilename(ls.Filename.String(), env); filename != ls.Filename.String() {
+		expanded := *ls
+		expanded.Filename = &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: filename, Pos: ls.Filename.Pos()}, Value: filename}
+		ls = &expanded
+	}
+	if err := checkFileAccess("load", ls.Filename.String()); err != nil {
+		return err
+	}
+	if ls.Mmap {
+		return loadMapped(ls, env)
+	}
+
+	if ls.Quarantine != "" && (ls.Database != "" || ls.StateFile != "" || isGlob(ls.Filename.String()) || ls.SourceFile ||
+		ls.Filename.String() == clipboardName || strings.HasPrefix(ls.Filename.String(), googleSheetPrefix)) {
+		return newError("quarantine is only supported when loading a single file")
+	}
+
+	if ls.Database != "" {
+		return loadFromDatabase(ls, env)
+	}
+	if ls.StateFile != "" {
+		return loadDelta(ls, env)
+	}
+	if isGlob(ls.Filename.String()) || ls.SourceFile {
+		return loadGlob(ls, env)
+	}
+	if name, ok := ls.Filename.(*ast.Identifier); ok && name.Value == clipboardName {
+		return loadFromClipboard(ls, env)
+	}
+	if strings.HasPrefix(ls.Filename.String(), googleSheetPrefix) {
+		csvObj, err := loadGoogleSheet(ls.Filename.String(), ls.Trim, ls.NumberLocale)
+		if err != nil {
+			return newError("could not load %s: %s", ls.Filename.String(), err)
+		}
+		env.SetOutsideBlocks("filename", &object.String{Value: ls.Filename.String()})
+		env.SetOutsideBlocks("csv", csvObj)
+		return csvObj
+	}
+
+	if ls.Quarantine != "" {
+		return loadQuarantined(ls, env)
+	}
+
 	// Store the filename in the environment
-	env.Set("filename", &object.String{ls.Filename.String()})
+	env.SetOutsideBlocks("filename", &object.String{Value: ls.Filename.String()})
 
-	// Open and read the CSV file
-	file, err := os.Open(ls.Filename.String())
-	if err != nil {
-		return newError("could not open file: %s", err)
+	// Open and read the CSV file, unless it is cached
+	csvObj, errObj := readCSVFile(ls.Filename.String(), ls.Trim, ls.NumberLocale)
+	if errObj != nil {
+		return errObj
 	}
-	defer file.Close()
+	csvObj.ReadOnly = ls.ReadOnly
+
+	// Store the CSV object in the environment
+	env.SetOutsideBlocks("csv", csvObj)
+	return csvObj
+}
 
-	// Parse CSV
-	reader := csv.NewReader(file)
+// ReadCSV reads a CSV whose first line is the header, and infers the types of its columns.
+// trim strips the whitespace around headers and cells, numbers written in a locale (eg. "de") are normalized.
+func ReadCSV(r io.Reader, trim bool, locale string) (*object.CSV, error) {
+	return readCSV(csv.NewReader(r), trim, locale)
+}
 
+// readCSV reads a CSV with a configured reader, eg. one splitting cells on tabs
+func readCSV(reader *csv.Reader, trim bool, locale string) (*object.CSV, error) {
 	// Read headers
 	headers, err := reader.Read()
 	if err != nil {
-		return newError("could not read CSV headers: %s", err)
+		return nil, fmt.Errorf("could not read CSV headers: %w", err)
 	}
 
 	// Read all records
 	records, err := reader.ReadAll()
 	if err != nil {
-		return newError("could not read CSV records: %s", err)
+		return nil, fmt.Errorf("could not read CSV records: %w", err)
+	}
+
+	if trim {
+		for i := range headers {
+			headers[i] = strings.TrimSpace(headers[i])
+		}
+	}
+	if err := checkLocale(locale); err != nil {
+		return nil, err
 	}
 
 	// Convert records to rows of maps
 	rows := make([]map[string]string, len(records))
 	for i, record := range records {
-		row := make(map[string]string)
-		for j, header := range headers {
-			row[header] = record[j]
+		rows[i] = recordToRow(headers, record, trim, locale)
+	}
+
+	// Store loaded CSV data in evaluator's in-app memory
+	csvObj := &object.CSV{
+		Headers: headers,
+		Rows:    rows,
+	}
+
+	// When the CSV is loaded successfully for the first time, infer column types and store the information for future use
+	csvObj.InferColumnTypes()
+	// and parse the cells once, so where clauses and aggregations compare numbers instead of parsing text per row
+	csvObj.ParseCells()
+	return csvObj, nil
+}
+
+// clearNAValues empties the cells holding a value that means missing, eg. "N/A" for load data.csv na values ["N/A"],
+// and infers the column types again as they may only have been strings because of them
+func clearNAValues(csvObj *object.CSV, na []string) {
+	for _, row := range csvObj.Rows {
+		for header, value := range row {
+			if value != "" && containsString(na, value) {
+				row[header] = ""
+			}
+		}
+	}
+	csvObj.InferColumnTypes()
+}
+
+// recordToRow maps the cells of a record to the headers, the record has a cell per header
+func recordToRow(headers, record []string, trim bool, locale string) map[string]string {
+	row := make(map[string]string, len(headers))
+	for j, header := range headers {
+		value := record[j]
+		if trim {
+			value = strings.TrimSpace(value)
+		}
+		// numbers written in the locale are stored the way csvlang reads numbers, so type inference and where clauses see them
+		if normalized, ok := normalizeNumber(value, locale); ok {
+			value = normalized
 		}
-		rows[i] = row
+		row[header] = value
 	}
+	return row
+}
 
-	// Store loaded CSV data in evaluator's in-app memory
-	csvObj := &object.CSV{
-		Headers: headers,
-		Rows:    rows,
-	}
+// batchPlaceholders are replaced in load and save filenames by the string variables of the same name,
+// `csvlang batch` sets them to the file being processed and the file to write, eg. load "{input}"
+var batchPlaceholders = []string{"input", "output"}
 
-	// When the CSV is loaded successfully for the first time, infer column types and store the information for future use
-	csvObj.InferColumnTypes()
+// expandBatchFilename replaces the batchPlaceholders in a filename, a placeholder whose variable isn't set is kept
+func expandBatchFilename(filename string, env *object.Environment) string {
+	if !strings.Contains(filename, "{") {
+		return filename
+	}
+	for _, name := range batchPlaceholders {
+		if value, ok := env.Get(name); ok {
+			if value, ok := value.(*object.String); ok {
+				filename = strings.ReplaceAll(filename, "{"+name+"}", value.Value)
+			}
+		}
+	}
+	return filename
+}
 
-	// Store the CSV object in the environment
-	env.Set("csv", csvObj)
-	return csvObj
+// checkFileAccess returns an error when scripts may not touch files, see FileAccess
+func checkFileAccess(action, name string) *object.Error {
+	if FileAccess {
+		return nil
+	}
+	return newError("file access is disabled, cannot %s %s", action, name)
 }
 
 // selectRows selects rows based on the rowIndex.
@@ -434,13 +1368,32 @@ func selectRows(rows []map[string]string, rowIndex int) []map[string]string {
 // It returns true if the condition is satisfied, otherwise false.
 // The column value is expected to be a string that can be converted to an integer.
 // The compare value is an integer.
-func evaluateNumericCondition(columnValue string, operator string, compareValue int64) bool {
+// A column value with a fractional part, eg. in a FLOAT column, is compared as a float.
+// The second return value is false if the column value is not a number.
+func evaluateNumericCondition(columnValue string, operator string, compareValue int64) (bool, bool) {
 	// Convert column value to number
 	rowVal, err := strconv.ParseInt(columnValue, 10, 64)
 	if err != nil {
-		return false
+		return evaluateFloatCondition(columnValue, operator, float64(compareValue))
+	}
+
+	return compareNumbers(rowVal, operator, compareValue), true
+}
+
+// evaluateFloatCondition evaluates a numeric condition with a float compare value.
+// Example: `price > 9.99`.
+// The second return value is false if the column value is not a number.
+func evaluateFloatCondition(columnValue string, operator string, compareValue float64) (bool, bool) {
+	rowVal, err := strconv.ParseFloat(columnValue, 64)
+	if err != nil {
+		return false, false
 	}
 
+	return compareNumbers(rowVal, operator, compareValue), true
+}
+
+// compareNumbers compares two numbers using the specified operator.
+func compareNumbers[T int64 | float64](rowVal T, operator string, compareValue T) bool {
 	switch operator {
 	case ">":
 		return rowVal > compareValue
@@ -460,13 +1413,17 @@ func evaluateNumericCondition(columnValue string, operator string, compareValue
 }
 
 // evaluateStringCondition evaluates a string condition based on the operator and value.
-// Example: `column == "value"`, `column != "value"`, etc.
+// Example: `column == "value"`, `column != "value"`, `column ==* "VALUE"` (case-insensitive), etc.
 func evaluateStringCondition(columnValue string, operator string, compareValue string) bool {
 	switch operator {
 	case "==":
 		return columnValue == compareValue
 	case "!=":
 		return columnValue != compareValue
+	case "==*":
+		return strings.EqualFold(columnValue, compareValue)
+	case "!=*":
+		return !strings.EqualFold(columnValue, compareValue)
 	case ">":
 		return columnValue > compareValue
 	case "<":
@@ -487,7 +1444,11 @@ func evaluateBooleanCondition(columnValue string, operator string, compareValue
 	if err != nil {
 		return false
 	}
+	return evaluateBoolean(rowVal, operator, compareValue)
+}
 
+// evaluateBoolean compares two booleans, only == and != are supported
+func evaluateBoolean(rowVal bool, operator string, compareValue bool) bool {
 	switch operator {
 	case "==":
 		return rowVal == compareValue
@@ -498,62 +1459,223 @@ func evaluateBooleanCondition(columnValue string, operator string, compareValue
 	}
 }
 
-// evaluateCondition evaluates a condition based on the column value, operator, and compare value.
-// It checks if the column value satisfies the condition specified in the where clause.
+// evaluateCondition evaluates a where clause against a row, index is the position of the row in the CSV.
+// Column comparisons can be combined using and/or (&&, ||) and negated using not (!).
+// Example: `age > 5 and not (name == "Bob")`.
+// It returns true if the condition is satisfied, otherwise false, or an error if a comparison fails.
+func evaluateCondition(scan *rowScan, index int, row map[string]string, where ast.Expression, env *object.Environment) (bool, object.Object) {
+	switch where := where.(type) {
+	case *ast.ReadFilterExpression:
+		return evaluateComparison(scan, index, row, where, env)
+	case *ast.InfixExpression:
+		left, errObj := evaluateCondition(scan, index, row, where.Left, env)
+		if errObj != nil {
+			return false, errObj
+		}
+		switch where.Operator {
+		case "and", "&&":
+			if !left {
+				return false, nil
+			}
+			return evaluateCondition(scan, index, row, where.Right, env)
+		case "or", "||":
+			if left {
+				return true, nil
+			}
+			return evaluateCondition(scan, index, row, where.Right, env)
+		}
+	case *ast.PrefixExpression:
+		if where.Operator == "not" || where.Operator == "!" {
+			matched, errObj := evaluateCondition(scan, index, row, where.Right, env)
+			return !matched, errObj
+		}
+	}
+	return false, nil
+}
+
+// rowScan holds what a where clause needs across the rows it checks: the parsed cells of the columns it compares,
+// each column is parsed once (see object.CSV.Cells), and the values it compares them with, which are literals evaluated once
+type rowScan struct {
+	csv     *object.CSV
+	columns map[string][]object.Cell
+	values  map[*ast.ReadFilterExpression]object.Object
+}
+
+func newRowScan(csv *object.CSV) *rowScan {
+	return &rowScan{csv: csv, columns: map[string][]object.Cell{}, values: map[*ast.ReadFilterExpression]object.Object{}}
+}
+
+func (s *rowScan) cell(column string, index int) object.Cell {
+	// rows streamed from a file, eg. by save from, aren't typed, their cells are parsed as they are compared
+	if s.csv == nil {
+		return object.Cell{Kind: object.StringCell}
+	}
+	cells, ok := s.columns[column]
+	if !ok {
+		cells = s.csv.Cells(column)
+		s.columns[column] = cells
+	}
+	return cells[index]
+}
+
+func (s *rowScan) value(where *ast.ReadFilterExpression, env *object.Environment) object.Object {
+	value, ok := s.values[where]
+	if !ok {
+		value = Eval(where.Value, env)
+		s.values[where] = value
+	}
+	return value
+}
+
+// evaluateComparison evaluates a single column comparison based on the column value, operator, and compare value.
 // Example: `column > 5`, `column == "value"`, etc.
 // It returns true if the condition is satisfied, otherwise false.
-func evaluateCondition(row map[string]string, where *ast.ReadFilterExpression, env *object.Environment) bool {
-	columnValue := row[where.ColumnName]
+//
+// A cell is null when the row has no value for the column, an empty cell is an empty string.
+// `column == null` and `column != null` check for null cells, any other comparison involving a null cell is false.
+// Empty cells never match a numeric comparison, other cells that aren't numbers are an error unless LenientNumbers is set.
+// Cells of INTEGER, FLOAT and BOOLEAN columns and of columns of dates are compared as parsed once, see object.CSV.Cells, other cells are parsed here.
+func evaluateComparison(scan *rowScan, index int, row map[string]string, where *ast.ReadFilterExpression, env *object.Environment) (bool, object.Object) {
+	columnValue, present := row[where.ColumnName]
 
 	// First evaluate the condition's value
-	compareValue := Eval(where.Value, env)
+	compareValue := scan.value(where, env)
 	if isError(compareValue) {
-		return false
+		return false, compareValue
+	}
+
+	if compareValue == NULL {
+		switch where.Operator {
+		case "==":
+			return !present, nil
+		case "!=":
+			return present, nil
+		default:
+			return false, nil
+		}
+	}
+
+	if !present {
+		return false, nil
 	}
+	cell := scan.cell(where.ColumnName, index)
 
 	switch compareValue.Type() {
-	case object.INTEGER_OBJ:
-		return evaluateNumericCondition(columnValue, where.Operator, compareValue.(*object.Integer).Value)
+	case object.INTEGER_OBJ, object.FLOAT_OBJ:
+		var matched, ok bool
+		integer, isInteger := compareValue.(*object.Integer)
+		switch {
+		case cell.Kind == object.IntegerCell && isInteger:
+			matched, ok = compareNumbers(cell.Int, where.Operator, integer.Value), true
+		case cell.Kind == object.IntegerCell || cell.Kind == object.FloatCell:
+			matched, ok = compareNumbers(cell.Float, where.Operator, toFloat(compareValue)), true
+		case isInteger:
+			matched, ok = evaluateNumericCondition(columnValue, where.Operator, integer.Value)
+		default:
+			matched, ok = evaluateFloatCondition(columnValue, where.Operator, compareValue.(*object.Float).Value)
+		}
+		if !ok && columnValue != "" && !LenientNumbers {
+			return false, newError("invalid number %q in column %s at row %d", columnValue, where.ColumnName, index)
+		}
+		return matched, nil
 
 	case object.STRING_OBJ:
-		return evaluateStringCondition(columnValue, where.Operator, compareValue.(*object.String).Value)
+		value := compareValue.(*object.String).Value
+		// dates are compared as dates, so 2024-01-02T09:00:00+02:00 is before 2024-01-02T08:00:00Z
+		if cell.Kind == object.DateCell {
+			if date, err := time.Parse(scan.csv.TypeOf(where.ColumnName).Format, value); err == nil {
+				if matched, ok := compareDates(cell.Time, where.Operator, date); ok {
+					return matched, nil
+				}
+			}
+		}
+		return evaluateStringCondition(columnValue, where.Operator, value), nil
 
 	case object.BOOLEAN_OBJ:
-		return evaluateBooleanCondition(columnValue, where.Operator, compareValue.(*object.Boolean).Value)
+		if cell.Kind == object.BooleanCell {
+			return evaluateBoolean(cell.Bool, where.Operator, compareValue.(*object.Boolean).Value), nil
+		}
+		return evaluateBooleanCondition(columnValue, where.Operator, compareValue.(*object.Boolean).Value), nil
 	default:
-		return false
+		return false, nil
 	}
 }
 
-// filterRows filters the rows based on the where clause.
-// It checks if each row satisfies the condition specified in the where clause.
-func filterRows(rows []map[string]string, where *ast.ReadFilterExpression, env *object.Environment) []map[string]string {
-	var filtered []map[string]string
+// compareDates compares two dates using the specified operator, the second return value is false for other operators, eg. ==*
+func compareDates(rowVal time.Time, operator string, compareValue time.Time) (bool, bool) {
+	switch operator {
+	case "==", "!=", "<", ">", "<=", ">=":
+		// Compare works for any date, UnixNano only for dates between the years 1678 and 2262
+		return compareNumbers(int64(rowVal.Compare(compareValue)), operator, 0), true
+	}
+	return false, false
+}
 
-	for _, row := range rows {
-		if evaluateCondition(row, where, env) {
-			filtered = append(filtered, row)
+// matchBuffers are scratch lists of the rows matching a where clause, kept between reads so filtering doesn't grow a new list each time
+var matchBuffers = sync.Pool{New: func() interface{} { return new([]map[string]string) }}
+
+// filterRows filters the rows of a CSV selected by rowIndex based on the where clause, see selectRows.
+// It checks if each row satisfies the condition specified in the where clause.
+// The matching rows are gathered in a pooled buffer, so the result is allocated once at its final size.
+func filterRows(csv *object.CSV, rowIndex int, where ast.Expression, env *object.Environment) ([]map[string]string, object.Object) {
+	buffer := matchBuffers.Get().(*[]map[string]string)
+	matches := (*buffer)[:0]
+	defer func() {
+		// drop the references to the rows, so the pool doesn't keep them alive
+		clear(matches)
+		*buffer = matches[:0]
+		matchBuffers.Put(buffer)
+	}()
+
+	// the selected rows start at this position in the CSV
+	first := max(rowIndex, 0)
+	scan := newRowScan(csv)
+	for i, row := range selectRows(csv.Rows, rowIndex) {
+		matched, errObj := evaluateCondition(scan, first+i, row, where, env)
+		if errObj != nil {
+			return nil, errObj
+		}
+		if matched {
+			matches = append(matches, row)
 		}
 	}
 
-	return filtered
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	return append(make([]map[string]string, 0, len(matches)), matches...), nil
 }
 
-// extractColumns extracts the specified columns from the rows.
-func extractColumns(rows []map[string]string, column string) *object.Array {
-	var values object.Array
+// extractColumn extracts the specified column from the rows of a CSV, the column is a header or its position (eg. col 0).
+// It returns a one column CSV, so the column keeps its name and type, eg. when it is saved.
+func extractColumn(csv *object.CSV, rows []map[string]string, column string) object.Object {
+	index := -1
+	for i, header := range csv.Headers {
+		if header == column {
+			index = i
+		}
+	}
+	if position, err := strconv.Atoi(column); index == -1 && err == nil && position >= 0 && position < len(csv.Headers) {
+		index = position
+		column = csv.Headers[position]
+	}
+	if index == -1 {
+		return newError("column not found: %s", column)
+	}
+
+	columnTypes := []object.ColumnType{{Name: column, DataType: object.STRING_OBJ}}
+	if index < len(csv.ColumnTypes) {
+		columnTypes[0] = csv.ColumnTypes[index]
+	}
 
+	values := make([]map[string]string, 0, len(rows))
 	for _, row := range rows {
 		if val, ok := row[column]; ok {
-			if intValue, err := strconv.ParseInt(val, 10, 64); err == nil {
-				values.Elements = append(values.Elements, &object.Integer{Value: intValue})
-			} else {
-				values.Elements = append(values.Elements, &object.String{Value: val})
-			}
+			values = append(values, map[string]string{column: val})
 		}
 	}
 
-	return &values
+	return &object.CSV{Headers: []string{column}, ColumnTypes: columnTypes, Rows: values}
 }
 
 // evalReadStatement evaluates a read statement.
@@ -561,9 +1683,20 @@ func extractColumns(rows []map[string]string, column string) *object.Array {
 func evalReadStatement(rs *ast.ReadExpression, env *object.Environment) object.Object {
 	// Retrieve stored CSV object
 	csv, ok := env.Get("csv")
-	if !ok {
+	if rs.Source != nil {
+		csv = evalIdentifier(rs.Source, env)
+		if isError(csv) {
+			return csv
+		}
+		if csv.Type() != object.CSV_OBJ && csv.Type() != object.MAPPED_CSV_OBJ {
+			return newError("cannot read from %s: expected CSV, got %s", rs.Source.Value, csv.Type())
+		}
+	} else if !ok {
 		return nil
 	}
+	if mapped, ok := csv.(*object.MappedCSV); ok {
+		return readMapped(rs, mapped, env)
+	}
 
 	csvObj, ok := csv.(*object.CSV)
 	if !ok {
@@ -574,13 +1707,20 @@ func evalReadStatement(rs *ast.ReadExpression, env *object.Environment) object.O
 	rows := selectRows(csvObj.Rows, rs.Location.RowIndex)
 
 	if rs.Location.Filter != nil {
-		rows = filterRows(rows, rs.Location.Filter, env)
+		var errObj object.Object
+		rows, errObj = filterRows(csvObj, rs.Location.RowIndex, rs.Location.Filter, env)
+		if errObj != nil {
+			return errObj
+		}
 	}
 
-	if rs.Location.ColIndex != "" {
-		return extractColumns(rows, rs.Location.ColIndex)
+	// `col *` selects every column
+	if rs.Location.ColIndex != "" && rs.Location.ColIndex != "*" {
+		return extractColumn(csvObj, rows, rs.Location.ColIndex)
 	}
 
+	// the where clause may change rows, so they are shared with csvObj once it is evaluated
+	csvObj.ShareRows()
 	return &object.CSV{Rows: rows, Headers: csvObj.Headers, ColumnTypes: csvObj.ColumnTypes}
 }
 
@@ -593,7 +1733,7 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.EXIT_OBJ {
 				return result
 			}
 		}
@@ -623,7 +1763,18 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 func applyFunction(fn object.Object, args []object.Object, env *object.Environment) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
+		if errObj := checkStopped(env); errObj != nil {
+			return errObj
+		}
+		if env.EnterCall() > MaxCallDepth {
+			env.ExitCall()
+			return newError("maximum call depth of %d exceeded", MaxCallDepth)
+		}
+		defer env.ExitCall()
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
@@ -636,13 +1787,54 @@ func applyFunction(fn object.Object, args []object.Object, env *object.Environme
 // extendFunctionEnv extends the function environment with the given arguments.
 // It creates a new environment for the function call and sets the parameters to the corresponding arguments.
 // This allows the function to access its arguments using the parameter names.
-// Example: `fn(param1, param2)`.
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// Missing arguments take the parameter's default value, which is evaluated in the function environment
+// so it can refer to earlier parameters. Remaining arguments are collected into the variadic parameter as an array.
+// Example: `fn(param1, param2 = 10, ...rest)`.
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, object.Object) {
+	required := 0
+	for paramIdx := range fn.Parameters {
+		if paramIdx >= len(fn.Defaults) || fn.Defaults[paramIdx] == nil {
+			required = paramIdx + 1
+		}
+	}
+	if len(args) < required || (fn.Rest == nil && len(args) > len(fn.Parameters)) {
+		return nil, newError("wrong number of arguments: got=%d, want=%s", len(args), arity(fn, required))
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+
+		value := Eval(fn.Defaults[paramIdx], env)
+		if isError(value) {
+			return nil, value
+		}
+		env.Set(param.Value, value)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+	return env, nil
+}
+
+// arity describes the number of arguments a function accepts, eg. "2", "1 to 3" or "at least 1".
+func arity(fn *object.Function, required int) string {
+	switch {
+	case fn.Rest != nil:
+		return fmt.Sprintf("at least %d", required)
+	case required == len(fn.Parameters):
+		return fmt.Sprintf("%d", required)
+	default:
+		return fmt.Sprintf("%d to %d", required, len(fn.Parameters))
 	}
-	return env
 }
 
 // evalProgram evaluates a program by executing each statement in the program.
@@ -657,7 +1849,7 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 		switch result := result.(type) {
 		case *object.ReturnValue:
 			return result.Value
-		case *object.Error:
+		case *object.Error, *object.Exit:
 			return result
 		}
 	}
@@ -678,7 +1870,7 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 // Example: `!true`, `-5`, etc.
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
-	case "!":
+	case "!", "not":
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
@@ -709,6 +1901,13 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return builtin
 	}
 
+	// Dot syntax reads a row field, eg. row.age
+	if base, field, ok := strings.Cut(node.Value, "."); ok {
+		if row, ok := env.Get(base); ok {
+			return evalFieldAccess(row, field)
+		}
+	}
+
 	return newError("identifier not found: " + node.Value)
 }
 
@@ -722,14 +1921,81 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return Eval(ie.Consequence, object.NewBlockEnvironment(env))
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return Eval(ie.Alternative, object.NewBlockEnvironment(env))
 	} else {
 		return NULL
 	}
 }
 
+// evalTryExpression evaluates a try expression.
+// It returns the value of the body, or of the handler if the body results in an error.
+// Example: `try { load extra.csv } catch (e) { print(e) }`.
+// The handler runs in its own scope with the error message bound to the catch parameter.
+func evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(te.Body, object.NewBlockEnvironment(env))
+	errObj, ok := result.(*object.Error)
+	// a stopped script isn't caught, it would run on
+	if !ok || env.Err() != nil {
+		return result
+	}
+
+	handlerEnv := object.NewBlockEnvironment(env)
+	if te.Param != nil {
+		handlerEnv.Set(te.Param.Value, &object.String{Value: errObj.Message})
+	}
+	return Eval(te.Handler, handlerEnv)
+}
+
+// evalMatchExpression evaluates a match expression.
+// It returns the value of the first arm with a pattern equal to the subject, or of the _ arm.
+// Example: `match (status) { "ok" => 1; _ => 0 }`.
+// It returns NULL if no arm matches.
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := Eval(me.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, arm := range me.Arms {
+		if len(arm.Patterns) == 0 {
+			return Eval(arm.Value, env)
+		}
+
+		for _, pattern := range arm.Patterns {
+			value := Eval(pattern, env)
+			if isError(value) {
+				return value
+			}
+			if objectsEqual(subject, value) {
+				return Eval(arm.Value, env)
+			}
+		}
+	}
+
+	return NULL
+}
+
+// objectsEqual checks if two objects hold the same value.
+// Numbers are compared by value (1 == 1.0), other scalars by type and value, and everything else by identity.
+func objectsEqual(left, right object.Object) bool {
+	if isNumeric(left) && isNumeric(right) {
+		return toFloat(left) == toFloat(right)
+	}
+	if left.Type() != right.Type() {
+		return false
+	}
+	switch left := left.(type) {
+	case *object.String:
+		return left.Value == right.(*object.String).Value
+	case *object.Boolean:
+		return left.Value == right.(*object.Boolean).Value
+	default:
+		return left == right
+	}
+}
+
 // isTruthy checks if an object is truthy.
 // It returns true if the object is not NULL, TRUE, or FALSE.
 func isTruthy(obj object.Object) bool {
@@ -752,6 +2018,14 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumeric(left) && isNumeric(right):
+		return evalFloatInfixExpression(operator, toFloat(left), toFloat(right))
+	case left == NULL || right == NULL:
+		return evalNullInfixExpression(operator, left, right)
+	case operator == "+" && isConcatenation(left, right):
+		return &object.String{Value: left.Inspect() + right.Inspect()}
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -759,25 +2033,90 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s",
 			left.Type(), operator, right.Type())
-	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
-		return evalStringInfixExpression(operator, left, right)
 	default:
 		return newError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
 }
 
+// evalNullInfixExpression evaluates an infix expression where at least one operand is null.
+// null only equals null; any other operator propagates null, eg. `null + 1` is null.
+func evalNullInfixExpression(operator string, left, right object.Object) object.Object {
+	switch operator {
+	case "==":
+		return nativeBoolToBooleanObject(left == right)
+	case "!=":
+		return nativeBoolToBooleanObject(left != right)
+	default:
+		return NULL
+	}
+}
+
+// isConcatenation checks if a "+" should concatenate a string with a number or boolean.
+// Example: `"Row count: " + count(rows)`.
+func isConcatenation(left, right object.Object) bool {
+	isScalar := func(obj object.Object) bool {
+		return isNumeric(obj) || obj.Type() == object.BOOLEAN_OBJ
+	}
+	return (left.Type() == object.STRING_OBJ && isScalar(right)) ||
+		(isScalar(left) && right.Type() == object.STRING_OBJ)
+}
+
+// isLogicalOperator checks if the operator is one of and/or (or their && and || counterparts).
+func isLogicalOperator(operator string) bool {
+	switch operator {
+	case "and", "&&", "or", "||":
+		return true
+	default:
+		return false
+	}
+}
+
+// evalLogicalExpression evaluates a logical and/or expression.
+// The right operand is only evaluated when the left operand doesn't decide the result.
+// Example: `x > 5 and y < 10`, `done or retries > 3`, etc.
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	isAnd := node.Operator == "and" || node.Operator == "&&"
+	if isAnd && !isTruthy(left) {
+		return FALSE
+	}
+	if !isAnd && isTruthy(left) {
+		return TRUE
+	}
+
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
 // evalStringInfixExpression evaluates a string infix expression.
 // It applies the operator to the left and right string operands and returns the result.
 // Example: `"hello" + "world"`.
-// It only supports the "+" operator for string concatenation.
+// It supports the "+" operator for string concatenation and "==" / "!=" for comparing values.
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
-	}
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
-	return &object.String{Value: leftVal + rightVal}
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case "==*":
+		return nativeBoolToBooleanObject(strings.EqualFold(leftVal, rightVal))
+	case "!=*":
+		return nativeBoolToBooleanObject(!strings.EqualFold(leftVal, rightVal))
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
 }
 
 // evalIntegerInfixExpression evaluates an integer infix expression.
@@ -788,17 +2127,59 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	rightVal := right.(*object.Integer).Value
 	switch operator {
 	case "+":
+		if !addFits(leftVal, rightVal) {
+			return newError("integer overflow: %d + %d", leftVal, rightVal)
+		}
 		return &object.Integer{Value: leftVal + rightVal}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		diff := leftVal - rightVal
+		if (leftVal >= 0 && rightVal < 0 && diff < 0) || (leftVal < 0 && rightVal > 0 && diff >= 0) {
+			return newError("integer overflow: %d - %d", leftVal, rightVal)
+		}
+		return &object.Integer{Value: diff}
 	case "*":
+		if !mulFits(leftVal, rightVal) {
+			return newError("integer overflow: %d * %d", leftVal, rightVal)
+		}
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %d / %d", leftVal, rightVal)
+		}
+		// MinInt64 / -1 doesn't fit in an int64
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return newError("integer overflow: %d / %d", leftVal, rightVal)
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case "..":
+		return integerRange(leftVal, rightVal, 1)
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero: %d %% %d", leftVal, rightVal)
+		}
+		// MinInt64 % -1 panics in Go even though the result is 0
+		if rightVal == -1 {
+			return &object.Integer{Value: 0}
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "**":
+		// a negative exponent yields a fraction, so fall back to float arithmetic
+		if rightVal < 0 {
+			return evalFloatInfixExpression(operator, float64(leftVal), float64(rightVal))
+		}
+		result, ok := integerPower(leftVal, rightVal)
+		if !ok {
+			return newError("integer overflow: %d ** %d", leftVal, rightVal)
+		}
+		return &object.Integer{Value: result}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -809,14 +2190,185 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
+// rangeArguments returns the bounds of range([start,] end[, step]), or of the builtins counting like it, eg. sequence
+func rangeArguments(name string, args []object.Object) (start, end, step int64, errObj *object.Error) {
+	if len(args) < 1 || len(args) > 3 {
+		return 0, 0, 0, newError("wrong number of arguments: got=%d, want=1, 2 or 3", len(args))
+	}
+
+	bounds := make([]int64, len(args))
+	for i, arg := range args {
+		integer, ok := arg.(*object.Integer)
+		if !ok {
+			return 0, 0, 0, newError("arguments to `%s` must be INTEGER, got %s", name, arg.Type())
+		}
+		bounds[i] = integer.Value
+	}
+
+	// range(end), range(start, end) or range(start, end, step)
+	switch len(bounds) {
+	case 1:
+		return 0, bounds[0], 1, nil
+	case 2:
+		return bounds[0], bounds[1], 1, nil
+	default:
+		if bounds[2] == 0 {
+			return 0, 0, 0, newError("%s step cannot be zero", name)
+		}
+		return bounds[0], bounds[1], bounds[2], nil
+	}
+}
+
+// integerRange builds an array of integers from start (inclusive) to end (exclusive) using the given step.
+// A negative step counts down, eg. integerRange(3, 0, -1) is [3, 2, 1].
+// It fails past MaxRangeLength integers, as the array is built at once, unlike sequence.
+func integerRange(start, end, step int64) object.Object {
+	length := rangeLength(start, end, step)
+	if length > uint64(MaxRangeLength) {
+		return newError("range of %d integers is longer than %d, use sequence to count without building an array", length, MaxRangeLength)
+	}
+	elements := make([]object.Object, length)
+	for i := range elements {
+		elements[i] = &object.Integer{Value: start}
+		// past the last integer start may overflow, it isn't used then
+		start += step
+	}
+	return &object.Array{Elements: elements}
+}
+
+// rangeLength returns the number of integers from start (inclusive) to end (exclusive) using the given step.
+// It is computed on unsigned integers, so the distance between the bounds doesn't overflow, eg. from math.MinInt64 to math.MaxInt64.
+func rangeLength(start, end, step int64) uint64 {
+	switch {
+	case step > 0 && start < end:
+		return (uint64(end)-uint64(start)-1)/uint64(step) + 1
+	case step < 0 && start > end:
+		return (uint64(start)-uint64(end)-1)/(-uint64(step)) + 1
+	}
+	return 0
+}
+
+// integerPower raises base to a non-negative exponent using exponentiation by squaring.
+// The second return value is false when the result doesn't fit in an int64.
+func integerPower(base, exponent int64) (int64, bool) {
+	result := int64(1)
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			if !mulFits(result, base) {
+				return 0, false
+			}
+			result *= base
+		}
+		exponent >>= 1
+		if exponent > 0 {
+			if !mulFits(base, base) {
+				return 0, false
+			}
+			base *= base
+		}
+	}
+	return result, true
+}
+
+// addFits reports whether a + b can be computed without overflowing an int64.
+func addFits(a, b int64) bool {
+	sum := a + b
+	// overflow happened if both operands share a sign that the result doesn't
+	return !(a > 0 && b > 0 && sum < 0) && !(a < 0 && b < 0 && sum >= 0)
+}
+
+// mulFits reports whether a * b can be computed without overflowing an int64.
+func mulFits(a, b int64) bool {
+	if a == 0 || b == 0 {
+		return true
+	}
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return false
+	}
+	product := a * b
+	return product/b == a
+}
+
+// evalFloatInfixExpression evaluates an infix expression where at least one operand is a float.
+// Integer operands are promoted to float before calling this function.
+// Example: `1.5 * 2`, `price > 9.99`, etc.
+func evalFloatInfixExpression(operator string, leftVal, rightVal float64) object.Object {
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %s / %s", formatFloat(leftVal), formatFloat(rightVal))
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero: %s %% %s", formatFloat(leftVal), formatFloat(rightVal))
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			object.FLOAT_OBJ, operator, object.FLOAT_OBJ)
+	}
+}
+
+// isNumeric checks if an object is an integer or a float.
+func isNumeric(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat converts a numeric object (integer or float) to a native float64.
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
+	}
+}
+
+// formatFloat formats a float the same way a Float object inspects.
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
 // evalMinusPrefixOperatorExpression evaluates a prefix minus operator.
 // It negates the value of the right operand.
 // Example: `-5`, `-x`, etc.
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	if right == NULL {
+		return NULL
+	}
+	if right.Type() == object.FLOAT_OBJ {
+		return &object.Float{Value: -right.(*object.Float).Value}
+	}
 	if right.Type() != object.INTEGER_OBJ {
 		return newError("unknown operator: -%s", right.Type())
 	}
 	value := right.(*object.Integer).Value
+	if value == math.MinInt64 {
+		return newError("integer overflow: -(%d)", value)
+	}
 	return &object.Integer{Value: -value}
 }
 
@@ -844,9 +2396,10 @@ func newError(format string, a ...interface{}) *object.Error {
 }
 
 // isError checks if an object is an error.
+// An exit request stops evaluation the same way, so it is treated as an error here, but try/catch doesn't catch it.
 func isError(obj object.Object) bool {
 	if obj != nil {
-		return obj.Type() == object.ERROR_OBJ
+		return obj.Type() == object.ERROR_OBJ || obj.Type() == object.EXIT_OBJ
 	}
 	return false
 }
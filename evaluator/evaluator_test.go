@@ -2,12 +2,34 @@
 package evaluator
 
 import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Rishabh570/csvlang/ast"
 	"github.com/Rishabh570/csvlang/lexer"
 	"github.com/Rishabh570/csvlang/object"
 	"github.com/Rishabh570/csvlang/parser"
+	"github.com/Rishabh570/csvlang/token"
 )
 
 // func TestLoadAndReadStatement(t *testing.T) {
@@ -88,6 +110,13 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"10 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"3 * 2 ** 2", 12},
+		{"5 ** 0", 1},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -95,6 +124,33 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"-2.25", -2.25},
+		{"1.5 + 1", 2.5},
+		{"3 * 0.5", 1.5},
+		{"7 / 2.0", 3.5},
+		{"7.5 % 2", 1.5},
+		{"100 * 1.5 ** 2", 225},
+		{"2 ** -1", 0.5},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		result, ok := evaluated.(*object.Float)
+		if !ok {
+			t.Errorf("object is not Float. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if result.Value != tt.expected {
+			t.Errorf("object has wrong value. got=%f, want=%f", result.Value, tt.expected)
+		}
+	}
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 	evaluated := testEval(input)
@@ -119,6 +175,64 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestStringCoercion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"Row count: " + 3`, "Row count: 3"},
+		{`2.5 + " kg"`, "2.5 kg"},
+		{`"done: " + true`, "done: true"},
+		{`"total: " + (1 + 2)`, "total: 3"},
+		{`format("Row count: {}", 3)`, "Row count: 3"},
+		{`format("{} of {} rows", 2, 10)`, "2 of 10 rows"},
+		{`format("no placeholders")`, "no placeholders"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. got=%q, want=%q", str.Value, tt.expected)
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`match ("ok") { "ok" => 1; "failed" => 2 }`, 1},
+		{`match ("error") { "ok" => 1; "failed", "error" => 2; _ => 0 }`, 2},
+		{`match ("unknown") { "ok" => 1; _ => 0 }`, 0},
+		{`match ("unknown") { "ok" => 1 }`, nil},
+		{`match (2) { 1 => 10; 2.0 => 20 }`, 20},
+		{`let x = 3; match (x + 1) { x => 1; x + 1 => 2 }`, 2},
+		{`
+			let status = "failed"
+			let code = match (status) {
+				# comments are allowed between arms
+				"ok" => 200
+				"failed", "error" => 500
+				_ => 0
+			}
+			code`, 500},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 	if !ok {
@@ -152,6 +266,14 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"1 != 1", false},
 		{"1 == 2", false},
 		{"1 != 2", true},
+		{"1.5 < 2", true},
+		{"1 <= 1", true},
+		{"1 >= 2", false},
+		{"2 >= 1", true},
+		{"2.5 <= 2", false},
+		{"2.0 == 2", true},
+		{`"a" == "a"`, true},
+		{`"a" != "b"`, true},
 		{"true == true", true},
 		{"false == false", true},
 		{"true == false", false},
@@ -258,6 +380,229 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestAssertAndExit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"assert(1 < 2, \"math works\"); 5", 5},
+		{"assert(len([1, 2]) == 3, \"expected 3 rows\"); 5", "assertion failed: expected 3 rows"},
+		{"assert(false)", "assertion failed"},
+		{"assert(null, \"missing\")", "assertion failed: missing"},
+		{"try { assert(false, \"bad\") } catch (e) { e }", "assertion failed: bad"},
+		{"exit(\"1\")", "argument to `exit` must be INTEGER, got STRING"},
+		{"exit(256)", "exit code must be between 0 and 255, got 256"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("expected %q. got=%q", expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("expected error %q. got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("expected %q. got=%T (%+v)", expected, evaluated, evaluated)
+			}
+		}
+	}
+
+	exitTests := []struct {
+		input    string
+		expected int
+	}{
+		{"exit(); 5", 0},
+		{"exit(3); 5", 3},
+		{"let f = fn() { for i, v in [1, 2] { if (v == 2) { exit(2) } }; 1 }; f(); 5", 2},
+		// exit is not caught by try
+		{"try { exit(4) } catch { 0 }; 5", 4},
+	}
+
+	for _, tt := range exitTests {
+		exit, ok := testEval(tt.input).(*object.Exit)
+		if !ok {
+			t.Errorf("object is not Exit for %q", tt.input)
+			continue
+		}
+		if exit.Code != tt.expected {
+			t.Errorf("wrong exit code. expected=%d, got=%d", tt.expected, exit.Code)
+		}
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"try { 1 + 1 } catch (e) { 0 }", 2},
+		{"try { 1 / 0 } catch (e) { -1 }", -1},
+		{"try { 1 / 0 } catch (e) { e }", "division by zero: 1 / 0"},
+		{"try { load missing.csv } catch { \"fallback\" }", "fallback"},
+		{"let f = fn() { 5 + true }; try { f() } catch (e) { e }", "type mismatch: INTEGER + BOOLEAN"},
+		{"let x = 1; try { x = 2; missing; x = 3 } catch (e) { x }", 2},
+		{"fn safe_div(a, b) { try { return a / b } catch { return 0 } }; safe_div(4, 0) + safe_div(4, 2);", 2},
+		// errors in the handler are not caught
+		{"try { 1 / 0 } catch (e) { missing }", "identifier not found: missing"},
+		// the catch parameter doesn't leak out of the handler
+		{"try { 1 / 0 } catch (e) { e }; e", "identifier not found: e"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("expected %q. got=%q", expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("expected error %q. got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("expected %q. got=%T (%+v)", expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"const LIMIT = 10; LIMIT * 2;", 20},
+		{"const LIMIT = 10; LIMIT = 5;", "cannot assign to constant LIMIT"},
+		{"const LIMIT = 10; LIMIT += 5;", "cannot assign to constant LIMIT"},
+		{"const LIMIT = 10; let LIMIT = 5;", "cannot redeclare constant LIMIT"},
+		{"const LIMIT = 10; for i, v in [1] { LIMIT = v }; LIMIT;", "cannot assign to constant LIMIT"},
+		// constants can be shadowed in nested scopes
+		{"const LIMIT = 10; let f = fn() { let LIMIT = 1; LIMIT = 2; LIMIT }; f() + LIMIT;", 12},
+		// const binds the name, the array itself can still change
+		{"const COLS = [1, 2]; COLS[0] = 5; COLS[0];", 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("expected error %q. got=%+v", expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestBlockScopes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{} // an integer, or an error message
+	}{
+		// names declared in if, else, try, catch and for bodies stay in the block
+		{"let x = 1; if (true) { let x = 2 }; x", 1},
+		{"if (true) { let y = 3; y }", 3},
+		{"if (true) { let y = 3 }; y", "identifier not found: y"},
+		{"if (false) { 1 } else { let z = 4 }; z", "identifier not found: z"},
+		{"try { let t = 5 } catch (e) { 0 }; t", "identifier not found: t"},
+		{"try { nope } catch (e) { let c = 6 }; c", "identifier not found: c"},
+		{"for i, v in [1, 2] { let kept = v }; kept", "identifier not found: kept"},
+		{"for i, v in [1, 2] { }; v", "identifier not found: v"},
+		{"let f = 1; if (true) { fn f() { 2 } }; f", 1},
+		// constants of outer scopes can be shadowed in blocks, not assigned
+		{"const LIMIT = 10; if (true) { let LIMIT = 1; LIMIT }", 1},
+		{"const LIMIT = 10; if (true) { LIMIT = 1 }", "cannot assign to constant LIMIT"},
+		// assignments update the variable where it is declared
+		{"let x = 1; if (true) { x = 2 }; x", 2},
+		{"let x = 1; try { x = 2; nope } catch (e) { x += 1 }; x", 3},
+		{"let x = 0; for i, v in [1, 2, 3] { if (v > 1) { x += v } }; x", 5},
+		// each iteration has its own bindings, closures keep those of the iteration defining them
+		{"let fs = []; for i, v in [1, 2, 3] { fs = push(fs, fn() { v }) }; fs[0]() + fs[2]()", 4},
+		{"let fs = []; for i, v in [1, 2] { let w = v * 10; fs = push(fs, fn() { w }) }; fs[1]()", 20},
+		// closures see later assignments to the variables they capture, not later declarations
+		{"let n = 1; let f = fn() { n }; n = 2; f()", 2},
+		{"let n = 1; let f = fn() { n }; if (true) { let n = 5 }; f()", 1},
+		{"let f = fn() { let k = 7; fn() { k } }; f()()", 7},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("%s: expected error %q. got=%+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+
+	// a file loaded in a block is the loaded file after it, as if it were loaded outside of it
+	path := filepath.Join(t.TempDir(), "extra.csv")
+	if err := os.WriteFile(path, []byte("id\n1\n2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range []string{
+		fmt.Sprintf("try { load %q } catch (e) { 0 }; count(csv)", path),
+		fmt.Sprintf("if (true) { load %q }; count(csv)", path),
+		fmt.Sprintf("for i, v in [1] { load %q }; count(csv)", path),
+	} {
+		testIntegerObject(t, testEval(input), 2)
+	}
+	// functions keep the files they load to themselves
+	if errObj, ok := testEval(fmt.Sprintf("fn f() { load %q }; f(); csv", path)).(*object.Error); !ok || errObj.Message != "identifier not found: csv" {
+		t.Errorf("the file loaded by a function should stay in it, got %+v", errObj)
+	}
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a += 2; a;", 7},
+		{"let a = 5; a -= 2; a;", 3},
+		{"let a = 5; a *= 2; a;", 10},
+		{"let a = 10; a /= 2; a;", 5},
+		{"let arr = [1, 2, 3]; arr[1] += 10; arr[1];", 12},
+		{"let arr = [4, 2]; arr[0] *= arr[1]; arr[0];", 8},
+		{`
+			let total = 0
+			for i, v in [1, 2, 3, 4] {
+				total += v
+			}
+			total`, 10},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	str, ok := testEval(`let s = "a"; s += "b"; s;`).(*object.String)
+	if !ok || str.Value != "ab" {
+		t.Errorf("string += did not concatenate. got=%+v", str)
+	}
+
+	errObj, ok := testEval("let a = 1; a /= 0;").(*object.Error)
+	if !ok || errObj.Message != "division by zero: 1 / 0" {
+		t.Errorf("expected division by zero error. got=%+v", errObj)
+	}
+}
+
 func TestErrorHandling(t *testing.T) {
 	tests := []struct {
 		input           string
@@ -305,6 +650,38 @@ func TestErrorHandling(t *testing.T) {
 			`"Hello" - "World"`,
 			"unknown operator: STRING - STRING",
 		},
+		{
+			"5 / 0",
+			"division by zero: 5 / 0",
+		},
+		{
+			"let zero = 0; 10 / zero;",
+			"division by zero: 10 / 0",
+		},
+		{
+			"9223372036854775807 + 1",
+			"integer overflow: 9223372036854775807 + 1",
+		},
+		{
+			"-9223372036854775807 - 2",
+			"integer overflow: -9223372036854775807 - 2",
+		},
+		{
+			"4611686018427387904 * 2",
+			"integer overflow: 4611686018427387904 * 2",
+		},
+		{
+			"5 % 0",
+			"division by zero: 5 % 0",
+		},
+		{
+			"2 ** 64",
+			"integer overflow: 2 ** 64",
+		},
+		{
+			"1.5 / 0",
+			"division by zero: 1.5 / 0",
+		},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -358,6 +735,96 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"fn double(x) { x * 2 }; double(21);", 42},
+		{"fn add(x, y) { return x + y; }\nadd(2, 3)", 5},
+		{"fn fact(n) { if (n < 2) { return 1 }; n * fact(n - 1) }; fact(5);", 120},
+		{"let offset = 10; fn shift(x) { x + offset }; shift(1);", 11},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDefaultAndVariadicParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"fn normalize(value, factor = 100) { value * factor }; normalize(2);", 200},
+		{"fn normalize(value, factor = 100) { value * factor }; normalize(2, 3);", 6},
+		{"let f = fn(a, b = a * 2) { a + b }; f(5);", 15},
+		{"fn count_all(...parts) { len(parts) }; count_all();", 0},
+		{"fn count_all(...parts) { len(parts) }; count_all(1, 2, 3);", 3},
+		{"fn concat_all(sep, ...parts) { let out = \"\"; for i, part in parts { out += part + sep }; out }; concat_all(\"-\", \"a\", \"b\");", "a-b-"},
+		{"fn f(a, b = 1) { a }; f();", "wrong number of arguments: got=0, want=1 to 2"},
+		{"fn f(a, b) { a }; f(1, 2, 3);", "wrong number of arguments: got=3, want=2"},
+		{"fn f(a, ...rest) { a }; f();", "wrong number of arguments: got=0, want=at least 1"},
+		{"fn f(a = missing) { a }; f();", "identifier not found: missing"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("expected %q. got=%+v", expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestLoopScoping(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// closures capture the bindings of their own iteration
+		{"let fns = []; for i, v in [10, 20, 30] { fns = push(fns, fn() { v + i }) }; fns[0]() + fns[2]();", 42},
+		// let inside the body shadows the outer variable only within the iteration
+		{"let x = 1; for i, v in [1, 2] { let x = v * 100 }; x;", 1},
+		// assignment updates the variable where it was declared
+		{"let total = 0; for i, v in [1, 2, 3] { total += v }; total;", 6},
+		{"let total = 0; for i, v in [1, 2] { for j, w in [10, 20] { total += v * w } }; total;", 90},
+		// loop variables and let declarations don't leak out of the loop
+		{"for i, v in [1, 2] { let inner = v }; inner;", "identifier not found: inner"},
+		{"for i, v in [1, 2] { v }; v;", "identifier not found: v"},
+		// reassigning the element leaves the array untouched, index assignment updates it
+		{"let arr = [1, 2]; for i, v in arr { v = 10 }; arr[0];", 1},
+		{"let arr = [1, 2]; for i, v in arr { arr[i] = v * 10 }; arr[1];", 20},
+		// return stops the loop and returns from the function
+		{"fn first_big(arr) { for i, v in arr { if (v > 10) { return v } }; return 0 }; first_big([5, 12, 30]);", 12},
+		{"fn find(arr) { for i, v in arr { if (v == 3) { return i } }; -1 }; find([1, 2]);", -1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("expected error %q. got=%+v", expected, evaluated)
+			}
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 	let newAdder = fn(x) {
@@ -376,8 +843,11 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`format("{} and {}", 1)`, "format string has 2 placeholders but got 1 values"},
+		{`format(1)`, "first argument to `format` must be STRING, got INTEGER"},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -399,41 +869,515 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
-func TestArrayLiterals(t *testing.T) {
-	input := "[1, 2 * 2, 3 + 3]"
-	evaluated := testEval(input)
-	result, ok := evaluated.(*object.Array)
-	if !ok {
-		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+func TestColumnAggregates(t *testing.T) {
+	data := "name,amount,price\nann,10,1.5\nbob,,2.25\ncid,25,3\n"
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`sum(csv, "amount")`, 35},
+		{`avg(csv, "amount")`, 17},
+		{`sum(csv, "price")`, 6.75},
+		{`avg(csv, "price")`, 2.25},
+		{`sum(csv, "name")`, "cannot sum non-numeric column name of type STRING"},
+		{`avg(csv, "nope")`, "column not found: nope"},
+		{`sum([1, 2], "amount")`, "first argument to `sum` must be CSV, got ARRAY"},
+		{`avg(filter_rows(csv, fn(row) { false }), "amount")`, "cannot calculate average of empty column"},
 	}
-	if len(result.Elements) != 3 {
-		t.Fatalf("array has wrong num of elements. got=%d",
-			len(result.Elements))
+
+	for _, tt := range tests {
+		evaluated := testEvalWithCSV(t, data, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			float, ok := evaluated.(*object.Float)
+			if !ok || float.Value != expected {
+				t.Errorf("%s wrong. expected=%v, got=%+v", tt.input, expected, evaluated)
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("%s wrong error. expected=%q, got=%+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+
+	data = "name,amount\nann,10\nbob,ten\n"
+	errObj, ok := testEvalWithCSV(t, data, `sum(csv, "amount")`).(*object.Error)
+	if !ok || errObj.Message != `invalid INTEGER "ten" in column amount at row 1` {
+		t.Errorf("expected invalid cell error. got=%+v", errObj)
 	}
-	testIntegerObject(t, result.Elements[0], 1)
-	testIntegerObject(t, result.Elements[1], 4)
-	testIntegerObject(t, result.Elements[2], 6)
 }
 
-func TestArrayIndexExpressions(t *testing.T) {
+func TestTypeBuiltins(t *testing.T) {
 	tests := []struct {
 		input    string
 		expected interface{}
 	}{
-		{
-			"[1, 2, 3][0]",
-			1,
-		},
-		{
-			"[1, 2, 3][1]",
-			2,
-		},
-		{
-			"[1, 2, 3][2]",
-			3,
-		},
-		{
-			"let i = 0; [1][i];",
+		{`type(1)`, "INTEGER"},
+		{`type(1.5)`, "FLOAT"},
+		{`type("a")`, "STRING"},
+		{`type([1])`, "ARRAY"},
+		{`type(null)`, "NULL"},
+		{`type(fn(x) { x })`, "FUNCTION"},
+		{`type(len)`, "BUILTIN"},
+		{`is_int(1)`, true},
+		{`is_int("1")`, false},
+		{`is_float(1.5)`, true},
+		{`is_array([])`, true},
+		{`is_array("a")`, false},
+		{`is_csv([1])`, false},
+		{`is_string("a")`, true},
+		{`is_bool(false)`, true},
+		{`let describe = fn(x) { if (is_array(x)) { len(x) } else { x } }; describe([1, 2, 3]) + describe(4)`, 7},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("expected %q. got=%+v", expected, evaluated)
+			}
+		}
+	}
+
+	testBooleanObject(t, testEvalWithCSV(t, "a\n1\n", `is_csv(csv)`), true)
+	str, ok := testEvalWithCSV(t, "a\n1\n", `type(csv[0])`).(*object.String)
+	if !ok || str.Value != "CSV_ROW" {
+		t.Errorf("expected CSV_ROW. got=%+v", str)
+	}
+}
+
+func TestSortBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`sort([3, 1, 2])`, "[1, 2, 3]"},
+		{`sort([2.5, 1, 2])`, "[1, 2, 2.5]"},
+		{`sort(["pear", "apple", "fig"])`, "[apple, fig, pear]"},
+		{`sort([])`, "[]"},
+		{`sort([1, 2, 3], fn(a, b) { a > b })`, "[3, 2, 1]"},
+		{`sort([3, 10, 2], fn(a, b) { b - a })`, "[10, 3, 2]"},
+		// stable: equal keys keep their order
+		{`sort(["bb", "a", "cc", "d"], fn(a, b) { len(a) < len(b) })`, "[a, d, bb, cc]"},
+		{`let arr = [2, 1]; let sorted = sort(arr); arr`, "[2, 1]"},
+		{`sort([1, "a"])`, "cannot sort STRING and INTEGER without a comparator"},
+		{`sort([1, 2], fn(a, b) { "x" })`, "sort comparator must return BOOLEAN or INTEGER, got STRING"},
+		{`sort([1, 2], fn(a, b) { missing })`, "identifier not found: missing"},
+		{`sort(1)`, "first argument to `sort` must be ARRAY, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		expected := tt.expected.(string)
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+			continue
+		}
+		if evaluated.Inspect() != expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, expected, evaluated.Inspect())
+		}
+	}
+
+	data := "name,age\nAlice,30\nBob,18\nCarol,25\n"
+	input := `
+	let rows = []
+	for i, row in csv { rows = push(rows, row) }
+	let sorted = sort(rows, fn(a, b) { a.age < b.age })
+	sorted[0]["name"] + sorted[2]["name"]`
+	str, ok := testEvalWithCSV(t, data, input).(*object.String)
+	if !ok || str.Value != "BobAlice" {
+		t.Errorf("rows sorted wrong. got=%+v", str)
+	}
+}
+
+func TestArrayBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`reverse([1, 2, 3])`, "[3, 2, 1]"},
+		{`reverse([])`, "[]"},
+		{`reverse("abc")`, "cba"},
+		{`let arr = [1, 2]; let r = reverse(arr); arr`, "[1, 2]"},
+		{`index_of([5, 6, 7], 6)`, "1"},
+		{`index_of([5, 6, 7], 8)`, "-1"},
+		{`index_of(["a", "b"], "b")`, "1"},
+		{`index_of([1, 2.0], 2)`, "1"},
+		{`index_of("hello", "ll")`, "2"},
+		{`contains([1, 2, 3], 3)`, "true"},
+		{`contains([1, 2, 3], "3")`, "false"},
+		{`contains("hello", "world")`, "false"},
+		{`slice([1, 2, 3, 4], 1, 3)`, "[2, 3]"},
+		{`slice([1, 2, 3, 4], 2)`, "[3, 4]"},
+		{`slice([1, 2, 3, 4], -2)`, "[3, 4]"},
+		{`slice([1, 2, 3, 4], 3, 1)`, "[]"},
+		{`slice("hello", 1, 10)`, "ello"},
+		{`zip([1, 2, 3], ["a", "b", "c"])`, "[[1, a], [2, b], [3, c]]"},
+		{`zip([1, 2, 3], ["a"], [true, false])`, "[[1, a, true]]"},
+		{`head([1, 2, 3], 2)`, "[1, 2]"},
+		{`head([1, 2, 3], 5)`, "[1, 2, 3]"},
+		{`tail([1, 2, 3], 2)`, "[2, 3]"},
+		{`tail([1, 2, 3], 0)`, "[]"},
+		{`head(range(0, 20))`, "[0, 1, 2, 3, 4, 5, 6, 7, 8, 9]"},
+		{`head("abc", 1)`, "ERROR: first argument to `head` must be ARRAY or CSV, got STRING"},
+		{`tail([1], -1)`, "ERROR: count of `tail` must be a non-negative INTEGER, got -1"},
+		{`reverse(1)`, "ERROR: argument to `reverse` must be ARRAY or STRING, got INTEGER"},
+		{`index_of(1, 1)`, "ERROR: first argument to `index_of` must be ARRAY or STRING, got INTEGER"},
+		{`contains("abc", 1)`, "ERROR: second argument to `contains` must be STRING when searching a STRING, got INTEGER"},
+		{`slice([1], "a")`, "ERROR: slice bound must be INTEGER, got STRING"},
+		{`zip([1], 2)`, "ERROR: arguments to `zip` must be ARRAY, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMathBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`abs(-5)`, "5"},
+		{`abs(5)`, "5"},
+		{`abs(-2.5)`, "2.5"},
+		{`floor(2.7)`, "2"},
+		{`floor(-2.2)`, "-3"},
+		{`floor(4)`, "4"},
+		{`ceil(2.1)`, "3"},
+		{`ceil(-2.7)`, "-2"},
+		{`round(2.5)`, "3"},
+		{`round(-2.5)`, "-3"},
+		{`round(2.4)`, "2"},
+		{`round(3.14159, 2)`, "3.14"},
+		{`round(2.675, 1)`, "2.7"},
+		{`round(19.999, 2)`, "20"},
+		{`round(7, 2)`, "7"},
+		{`round(10 / 4.0, 0)`, "3"},
+		{`is_int(round(2.5))`, "true"},
+		{`is_float(round(2.5, 1))`, "true"},
+		{`abs("a")`, "ERROR: argument to `abs` must be INTEGER or FLOAT, got STRING"},
+		{`floor("a")`, "ERROR: argument to `floor` must be INTEGER or FLOAT, got STRING"},
+		{`round(1.5, 1.5)`, "ERROR: precision of `round` must be INTEGER, got FLOAT"},
+		{`round(1.5, -1)`, "ERROR: precision of `round` must be between 0 and 15, got -1"},
+		{`ceil(10000000000.5 * 10000000000.0)`, "ERROR: ceil(100000000005000000000) does not fit in an INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestRegexBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`regex_match("12 Main St", "^[0-9]+ ")`, "true"},
+		{`regex_match("Main St", "^[0-9]+ ")`, "false"},
+		{`regex_match(94107, "^[0-9]{5}$")`, "true"},
+		{`regex_extract("Springfield, IL 62704", "[A-Z]{2}")`, "IL"},
+		{`regex_extract("Springfield, IL 62704", "([A-Z]{2}) ([0-9]{5})", 2)`, "62704"},
+		{`regex_extract("no digits", "[0-9]+")`, "null"},
+		{`regex_extract("a", "(b)?a", 1)`, "null"},
+		{`regex_replace("a  b   c", " +", " ")`, "a b c"},
+		{`regex_replace("Doe, John", "(\\w+), (\\w+)", "$2 $1")`, "John Doe"},
+		{`regex_match("a", "(")`, "ERROR: invalid pattern (: error parsing regexp: missing closing ): `(`"},
+		{`regex_match([1], "a")`, "ERROR: first argument to `regex_match` must be STRING, got ARRAY"},
+		{`regex_extract("a", "a", 1)`, "ERROR: pattern a has no group 1"},
+		{`regex_replace("a", "a", 1)`, "ERROR: replacement of `regex_replace` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	first, _ := compileRegex("[a-z]+")
+	second, _ := compileRegex("[a-z]+")
+	if first != second {
+		t.Errorf("expected compiled pattern to be cached")
+	}
+}
+
+func TestSplitAndMergeColumns(t *testing.T) {
+	data := "id,full_name,city,state\n1,Ada Lovelace,London,\n2,Alan Mathison Turing,Wilmslow,Cheshire\n3,Plato,Athens,Attica\n"
+
+	split, ok := testEvalWithCSV(t, data, `split_column(csv, "full_name", " ", ["first", "last"])`).(*object.CSV)
+	if !ok {
+		t.Fatalf("object is not CSV. got=%T", split)
+	}
+	if strings.Join(split.Headers, ",") != "id,first,last,city,state" {
+		t.Errorf("wrong headers. got=%v", split.Headers)
+	}
+	if split.Rows[1]["first"] != "Alan" || split.Rows[1]["last"] != "Mathison Turing" || split.Rows[2]["last"] != "" {
+		t.Errorf("wrong split rows. got=%+v", split.Rows)
+	}
+	if len(split.ColumnTypes) != 5 || split.ColumnTypes[0].DataType != object.INTEGER_OBJ || split.ColumnTypes[1].Name != "first" || split.ColumnTypes[1].DataType != object.STRING_OBJ {
+		t.Errorf("wrong column types. got=%+v", split.ColumnTypes)
+	}
+
+	merged, ok := testEvalWithCSV(t, data, `merge_columns(csv, ["city", "state"], ", ", "location")`).(*object.CSV)
+	if !ok {
+		t.Fatalf("object is not CSV. got=%T", merged)
+	}
+	if strings.Join(merged.Headers, ",") != "id,full_name,location" {
+		t.Errorf("wrong headers. got=%v", merged.Headers)
+	}
+	if merged.Rows[0]["location"] != "London" || merged.Rows[1]["location"] != "Wilmslow, Cheshire" {
+		t.Errorf("wrong merged rows. got=%+v", merged.Rows)
+	}
+	if _, ok := merged.Rows[0]["city"]; ok {
+		t.Errorf("merged columns should be removed. got=%+v", merged.Rows[0])
+	}
+
+	// the input CSV is left untouched
+	original, ok := testEvalWithCSV(t, data, `let s = split_column(csv, "full_name", " ", ["first", "last"]); csv`).(*object.CSV)
+	if !ok || len(original.Headers) != 4 || original.Rows[0]["full_name"] != "Ada Lovelace" {
+		t.Errorf("input CSV was modified. got=%+v", original)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`split_column(csv, "missing", " ", ["a"])`, "column not found: missing"},
+		{`split_column(csv, "full_name", " ", ["id", "last"])`, "column already exists: id"},
+		{`split_column(csv, "full_name", "", ["a"])`, "separator of `split_column` must be a non-empty STRING, got "},
+		{`split_column([1], "full_name", " ", ["a"])`, "first argument to `split_column` must be CSV, got ARRAY"},
+		{`merge_columns(csv, ["city", "nope"], " ", "x")`, "column not found: nope"},
+		{`merge_columns(csv, ["city", "state"], " ", "id")`, "column already exists: id"},
+		{`merge_columns(csv, [], " ", "x")`, "column names of `merge_columns` must be a non-empty ARRAY, got []"},
+	}
+
+	for _, tt := range errorTests {
+		errObj, ok := testEvalWithCSV(t, data, tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("expected error %q. got=%+v", tt.expected, errObj)
+		}
+	}
+}
+
+func TestOneHot(t *testing.T) {
+	data := "id,color,size\n1,red,S\n2,blue,M\n3,,L\n4,red,S\n"
+
+	encoded, ok := testEvalWithCSV(t, data, `one_hot(csv, "color")`).(*object.CSV)
+	if !ok {
+		t.Fatalf("object is not CSV. got=%T", encoded)
+	}
+	if strings.Join(encoded.Headers, ",") != "id,color_red,color_blue,size" {
+		t.Errorf("wrong headers. got=%v", encoded.Headers)
+	}
+
+	expected := []string{"true,false", "false,true", "false,false", "true,false"}
+	for i, row := range encoded.Rows {
+		if got := row["color_red"] + "," + row["color_blue"]; got != expected[i] {
+			t.Errorf("row %d wrong. expected=%s, got=%s", i, expected[i], got)
+		}
+		if _, ok := row["color"]; ok {
+			t.Errorf("encoded column should be removed. got=%+v", row)
+		}
+	}
+	if encoded.ColumnTypes[1].DataType != object.BOOLEAN_OBJ || encoded.ColumnTypes[3].DataType != object.STRING_OBJ {
+		t.Errorf("wrong column types. got=%+v", encoded.ColumnTypes)
+	}
+
+	errObj, ok := testEvalWithCSV(t, "color,color_red\nred,x\n", `one_hot(csv, "color")`).(*object.Error)
+	if !ok || errObj.Message != "column already exists: color_red" {
+		t.Errorf("expected column already exists error. got=%+v", errObj)
+	}
+}
+
+func TestNormalizationBuiltins(t *testing.T) {
+	data := "name,score\na,10\nb,20\nc,\nd,30\ne,40\n"
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`normalize(csv, "score")`, []string{"0", "0.3333333333333333", "", "0.6666666666666666", "1"}},
+		{`normalize(csv, "score", "zero")`, []string{"0.25", "0.5", "0", "0.75", "1"}},
+		{`normalize(csv, "score", "mean")`, []string{"0", "0.3333333333333333", "0.5", "0.6666666666666666", "1"}},
+		{`zscore(csv, "score")`, []string{"-1.3416407864998738", "-0.4472135954999579", "", "0.4472135954999579", "1.3416407864998738"}},
+		{`zscore(csv, "score", "mean")`, []string{"-1.5", "-0.5", "0", "0.5", "1.5"}},
+	}
+
+	for _, tt := range tests {
+		scaled, ok := testEvalWithCSV(t, data, tt.input).(*object.CSV)
+		if !ok {
+			t.Fatalf("object is not CSV for %s", tt.input)
+		}
+		for i, row := range scaled.Rows {
+			if row["score"] != tt.expected[i] {
+				t.Errorf("%s row %d wrong. expected=%s, got=%s", tt.input, i, tt.expected[i], row["score"])
+			}
+		}
+		if scaled.ColumnTypes[1].DataType != object.FLOAT_OBJ {
+			t.Errorf("%s score should be FLOAT. got=%s", tt.input, scaled.ColumnTypes[1].DataType)
+		}
+	}
+
+	constant, ok := testEvalWithCSV(t, "x\n5\n5\n", `normalize(csv, "x")`).(*object.CSV)
+	if !ok || constant.Rows[0]["x"] != "0" || constant.Rows[1]["x"] != "0" {
+		t.Errorf("constant column should normalize to 0. got=%+v", constant)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`normalize(csv, "score", "error")`, "column score has an empty value in row 2"},
+		{`normalize(csv, "score", "drop")`, "unknown policy \"drop\" for empty cells, want skip, zero, mean or error"},
+		{`zscore(csv, "name")`, "column name has non-numeric value \"a\" in row 0"},
+		{`zscore(csv, "missing")`, "column not found: missing"},
+	}
+
+	for _, tt := range errorTests {
+		errObj, ok := testEvalWithCSV(t, data, tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("expected error %q. got=%+v", tt.expected, errObj)
+		}
+	}
+}
+
+func TestWindowBuiltins(t *testing.T) {
+	data := "day,price\n1,10\n2,20\n3,\n4,40\n5,50\n6,60\n"
+
+	tests := []struct {
+		input    string
+		column   string
+		expected []string
+	}{
+		{`lag(csv, "price")`, "price_lag_1", []string{"", "10", "20", "", "40", "50"}},
+		{`lag(csv, "price", 2)`, "price_lag_2", []string{"", "", "10", "20", "", "40"}},
+		{`lead(csv, "price", 1, "next_price")`, "next_price", []string{"20", "", "40", "50", "60", ""}},
+		{`rolling_avg(csv, "price", 2)`, "price_rolling_avg_2", []string{"", "15", "", "", "45", "55"}},
+		{`rolling_avg(csv, "price", 3)`, "price_rolling_avg_3", []string{"", "", "", "", "", "50"}},
+	}
+
+	for _, tt := range tests {
+		result, ok := testEvalWithCSV(t, data, tt.input).(*object.CSV)
+		if !ok {
+			t.Fatalf("object is not CSV for %s", tt.input)
+		}
+		if result.Headers[len(result.Headers)-1] != tt.column {
+			t.Errorf("%s wrong new column. expected=%s, got=%v", tt.input, tt.column, result.Headers)
+		}
+		for i, row := range result.Rows {
+			if row[tt.column] != tt.expected[i] {
+				t.Errorf("%s row %d wrong. expected=%q, got=%q", tt.input, i, tt.expected[i], row[tt.column])
+			}
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`lag(csv, "price", 0)`, "size of `lag` must be a positive INTEGER, got 0"},
+		{`lead(csv, "price", 1, "day")`, "column already exists: day"},
+		{`rolling_avg(csv, "nope", 2)`, "column not found: nope"},
+	}
+
+	for _, tt := range errorTests {
+		errObj, ok := testEvalWithCSV(t, data, tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("expected error %q. got=%+v", tt.expected, errObj)
+		}
+	}
+}
+
+func TestRankBuiltins(t *testing.T) {
+	data := "player,score\nann,30\nbob,50\ncid,\ndan,30\neve,9\n"
+
+	tests := []struct {
+		input    string
+		column   string
+		expected []string
+	}{
+		{`row_number(csv)`, "row_number", []string{"1", "2", "3", "4", "5"}},
+		{`row_number(csv, "id")`, "id", []string{"1", "2", "3", "4", "5"}},
+		{`rank(csv, "score")`, "score_rank", []string{"2", "4", "", "2", "1"}},
+		{`rank(csv, "score", "desc")`, "score_rank", []string{"2", "1", "", "2", "4"}},
+		{`rank(csv, "player", "asc", "position")`, "position", []string{"1", "2", "3", "4", "5"}},
+	}
+
+	for _, tt := range tests {
+		result, ok := testEvalWithCSV(t, data, tt.input).(*object.CSV)
+		if !ok {
+			t.Fatalf("object is not CSV for %s", tt.input)
+		}
+		for i, row := range result.Rows {
+			if row[tt.column] != tt.expected[i] {
+				t.Errorf("%s row %d wrong. expected=%q, got=%q", tt.input, i, tt.expected[i], row[tt.column])
+			}
+		}
+		if result.ColumnTypes[len(result.ColumnTypes)-1].DataType != object.INTEGER_OBJ {
+			t.Errorf("%s new column should be INTEGER. got=%+v", tt.input, result.ColumnTypes)
+		}
+	}
+
+	errObj, ok := testEvalWithCSV(t, data, `rank(csv, "score", "up")`).(*object.Error)
+	if !ok || errObj.Message != "order of `rank` must be \"asc\" or \"desc\", got up" {
+		t.Errorf("expected order error. got=%+v", errObj)
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d",
+			len(result.Elements))
+	}
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			"[1, 2, 3][0]",
+			1,
+		},
+		{
+			"[1, 2, 3][1]",
+			2,
+		},
+		{
+			"[1, 2, 3][2]",
+			3,
+		},
+		{
+			"let i = 0; [1][i];",
 			1,
 		},
 		{
@@ -472,34 +1416,2842 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
-func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
-	result, ok := obj.(*object.Boolean)
-	if !ok {
-		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
-		return false
+func TestNestedIndexAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let m = [[1, 2, 3], [4, 5, 6]]; m[1][2] = 50; m[1][2];", 50},
+		{"let m = [[1, 2, 3], [4, 5, 6]]; m[0][0] += 10; m[0][0];", 11},
+		{"let m = [[[1]]]; m[0][0][0] = 7; m[0][0][0];", 7},
+		{"let m = [[1, 2], [3, 4]]; let i = 1; m[i][i - 1] = 9; m[1][0] + m[0][0];", 10},
 	}
-	if result.Value != expected {
-		t.Errorf("object has wrong value. got=%t, want=%t",
-			result.Value, expected)
-		return false
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
 	}
-	return true
+
+	errObj, ok := testEval("let m = [1, 2]; m[0][0] = 5;").(*object.Error)
+	if !ok || errObj.Message != "index assignment not supported for type: INTEGER" {
+		t.Errorf("expected index assignment error. got=%+v", errObj)
+	}
+
+	data := "name,age\nAlice,17\nBob,18\n"
+	testIntegerObject(t, testEvalWithCSV(t, data, `csv[1]["age"] = 31; csv[1]["age"]`), 31)
+	testIntegerObject(t, testEvalWithCSV(t, data, `csv[0]["age"] += 1; csv[0]["age"]`), 18)
 }
 
-func testEval(input string) object.Object {
-	l := lexer.New(input)
-	p := parser.New(l)
-	program := p.ParseProgram()
-	fmt.Printf("\nprogram: %s\n", program.String())
-	env := object.NewEnvironment()
+func TestReadWhereComparisonOperators(t *testing.T) {
+	data := "name,age,price\nAlice,17,9.99\nBob,18,12.5\nCarol,30,10\n"
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"read row * where age >= 18", 2},
+		{"read row * where age <= 18", 2},
+		{"read row * where age > 18", 1},
+		{"read row * where age < 18", 1},
+		{`read row * where name >= "Bob"`, 2},
+		{"read row * where price > 9.99", 2},
+		{"read row * where price <= 9.99", 1},
+		{"read row * where price == 12.5", 1},
+		// integer values compare against the fractional part of FLOAT cells
+		{"read row * where price > 12", 1},
+		{"read row * where age < 17.5", 1},
+	}
+	for _, tt := range tests {
+		evaluated := testEvalWithCSV(t, data, tt.input)
+		csv, ok := evaluated.(*object.CSV)
+		if !ok {
+			t.Errorf("object is not CSV. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if len(csv.Rows) != tt.expected {
+			t.Errorf("%q: wrong number of rows. got=%d, want=%d", tt.input, len(csv.Rows), tt.expected)
+		}
+	}
+}
 
-	return Eval(program, env)
+func TestReadWhereLogicalOperators(t *testing.T) {
+	data := "name,age,status\nAlice,17,ok\nBob,18,failed\nCarol,30,ok\n"
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{`read row * where age >= 18 and status == "ok"`, 1},
+		{`read row * where age < 18 or name == "Bob"`, 2},
+		{`read row * where not (status == "ok")`, 1},
+		{`read row * where !(age > 20) && status == "ok"`, 1},
+		{`read row * where age > 10 and (name == "Bob" or name == "Carol")`, 2},
+	}
+	for _, tt := range tests {
+		evaluated := testEvalWithCSV(t, data, tt.input)
+		csv, ok := evaluated.(*object.CSV)
+		if !ok {
+			t.Errorf("object is not CSV. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if len(csv.Rows) != tt.expected {
+			t.Errorf("%q: wrong number of rows. got=%d, want=%d", tt.input, len(csv.Rows), tt.expected)
+		}
+	}
 }
 
-// func testEval(input string, env *object.Environment) object.Object {
-// 	l := lexer.New(input)
-// 	p := parser.New(l)
-// 	program := p.ParseProgram()
+func TestReadWhereInvalidNumbers(t *testing.T) {
+	data := "name,age\nAlice,17\nBob,\nCarol,n/a\nDan,30\n"
 
-// 	return Eval(program, env)
+	errObj, ok := testEvalWithCSV(t, data, "read row * where age > 18").(*object.Error)
+	if !ok || errObj.Message != `invalid number "n/a" in column age at row 2` {
+		t.Errorf("expected invalid number error. got=%+v", errObj)
+	}
+
+	// the numeric comparison is never reached for the invalid cell
+	csv, ok := testEvalWithCSV(t, data, `read row * where name != "Carol" and age > 18`).(*object.CSV)
+	if !ok || len(csv.Rows) != 1 {
+		t.Errorf("expected 1 row. got=%+v", csv)
+	}
+
+	LenientNumbers = true
+	defer func() { LenientNumbers = false }()
+	csv, ok = testEvalWithCSV(t, data, "read row * where age > 18").(*object.CSV)
+	if !ok || len(csv.Rows) != 1 || csv.Rows[0]["name"] != "Dan" {
+		t.Errorf("expected only Dan in lenient mode. got=%+v", csv)
+	}
+}
+
+func TestCaseInsensitiveComparisons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"Bob" ==* "bOB"`, true},
+		{`"Bob" ==* "Bobby"`, false},
+		{`"Bob" !=* "BOB"`, false},
+		{`"Bob" !=* "Alice"`, true},
+	}
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+
+	data := "name , city\n  Bob , Paris\nALICE,paris \nbob,Rome\n"
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rowTests := []struct {
+		input    string
+		expected int
+	}{
+		{`read row * where name ==* "bob"`, 2},
+		{`read row * where name !=* "BOB"`, 1},
+		{`read row * where city == "Paris"`, 1},
+		{`read row * where city ==* "PARIS"`, 2},
+	}
+	for _, tt := range rowTests {
+		csv, ok := testEval(fmt.Sprintf("load %q trim\n%s", path, tt.input)).(*object.CSV)
+		if !ok || len(csv.Rows) != tt.expected {
+			t.Errorf("%q: expected %d rows. got=%+v", tt.input, tt.expected, csv)
+		}
+	}
+
+	// without trim the padded cells don't match
+	csv, ok := testEval(fmt.Sprintf("load %q\nread row * where city ==* \"paris\"", path)).(*object.CSV)
+	if !ok || len(csv.Rows) != 0 || csv.Headers[1] != " city" {
+		t.Errorf("expected untrimmed cells and headers. got=%+v", csv)
+	}
+}
+
+func TestLocaleNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`parse_number("1.234,56", "de")`, 1234.56},
+		{`parse_number("-1.234.567", "de")`, -1234567},
+		{`parse_number("1234,5", "de")`, 1234.5},
+		{`parse_number("1,234.5", "en")`, 1234.5},
+		{`parse_number("1 234,5", "fr")`, 1234.5},
+		{`parse_number("1.23,4", "de")`, `could not parse "1.23,4" as a de number`},
+		{`parse_number("1,5", "xx")`, "unknown number locale: xx, supported locales are de, en, es, fr, it, nl, pt"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			float, ok := evaluated.(*object.Float)
+			if !ok || float.Value != expected {
+				t.Errorf("%s wrong. expected=%v, got=%+v", tt.input, expected, evaluated)
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("%s wrong error. expected=%q, got=%+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte("item,price,date\na,\"1.234,50\",01.02.2024\nb,\"9,99\",03.02.2024\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	csv, ok := testEval(fmt.Sprintf("load %q numbers \"de\"\nread row * where price > 10", path)).(*object.CSV)
+	if !ok || len(csv.Rows) != 1 || csv.Rows[0]["price"] != "1234.50" || csv.Rows[0]["date"] != "01.02.2024" {
+		t.Fatalf("expected the normalized row. got=%+v", csv)
+	}
+	if csv.ColumnTypes[1].DataType != object.FLOAT_OBJ {
+		t.Errorf("price should be inferred as FLOAT. got=%+v", csv.ColumnTypes)
+	}
+}
+
+func TestNumberScrubbing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`to_number("$1,234.50")`, 1234.5},
+		{`to_number("€ 1,000")`, 1000},
+		{`to_number("-42")`, -42},
+		{`to_number("(12.25)")`, -12.25},
+		{`to_number(7)`, 7},
+		{`to_number("12abc")`, `could not convert "12abc" to a number`},
+		{`to_number("NaN")`, `could not convert "NaN" to a number`},
+		{`to_number("1,234,567")`, 1234567},
+		{`to_number("1,23")`, `could not convert "1,23" to a number`},
+		{`to_number("1,2,3")`, `could not convert "1,2,3" to a number`},
+		{`to_number("1.5,000")`, `could not convert "1.5,000" to a number`},
+		{`to_number([1])`, "argument to `to_number` must be STRING, got ARRAY"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			float, ok := evaluated.(*object.Float)
+			if !ok || float.Value != expected {
+				t.Errorf("%s wrong. expected=%v, got=%+v", tt.input, expected, evaluated)
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("%s wrong error. expected=%q, got=%+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+
+	data := "item,amount\na,\"$1,200.00\"\nb,\nc,($5.50)\n"
+	cleaned, ok := testEvalWithCSV(t, data, `clean_numeric(csv, "amount")`).(*object.CSV)
+	if !ok {
+		t.Fatalf("object is not CSV")
+	}
+	expected := []string{"1200.00", "", "-5.50"}
+	for i, row := range cleaned.Rows {
+		if row["amount"] != expected[i] {
+			t.Errorf("row %d wrong. expected=%q, got=%q", i, expected[i], row["amount"])
+		}
+	}
+	if cleaned.ColumnTypes[1].DataType != object.FLOAT_OBJ {
+		t.Errorf("amount should be FLOAT. got=%+v", cleaned.ColumnTypes)
+	}
+
+	errObj, ok := testEvalWithCSV(t, "item,amount\na,n/a\n", `clean_numeric(csv, "amount")`).(*object.Error)
+	if !ok || errObj.Message != `could not convert "n/a" in column amount at row 0 to a number` {
+		t.Errorf("expected conversion error. got=%+v", errObj)
+	}
+}
+
+func TestReadColumn(t *testing.T) {
+	data := "name,age,amount\nAnn,30,100\nBob,17,200\nCid,40,300\n"
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`read row * col name`, []string{"Ann", "Bob", "Cid"}},
+		{`read row * col 1`, []string{"30", "17", "40"}},
+		{`read row 1 col name`, []string{"Bob"}},
+		{`read row * col name where age > 20`, []string{"Ann", "Cid"}},
+		// the projected column can be filtered on as well
+		{`read row * col name where name != "Bob" and name != "Cid"`, []string{"Ann"}},
+		{`read row * col age where age > 20 and age < 35`, []string{"30"}},
+	}
+
+	for _, tt := range tests {
+		csv, ok := testEvalWithCSV(t, data, tt.input).(*object.CSV)
+		if !ok {
+			t.Errorf("%q: object is not CSV", tt.input)
+			continue
+		}
+		if len(csv.Headers) != 1 || len(csv.Rows) != len(tt.expected) {
+			t.Errorf("%q: expected 1 column and %d rows. got=%+v", tt.input, len(tt.expected), csv)
+			continue
+		}
+		for i, row := range csv.Rows {
+			if row[csv.Headers[0]] != tt.expected[i] {
+				t.Errorf("%q: row %d wrong. expected=%q, got=%q", tt.input, i, tt.expected[i], row[csv.Headers[0]])
+			}
+		}
+	}
+
+	csv := testEvalWithCSV(t, data, `read row * col age`).(*object.CSV)
+	if csv.Headers[0] != "age" || csv.ColumnTypes[0].DataType != object.INTEGER_OBJ {
+		t.Errorf("column should keep its name and type. got=%+v", csv)
+	}
+
+	testIntegerObject(t, testEvalWithCSV(t, data, `sum(read row * col amount where age > 20)`), 400)
+	testIntegerObject(t, testEvalWithCSV(t, data, `avg(read row * col amount)`), 200)
+
+	errObj, ok := testEvalWithCSV(t, data, `read row * col email`).(*object.Error)
+	if !ok || errObj.Message != "column not found: email" {
+		t.Errorf("expected column not found error. got=%+v", errObj)
+	}
+}
+
+func TestReadFrom(t *testing.T) {
+	data := "name,age,region\nAnn,30,EU\nBob,17,US\nCid,40,EU\nDan,25,EU\n"
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let eu = read row * where region == "EU"; read from eu row * col name where age > 26`, []string{"Ann", "Cid"}},
+		{`let eu = read row * where region == "EU"; let older = read from eu row * where age > 26; read from older row 1 col name`, []string{"Cid"}},
+		{`let adults = read row * where age >= 18; let young = read from adults row * where age < 30; read from young row * col name`, []string{"Dan"}},
+	}
+
+	for _, tt := range tests {
+		csv, ok := testEvalWithCSV(t, data, tt.input).(*object.CSV)
+		if !ok || len(csv.Rows) != len(tt.expected) {
+			t.Errorf("%q: expected %d rows. got=%+v", tt.input, len(tt.expected), csv)
+			continue
+		}
+		for i, row := range csv.Rows {
+			if row["name"] != tt.expected[i] {
+				t.Errorf("%q: row %d wrong. expected=%q, got=%q", tt.input, i, tt.expected[i], row["name"])
+			}
+		}
+	}
+
+	errObj, ok := testEvalWithCSV(t, data, `let n = 5; read from n row *`).(*object.Error)
+	if !ok || errObj.Message != "cannot read from n: expected CSV, got INTEGER" {
+		t.Errorf("expected read from error. got=%+v", errObj)
+	}
+	errObj, ok = testEvalWithCSV(t, data, `read from missing row *`).(*object.Error)
+	if !ok || errObj.Message != "identifier not found: missing" {
+		t.Errorf("expected identifier error. got=%+v", errObj)
+	}
+}
+
+func TestPipeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"[1, 2, 3] |> len", 3},
+		{"[1, 2, 3] |> filter(fn(x) { x > 1 }) |> len()", 2},
+		{"let add = fn(a, b) { a + b }; 1 |> add(2) |> add(3)", 6},
+		{"5 |> fn(x) { x * 2 }", 10},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	data := "name,age\nAnn,30\nBob,17\nCid,40\n"
+	out := filepath.Join(t.TempDir(), "out.csv")
+	input := fmt.Sprintf(`read row * |> filter(fn(r) { r.age > 20 }) |> select(["name"]) |> save(%q)
+load %q
+csv`, out, out)
+	saved, ok := testEvalWithCSV(t, data, input).(*object.CSV)
+	if !ok || len(saved.Headers) != 1 || saved.Headers[0] != "name" || len(saved.Rows) != 2 || saved.Rows[1]["name"] != "Cid" {
+		t.Errorf("pipeline should save the filtered names. got=%+v", saved)
+	}
+
+	errObj, ok := testEval("1 |> 2").(*object.Error)
+	if !ok || errObj.Message != "not a function: INTEGER" {
+		t.Errorf("expected not a function error. got=%+v", errObj)
+	}
+}
+
+func TestMethodCalls(t *testing.T) {
+	data := "name,age\nAnn,30\nBob,17\nCid,40\nDan,25\n"
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`csv.filter(fn(row) { row.age > 18 }).sort("age").head(2)`, []string{"Dan", "Ann"}},
+		{`csv.sort("age", "desc").head(1)`, []string{"Cid"}},
+		{`csv.tail(2)`, []string{"Cid", "Dan"}},
+		{`let adults = read row * where age >= 18; adults.sort("name", "desc")`, []string{"Dan", "Cid", "Ann"}},
+	}
+	for _, tt := range tests {
+		csv, ok := testEvalWithCSV(t, data, tt.input).(*object.CSV)
+		if !ok || len(csv.Rows) != len(tt.expected) {
+			t.Errorf("%q: expected %d rows. got=%+v", tt.input, len(tt.expected), csv)
+			continue
+		}
+		for i, row := range csv.Rows {
+			if row["name"] != tt.expected[i] {
+				t.Errorf("%q: row %d wrong. expected=%q, got=%q", tt.input, i, tt.expected[i], row["name"])
+			}
+		}
+	}
+
+	testIntegerObject(t, testEval("let a = [3, 1, 2]; a.sort().head(1)[0]"), 1)
+	testIntegerObject(t, testEval("let double = fn(x) { x * 2 }; let n = 4; n.double()"), 8)
+
+	errObj, ok := testEvalWithCSV(t, data, `csv.nope()`).(*object.Error)
+	if !ok || errObj.Message != "unknown method nope for CSV" {
+		t.Errorf("expected unknown method error. got=%+v", errObj)
+	}
+}
+
+func TestColumnRoundTrip(t *testing.T) {
+	data := "name,age,amount\nAnn,30,100\nBob,17,200\n"
+	out := filepath.Join(t.TempDir(), "ages.csv")
+
+	input := fmt.Sprintf("let ages = read row * col age;\nsave ages as %q;\nload %q\ncsv", out, out)
+	loaded, ok := testEvalWithCSV(t, data, input).(*object.CSV)
+	if !ok {
+		t.Fatalf("object is not CSV")
+	}
+	if len(loaded.Headers) != 1 || loaded.Headers[0] != "age" || loaded.ColumnTypes[0].DataType != object.INTEGER_OBJ {
+		t.Errorf("saved column should keep its header and type. got=%+v", loaded)
+	}
+	if len(loaded.Rows) != 2 || loaded.Rows[1]["age"] != "17" {
+		t.Errorf("saved column rows wrong. got=%+v", loaded.Rows)
+	}
+
+	column, ok := testEvalWithCSV(t, data, `csv["amount"]`).(*object.CSV)
+	if !ok || len(column.Headers) != 1 || column.Headers[0] != "amount" || len(column.Rows) != 2 {
+		t.Errorf("csv[\"amount\"] should be a one column CSV. got=%+v", column)
+	}
+	testIntegerObject(t, testEvalWithCSV(t, data, `sum(csv["amount"])`), 300)
+}
+
+func TestSaveDestinations(t *testing.T) {
+	data := "name,region\nAnn,EU\nBob,US\nCid,EU\nDee,\n"
+	dir := t.TempDir()
+
+	input := fmt.Sprintf("save csv as %q, %q", filepath.Join(dir, "all.csv"), filepath.Join(dir, "all.json"))
+	if result := testEvalWithCSV(t, data, input); isError(result) {
+		t.Fatalf("save with several destinations failed: %s", result.Inspect())
+	}
+	for _, name := range []string{"all.csv", "all.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was not written: %s", name, err)
+		}
+	}
+
+	input = fmt.Sprintf("save csv split by region as %q", filepath.Join(dir, "out_{region}.csv"))
+	if result := testEvalWithCSV(t, data, input); isError(result) {
+		t.Fatalf("split save failed: %s", result.Inspect())
+	}
+	expected := map[string]string{
+		"out_EU.csv":    "name,region\nAnn,EU\nCid,EU\n",
+		"out_US.csv":    "name,region\nBob,US\n",
+		"out_empty.csv": "name,region\nDee,\n",
+	}
+	for name, want := range expected {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("%s was not written: %s", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s content wrong. want=%q, got=%q", name, want, got)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`save csv split by country as "out_{country}.csv"`, "column not found: country"},
+		{`save csv split by region as "out.csv"`, "filename out.csv must contain {region} to split by region"},
+	}
+	for _, tt := range errorTests {
+		errObj, ok := testEvalWithCSV(t, data, tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("%q: wrong error. want=%q, got=%v", tt.input, tt.expected, errObj)
+		}
+	}
+}
+
+func TestUnload(t *testing.T) {
+	data := "name,age\nAnn,30\nBo,12\n"
+	tests := []struct {
+		input    string
+		expected string // the error, empty when the input evaluates to NULL
+	}{
+		{`unload csv; csv`, "identifier not found: csv"},
+		{`let rows = csv; unload csv; count(rows)`, ""},
+		{`let data = csv; free(csv); data`, "identifier not found: data"},
+		{`let rows = csv; let kept = unique(csv); free(rows); count(kept)`, ""},
+		{`let n = 1; if (true) { unload n }; n`, "identifier not found: n"},
+		{`const LIMIT = 1; unload LIMIT`, "cannot unload constant LIMIT"},
+		{`unload nope`, "identifier not found: nope"},
+		{`free()`, "wrong number of arguments: got=0, want at least 1"},
+	}
+	for _, tt := range tests {
+		result := testEvalWithCSV(t, data, tt.input)
+		errObj, isErr := result.(*object.Error)
+		switch {
+		case tt.expected == "" && isErr:
+			t.Errorf("%s: unexpected error %s", tt.input, errObj.Message)
+		case tt.expected != "" && (!isErr || errObj.Message != tt.expected):
+			t.Errorf("%s: expected error %q, got %s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestSaveFrom(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "big.csv")
+	if err := os.WriteFile(source, []byte("id,status,code\n1,ok,200\n2,failed,500\n3,failed,404\n4,ok,\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "failed.csv")
+	input := fmt.Sprintf(`save from %q where status == "failed" and code >= 500 as %q`, source, out)
+	if result := testEval(input); isError(result) {
+		t.Fatalf("save from failed: %s", result.Inspect())
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "id,status,code\n2,failed,500\n" {
+		t.Errorf("wrong rows saved. got=%q", got)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{fmt.Sprintf("save from %q as %q", source, filepath.Join(dir, "out.json")), "rows saved from a file can only be written to CSV files, got " + filepath.Join(dir, "out.json")},
+		{fmt.Sprintf(`save from %q where status > 1 as %q`, source, out), `invalid number "ok" in column status at row 0`},
+		{fmt.Sprintf("save from %q as %q", filepath.Join(dir, "missing.csv"), out), "could not open file: open " + filepath.Join(dir, "missing.csv") + ": no such file or directory"},
+	}
+	for _, tt := range errorTests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("%q: wrong error. want=%q, got=%v", tt.input, tt.expected, errObj)
+		}
+	}
+}
+
+func TestLoadMapped(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "big.csv")
+	// a quoted cell spanning lines is a single row, blank lines aren't rows
+	if err := os.WriteFile(source, []byte("id,status,note\n1,ok,\"two\nlines\"\n\n2,failed,\n3,failed,\"say \"\"hi\"\"\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	load := fmt.Sprintf("load %q readonly mmap; ", source)
+	tests := []struct {
+		input    string
+		expected []string // the ids of the rows read
+	}{
+		{`read row 0`, []string{"1"}},
+		{`read row 2`, []string{"3"}},
+		{`read row 2; read row 1`, []string{"2"}},
+		{`read row 99`, []string{}},
+		{`read row * where status == "failed"`, []string{"2", "3"}},
+		{`read row * where id > 1 and note != ""`, []string{"3"}},
+	}
+	for _, tt := range tests {
+		result := testEval(load + tt.input)
+		csv, ok := result.(*object.CSV)
+		if !ok {
+			t.Errorf("%s: expected CSV, got %s", tt.input, result.Inspect())
+			continue
+		}
+		ids := []string{}
+		for _, row := range csv.Rows {
+			ids = append(ids, row["id"])
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("%s: wrong rows. expected=%v, got=%v", tt.input, tt.expected, ids)
+		}
+	}
+
+	if result := testEval(load + `read row 0 col note`); !strings.Contains(result.Inspect(), "two\nlines") {
+		t.Errorf("wrong cell read. got=%s", result.Inspect())
+	}
+	if result := testEval(load + `read row 2 col note`); !strings.Contains(result.Inspect(), `say "hi"`) {
+		t.Errorf("quotes should be unescaped. got=%s", result.Inspect())
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{load + `count(csv)`, "argument must be ARRAY or CSV, got MAPPED_CSV"},
+		{load + fmt.Sprintf("save as %q", filepath.Join(dir, "out.csv")), fmt.Sprintf("cannot save mapped file %s, save from it instead, eg. save from %q as out.csv", source, source)},
+		{fmt.Sprintf("load %q readonly mmap trim", source), "mapped files are read as they are, they can't be loaded with trim, numbers or na values"},
+		{fmt.Sprintf("load %q readonly; csv[0][\"status\"] = \"done\"", source), "cannot change status: the file was loaded readonly"},
+		{fmt.Sprintf("load %q readonly; for i, row in csv { row.id = i }", source), "cannot change id: the file was loaded readonly"},
+	}
+	for _, tt := range errorTests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("%q: wrong error. want=%q, got=%v", tt.input, tt.expected, errObj)
+		}
+	}
+
+	// rows read from a readonly file can be changed, they are copied on write
+	if result := testEval(fmt.Sprintf("load %q readonly; let failed = read row * where status == \"failed\"; failed[0][\"id\"] = 9; csv[1][\"id\"]", source)); result.Inspect() != "2" {
+		t.Errorf("the readonly CSV should keep its rows. got=%s", result.Inspect())
+	}
+}
+
+func TestLoadCache(t *testing.T) {
+	defer func(dir string, minSize int64) { CacheDir, cacheMinSize = dir, minSize }(CacheDir, cacheMinSize)
+	CacheDir, cacheMinSize = filepath.Join(t.TempDir(), "cache"), 0
+	source := filepath.Join(t.TempDir(), "people.csv")
+	if err := os.WriteFile(source, []byte("name,age,joined\n ann ,31,2024-01-02\nbob,,2024-03-04\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	load := fmt.Sprintf("load %q", source)
+	expected := "name,age,joined\n\" ann \",31,2024-01-02\nbob,,2024-03-04\n"
+	for _, run := range []string{"parsed", "cached"} {
+		csv, ok := testEval(load).(*object.CSV)
+		if !ok {
+			t.Fatalf("%s: expected CSV", run)
+		}
+		if got := csvOrError(t, load, csv); got != expected {
+			t.Errorf("%s: wrong rows. expected=%q, got=%q", run, expected, got)
+		}
+		if types := fmt.Sprint(csv.ColumnTypes); types != "[{name STRING false  } {age INTEGER true  } {joined STRING false 2006-01-02 }]" {
+			t.Errorf("%s: wrong column types. got=%s", run, types)
+		}
+	}
+	entries, _ := filepath.Glob(filepath.Join(CacheDir, "*.gob"))
+	if len(entries) != 1 {
+		t.Fatalf("expected a cache entry, got %v", entries)
+	}
+
+	// the options are part of the key, trimmed rows have an entry of their own
+	if got := csvOrError(t, load+" trim", testEval(load+" trim")); !strings.Contains(got, "\nann,31") {
+		t.Errorf("the cells should be trimmed. got=%q", got)
+	}
+
+	// entries are read as long as the file has the same size and modification time, and parsed again once it changes
+	info, err := os.Stat(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(source, []byte("name,age,joined\n cal ,32,2024-01-02\nbob,,2024-03-04\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(source, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if got := csvOrError(t, load, testEval(load)); got != expected {
+		t.Errorf("the unchanged entry should be read. got=%q", got)
+	}
+	if err := os.Chtimes(source, info.ModTime(), info.ModTime().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if got := csvOrError(t, load, testEval(load)); !strings.Contains(got, "\" cal \",32") {
+		t.Errorf("the changed file should be parsed again. got=%q", got)
+	}
+}
+
+func TestIterators(t *testing.T) {
+	dir := t.TempDir()
+	events := filepath.Join(dir, "events.csv")
+	if err := os.WriteFile(events, []byte("id,status\n1,ok\n2,failed\n3,failed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	log := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(log, []byte("start\nerror: disk\nstop\nerror: net"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let total = 0; for i, n in sequence(1, 5) { total += n }; total`, "10"},
+		{`collect(sequence(0, 10, 3))`, "[0, 3, 6, 9]"},
+		{`collect(sequence(3, 0, -1))`, "[3, 2, 1]"},
+		{`collect(sequence(9223372036854775800, 9223372036854775807, 5))`, "[9223372036854775800, 9223372036854775805]"},
+		{`collect(sequence(-9223372036854775800, -9223372036854775807 - 1, -5))`, "[-9223372036854775800, -9223372036854775805]"},
+		// only the values asked for are produced
+		{`collect(head(sequence(1000000000000), 3))`, "[0, 1, 2]"},
+		{`let it = sequence(3); collect(it); collect(it)`, "[]"},
+		{`let last = 0; for i, n in sequence(10) { if (n == 4) { return i }; last = n }; last`, "4"},
+		{fmt.Sprintf(`let errors = 0; for i, line in lines(%q) { if (line != "start" && line != "stop") { errors += 1 } }; errors`, log), "2"},
+		{fmt.Sprintf(`collect(head(lines(%q), 2))`, log), `[start, error: disk]`},
+		{fmt.Sprintf(`let failed = 0; for i, row in rows(%q) { if (row["status"] == "failed") { failed += 1 } }; failed`, events), "2"},
+		{fmt.Sprintf(`count(collect(rows(%q)))`, events), "3"},
+		{fmt.Sprintf(`load %q readonly mmap; collect(head(rows(csv), 1))[0]["status"]`, events), "ok"},
+		{fmt.Sprintf(`load %q; for i, row in rows(csv) { row["status"] = "done" }; csv[2]["status"]`, events), "done"},
+		{`type(sequence(1))`, "ITERATOR"},
+	}
+	for _, tt := range tests {
+		if result := testEval(tt.input); result == nil || result.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%s, got=%v", tt.input, tt.expected, result)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`sequence(1, 2, 0)`, "sequence step cannot be zero"},
+		{`sequence("a")`, "arguments to `sequence` must be INTEGER, got STRING"},
+		{`collect([1])`, "argument to `collect` must be ITERATOR, got ARRAY"},
+		{`rows(1)`, "argument to `rows` must be STRING, CSV or MAPPED_CSV, got INTEGER"},
+		{`tail(sequence(3), 1)`, "first argument to `tail` must be ARRAY or CSV, got ITERATOR"},
+		{`for i, x in 5 { x }`, "for loop iterable must be ARRAY, CSV or ITERATOR, got INTEGER"},
+		{fmt.Sprintf("lines(%q)", filepath.Join(dir, "missing.log")), "could not open file: open " + filepath.Join(dir, "missing.log") + ": no such file or directory"},
+	}
+	for _, tt := range errorTests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("%q: wrong error. want=%q, got=%v", tt.input, tt.expected, errObj)
+		}
+	}
+
+	// closing an iterator, eg. when a loop returns early, ends it
+	it := fileLines(log).(*object.Iterator)
+	if _, ok, err := it.Next(); !ok || err != nil {
+		t.Fatalf("expected a line, got ok=%v err=%v", ok, err)
+	}
+	it.Close()
+	if _, ok, _ := it.Next(); ok {
+		t.Errorf("a closed iterator should be done")
+	}
+}
+
+func TestEmbedding(t *testing.T) {
+	csv, err := object.NewCSVFromRecords([]string{"name", "age"}, [][]string{{"Ann", "30"}, {"Bob", "17"}, {"Cy", ""}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if age := csv.TypeOf("age"); age.DataType != object.INTEGER_OBJ || !age.Nullable {
+		t.Errorf("column types should be inferred. got=%+v", age)
+	}
+
+	env := object.NewEnvironment()
+	env.Set("csv", csv)
+	program := parser.New(lexer.New(`read row * where age > 17`)).ParseProgram()
+	result, ok := Eval(program, env).(*object.CSV)
+	if !ok {
+		t.Fatalf("expected a CSV, got %v", result)
+	}
+	if got := fmt.Sprint(result.Records()); got != "[[Ann 30]]" {
+		t.Errorf("wrong records. got=%s", got)
+	}
+
+	if _, err := object.NewCSVFromRecords([]string{"name"}, [][]string{{"Ann", "30"}}); err == nil || err.Error() != "row 0 has 2 cells for 1 headers" {
+		t.Errorf("records should have a cell per header. got=%v", err)
+	}
+
+	// values convert without an environment
+	arr := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.String{Value: "a"}}}
+	converted, err := arr.ToCSV(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(converted.Headers, converted.Records()); got != "[col1 col2] [[1 a]]" {
+		t.Errorf("wrong conversion. got=%s", got)
+	}
+	if _, err := (&object.Integer{Value: 1}).ToCSV(nil); err != nil {
+		t.Errorf("integers should convert without an environment. got=%v", err)
+	}
+}
+
+type unmarshalAddress struct {
+	City string `csv:"city"`
+}
+
+type unmarshalPerson struct {
+	Name    string `csv:"name"`
+	Age     int    `csv:"age"`
+	Score   *float64
+	Active  bool   `csv:"active,omitempty"`
+	Ignored string `csv:"-"`
+	unmarshalAddress
+	Joined time.Time `csv:"joined"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	content := "name,age,Score,active,Ignored,city,joined\nAnn,30,1.5,true,x,Oslo,2024-01-02T00:00:00Z\nBob,17,,false,y,,2024-03-04T00:00:00Z\n"
+
+	var people []unmarshalPerson
+	if err := object.Unmarshal(testEvalWithCSV(t, content, `csv`), &people); err != nil {
+		t.Fatal(err)
+	}
+	if len(people) != 2 {
+		t.Fatalf("expected 2 people, got %d", len(people))
+	}
+	ann, bob := people[0], people[1]
+	if ann.Name != "Ann" || ann.Age != 30 || ann.Score == nil || *ann.Score != 1.5 || !ann.Active || ann.Ignored != "" || ann.City != "Oslo" || ann.Joined.Month() != time.January {
+		t.Errorf("wrong first person. got=%+v", ann)
+	}
+	if bob.Score != nil || bob.Active || bob.Age != 17 {
+		t.Errorf("empty cells should be zero values. got=%+v", bob)
+	}
+
+	var adults []*unmarshalPerson
+	if err := object.Unmarshal(testEvalWithCSV(t, content, `read row * where age > 17`), &adults); err != nil {
+		t.Fatal(err)
+	}
+	if len(adults) != 1 || adults[0].Name != "Ann" {
+		t.Errorf("wrong adults. got=%v", adults)
+	}
+
+	// a row is a slice of one struct
+	var first []unmarshalPerson
+	if err := object.Unmarshal(testEvalWithCSV(t, content, `csv[1]`), &first); err != nil || len(first) != 1 || first[0].Name != "Bob" {
+		t.Errorf("a row should unmarshal. got=%v, err=%v", first, err)
+	}
+
+	errorTests := []struct {
+		result   object.Object
+		target   interface{}
+		expected string
+	}{
+		{testEvalWithCSV(t, "name,age\nAnn,old\n", `csv`), &people, `cannot unmarshal "old" in column age of row 0 into int: strconv.ParseInt: parsing "old": invalid syntax`},
+		{testEvalWithCSV(t, content, `csv`), people, "cannot unmarshal into []evaluator.unmarshalPerson, expected a pointer to a slice of structs"},
+		{testEvalWithCSV(t, content, `csv`), &[]string{}, "cannot unmarshal into *[]string, expected a pointer to a slice of structs"},
+	}
+	for _, tt := range errorTests {
+		if err := object.Unmarshal(tt.result, tt.target); err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%v", tt.expected, err)
+		}
+	}
+}
+
+// recordingTracer keeps the events of a trace, see TestTrace
+type recordingTracer struct {
+	events []string
+}
+
+func (r *recordingTracer) OnStatement(pos token.Position, statement string) {
+	r.events = append(r.events, fmt.Sprintf("%d statement %s", pos.Line, statement))
+}
+
+func (r *recordingTracer) OnLoad(pos token.Position, filename string, rows int) {
+	r.events = append(r.events, fmt.Sprintf("%d load %s %d", pos.Line, filepath.Base(filename), rows))
+}
+
+func (r *recordingTracer) OnSave(pos token.Position, filename string, rows int) {
+	r.events = append(r.events, fmt.Sprintf("%d save %s %d", pos.Line, filepath.Base(filename), rows))
+}
+
+func (r *recordingTracer) OnError(pos token.Position, message string) {
+	r.events = append(r.events, fmt.Sprintf("%d error %s", pos.Line, message))
+}
+
+func TestTrace(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "people.csv")
+	if err := os.WriteFile(source, []byte("name,age\nAnn,30\nBob,17\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tracer := &recordingTracer{}
+	Trace = tracer
+	defer func() { Trace = nil }()
+
+	input := fmt.Sprintf(`load %q
+if (true) {
+  save as %q
+}
+csv |> save(%q)
+try { nope } catch (e) { 1 }
+load %q readonly mmap
+missing`, source, filepath.Join(dir, "all.csv"), filepath.Join(dir, "copy.json"), source)
+	testEval(input)
+
+	expected := []string{
+		fmt.Sprintf("1 statement load %s", source),
+		"1 load people.csv 2",
+		"2 statement iftrue save " + filepath.Join(dir, "all.csv"),
+		"3 statement save " + filepath.Join(dir, "all.csv"),
+		"3 save all.csv 2",
+		fmt.Sprintf("5 statement (csv |> save(%s))", filepath.Join(dir, "copy.json")),
+		"0 save copy.json 2",
+		"6 statement try nope catch (e) 1",
+		"6 statement nope",
+		"6 error identifier not found: nope",
+		"6 statement 1",
+		fmt.Sprintf("7 statement load %s readonly mmap", source),
+		"7 load people.csv -1",
+		"8 statement missing",
+		"8 error identifier not found: missing",
+	}
+	if strings.Join(tracer.events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong trace. expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(tracer.events, "\n"))
+	}
+
+	var out strings.Builder
+	writer := NewTraceWriter(&out)
+	writer.OnSave(token.Position{Line: 4, Column: 1}, "adults.csv", 1)
+	writer.OnLoad(token.Position{}, "app.log", -1)
+	if out.String() != "trace: 4:1 save adults.csv: 1 row\ntrace: - load app.log: rows not counted\n" {
+		t.Errorf("wrong trace lines. got=%q", out.String())
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "people.csv")
+	content := "name,age\nAnn,30\nBob,17\n"
+	if err := os.WriteFile(source, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	audit := NewAuditLog()
+	Trace = audit
+	defer func() { Trace = nil }()
+
+	adults := filepath.Join(dir, "adults.csv")
+	copied := filepath.Join(dir, "copy.csv")
+	testEval(fmt.Sprintf("load %q\nlet adults = read row * where age > 17\nsave adults as %q\nadults |> save(%q)", source, adults, copied))
+	DryRun, DryRunOutput = true, io.Discard
+	testEval(fmt.Sprintf("load %q\nsave as %q", source, adults))
+	DryRun, DryRunOutput = false, os.Stdout
+
+	checksum := func(data string) string {
+		sum := sha256.Sum256([]byte(data))
+		return fmt.Sprintf("%x", sum)
+	}
+	expected := []AuditEntry{
+		{Operation: "read", Path: source, Line: 1, Rows: 2, Bytes: int64(len(content)), SHA256: checksum(content)},
+		{Operation: "write", Path: adults, Line: 3, Rows: 1, Bytes: 16, SHA256: checksum("name,age\nAnn,30\n")},
+		{Operation: "write", Path: copied, Rows: 1, Bytes: 16, SHA256: checksum("name,age\nAnn,30\n")},
+		{Operation: "read", Path: source, Line: 1, Rows: 2, Bytes: int64(len(content)), SHA256: checksum(content)},
+		{Operation: "write", Path: adults, Line: 2, Rows: 2, DryRun: true},
+	}
+	if entries := audit.Entries(); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("wrong audit log.\nexpected=%+v\ngot=%+v", expected, entries)
+	}
+
+	var out strings.Builder
+	if err := audit.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Files []AuditEntry `json:"files"`
+	}
+	if err := json.Unmarshal([]byte(out.String()), &decoded); err != nil || !reflect.DeepEqual(decoded.Files, expected) {
+		t.Errorf("the JSON should hold the entries. got=%s, err=%v", out.String(), err)
+	}
+}
+
+func TestSaveBundle(t *testing.T) {
+	data := "name,age\nAnn,30\nBob,17\n"
+	dir := filepath.Join(t.TempDir(), "report")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "stale.csv"), []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := fmt.Sprintf("let adults = read row * where age > 20;\nsave {people: csv, \"adults.json\": adults} as %q", dir)
+	if result := testEvalWithCSV(t, data, input); isError(result) {
+		t.Fatalf("bundle save failed: %s", result.Inspect())
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "adults.json,people.csv" {
+		t.Errorf("bundle should replace the directory. got=%v", names)
+	}
+
+	input = fmt.Sprintf("save {people: csv, count: count(csv)} as %q", dir)
+	errObj, ok := testEvalWithCSV(t, data, input).(*object.Error)
+	if !ok || errObj.Message != "cannot save count.csv in bundle: expected CSV, got INTEGER" {
+		t.Errorf("wrong error for a non-CSV entry. got=%v", errObj)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "adults.json")); err != nil {
+		t.Errorf("a failed bundle should keep the previous report: %s", err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	var report strings.Builder
+	DryRun, DryRunOutput = true, &report
+	defer func() { DryRun, DryRunOutput = false, os.Stdout }()
+
+	data := "name,region\nAnn,EU\nBob,US\nCid,EU\n"
+	dir := t.TempDir()
+	input := fmt.Sprintf("save csv as %q\nsave csv split by region as %q\nsave {eu: read row * where region == \"EU\"} as %q",
+		filepath.Join(dir, "all.csv"), filepath.Join(dir, "{region}.json"), filepath.Join(dir, "report"))
+	if result := testEvalWithCSV(t, data, input); isError(result) {
+		t.Fatalf("dry run failed: %s", result.Inspect())
+	}
+
+	expected := fmt.Sprintf("dry run: would write 3 rows to %s\ndry run: would write 2 rows to %s\ndry run: would write 1 rows to %s\ndry run: would write 2 rows to %s\n",
+		filepath.Join(dir, "all.csv"), filepath.Join(dir, "EU.json"), filepath.Join(dir, "US.json"), filepath.Join(dir, "report", "eu.csv"))
+	if report.String() != expected {
+		t.Errorf("dry run report wrong. want=%q, got=%q", expected, report.String())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("dry run should not write files. got=%d entries", len(entries))
+	}
+}
+
+func TestHeaderOrder(t *testing.T) {
+	data := "zeta,alpha,mid\nz1,a1,m1\nz2,a2,m2\n"
+	out := filepath.Join(t.TempDir(), "out.json")
+
+	testEvalWithCSV(t, data, fmt.Sprintf("save csv as %q", out))
+	saved, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  "headers": [
+    "zeta",
+    "alpha",
+    "mid"
+  ],
+  "rows": [
+    {
+      "zeta": "z1",
+      "alpha": "a1",
+      "mid": "m1"
+    },
+    {
+      "zeta": "z2",
+      "alpha": "a2",
+      "mid": "m2"
+    }
+  ],
+  "schema": [
+    {
+      "name": "zeta",
+      "type": "STRING",
+      "nullable": false
+    },
+    {
+      "name": "alpha",
+      "type": "STRING",
+      "nullable": false
+    },
+    {
+      "name": "mid",
+      "type": "STRING",
+      "nullable": false
+    }
+  ]
+}`
+	if string(saved) != expected {
+		t.Errorf("json rows should follow the header order. got=\n%s", saved)
+	}
+
+	inspected := testEvalWithCSV(t, data, "csv").Inspect()
+	if !strings.HasPrefix(inspected, "zeta alpha mid") || !strings.Contains(inspected, "z2   a2    m2") {
+		t.Errorf("Inspect should follow the header order. got=\n%s", inspected)
+	}
+
+	var fields []string
+	for i := 0; i < 20; i++ {
+		row := testEvalWithCSV(t, data, "let r = null; for i, row in csv { r = row }; r").Inspect()
+		fields = append(fields, row)
+	}
+	for _, row := range fields {
+		if row != "{zeta: z2, alpha: a2, mid: m2}" {
+			t.Fatalf("row Inspect should follow the header order. got=%s", row)
+		}
+	}
+}
+
+func TestUniqueKeys(t *testing.T) {
+	unique, ok := testEvalWithCSV(t, "a,b\nx|y,z\nx,y|z\nx,y|z\n", "unique(csv)").(*object.CSV)
+	if !ok || len(unique.Rows) != 2 {
+		t.Errorf("rows whose cells only differ in where a | is should stay apart. got=%+v", unique)
+	}
+
+	unique, ok = testEval("unique([[1, 2, 3], [1, 2, 3], [1, 2, 4]])").(*object.CSV)
+	if !ok || len(unique.Rows) != 2 || unique.Rows[1]["col3"] != "4" {
+		t.Errorf("unique of a 2d array wrong. got=%+v", unique)
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1\nlet y = x - \"a\"", "type mismatch: INTEGER - STRING at line 2, column 9"},
+		{"let f = fn(a) {\n  a * true\n}\nf(1)", "type mismatch: INTEGER * BOOLEAN at line 2, column 3"},
+		{"let x = 1\n  missing(x)", "identifier not found: missing at line 2, column 3"},
+	}
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error", tt.input)
+			continue
+		}
+		if errObj.Location() != tt.expected {
+			t.Errorf("wrong location. expected=%q, got=%q", tt.expected, errObj.Location())
+		}
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true and true", true},
+		{"true and false", false},
+		{"false or true", true},
+		{"false || false", false},
+		{"true && 1 < 2", true},
+		{"not true", false},
+		{"not 1 == 2", true},
+		{"1 > 2 or 2 > 1 and 3 > 2", true},
+		// the right operand is never evaluated, so the unknown identifier doesn't error
+		{"false and missing", false},
+		{"true or missing", true},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestNullSemantics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"null == null", true},
+		{"null != null", false},
+		{"1 == null", false},
+		{"null != 1", true},
+		{"is_null(null)", true},
+		{"is_null(0)", false},
+		{`is_null("")`, false},
+		{"is_null([1][5])", true},
+		{"is_null(null + 1)", true},
+		{"is_null(-null)", true},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected.(bool))
+	}
+
+	testNullObject(t, testEval("null"))
+}
+
+func TestReadWhereNull(t *testing.T) {
+	data := "name,email\nAlice,alice@example.com\nBob,\n"
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		// empty cells are empty strings, not null
+		{`read row * where email == ""`, 1},
+		{`read row * where email == null`, 0},
+		{`read row * where email != null`, 2},
+		// a column missing from the rows is null
+		{`read row * where phone == null`, 2},
+		{`read row * where phone != "123"`, 0},
+	}
+	for _, tt := range tests {
+		evaluated := testEvalWithCSV(t, data, tt.input)
+		csv, ok := evaluated.(*object.CSV)
+		if !ok {
+			t.Errorf("object is not CSV. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if len(csv.Rows) != tt.expected {
+			t.Errorf("%q: wrong number of rows. got=%d, want=%d", tt.input, len(csv.Rows), tt.expected)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4, 5, 6][2:5]", "[3, 4, 5]"},
+		{"[1, 2, 3, 4, 5, 6][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4, 5, 6][-3:]", "[4, 5, 6]"},
+		{"[1, 2, 3, 4, 5, 6][:]", "[1, 2, 3, 4, 5, 6]"},
+		{"[1, 2, 3][1:100]", "[2, 3]"},
+		{"[1, 2, 3][-100:1]", "[1]"},
+		{"[1, 2, 3][2:1]", "[]"},
+		{"let arr = [1, 2, 3]; let n = 1; arr[n:n + 1]", "[2]"},
+		{`"csvlang"[0:3]`, "csv"},
+		{`"csvlang"[-4:]`, "lang"},
+		{`"héllo"[0:2]`, "hé"},
+		{`"日本語"[-1:]`, "語"},
+		{`slice("héllo", 1)`, "éllo"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+
+	errObj, ok := testEval(`[1, 2][0:"a"]`).(*object.Error)
+	if !ok || errObj.Message != "slice bound must be INTEGER, got STRING" {
+		t.Errorf("expected slice bound error. got=%+v", errObj)
+	}
+}
+
+func TestRanges(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0..5", "[0, 1, 2, 3, 4]"},
+		{"let n = 3; 1..n + 1", "[1, 2, 3]"},
+		{"5..5", "[]"},
+		{"range(3)", "[0, 1, 2]"},
+		{"range(2, 5)", "[2, 3, 4]"},
+		{"range(0, 10, 3)", "[0, 3, 6, 9]"},
+		{"range(3, 0, -1)", "[3, 2, 1]"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+
+	input := `
+	let total = 0
+	for i, x in 0..100 {
+		total += x
+	}
+	total`
+	testIntegerObject(t, testEval(input), 4950)
+
+	errObj, ok := testEval("range(0, 10, 0)").(*object.Error)
+	if !ok || errObj.Message != "range step cannot be zero" {
+		t.Errorf("expected range step error. got=%+v", errObj)
+	}
+
+	// ranges near the bounds of an int64 stop without overflowing, and too long ranges fail before being built
+	tests = []struct {
+		input    string
+		expected string
+	}{
+		{"range(9223372036854775800, 9223372036854775807, 5)", "[9223372036854775800, 9223372036854775805]"},
+		{"range(-9223372036854775800, -9223372036854775807 - 1, -5)", "[-9223372036854775800, -9223372036854775805]"},
+		{"range(9223372036854775806, 9223372036854775807)", "[9223372036854775806]"},
+		{"range(0, 9223372036854775807, 4611686018427387904)", "[0, 4611686018427387904]"},
+		{"0..10000000000", "range of 10000000000 integers is longer than 16777216, use sequence to count without building an array"},
+		{"range(-9223372036854775807 - 1, 9223372036854775807)", "range of 18446744073709551615 integers is longer than 16777216, use sequence to count without building an array"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if errObj, ok := evaluated.(*object.Error); ok {
+			evaluated = &object.String{Value: errObj.Message}
+		}
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestCSVForLoop(t *testing.T) {
+	data := "name,age\nAlice,17\nBob,18\nCarol,30\n"
+
+	input := `
+	let total = 0
+	for i, row in csv {
+		total += row["age"]
+	}
+	total`
+	testIntegerObject(t, testEvalWithCSV(t, data, input), 65)
+
+	input = `
+	let names = ""
+	for i, row in read row * where age >= 18 {
+		names += row["name"]
+	}
+	names`
+	str, ok := testEvalWithCSV(t, data, input).(*object.String)
+	if !ok || str.Value != "BobCarol" {
+		t.Errorf("wrong names collected. got=%+v", str)
+	}
+
+	// mutations write back to the CSV
+	input = `
+	for i, row in csv {
+		row["age"] += 1
+		row["name"] = row["name"] + "!"
+	}
+	csv`
+	csv, ok := testEvalWithCSV(t, data, input).(*object.CSV)
+	if !ok {
+		t.Fatalf("object is not CSV. got=%T", csv)
+	}
+	if csv.Rows[0]["age"] != "18" || csv.Rows[2]["name"] != "Carol!" {
+		t.Errorf("rows were not updated. got=%+v", csv.Rows)
+	}
+
+	input = `for i, row in csv { row["missing"] = 1 }`
+	errObj, ok := testEvalWithCSV(t, data, input).(*object.Error)
+	if !ok || errObj.Message != "column not found: missing" {
+		t.Errorf("expected column not found error. got=%+v", errObj)
+	}
+
+	input = `let cell = 0; for i, row in csv { cell = row["missing"] }; is_null(cell)`
+	testBooleanObject(t, testEvalWithCSV(t, data, input), true)
+}
+
+func TestRowFieldAccess(t *testing.T) {
+	data := "name,age\nAlice,17\nBob,18\nCarol,30\n"
+
+	input := `
+	let total = 0
+	for i, row in csv {
+		total += row.age
+	}
+	total`
+	testIntegerObject(t, testEvalWithCSV(t, data, input), 65)
+
+	input = `
+	for i, row in csv {
+		row.age += 1
+	}
+	csv`
+	csv, ok := testEvalWithCSV(t, data, input).(*object.CSV)
+	if !ok {
+		t.Fatalf("object is not CSV. got=%T", csv)
+	}
+	if csv.Rows[0]["age"] != "18" || csv.Rows[2]["age"] != "31" {
+		t.Errorf("rows were not updated. got=%+v", csv.Rows)
+	}
+
+	input = `filter_rows(csv, fn(row) { row.age >= 18 && row.name != "Carol" })`
+	csv, ok = testEvalWithCSV(t, data, input).(*object.CSV)
+	if !ok {
+		t.Fatalf("object is not CSV. got=%T", csv)
+	}
+	if len(csv.Rows) != 1 || csv.Rows[0]["name"] != "Bob" {
+		t.Errorf("wrong rows filtered. got=%+v", csv.Rows)
+	}
+
+	input = `let x = 5; x.age`
+	errObj, ok := testEvalWithCSV(t, data, input).(*object.Error)
+	if !ok || errObj.Message != "field access not supported for type: INTEGER" {
+		t.Errorf("expected field access error. got=%+v", errObj)
+	}
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testEval(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	fmt.Printf("\nprogram: %s\n", program.String())
+	env := object.NewEnvironment()
+
+	return Eval(program, env)
+}
+
+func TestImportStatement(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "cleaning.cl")
+	content := "let threshold = 18\nfn is_adult(age) { age >= threshold }\n"
+	if err := os.WriteFile(lib, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cycle := filepath.Join(dir, "cycle.cl")
+	if err := os.WriteFile(cycle, []byte(fmt.Sprintf("import %q\n", cycle)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{fmt.Sprintf("import %q\nis_adult(20)", lib), true},
+		{fmt.Sprintf("import %q\nthreshold", lib), 18},
+		{fmt.Sprintf("import %q as cleaning\ncleaning.is_adult(10)", lib), false},
+		{fmt.Sprintf("import %q as cleaning\ncleaning.threshold", lib), 18},
+		{fmt.Sprintf("import %q as cleaning\nthreshold", lib), "identifier not found: threshold"},
+		{fmt.Sprintf("import %q as cleaning\ncleaning.missing", lib), "missing is not defined in module cleaning"},
+		{fmt.Sprintf("import %q", cycle), fmt.Sprintf("%s: import cycle: %s is already being imported", cycle, cycle)},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("expected error %q. got=%+v", expected, evaluated)
+			}
+		}
+	}
+
+	errObj, ok := testEval(`import "does/not/exist.cl"`).(*object.Error)
+	if !ok || !strings.HasPrefix(errObj.Message, "could not open file") {
+		t.Errorf("expected could not open file error. got=%+v", errObj)
+	}
+}
+
+// TestConcurrentEnvironment runs scripts in parallel on a shared environment, run it with -race to catch unguarded accesses
+func TestConcurrentEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	people, err := object.NewCSVFromRecords([]string{"name", "age"}, [][]string{{"ann", "30"}, {"bob", "17"}, {"cal", "45"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := object.NewEnvironment()
+	shared.Set("csv", people)
+	Eval(parser.New(lexer.New("let total = 0\nconst limit = 17\nfn double(x) { x * 2 }")).ParseProgram(), shared)
+
+	const workers = 8
+	var wg sync.WaitGroup
+	results := make([]object.Object, workers)
+	for w := 0; w < workers; w++ {
+		lib := filepath.Join(dir, fmt.Sprintf("lib%d.cl", w))
+		if err := os.WriteFile(lib, []byte(fmt.Sprintf("let from_lib%d = %d\n", w, w)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		script := fmt.Sprintf(`import %q
+let mine%d = double(from_lib%d)
+for i, row in csv { total = total + 1 }
+let temp%d = 1
+unload temp%d
+let adults = read row * where age > 17;
+[mine%d, count(adults), limit]`, lib, w, w, w, w, w)
+		program := parser.New(lexer.New(script)).ParseProgram()
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			results[w] = Eval(program, shared)
+		}(w)
+	}
+	wg.Wait()
+
+	for w, result := range results {
+		if expected := fmt.Sprintf("[%d, 2, 17]", w*2); result.Inspect() != expected {
+			t.Errorf("worker %d: expected=%s, got=%s", w, expected, result.Inspect())
+		}
+		if _, ok := shared.Get(fmt.Sprintf("mine%d", w)); !ok {
+			t.Errorf("worker %d: its variable should be set in the shared environment", w)
+		}
+		if _, ok := shared.Get(fmt.Sprintf("temp%d", w)); ok {
+			t.Errorf("worker %d: its unloaded variable should be gone", w)
+		}
+	}
+	if total, _ := shared.Get("total"); total.(*object.Integer).Value < 3 {
+		t.Errorf("the loops should update the shared total, got %s", total.Inspect())
+	}
+}
+
+// testEvalWithCSV writes the given CSV content to a temporary file, loads it and evaluates the input
+func testEvalWithCSV(t *testing.T, content string, input string) object.Object {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return testEval(fmt.Sprintf("load %q\n%s", path, input))
+}
+
+// func testEval(input string, env *object.Environment) object.Object {
+// 	l := lexer.New(input)
+// 	p := parser.New(l)
+// 	program := p.ParseProgram()
+
+// 	return Eval(program, env)
 // }
+
+func TestExpectations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "people.csv")
+	if err := os.WriteFile(path, []byte("name,age\nAnn,30\nBo,12\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string // error message, empty when the checks pass
+	}{
+		{`expect(1 + 1, 2.0)`, ""},
+		{`expect([1, "a", [true]], [1, "a", [true]])`, ""},
+		{`expect("a", "b")`, "assertion failed: expected b, got a"},
+		{`expect(1, "1", "types")`, "assertion failed: types: expected 1, got 1"},
+		{`expect([1, 2], [1, 3])`, "assertion failed: element 1: expected 3, got 2"},
+		{`expect([1], [1, 2])`, "assertion failed: expected 2 elements, got 1"},
+		{"load people.csv\nlet people = csv\nlet adults = read row * where age > 17\nexpect(adults, people)", "assertion failed: expected 2 rows, got 1"},
+		{"load people.csv\nexpect(csv, csv)", ""},
+		{"load people.csv\nexpect(select(csv, [\"age\"]), csv)", "assertion failed: expected columns [name, age], got [age]"},
+		{"load people.csv\nexpect(csv[1], csv[0])", "assertion failed: row 0, column name: expected \"Ann\", got \"Bo\""},
+		{"load people.csv\nassert_rows(csv, 2)", ""},
+		{"load people.csv\nassert_rows(csv, 3, \"people\")", "assertion failed: people: expected 3 rows, got 2"},
+		{"load people.csv\nassert_rows(csv, \"2\")", "row count of `assert_rows` must be INTEGER, got STRING"},
+		{"load people.csv\nassert_schema(csv, [\"name\", \"age\"], [\"string\", \"INTEGER\"])", ""},
+		{"load people.csv\nassert_schema(csv, [\"age\", \"name\"])", "assertion failed: expected columns [age, name], got [name, age]"},
+		{"load people.csv\nassert_schema(csv, [\"name\", \"age\"], [\"string\", \"float\"])", "assertion failed: column age: expected type FLOAT, got INTEGER"},
+		{"load people.csv\nassert_schema(csv, [\"name\", \"age\"], [\"string\"])", "`assert_schema` got 1 types for 2 columns"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(strings.ReplaceAll(tt.input, "people.csv", fmt.Sprintf("%q", path)))
+		errObj, isErr := evaluated.(*object.Error)
+		switch {
+		case tt.expected == "" && isErr:
+			t.Errorf("%q: unexpected error %q", tt.input, errObj.Message)
+		case tt.expected != "" && !isErr:
+			t.Errorf("%q: expected error %q. got=%T (%+v)", tt.input, tt.expected, evaluated, evaluated)
+		case isErr && errObj.Message != tt.expected:
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestExpectGolden(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "people.csv")
+	if err := os.WriteFile(data, []byte("name,age\nAnn,30\nBo,12\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join(dir, "golden", "sorted.csv")
+	script := fmt.Sprintf("load %q\nexpect_golden(sort(csv, \"age\"), %q)", data, golden)
+
+	errObj, ok := testEval(script).(*object.Error)
+	if !ok || !strings.HasPrefix(errObj.Message, "golden file "+golden+" doesn't exist") {
+		t.Errorf("a missing golden file should fail. got=%+v", errObj)
+	}
+
+	UpdateGolden = true
+	result := testEval(script)
+	UpdateGolden = false
+	if isError(result) {
+		t.Fatalf("updating the golden file failed: %s", result.Inspect())
+	}
+	content, err := os.ReadFile(golden)
+	if err != nil || string(content) != "name,age\nBo,12\nAnn,30\n" {
+		t.Errorf("wrong golden file. got=%q (%v)", content, err)
+	}
+	if result := testEval(script); isError(result) {
+		t.Errorf("output should match the golden file. got=%s", result.Inspect())
+	}
+
+	if err := os.WriteFile(golden, []byte("name,age\nBo,12\nCy,40\nAnn,31\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected := "assertion failed: output differs from golden file " + golden + " (- expected, + actual)\n  - 3: Cy,40\n  - 4: Ann,31\n  + 3: Ann,30"
+	if errObj, ok := testEval(script).(*object.Error); !ok || errObj.Message != expected {
+		t.Errorf("wrong diff.\nexpected=%q\ngot=%+v", expected, errObj)
+	}
+}
+
+func TestGoldenDiff(t *testing.T) {
+	tests := []struct {
+		expected []string
+		actual   []string
+		diff     string
+	}{
+		{[]string{"a", "b"}, []string{"a", "b"}, ""},
+		{[]string{"a", "b", "c"}, []string{"a", "c"}, "  - 2: b"},
+		{[]string{"a", "c"}, []string{"a", "b", "c"}, "  + 2: b"},
+		{[]string{"a", "b"}, []string{"a", "x"}, "  - 2: b\n  + 2: x"},
+		{[]string{}, []string{"a"}, "  + 1: a"},
+	}
+	for _, tt := range tests {
+		if diff := goldenDiff(tt.expected, tt.actual); diff != tt.diff {
+			t.Errorf("goldenDiff(%q, %q): expected=%q, got=%q", tt.expected, tt.actual, tt.diff, diff)
+		}
+	}
+
+	long := make([]string, maxDiffLines+5)
+	for i := range long {
+		long[i] = fmt.Sprint(i)
+	}
+	if diff := goldenDiff(nil, long); !strings.HasSuffix(diff, "  ... and 5 more") {
+		t.Errorf("long diffs should be cut. got=%q", diff)
+	}
+}
+
+func TestQuerySQL(t *testing.T) {
+	content := "name,age,city\nAnn,30,Oslo\nBob,20,\nCid,41,Rome\nDee,27,Oslo\n"
+	tests := []struct {
+		input    string
+		expected string // the result as CSV, or an error message
+	}{
+		{`query_sql("SELECT name FROM csv WHERE age > 25 ORDER BY age DESC")`, "name\nCid\nAnn\nDee\n"},
+		{`query_sql("SELECT city, count(*) AS n, avg(age) AS avg_age FROM csv WHERE city IS NOT NULL GROUP BY city ORDER BY city")`,
+			"city,n,avg_age\nOslo,2,28.5\nRome,1,41\n"},
+		{"let young = read row * where age < 28\n" + `query_sql("SELECT y.name, c.name AS older FROM young y JOIN csv c ON c.age > y.age AND c.city = y.city")`,
+			"name,older\nDee,Ann\n"},
+		{`query_sql("SELECT name, city IS NULL AS missing FROM csv WHERE name = 'Bob'")`, "name,missing\nBob,1\n"},
+		{`query_sql("SELECT * FROM people")`, "query_sql: no such table: people, tables are CSV variables"},
+		{`query_sql("SELECT name, name FROM csv")`, "query_sql: the result has two columns named name, rename one with AS"},
+		{`query_sql("ATTACH 'other.db' AS other")`, "query_sql: SQL logic error: too many attached databases - max 0 (1)"},
+		{`query_sql(1)`, "argument to `query_sql` must be STRING, got INTEGER"},
+	}
+	for _, tt := range tests {
+		evaluated := testEvalWithCSV(t, content, tt.input)
+		got := ""
+		switch result := evaluated.(type) {
+		case *object.Error:
+			got = result.Message
+		case *object.CSV:
+			var out strings.Builder
+			WriteCSV(&out, result)
+			got = out.String()
+		default:
+			t.Errorf("%s: expected a CSV or an error, got %s", tt.input, evaluated.Inspect())
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	// a query is interrupted once the run is stopped
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	input := `query_sql("WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n) SELECT count(*) FROM n")`
+	result := EvalContext(ctx, parser.New(lexer.New(input)).ParseProgram(), object.NewEnvironment())
+	if errObj, ok := result.(*object.Error); !ok || errObj.Message != "script stopped: context deadline exceeded" {
+		t.Errorf("expected the query to stop, got %s", result.Inspect())
+	}
+}
+
+// testEvalWithCSVs loads two CSVs as the variables a and b before evaluating the input
+func testEvalWithCSVs(t *testing.T, a, b string, input string) object.Object {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.csv": a, "b.csv": b} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return testEval(fmt.Sprintf("load %q\nlet a = csv\nload %q\nlet b = csv\n%s", filepath.Join(dir, "a.csv"), filepath.Join(dir, "b.csv"), input))
+}
+
+// csvOrError returns a CSV as CSV text, or the message of an error
+func csvOrError(t *testing.T, input string, evaluated object.Object) string {
+	switch result := evaluated.(type) {
+	case *object.Error:
+		return result.Message
+	case *object.CSV:
+		var out strings.Builder
+		WriteCSV(&out, result)
+		return out.String()
+	}
+	t.Errorf("%s: expected a CSV or an error, got %s", input, evaluated.Inspect())
+	return ""
+}
+
+func TestDiff(t *testing.T) {
+	a := "id,name,price\n1,a,10\n2,b,20\n3,c,30\n"
+	b := "id,price,name,stock\n1,11,a,5\n3,30,C,\n4,40,d,1\n"
+	tests := []struct {
+		input    string
+		expected string // the result as CSV, or an error message
+	}{
+		{`diff(a, b, "id")`, "change,id,column,old,new\nchanged,1,price,10,11\nchanged,1,stock,,5\nremoved,2,,,\nchanged,3,name,c,C\nadded,4,,,\n"},
+		{`diff(a, b, "id", ["price"])`, "change,id,column,old,new\nchanged,1,price,10,11\nremoved,2,,,\nadded,4,,,\n"},
+		{`diff(a, a, "id")`, "change,id,column,old,new\n"},
+		{`diff(a, query_sql("SELECT name FROM b"), "id")`, "column not found in second CSV: id"},
+		{`diff(a, query_sql("SELECT * FROM a UNION ALL SELECT * FROM a"), "id")`, "diff: second CSV: duplicate key id=\"1\" in row 3"},
+		{`diff(a, b, "sku")`, "column not found: sku"},
+		{`diff(a, b, "id", ["colour"])`, "diff: column not found: colour"},
+		{`diff(a, [1], "id")`, "second argument to `diff` must be CSV, got ARRAY"},
+	}
+	for _, tt := range tests {
+		got := csvOrError(t, tt.input, testEvalWithCSVs(t, a, b, tt.input))
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	target := "id,name,price\n1,a,10\n2,b,20\n3,c,30\n"
+	updates := "id,price,stock\n3,35.5,2\n4,40,1\n"
+	tests := []struct {
+		input    string
+		expected string // the result as CSV, or an error message
+	}{
+		{`upsert(a, b, "id")`, "id,name,price,stock\n1,a,10,\n2,b,20,\n3,c,35.5,2\n4,,40,1\n"},
+		// price is a FLOAT column now, so it sorts as numbers
+		{`upsert(a, b, "id") |> sort("price", "desc")`, "id,name,price,stock\n4,,40,1\n3,c,35.5,2\n2,b,20,\n1,a,10,\n"},
+		{`upsert(b, a, "id")`, "id,price,stock,name\n3,30,2,c\n4,40,1,\n1,10,,a\n2,20,,b\n"},
+		{`upsert(a, query_sql("SELECT * FROM b UNION ALL SELECT * FROM b"), "id")`, "upsert: second CSV: duplicate key id=\"3\" in row 2"},
+		{`upsert(a, b, "name")`, "column not found in second CSV: name"},
+		{`upsert(a, b)`, "wrong number of arguments: got=2, want=3"},
+	}
+	for _, tt := range tests {
+		got := csvOrError(t, tt.input, testEvalWithCSVs(t, target, updates, tt.input))
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	// the target isn't modified
+	got := csvOrError(t, "", testEvalWithCSVs(t, target, updates, "let merged = upsert(a, b, \"id\")\na"))
+	if got != target {
+		t.Errorf("upsert modified its target. got=%q", got)
+	}
+}
+
+func TestExceptAndIntersect(t *testing.T) {
+	customers := "email,name\nann@x.io,Ann\nbo@x.io,Bo\nann@x.io,Ann B\ncy@x.io,Cy\n"
+	subscribers := "email\nbo@x.io\nann@x.io\nbo@x.io\n"
+	tests := []struct {
+		input    string
+		expected string // the result as CSV, or an error message
+	}{
+		{`except(a, b, "email")`, "email,name\ncy@x.io,Cy\n"},
+		{`intersect(a, b, "email")`, "email,name\nann@x.io,Ann\nbo@x.io,Bo\nann@x.io,Ann B\n"},
+		{`except(b, a, "email")`, "email\n"},
+		{`intersect(a, b, "name")`, "column not found in second CSV: name"},
+		{`except(a, "b", "email")`, "second argument to `except` must be CSV, got STRING"},
+		{`intersect(a, b)`, "wrong number of arguments: got=2, want=3"},
+	}
+	for _, tt := range tests {
+		got := csvOrError(t, tt.input, testEvalWithCSVs(t, customers, subscribers, tt.input))
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	// the kept rows are shared, writing to them doesn't change a
+	input := "let kept = intersect(a, b, \"email\")\nkept[0][\"name\"] = \"Zed\"\na"
+	if got := csvOrError(t, input, testEvalWithCSVs(t, customers, subscribers, input)); got != customers {
+		t.Errorf("intersect shared its rows for writing. got=%q", got)
+	}
+}
+
+func TestSimilarities(t *testing.T) {
+	tests := []struct {
+		a, b        string
+		jaroWinkler float64
+		levenshtein float64
+	}{
+		{"martha", "marhta", 0.961, 0.667},
+		{"dixon", "dicksonx", 0.813, 0.5},
+		{"same", "same", 1, 1},
+		{"", "", 1, 1},
+		{"abc", "", 0, 0},
+		{"zürich", "zurich", 0.9, 0.833},
+	}
+	for _, tt := range tests {
+		if got := math.Round(jaroWinkler(tt.a, tt.b)*1000) / 1000; got != tt.jaroWinkler {
+			t.Errorf("jaroWinkler(%q, %q): expected=%v, got=%v", tt.a, tt.b, tt.jaroWinkler, got)
+		}
+		if got := math.Round(levenshteinSimilarity(tt.a, tt.b)*1000) / 1000; got != tt.levenshtein {
+			t.Errorf("levenshteinSimilarity(%q, %q): expected=%v, got=%v", tt.a, tt.b, tt.levenshtein, got)
+		}
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	crm := "id,name\n1,Jon Smith\n2,ACME Corp\n3,Zed\n"
+	billing := "name,plan\nJohn Smith,pro\n acme corp.,team\nJane Doe,free\n"
+	tests := []struct {
+		input    string
+		expected string // the result as CSV, or an error message
+	}{
+		{`fuzzy_match(a, b, "name")`, "id,name,name_match,plan,match_score\n1,Jon Smith,John Smith,pro,0.973\n2,ACME Corp,\" acme corp.\",team,0.98\n3,Zed,,,\n"},
+		{`fuzzy_match(a, b, "name", 0.99)`, "id,name,name_match,plan,match_score\n1,Jon Smith,,,\n2,ACME Corp,,,\n3,Zed,,,\n"},
+		{`fuzzy_match(a, b, "name", 0.85, "levenshtein")`, "id,name,name_match,plan,match_score\n1,Jon Smith,John Smith,pro,0.9\n2,ACME Corp,\" acme corp.\",team,0.9\n3,Zed,,,\n"},
+		{`fuzzy_match(a, b, "name", 2)`, "threshold of `fuzzy_match` must be a number between 0 and 1, got 2"},
+		{`fuzzy_match(a, b, "name", 0.8, "soundex")`, "method of `fuzzy_match` must be \"jaro_winkler\" or \"levenshtein\", got soundex"},
+		{`fuzzy_match(a, b, "id")`, "column not found in second CSV: id"},
+	}
+	for _, tt := range tests {
+		got := csvOrError(t, tt.input, testEvalWithCSVs(t, crm, billing, tt.input))
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	people := "name,email,ssn,phone\nAnn Lee,ann@x.io,123-45-6789,5551234\nBo,,987-65-4321,0551234\nAnn Lee,ann@x.io,,\n"
+	tests := []struct {
+		input    string
+		column   string
+		expected string // the masked column, one cell per row, or an error message
+	}{
+		{`mask(csv, "email", "hash")`, "email", "31122705444181b10221d3b55cfc293b23730cb63db6fd4b2ea5a33a35aa510a||31122705444181b10221d3b55cfc293b23730cb63db6fd4b2ea5a33a35aa510a"},
+		{`mask(csv, "email", "hash", "s3cret")`, "email", "1a38c9f9a64accb7b971f1260aad5941ddc1619f581cdcaad0bda11c1db1ca6a||1a38c9f9a64accb7b971f1260aad5941ddc1619f581cdcaad0bda11c1db1ca6a"},
+		{`mask(csv, "ssn", "redact")`, "ssn", "***-**-****|***-**-****|"},
+		{`mask(csv, "name", "redact")`, "name", "*** ***|**|*** ***"},
+		{`mask(csv, "ssn", "shuffle")`, "ssn", "method of `mask` must be \"hash\", \"redact\" or \"fake\", got shuffle"},
+		{`mask(csv, "ssn", "redact", "s3cret")`, "ssn", "only \"hash\" and \"fake\" take a secret, as a STRING, got s3cret"},
+		{`mask(csv, "zip", "hash")`, "zip", "column not found: zip"},
+	}
+	for _, tt := range tests {
+		got := ""
+		switch result := testEvalWithCSV(t, people, tt.input).(type) {
+		case *object.CSV:
+			cells := []string{}
+			for _, row := range result.Rows {
+				cells = append(cells, row[tt.column])
+			}
+			got = strings.Join(cells, "|")
+		case *object.Error:
+			got = result.Message
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	// fake values look like the originals and are the same for the same value
+	masked, ok := testEvalWithCSV(t, people, `mask(mask(mask(csv, "name", "fake"), "email", "fake"), "phone", "fake")`).(*object.CSV)
+	if !ok {
+		t.Fatalf("expected a CSV")
+	}
+	first, second, third := masked.Rows[0], masked.Rows[1], masked.Rows[2]
+	if first["name"] == "Ann Lee" || first["name"] != third["name"] || len(strings.Fields(first["name"])) != 3 {
+		t.Errorf("wrong fake name: %q, %q", first["name"], third["name"])
+	}
+	if !strings.HasSuffix(first["email"], "@example.com") || first["email"] != third["email"] || second["email"] != "" {
+		t.Errorf("wrong fake email: %q", first["email"])
+	}
+	if len(first["phone"]) != 7 || !isDigits(first["phone"]) || first["phone"] == "5551234" || first["phone"][0] == '0' {
+		t.Errorf("wrong fake phone: %q, %q", first["phone"], second["phone"])
+	}
+
+	// different values get different fake ones, and a secret changes them
+	seen := map[string]string{}
+	for i := 0; i < 5000; i++ {
+		for _, value := range []string{fmt.Sprintf("person%d@x.io", i), fmt.Sprintf("Person %d", i)} {
+			faked := fake(value, "")
+			if other, ok := seen[faked]; ok {
+				t.Fatalf("%q and %q are both faked as %q", other, value, faked)
+			}
+			seen[faked] = value
+		}
+	}
+	if fake("ann@x.io", "s3cret") == fake("ann@x.io", "") || fake("ann@x.io", "s3cret") != fake("ann@x.io", "s3cret") {
+		t.Errorf("expected the secret to change the fake value, and the same secret to keep it")
+	}
+}
+
+func TestHashing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // the digest, or an error message
+	}{
+		{`md5("ann@x.io")`, "820de57cd5d136f3f77ddbe18d32e70b"},
+		{`md5(42)`, "a1d0c6e83f027327d8461063f4ac58a6"},
+		{`sha256("ann@x.io")`, "31122705444181b10221d3b55cfc293b23730cb63db6fd4b2ea5a33a35aa510a"},
+		{`sha256([1])`, "argument to `sha256` must be STRING, got ARRAY"},
+		{`md5()`, "wrong number of arguments: got=0, want=1"},
+		{`uuid(1)`, "wrong number of arguments: got=1, want=0"},
+	}
+	for _, tt := range tests {
+		got := ""
+		switch result := testEval(tt.input).(type) {
+		case *object.String:
+			got = result.Value
+		case *object.Error:
+			got = result.Message
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	first, _ := testEval(`uuid()`).(*object.String)
+	second, _ := testEval(`uuid()`).(*object.String)
+	if first == nil || second == nil || !uuid.MatchString(first.Value) || first.Value == second.Value {
+		t.Errorf("expected two different version 4 UUIDs, got %v and %v", first, second)
+	}
+}
+
+func TestRandomData(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // the inspected value, or an error message
+	}{
+		{`rand_int(7, 7)`, "7"},
+		{`rand_choice(["only"])`, "only"},
+		{`rand_int(10, 1)`, "`rand_int` needs the lower bound first, got 10 and 1"},
+		{`rand_int(1, 2.5)`, "arguments to `rand_int` must be INTEGER, got FLOAT"},
+		{`rand_choice([])`, "argument to `rand_choice` must be a non-empty ARRAY, got []"},
+		{`fake("ssn")`, "argument to `fake` must be one of city, email, first_name, last_name, name, phone, got ssn"},
+		{`seed("a")`, "argument to `seed` must be INTEGER, got STRING"},
+	}
+	for _, tt := range tests {
+		got := ""
+		switch result := testEval(tt.input).(type) {
+		case *object.Error:
+			got = result.Message
+		default:
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	// a seed repeats the values
+	script := `seed(42)
+[rand_int(1, 100), rand_choice(["a", "b", "c"]), fake("name"), fake("email"), fake("phone"), fake("city")]`
+	first, second := testEval(script).Inspect(), testEval(script).Inspect()
+	if first != second {
+		t.Errorf("expected the same values with the same seed. got=%s and %s", first, second)
+	}
+	values, ok := testEval(script).(*object.Array)
+	if !ok || len(values.Elements) != 6 {
+		t.Fatalf("expected an array of 6 values, got %s", first)
+	}
+	if n := values.Elements[0].(*object.Integer).Value; n < 1 || n > 100 {
+		t.Errorf("rand_int out of range: %d", n)
+	}
+	if email := values.Elements[3].(*object.String).Value; !regexp.MustCompile(`^[a-z]+\.[a-z]+\d+@example\.(com|org|net)$`).MatchString(email) {
+		t.Errorf("wrong fake email: %s", email)
+	}
+	if phone := values.Elements[4].(*object.String).Value; !regexp.MustCompile(`^555-\d{3}-\d{4}$`).MatchString(phone) {
+		t.Errorf("wrong fake phone: %s", phone)
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	defer func() { Now, deterministic = time.Now, false }()
+	script := `[rand_int(1, 1000000), fake("email"), uuid(), now(), today()]`
+	runs := make([]string, 2)
+	for i := range runs {
+		Deterministic(1, time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC))
+		runs[i] = testEval(script).Inspect()
+	}
+	if runs[0] != runs[1] {
+		t.Errorf("expected the same values on every run. got=%s and %s", runs[0], runs[1])
+	}
+	values, ok := testEval(script).(*object.Array)
+	if !ok || len(values.Elements) != 5 {
+		t.Fatalf("expected an array of 5 values, got %s", runs[0])
+	}
+	if now := values.Elements[3].Inspect(); now != "2024-05-01T09:30:00Z" {
+		t.Errorf("wrong now(): %s", now)
+	}
+	if today := values.Elements[4].Inspect(); today != "2024-05-01" {
+		t.Errorf("wrong today(): %s", today)
+	}
+	if id := values.Elements[2].Inspect(); !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("wrong uuid(): %s", id)
+	}
+	if errObj, ok := testEval(`now(1)`).(*object.Error); !ok || errObj.Message != "wrong number of arguments: got=1, want=0" {
+		t.Errorf("expected an error for now(1), got %s", testEval(`now(1)`).Inspect())
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // the result as CSV, or an error message
+	}{
+		{`generate 3 rows with (id: row_number, even: row_number % 2 == 0, score: row_number * 10, label: "c")`,
+			"id,even,score,label\n1,false,10,c\n2,true,20,c\n3,false,30,c\n"},
+		{`let n = 2; generate n rows with (kind: rand_choice(["x"]), note: null)`, "kind,note\nx,\nx,\n"},
+		{`generate 0 rows with (id: row_number)`, "id\n"},
+		{`generate 2 rows with (id: row_number) |> select(["id"])`, "id\n1\n2\n"},
+		{`let n = 0 - 1; generate n rows with (id: row_number)`, "number of rows to generate must be a non-negative INTEGER, got -1"},
+		{`generate 2 rows with (id: missing)`, "identifier not found: missing"},
+		{`generate 1099511627776 rows with (id: row_number)`, "cannot generate 1099511627776 rows, the limit is 16777216"},
+	}
+	for _, tt := range tests {
+		got := csvOrError(t, tt.input, testEval(tt.input))
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	// row_number is only bound while generating
+	if result, ok := testEval(`generate 1 rows with (id: row_number); row_number`).(*object.Builtin); !ok {
+		t.Errorf("expected row_number to be the builtin after generate, got %s", result.Inspect())
+	}
+	csv, ok := testEval(`generate 2 rows with (id: row_number, price: 1.5)`).(*object.CSV)
+	if !ok || len(csv.ColumnTypes) != 2 || csv.ColumnTypes[0].DataType != object.INTEGER_OBJ || csv.ColumnTypes[1].DataType != object.FLOAT_OBJ {
+		t.Errorf("expected inferred column types, got %v", csv)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	content := "name,age,email,plan,joined\nAnn,34,ann@x.io,pro,2024-01-31\n,-2,bo-at-x,free,2024-02-30\nCy,abc,cy@x.io,gold,\nAnn,,ann@x.io,,2024-03-01\n"
+	tests := []struct {
+		input    string
+		expected string // the violations as CSV, or an error message
+	}{
+		{`validate csv with (name: required and unique, age: int > 0, email: matches(".+@.+"), plan: one_of(["free", "pro"]))`,
+			"row,column,rule,value\n2,name,required,\n2,age,int > 0,-2\n2,email,\"matches(\"\".+@.+\"\")\",bo-at-x\n3,age,int > 0,abc\n3,plan,\"one_of([\"\"free\"\", \"\"pro\"\"])\",gold\n4,name,unique,Ann\n"},
+		{`validate csv with (joined: date, plan: required)`, "row,column,rule,value\n2,joined,date,2024-02-30\n4,plan,required,\n"},
+		{`let limit = 30; validate csv with (age: int < limit || number >= 100, name: len <= 3)`, "row,column,rule,value\n1,age,(int < limit) || (number >= 100),34\n3,age,(int < limit) || (number >= 100),abc\n"},
+		{`validate csv with (name: len == 3)`, "row,column,rule,value\n3,name,len == 3,Cy\n"},
+		{`validate csv with (age: int)`, "row,column,rule,value\n3,age,int,abc\n"},
+		{`validate csv with (nope: required)`, "column not found: nope"},
+		{`validate csv with (age: positive)`, "unknown rule for column age: positive, expected required, unique, a type, a comparison, matches(...) or one_of(...)"},
+		{`validate csv with (age: int > "a")`, "rule for column age must compare with a number, got a"},
+		{`validate csv with (email: matches("("))`, "invalid pattern for column email: error parsing regexp: missing closing ): `(`"},
+		{`let n = 1; validate n with (age: int)`, "validate needs a CSV, got INTEGER"},
+	}
+	for _, tt := range tests {
+		got := csvOrError(t, tt.input, testEvalWithCSV(t, content, tt.input))
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestClipboard(t *testing.T) {
+	dir := t.TempDir()
+	copied := filepath.Join(dir, "copied.txt")
+	pasted := filepath.Join(dir, "pasted.txt")
+	readers, writers := clipboardReaders, clipboardWriters
+	defer func() { clipboardReaders, clipboardWriters = readers, writers }()
+	clipboardReaders = [][]string{{"csvlang-missing-tool"}, {"cat", copied}}
+	clipboardWriters = [][]string{{"sh", "-c", "cat > " + pasted}}
+
+	tests := []struct {
+		copied   string
+		input    string
+		expected string // what is pasted, or an error message
+	}{
+		// rows copied out of a spreadsheet are separated by tabs and end lines with \r\n
+		{"name\tage\r\nAnn\t30\r\nBo, Jr\t12\r\n", "load clipboard\nlet adults = read row * where age > 18\nsave adults as clipboard", "name\tage\nAnn\t30\n"},
+		{"name,city\nAnn,\"Oslo, NO\"\n", "load clipboard\nsave as clipboard", "name\tcity\nAnn\tOslo, NO\n"},
+		{" name \n Ann \n", "load clipboard trim\ncsv |> save(\"clipboard\")", "name\nAnn\n"},
+		{"", "load clipboard", "could not read the clipboard: could not read CSV headers: EOF"},
+	}
+	for _, tt := range tests {
+		os.Remove(pasted)
+		if err := os.WriteFile(copied, []byte(tt.copied), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if err, ok := testEval(tt.input).(*object.Error); ok {
+			got = err.Message
+		} else {
+			content, _ := os.ReadFile(pasted)
+			got = string(content)
+		}
+		if got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	clipboardReaders = [][]string{{"csvlang-missing-tool"}}
+	if err, ok := testEval("load clipboard").(*object.Error); !ok || err.Message != "could not read the clipboard: no clipboard tool found, install one of csvlang-missing-tool" {
+		t.Errorf("expected an error without a clipboard tool. got=%v", err)
+	}
+}
+
+func TestLoadFromDatabase(t *testing.T) {
+	t.Setenv("MYSQL_DSN", "")
+	// nothing listens on port 1, so connecting fails
+	t.Setenv("DATABASE_URL", "postgres://csvlang@127.0.0.1:1/shop?sslmode=disable&connect_timeout=5")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`load mysql "SELECT * FROM orders"`, "MYSQL_DSN must be set to the connection string of the database"},
+		{`load pg "SELECT * FROM " + table`, "identifier not found: table"},
+		{`load pg "SELECT * FROM orders"`, "could not load from pg: dial tcp 127.0.0.1:1: connect: connection refused"},
+		{`load pg "SELECT * FROM orders" numbers "xx"`, "could not load from pg: unknown number locale: xx, supported locales are " + strings.Join(supportedLocales(), ", ")},
+	}
+	for _, tt := range tests {
+		err, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error", tt.input)
+			continue
+		}
+		if err.Message != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, err.Message)
+		}
+	}
+
+	// the rows of a query are trimmed and read in the locale like the rows of a file
+	queried := &object.CSV{Headers: []string{" name ", "price"}, Rows: []map[string]string{{" name ": " Ann ", "price": "1.234,5"}}}
+	var out strings.Builder
+	WriteCSV(&out, applyLoadOptions(queried, true, "de"))
+	if out.String() != "name,price\nAnn,1234.5\n" {
+		t.Errorf("expected the rows to be trimmed and their numbers read, got=%q", out.String())
+	}
+}
+
+func TestLoadDelta(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "big.csv")
+	load := fmt.Sprintf("load delta of %q since state %q", path, filepath.Join(dir, "state"))
+	// the offset is recorded once the run succeeds
+	run := func(input string) object.Object {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		return EvalContext(context.Background(), program, object.NewEnvironment())
+	}
+
+	tests := []struct {
+		appended string
+		write    bool // the file is replaced instead of appended to
+		expected []string
+	}{
+		{"name,age\nAnn,30\nBo,12\n", true, []string{"Ann", "Bo"}},
+		{"", false, []string{}},
+		// a line still being written is left for the next run
+		{"Cy,40\nDi,", false, []string{"Cy"}},
+		{"5\n", false, []string{"Di"}},
+		// a new header means the file was replaced, it is read from the start
+		{"id,name\n1,Ed\n", true, []string{"Ed"}},
+		// so does a file shorter than the offset
+		{"name,age\n", true, []string{}},
+	}
+	for i, tt := range tests {
+		flag := os.O_APPEND | os.O_CREATE | os.O_WRONLY
+		if tt.write {
+			flag = os.O_TRUNC | os.O_CREATE | os.O_WRONLY
+		}
+		file, err := os.OpenFile(path, flag, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.WriteString(tt.appended)
+		file.Close()
+
+		result := run(load)
+		csv, ok := result.(*object.CSV)
+		if !ok {
+			t.Fatalf("run %d: expected a CSV, got=%v", i, result)
+		}
+		names := []string{}
+		for _, row := range csv.Rows {
+			names = append(names, row["name"])
+		}
+		if strings.Join(names, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("run %d: expected=%v, got=%v", i, tt.expected, names)
+		}
+	}
+
+	// a run failing after the load, or exiting with an error code, leaves the offset as it was
+	os.WriteFile(path, []byte("name,age\nAnn,30\n"), 0644)
+	for _, input := range []string{load + "\nlet x = 1 / 0", load + "\nexit(1)"} {
+		if _, ok := run(input).(*object.CSV); ok {
+			t.Fatalf("%q: expected the run to fail", input)
+		}
+	}
+	if csv, ok := run(load).(*object.CSV); !ok || len(csv.Rows) != 1 || csv.Rows[0]["name"] != "Ann" {
+		t.Errorf("expected the rows of the failed runs to be loaded again, got=%v", csv)
+	}
+	if csv, ok := run(load).(*object.CSV); !ok || len(csv.Rows) != 0 {
+		t.Errorf("expected no new rows, got=%v", csv)
+	}
+}
+
+func TestLoadGlob(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"2024-01.csv":      "name,age\nAnn,30\n",
+		"2024-02.csv":      "age,name\n12,Bo\n",
+		"2023-12.csv":      "name,city\nCy,Oslo\n",
+		"report[2024].csv": "name,age\nDee,41\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string // the rows, or an error message
+	}{
+		{fmt.Sprintf("load %q", filepath.Join(dir, "2024-*.csv")), "Ann 30|Bo 12"},
+		{fmt.Sprintf("load %q source_file", filepath.Join(dir, "2024-*.csv")), fmt.Sprintf("Ann 30 %s|Bo 12 %s", filepath.Join(dir, "2024-01.csv"), filepath.Join(dir, "2024-02.csv"))},
+		{fmt.Sprintf("load %q", filepath.Join(dir, "*.csv")), fmt.Sprintf("could not load %s: %s has the headers name, age, expected name, city", filepath.Join(dir, "*.csv"), filepath.Join(dir, "2024-01.csv"))},
+		{fmt.Sprintf("load %q", filepath.Join(dir, "2025-*.csv")), fmt.Sprintf("could not load %s: no files match", filepath.Join(dir, "2025-*.csv"))},
+		{fmt.Sprintf("load %q", filepath.Join(dir, "report[2024].csv")), "Dee 41"},
+	}
+	for _, tt := range tests {
+		got := ""
+		switch result := testEval(tt.input).(type) {
+		case *object.CSV:
+			rows := []string{}
+			for _, row := range result.Rows {
+				rows = append(rows, strings.Join(result.Values(row), " "))
+			}
+			got = strings.Join(rows, "|")
+		case *object.Error:
+			got = result.Message
+		}
+		if got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestLoadQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "people.csv")
+	content := "id,name,age\n1,Ann,34\n2,Bo\n3,\"Cy\"x,20\n4,Di,abc\n5,Ed,41\n\n6,Flo,\n7,\"Gus, Jr\",52,extra\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rejected := filepath.Join(dir, "rejected.csv")
+	expectedBad := "line,reason,raw\n" +
+		"3,\"2 fields, expected 3\",\"2,Bo\"\n" +
+		"4,\"extraneous or missing \"\" in quoted-field\",\"3,\"\"Cy\"\"x,20\"\n" +
+		"5,age is not a number: abc,\"4,Di,abc\"\n" +
+		"9,\"4 fields, expected 3\",\"7,\"\"Gus, Jr\"\",52,extra\"\n"
+
+	tests := []struct {
+		input    string
+		expected string // the result as CSV, or an error message
+	}{
+		{fmt.Sprintf("load %q quarantine bad_rows", path), "id,name,age\n1,Ann,34\n5,Ed,41\n6,Flo,\n"},
+		{fmt.Sprintf("load %q quarantine bad_rows\nbad_rows", path), expectedBad},
+		{fmt.Sprintf("load %q quarantine bad_rows to %q\nload %q", path, rejected, rejected), expectedBad},
+		{fmt.Sprintf("load %q", path), "could not read CSV records: record on line 3: wrong number of fields"},
+		{fmt.Sprintf("load %q source_file quarantine bad_rows", path), "quarantine is only supported when loading a single file"},
+		{fmt.Sprintf("load %q quarantine bad_rows to %q", path, filepath.Join(dir, "rejected.txt")), "unsupported file format: " + filepath.Join(dir, "rejected.txt")},
+	}
+	for _, tt := range tests {
+		got := csvOrError(t, tt.input, testEval(tt.input))
+		if got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestLoadNAValues(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sales.csv")
+	if err := os.WriteFile(path, []byte("region,amount\nN/A,NA\nwest,10\n-,-\neast,5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	quarantined := filepath.Join(dir, "quarantined.csv")
+	if err := os.WriteFile(quarantined, []byte("region,amount\nwest,10\nsouth,3\neast,NA\nnorth,x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	load := fmt.Sprintf(`load %q na values ["NA", "N/A", "-"]`, path)
+
+	tests := []struct {
+		input    string
+		expected string // the result as CSV, or an error message
+	}{
+		{load, "region,amount\n,\nwest,10\n,\neast,5\n"},
+		{load + "\nfill_empty(csv, \"region\", \"unknown\")", "region,amount\nunknown,\nwest,10\nunknown,\neast,5\n"},
+		{load + "\ndrop_empty(csv)", "region,amount\nwest,10\neast,5\n"},
+		{load + "\ndrop_empty(csv, \"amount\")", "region,amount\nwest,10\neast,5\n"},
+		{fmt.Sprintf("load %q\ndrop_empty(csv, \"region\")", path), "region,amount\nN/A,NA\nwest,10\n-,-\neast,5\n"},
+		{load + "\ndrop_empty(csv, \"city\")", "column not found: city"},
+		{fmt.Sprintf(`load %q quarantine bad na values ["NA"]`, quarantined) + "\nbad", "line,reason,raw\n5,amount is not a number: x,\"north,x\"\n"},
+	}
+	for _, tt := range tests {
+		got := csvOrError(t, tt.input, testEval(tt.input))
+		if got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	// the column is numeric once its NA values are missing, so it can be aggregated
+	if sum, ok := testEval(load + "\nsum(csv, \"amount\")").(*object.Integer); !ok || sum.Value != 15 {
+		t.Errorf("expected the sum of amount to be 15, got %v", sum)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	content := "id,amount,shipped,created,note\n1,9.5,2024-01-31,2024-01-31 10:00:00,\n2,,2024-02-01,2024-02-01 09:30:00,fragile\n"
+	tests := []struct {
+		input    string
+		expected string // the result as CSV, or an error message
+	}{
+		{`schema(csv)`, "column,type,nullable,format,description\nid,INTEGER,false,,\namount,FLOAT,true,,\nshipped,STRING,false,2006-01-02,\ncreated,STRING,false,2006-01-02 15:04:05,\nnote,STRING,true,,\n"},
+		{`describe_column(csv, "amount", "total in EUR") |> unique() |> select(["id", "amount"]) |> schema()`, "column,type,nullable,format,description\nid,INTEGER,false,,\namount,FLOAT,true,,total in EUR\n"},
+		{`let described = describe_column(csv, "note", "handling notes"); schema(csv)`, "column,type,nullable,format,description\nid,INTEGER,false,,\namount,FLOAT,true,,\nshipped,STRING,false,2006-01-02,\ncreated,STRING,false,2006-01-02 15:04:05,\nnote,STRING,true,,\n"},
+		{`describe_column(csv, "nope", "x")`, "column not found: nope"},
+		{`describe_column(csv, "id", 1)`, "description of `describe_column` must be STRING, got INTEGER"},
+		{`schema(1)`, "first argument to `schema` must be CSV, got INTEGER"},
+	}
+	for _, tt := range tests {
+		got := csvOrError(t, tt.input, testEvalWithCSV(t, content, tt.input))
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	out := filepath.Join(t.TempDir(), "out.json")
+	testEvalWithCSV(t, content, fmt.Sprintf(`describe_column(csv, "id", "order id") |> select(["id", "shipped"]) |> save(%q)`, out))
+	saved, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written struct {
+		Schema []map[string]interface{} `json:"schema"`
+	}
+	if err := json.Unmarshal(saved, &written); err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]interface{}{
+		{"name": "id", "type": "INTEGER", "nullable": false, "description": "order id"},
+		{"name": "shipped", "type": "STRING", "nullable": false, "format": "2006-01-02"},
+	}
+	if !reflect.DeepEqual(written.Schema, expected) {
+		t.Errorf("wrong schema in JSON. expected=%v, got=%v", expected, written.Schema)
+	}
+}
+
+func TestTypedCells(t *testing.T) {
+	content := "id,amount,at\n1,9.5,2024-01-02T09:00:00+02:00\n2,,2024-01-02T08:00:00Z\n3,12,2024-01-01T23:00:00Z\n"
+	rows := strings.Split(content, "\n")
+	tests := []struct {
+		input    string
+		expected string // the result as CSV, or an error message
+	}{
+		{`read row * where amount > 10`, rows[0] + "\n" + rows[3] + "\n"},
+		{`read row * where amount == 9.5`, rows[0] + "\n" + rows[1] + "\n"},
+		{`read row 2 where id == 3`, rows[0] + "\n" + rows[3] + "\n"},
+		// dates are compared as dates, not as text
+		{`read row * where at < "2024-01-02T08:00:00Z"`, rows[0] + "\n" + rows[1] + "\n" + rows[3] + "\n"},
+		{`read row * where at == "2024-01-02T07:00:00Z"`, rows[0] + "\n" + rows[1] + "\n"},
+		// cells changed after load are parsed again
+		{`csv[0]["amount"] = 20; read row * where amount > 10`, rows[0] + "\n1,20,2024-01-02T09:00:00+02:00\n" + rows[3] + "\n"},
+		// assignments have to fit the type of the column
+		{`csv[2]["id"] = "x"; sum(csv, "id")`, "cannot change id to \"x\": the column is INTEGER"},
+	}
+	for _, tt := range tests {
+		got := csvOrError(t, tt.input, testEvalWithCSV(t, content, tt.input))
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+	// dates far in the past or the future compare too
+	dates := "id,at\n1,1600-01-01\n2,2300-01-01\n"
+	for input, expected := range map[string]string{
+		`read row * where at < "2000-01-01"`:  "id,at\n1,1600-01-01\n",
+		`read row * where at >= "2299-12-31"`: "id,at\n2,2300-01-01\n",
+	} {
+		if got := csvOrError(t, input, testEvalWithCSV(t, dates, input)); got != expected {
+			t.Errorf("%s: expected=%q, got=%q", input, expected, got)
+		}
+	}
+	// rows changed by Go programs aren't checked, their cells fail when they are read
+	for input, expected := range map[string]string{
+		`sum(csv, "id")`:          "invalid INTEGER \"x\" in column id at row 2",
+		`read row * where id > 1`: "invalid number \"x\" in column id at row 2",
+	} {
+		changed, err := ReadCSV(strings.NewReader(content), false, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		changed.WritableRow(2)["id"] = "x"
+		env := object.NewEnvironment()
+		env.Set("csv", changed)
+		if got := csvOrError(t, input, Eval(parser.New(lexer.New(input)).ParseProgram(), env)); got != expected {
+			t.Errorf("%s: expected=%q, got=%q", input, expected, got)
+		}
+	}
+	if sum, ok := testEvalWithCSV(t, content, `csv[1]["amount"] = 1.5; sum(csv, "amount")`).(*object.Float); !ok || sum.Value != 23 {
+		t.Errorf("wrong sum after assignment. expected=23, got=%+v", sum)
+	}
+
+	csv, err := ReadCSV(strings.NewReader(content), false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := []object.CellKind{}
+	for _, header := range csv.Headers {
+		for _, cell := range csv.Cells(header) {
+			kinds = append(kinds, cell.Kind)
+		}
+	}
+	expected := []object.CellKind{
+		object.IntegerCell, object.IntegerCell, object.IntegerCell,
+		object.FloatCell, object.NullCell, object.FloatCell,
+		object.DateCell, object.DateCell, object.DateCell,
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("wrong kinds of cells. expected=%v, got=%v", expected, kinds)
+	}
+
+	// the cells of the rows written to are parsed again, whether a few or most rows of the column changed
+	csv.WritableRow(1)["amount"] = "4"
+	if cells := csv.Cells("amount"); cells[1].Kind != object.FloatCell || cells[1].Float != 4 || cells[0].Float != 9.5 {
+		t.Errorf("wrong cells after a row changed. got=%+v", cells)
+	}
+	for i := range csv.Rows {
+		csv.WritableRow(i)["amount"] = ""
+	}
+	for i, cell := range csv.Cells("amount") {
+		if cell.Kind != object.NullCell {
+			t.Errorf("wrong cell %d after every row changed. got=%+v", i, cell)
+		}
+	}
+}
+
+func TestCSVCellAssignment(t *testing.T) {
+	content := "name,age,score,joined\nAnn,30,9.5,2024-01-02\nBo,17,7.25,2024-03-04\n"
+	tests := []struct {
+		input    string
+		expected string // the result as CSV, or an error message
+	}{
+		{`csv[1]["age"] = 42; csv`, "name,age,score,joined\nAnn,30,9.5,2024-01-02\nBo,42,7.25,2024-03-04\n"},
+		{`csv[0]["age"] += 1; csv[0]["score"] = 8; csv[1]["joined"] = "2024-05-06"; csv`, "name,age,score,joined\nAnn,31,8,2024-01-02\nBo,17,7.25,2024-05-06\n"},
+		// numbers written as strings fit numeric columns, anything fits a STRING column and null clears a cell
+		{`csv[0]["age"] = "33"; csv[0]["name"] = 5; csv[1]["score"] = null; csv`, "name,age,score,joined\n5,33,9.5,2024-01-02\nBo,17,,2024-03-04\n"},
+		// the rows of CSVs derived from csv keep their values
+		{`let adults = read row * where age > 17; csv[0]["age"] = 50; adults`, "name,age,score,joined\nAnn,30,9.5,2024-01-02\n"},
+		{`csv[0]["age"] = 4.5`, "cannot change age to 4.5: the column is INTEGER"},
+		{`csv[0]["age"] = ""`, "cannot change age to \"\": the column is INTEGER"},
+		{`csv[0]["score"] = "high"`, "cannot change score to \"high\": the column is FLOAT"},
+		{`csv[0]["joined"] = "soon"`, "cannot change joined to \"soon\": the column holds dates like 2006-01-02"},
+		{`for i, row in csv { row.age = "old" }`, "cannot change age to \"old\": the column is INTEGER"},
+		{`csv[2]["age"] = 1`, "row index out of bounds: 2"},
+		{`csv[0 - 1]["age"] = 1`, "row index out of bounds: -1"},
+		{`csv[0]["email"] = "a@b.c"`, "column not found: email"},
+		{`csv[0][1] = 1`, "row index must be STRING, got INTEGER"},
+	}
+	for _, tt := range tests {
+		if got := csvOrError(t, tt.input, testEvalWithCSV(t, content, tt.input)); got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	content := "name,age,status\nAnn,30,\nBo,12,\nCy,45,vip\n"
+	tests := []struct {
+		input    string
+		expected string // the result as CSV, the number of rows matched or an error message
+	}{
+		{`update csv set (status: "adult") where age > 17; csv`, "name,age,status\nAnn,30,adult\nBo,12,\nCy,45,adult\n"},
+		{`update csv set (status: "adult") where age > 17`, "2"},
+		{`update csv set (status: "senior") where age > 99`, "0"},
+		// rows whose cells already hold the values are counted as matched
+		{`update csv set (status: "vip") where name == "Cy"`, "1"},
+		{`update csv set (age: row.age + 1, status: row.name)`, "3"},
+		{`update csv set (age: row.age + 1, status: row.name); csv`, "name,age,status\nAnn,31,Ann\nBo,13,Bo\nCy,46,Cy\n"},
+		// the rows are matched before they change
+		{`update csv set (age: 10) where age > 17 or age < 20; csv`, "name,age,status\nAnn,10,\nBo,10,\nCy,10,vip\n"},
+		{`let n = update csv set (status: null) where status == "vip"; if (n == 0) { 99 } else { n }`, "1"},
+		{`let adults = read row * where age > 17; update adults set (status: "x"); csv`, "name,age,status\nAnn,30,\nBo,12,\nCy,45,vip\n"},
+		// nothing changes when a value doesn't fit its column
+		{`update csv set (status: "x", age: row.name) where age > 17`, "cannot change age to \"Ann\": the column is INTEGER"},
+		{`try { update csv set (status: "x", age: row.status) } catch (e) { 0 }; csv`, "name,age,status\nAnn,30,\nBo,12,\nCy,45,vip\n"},
+		{`update csv set (email: "x")`, "column not found: email"},
+		{`update [1, 2] set (a: 1)`, "update target must be CSV, got ARRAY"},
+		{`update csv set (status: missing)`, "identifier not found: missing"},
+	}
+	for _, tt := range tests {
+		got := ""
+		switch result := testEvalWithCSV(t, content, tt.input).(type) {
+		case *object.Integer:
+			got = result.Inspect()
+		default:
+			got = csvOrError(t, tt.input, result)
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "people.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if errObj, ok := testEval(fmt.Sprintf("load %q readonly; update csv set (status: \"x\")", path)).(*object.Error); !ok || errObj.Message != "cannot change status: the file was loaded readonly" {
+		t.Errorf("readonly files can't be updated, got %+v", errObj)
+	}
+}
+
+func TestCopyOnWrite(t *testing.T) {
+	content := "name,age,note\nAnn,30,\nBo,12,vip\nAnn,30,\n"
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// changing a derived CSV leaves the CSV it was derived from as it was, and the other way around
+		{`let u = unique(csv); u[0]["age"] = 99; csv[0]["age"] + csv[2]["age"]`, 60},
+		{`let orig = csv; let f = fill_empty(orig, "note", "-"); orig[1]["age"] = 1; f[1]["age"]`, 12},
+		{`let adults = read row * where age > 18; adults[0]["age"] += 1; csv[0]["age"]`, 30},
+		{`let masked = mask(csv, "note", "redact"); csv[0]["age"] = 1; masked[0]["age"]`, 30},
+		// rows read before a change see it
+		{`let r = csv[0]; let u = unique(csv); csv[0]["age"] = 5; r["age"]`, 5},
+		{`for i, row in csv { row["age"] = i }; csv[2]["age"]`, 2},
+		{`let kept = filter_rows(csv, fn(row) { row.age = 1; true }); kept[1]["age"] + csv[1]["age"]`, 2},
+		// rows changed before deriving a CSV are shared with it too, so they are copied again when they change
+		{`csv[1]["age"] = 7; let c = csv |> head(2); let d = read from csv row * where age > 0; csv[1]["age"] = 8; c[1]["age"] + d[1]["age"]`, 14},
+		{`csv[1]["age"] = 7; let u = unique(csv); let s = sort(csv, "age"); csv[1]["age"] = 8; u[1]["age"] + s[0]["age"]`, 14},
+		{`csv[1]["age"] = 7; let all = collect(rows(csv)); csv[1]["age"] = 8; all[1]["age"]`, 7},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEvalWithCSV(t, content, tt.input), tt.expected)
+	}
+
+	// transforms share the rows they don't change
+	csv, err := ReadCSV(strings.NewReader(content), false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filled := builtins["fill_empty"].Fn(object.NewEnvironment(), csv, &object.String{Value: "note"}, &object.String{Value: "-"}).(*object.CSV)
+	for i, row := range filled.Rows {
+		shared := reflect.ValueOf(row).Pointer() == reflect.ValueOf(csv.Rows[i]).Pointer()
+		if shared != (csv.Rows[i]["note"] != "") {
+			t.Errorf("row %d: expected only the rows without an empty note to be shared, shared=%t", i, shared)
+		}
+	}
+}
+
+func TestGoogleSheets(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+
+	// a fake of the token endpoint and of the Sheets API holding one sheet
+	sheet := [][]interface{}{{"name", "age", "note"}, {"Ann", "30", "vip"}, {"Bo", "12"}}
+	// the content of the sheet saved to, larger than the rows saved
+	old := [][]interface{}{{"name", "age", "note", "city"}, {"Cy", "40", "", "Oslo"}, {"Di", "50"}}
+	var saved map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			parts := strings.Split(r.FormValue("assertion"), ".")
+			signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash[:], signature) != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"access_token": "token-1", "expires_in": 3600}`)
+		case r.Header.Get("Authorization") != "Bearer token-1":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/sheet-1/values/Sheet1" && r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(map[string]interface{}{"values": sheet})
+		case r.URL.Path == "/sheet-1/values/Q3 Sales" && r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(map[string]interface{}{"values": old})
+		case r.URL.Path == "/sheet-1/values/Q3 Sales" && r.Method == http.MethodPut && r.URL.Query().Get("valueInputOption") == "RAW":
+			json.NewDecoder(r.Body).Decode(&saved)
+			fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": 404, "message": "Requested entity was not found."}}`)
+		}
+	}))
+	defer server.Close()
+	api := sheetsAPI
+	defer func() { sheetsAPI = api }()
+	sheetsAPI = server.URL
+
+	credentials := filepath.Join(t.TempDir(), "account.json")
+	account, _ := json.Marshal(map[string]string{
+		"client_email": "reports@example.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    server.URL + "/token",
+	})
+	os.WriteFile(credentials, account, 0644)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", credentials)
+
+	input := `load "gsheet://sheet-1/Sheet1"
+let adults = read row * where age > 18
+save adults as "gsheet://sheet-1/Q3 Sales"
+adults`
+	evaluated := testEval(input)
+	data, ok := evaluated.(*object.CSV)
+	if !ok {
+		t.Fatalf("expected a CSV, got %s", evaluated.Inspect())
+	}
+	if len(data.Rows) != 1 || data.Rows[0]["name"] != "Ann" || data.Rows[0]["note"] != "vip" {
+		t.Errorf("wrong rows loaded from the sheet. got=%v", data.Rows)
+	}
+	// the old content past the rows saved is emptied by the same write
+	values, _ := json.Marshal(saved["values"])
+	if string(values) != `[["name","age","note",""],["Ann",30,"vip",""],["","","",""]]` {
+		t.Errorf("wrong values saved to the sheet. got=%s", values)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`load "gsheet://sheet-1/Missing"`, "could not load gsheet://sheet-1/Missing: google returned 404 Not Found: Requested entity was not found."},
+		{`load "gsheet://sheet-1"`, "could not load gsheet://sheet-1: invalid Google Sheet gsheet://sheet-1, expected gsheet://<sheet-id>/<sheet name>"},
+	}
+	for _, tt := range tests {
+		if err, ok := testEval(tt.input).(*object.Error); !ok || err.Message != tt.expected {
+			t.Errorf("%s: expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+// benchmarkCSV returns a loaded CSV of n rows: an id, an age from 0 to 99 and a name
+func benchmarkCSV(b *testing.B, n int) *object.CSV {
+	var content strings.Builder
+	content.WriteString("id,age,name\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&content, "%d,%d,name%d\n", i, i%100, i)
+	}
+	csv, err := ReadCSV(strings.NewReader(content.String()), false, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	return csv
+}
+
+// benchmarkFilter parses the where clause of a read statement
+func benchmarkFilter(b *testing.B, input string) ast.Expression {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	read, ok := program.Statements[0].(*ast.ReadStatement)
+	if !ok || read.Location.Filter == nil {
+		b.Fatalf("%s: expected a read statement with a where clause", input)
+	}
+	return read.Location.Filter
+}
+
+func BenchmarkFilterRows(b *testing.B) {
+	csv := benchmarkCSV(b, 10000)
+	env := object.NewEnvironment()
+	for _, bm := range []struct {
+		name  string
+		where string
+	}{
+		{"selective", "read row * where age < 5"},
+		{"half", `read row * where age >= 50 and name != "x"`},
+		{"all", "read row * where age >= 0"},
+	} {
+		filter := benchmarkFilter(b, bm.where)
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, errObj := filterRows(csv, -2, filter, env); errObj != nil {
+					b.Fatal(errObj.Inspect())
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkExtractColumn(b *testing.B) {
+	csv := benchmarkCSV(b, 10000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if result := extractColumn(csv, csv.Rows, "name"); isError(result) {
+			b.Fatal(result.Inspect())
+		}
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	content := "name,age,status\nAnn,30,\nBo,12,\n"
+	tests := []struct {
+		input    string
+		expected string // the result as CSV, or an error message
+	}{
+		{`begin(csv); update csv set (status: "adult") where age > 17; rollback(csv); csv`, content},
+		{`begin(csv); update csv set (status: "adult") where age > 17; commit(csv); csv`, "name,age,status\nAnn,30,adult\nBo,12,\n"},
+		// the changes are seen as they are made, before the commit
+		{`begin(csv); csv[1]["status"] = "minor"; csv`, "name,age,status\nAnn,30,\nBo,12,minor\n"},
+		{`begin(csv); csv[0]["age"] = 31; csv[1]["status"] = "minor"; rollback(csv); csv[0]["age"] = 32; csv`, "name,age,status\nAnn,32,\nBo,12,\n"},
+		// a validation failing midway undoes what the block changed so far
+		{`begin(csv); try { update csv set (age: row.age + 1); assert(count(csv) > 5, "too few rows"); commit(csv) } catch (e) { rollback(csv) }; csv`, content},
+		{`let adults = read row * where age > 17; begin(csv); update csv set (status: "x"); rollback(csv); adults`, "name,age,status\nAnn,30,\n"},
+		{`begin(csv); commit(csv); begin(csv); csv[0]["status"] = "x"; rollback(csv); csv`, content},
+		{`begin(csv); begin(csv)`, "a transaction is already open on this CSV, commit or roll it back first"},
+		{`commit(csv)`, "no transaction to commit, call begin(csv) first"},
+		{`begin(csv); rollback(csv); rollback(csv)`, "no transaction to roll back, call begin(csv) first"},
+		{`begin([1])`, "argument to `begin` must be CSV, got ARRAY"},
+		{`rollback()`, "wrong number of arguments: got=0, want=1"},
+	}
+	for _, tt := range tests {
+		got := csvOrError(t, tt.input, testEvalWithCSV(t, content, tt.input))
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	content := "name,age\nAnn,30\nBo,12\n"
+	tests := []struct {
+		input    string
+		expected string // the result as CSV, or an error message
+	}{
+		{`snapshot(csv, "before"); update csv set (age: 1); restore("before")`, content},
+		{`snapshot(csv, "before"); update csv set (age: 1); csv`, "name,age\nAnn,1\nBo,1\n"},
+		{`snapshot(csv, "before"); csv[0]["age"] = 31; diff(restore("before"), csv, "name")`, "change,name,column,old,new\nchanged,Ann,age,30,31\n"},
+		// rows changed before the snapshot are copied again when they change after it
+		{`csv[0]["age"] = 31; snapshot(csv, "before"); csv[0]["age"] = 32; restore("before")`, "name,age\nAnn,31\nBo,12\n"},
+		{`snapshot(csv, "before"); let restored = restore("before"); restored[0]["age"] = 5; restore("before")`, content},
+		{`snapshot(csv, "before"); csv[0]["age"] = 31; snapshot(csv, "before"); csv[0]["age"] = 32; restore("before")`, "name,age\nAnn,31\nBo,12\n"},
+		{`fn original() { restore("before") }; snapshot(csv, "before"); update csv set (age: 1); original()`, content},
+		{`restore("missing")`, "no snapshot named \"missing\", take one with snapshot(csv, \"missing\")"},
+		{`snapshot([1], "x")`, "first argument to `snapshot` must be CSV, got ARRAY"},
+		{`snapshot(csv, 1)`, "second argument to `snapshot` must be STRING, got INTEGER"},
+		{`restore(csv)`, "argument to `restore` must be STRING, got CSV"},
+		{`snapshot(csv)`, "wrong number of arguments: got=1, want=2"},
+	}
+	for _, tt := range tests {
+		got := csvOrError(t, tt.input, testEvalWithCSV(t, content, tt.input))
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	// big snapshots are written to a temp file, removed when the process exits
+	defer func(maxCells int) { snapshotMaxCells = maxCells }(snapshotMaxCells)
+	snapshotMaxCells = 0
+	input := `snapshot(csv, "before"); update csv set (age: 1); restore("before")`
+	restored, ok := testEvalWithCSV(t, content, input).(*object.CSV)
+	if got := csvOrError(t, input, restored); got != content {
+		t.Errorf("%s: expected=%q, got=%q", input, content, got)
+	}
+	if !ok || fmt.Sprint(restored.ColumnTypes) != "[{name STRING false  } {age INTEGER false  }]" {
+		t.Errorf("the column types should be restored, got %+v", restored)
+	}
+	if len(snapshotFiles.paths) != 1 {
+		t.Fatalf("expected a snapshot file, got %v", snapshotFiles.paths)
+	}
+	for path := range snapshotFiles.paths {
+		RemoveSnapshotFiles()
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("the snapshot file should be removed, got %v", err)
+		}
+	}
+
+	// the files of a run are removed once it is closed, eg. a script posted to a server
+	env := object.NewEnvironment()
+	env.Set("csv", restored)
+	program := parser.New(lexer.New(`snapshot(csv, "before"); restore("before")`)).ParseProgram()
+	if got := csvOrError(t, input, EvalContext(context.Background(), program, env)); got != content {
+		t.Errorf("expected the snapshot to be restored, got=%q", got)
+	}
+	if len(snapshotFiles.paths) != 0 {
+		t.Errorf("the snapshot file should be removed with its run, got %v", snapshotFiles.paths)
+	}
+
+	// without file access, a snapshot too large for memory is refused
+	FileAccess = false
+	defer func() { FileAccess = true }()
+	expected := "snapshot of 4 cells is larger than 0: file access is disabled, cannot write snapshot before"
+	if got := csvOrError(t, input, Eval(program, env)); got != expected {
+		t.Errorf("expected=%q, got=%q", expected, got)
+	}
+}
+
+func TestColumnStats(t *testing.T) {
+	content := "name,age,score,joined\nAnn,30,9.5,2024-01-02\nBo,,7.25,2023-03-04\nCy,12,9.5,\n"
+	tests := []struct {
+		input    string
+		expected string // the value, the result as CSV or an error message
+	}{
+		{`min(csv, "age")`, "12"},
+		{`max(csv, "age")`, "30"},
+		{`min(csv, "score")`, "7.25"},
+		{`max(csv, "name")`, "Cy"},
+		{`min(csv, "joined")`, "2023-03-04"},
+		{`describe(csv)`, "column,type,count,nulls,distinct,min,max\nname,STRING,3,0,3,Ann,Cy\nage,INTEGER,2,1,2,12,30\nscore,FLOAT,3,0,2,7.25,9.5\njoined,STRING,2,1,2,2023-03-04,2024-01-02\n"},
+		// the stats are computed again once the CSV changes
+		{`max(csv, "age"); csv[0]["age"] = 50; max(csv, "age")`, "50"},
+		{`min(csv, "age"); update csv set (age: 1) where name == "Cy"; min(csv, "age")`, "1"},
+		{`max(csv, "age"); begin(csv); csv[0]["age"] = 99; max(csv, "age"); rollback(csv); max(csv, "age")`, "30"},
+		{`let ages = read row * col age where age > 0; max(ages)`, "30"},
+		{`min(read row * where age > 99, "age")`, "null"},
+		{`min(csv, "email")`, "column not found: email"},
+		{`max([1, 2], "age")`, "first argument to `max` must be CSV, got ARRAY"},
+		{`describe(1)`, "argument to `describe` must be CSV, got INTEGER"},
+	}
+	for _, tt := range tests {
+		got := ""
+		switch result := testEvalWithCSV(t, content, tt.input).(type) {
+		case *object.CSV, *object.Error:
+			got = csvOrError(t, tt.input, result)
+		default:
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	// the stats are kept until a row is handed out for changing it
+	csv, err := object.NewCSVFromRecords([]string{"age"}, [][]string{{"30"}, {"12"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := csv.Stats("age"); stats.Max.Int != 30 || stats.Count != 2 {
+		t.Fatalf("wrong stats. got=%+v", stats)
+	}
+	csv.Rows[0]["age"] = "40"
+	if stats := csv.Stats("age"); stats.Max.Int != 30 {
+		t.Errorf("expected the cached stats. got=%+v", stats)
+	}
+	csv.WritableRow(0)["age"] = "40"
+	if stats := csv.Stats("age"); stats.Max.Int != 40 {
+		t.Errorf("expected the stats to be computed again. got=%+v", stats)
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	parse := func(input string) *ast.Program {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors) != 0 {
+			t.Fatalf("%s: parser errors %v", input, p.Errors)
+		}
+		return program
+	}
+
+	// a runaway recursion fails instead of overflowing the stack
+	result := EvalContext(context.Background(), parse(`fn f(n) { f(n + 1) }; f(0)`), object.NewEnvironment())
+	if errObj, ok := result.(*object.Error); !ok || errObj.Message != fmt.Sprintf("maximum call depth of %d exceeded", MaxCallDepth) {
+		t.Errorf("expected the call depth to be exceeded, got %s", result.Inspect())
+	}
+	env := object.NewEnvironment()
+	if result := EvalContext(context.Background(), parse(`fn f(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(500)`), env); result.Inspect() != "0" {
+		t.Errorf("expected calls to end, got %s", result.Inspect())
+	}
+	if result := EvalContext(context.Background(), parse(`f(500)`), env); result.Inspect() != "0" {
+		t.Errorf("ended calls should not count, got %s", result.Inspect())
+	}
+
+	// loops stop once the context is done, try doesn't catch it
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	result = EvalContext(ctx, parse(`let n = 0; for i, x in sequence(1000000000000) { try { n += 1 } catch (e) { 0 } }`), object.NewEnvironment())
+	if errObj, ok := result.(*object.Error); !ok || errObj.Message != "script stopped: context deadline exceeded" {
+		t.Errorf("expected the script to stop, got %s", result.Inspect())
+	}
+	result = EvalContext(ctx, parse(`collect(sequence(1000000000000))`), object.NewEnvironment())
+	if errObj, ok := result.(*object.Error); !ok || errObj.Message != "script stopped: context deadline exceeded" {
+		t.Errorf("expected collect to stop, got %s", result.Inspect())
+	}
+	result = EvalContext(ctx, parse(`generate 16000000 rows with (id: row_number)`), object.NewEnvironment())
+	if errObj, ok := result.(*object.Error); !ok || errObj.Message != "script stopped: context deadline exceeded" {
+		t.Errorf("expected generate to stop, got %s", result.Inspect())
+	}
+
+	builtins["test_panic"] = &object.Builtin{Fn: func(env *object.Environment, args ...object.Object) object.Object { panic("boom") }}
+	defer delete(builtins, "test_panic")
+	result = EvalContext(context.Background(), parse(`test_panic()`), object.NewEnvironment())
+	if errObj, ok := result.(*object.Error); !ok || errObj.Message != "internal error: boom" {
+		t.Errorf("expected the panic as an error, got %s", result.Inspect())
+	}
+}
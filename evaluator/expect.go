@@ -0,0 +1,267 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// UpdateGolden makes expect_golden write the CSV it is given to the golden file instead of comparing them,
+// to create golden files or accept an intended change of behavior (`csvlang test --update-golden`).
+var UpdateGolden = false
+
+// maxDiffLines limits the rows shown when a golden file doesn't match
+const maxDiffLines = 20
+
+// Builtins for test scripts (eg. expect) are registered here, see `csvlang test`.
+// A failed check returns an error describing the difference, which fails the test it was called in.
+func init() {
+	builtins["expect"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError("wrong number of arguments: got=%d, want=2 or 3", len(args))
+			}
+			difference := objectDifference(args[0], args[1])
+			if difference == "" {
+				return NULL
+			}
+			return expectFailed(args[2:], difference)
+		},
+	}
+	builtins["assert_rows"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError("wrong number of arguments: got=%d, want=2 or 3", len(args))
+			}
+			csv, errObj := csvArg("assert_rows", args[0])
+			if errObj != nil {
+				return errObj
+			}
+			expected, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("row count of `assert_rows` must be INTEGER, got %s", args[1].Type())
+			}
+			if int64(len(csv.Rows)) == expected.Value {
+				return NULL
+			}
+			return expectFailed(args[2:], fmt.Sprintf("expected %d rows, got %d", expected.Value, len(csv.Rows)))
+		},
+	}
+	builtins["assert_schema"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError("wrong number of arguments: got=%d, want=2 or 3", len(args))
+			}
+			csv, errObj := csvArg("assert_schema", args[0])
+			if errObj != nil {
+				return errObj
+			}
+			columns, errObj := stringsArg("assert_schema", args[1])
+			if errObj != nil {
+				return errObj
+			}
+			if strings.Join(columns, ",") != strings.Join(csv.Headers, ",") {
+				return expectFailed(nil, fmt.Sprintf("expected columns [%s], got [%s]", strings.Join(columns, ", "), strings.Join(csv.Headers, ", ")))
+			}
+			if len(args) == 2 {
+				return NULL
+			}
+
+			types, errObj := stringsArg("assert_schema", args[2])
+			if errObj != nil {
+				return errObj
+			}
+			if len(types) != len(columns) {
+				return newError("`assert_schema` got %d types for %d columns", len(types), len(columns))
+			}
+			for i, column := range columns {
+				actual := columnType(csv, column)
+				if string(actual) != strings.ToUpper(types[i]) {
+					return expectFailed(nil, fmt.Sprintf("column %s: expected type %s, got %s", column, strings.ToUpper(types[i]), actual))
+				}
+			}
+			return NULL
+		},
+	}
+	builtins["expect_golden"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments: got=%d, want=2", len(args))
+			}
+			actual, errObj := csvArg("expect_golden", args[0])
+			if errObj != nil {
+				return errObj
+			}
+			path, ok := args[1].(*object.String)
+			if !ok {
+				return newError("golden file of `expect_golden` must be STRING, got %s", args[1].Type())
+			}
+
+			if err := checkFileAccess("read golden file", path.Value); err != nil {
+				return err
+			}
+			if UpdateGolden {
+				if err := os.MkdirAll(filepath.Dir(path.Value), 0755); err != nil {
+					return newError("could not create directory: %s", err)
+				}
+				return saveAsCSV(actual, path.Value)
+			}
+
+			content, err := os.ReadFile(path.Value)
+			if os.IsNotExist(err) {
+				return newError("golden file %s doesn't exist, run `csvlang test --update-golden` to create it", path.Value)
+			}
+			if err != nil {
+				return newError("could not open file: %s", err)
+			}
+			expected, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+			if err != nil {
+				return newError("could not read golden file %s: %s", path.Value, err)
+			}
+			if diff := goldenDiff(csvLines(expected), csvLines(csvRecords(actual))); diff != "" {
+				return newError("assertion failed: output differs from golden file %s (- expected, + actual)\n%s", path.Value, diff)
+			}
+			return NULL
+		},
+	}
+}
+
+// csvRecords returns the header and the rows of a CSV as they are written to a file
+func csvRecords(csv *object.CSV) [][]string {
+	records := [][]string{csv.Headers}
+	for _, row := range csv.Rows {
+		records = append(records, csv.Values(row))
+	}
+	return records
+}
+
+// csvLines encodes each record as a CSV line, so rows of a diff read like the file
+func csvLines(records [][]string) []string {
+	lines := make([]string, len(records))
+	for i, record := range records {
+		var line bytes.Buffer
+		writer := csv.NewWriter(&line)
+		writer.Write(record)
+		writer.Flush()
+		lines[i] = strings.TrimSuffix(line.String(), "\n")
+	}
+	return lines
+}
+
+// goldenDiff returns the lines removed (-) and added (+) to turn expected into actual, or "" if they are equal.
+// Removed lines are numbered by their line in the golden file, added lines by their line in the output.
+// Lines both have in common are matched with a longest common subsequence.
+func goldenDiff(expected, actual []string) string {
+	// common[i][j] is the length of the longest common subsequence of expected[i:] and actual[j:]
+	common := make([][]int, len(expected)+1)
+	for i := range common {
+		common[i] = make([]int, len(actual)+1)
+	}
+	for i := len(expected) - 1; i >= 0; i-- {
+		for j := len(actual) - 1; j >= 0; j-- {
+			if expected[i] == actual[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	lines := []string{}
+	i, j := 0, 0
+	for i < len(expected) || j < len(actual) {
+		switch {
+		case i < len(expected) && j < len(actual) && expected[i] == actual[j]:
+			i, j = i+1, j+1
+		case i < len(expected) && (j == len(actual) || common[i+1][j] >= common[i][j+1]):
+			lines = append(lines, fmt.Sprintf("  - %d: %s", i+1, expected[i]))
+			i++
+		default:
+			lines = append(lines, fmt.Sprintf("  + %d: %s", j+1, actual[j]))
+			j++
+		}
+	}
+	if len(lines) > maxDiffLines {
+		lines = append(lines[:maxDiffLines], fmt.Sprintf("  ... and %d more", len(lines)-maxDiffLines))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// expectFailed returns the error of a failed check, prefixed with the optional message of the check.
+func expectFailed(message []object.Object, difference string) *object.Error {
+	if len(message) == 1 {
+		return newError("assertion failed: %s: %s", message[0].Inspect(), difference)
+	}
+	return newError("assertion failed: %s", difference)
+}
+
+// columnType returns the inferred type of a column, or STRING if its type is unknown.
+func columnType(csv *object.CSV, column string) object.ObjectType {
+	for _, columnType := range csv.ColumnTypes {
+		if columnType.Name == column {
+			return columnType.DataType
+		}
+	}
+	return object.STRING_OBJ
+}
+
+// objectDifference compares an actual value with an expected one, arrays and CSVs are compared element by element.
+// It describes the first difference, or returns "" if the values are equal.
+func objectDifference(actual, expected object.Object) string {
+	switch expected := expected.(type) {
+	case *object.Array:
+		actual, ok := actual.(*object.Array)
+		if !ok {
+			break
+		}
+		if len(actual.Elements) != len(expected.Elements) {
+			return fmt.Sprintf("expected %d elements, got %d", len(expected.Elements), len(actual.Elements))
+		}
+		for i := range expected.Elements {
+			if difference := objectDifference(actual.Elements[i], expected.Elements[i]); difference != "" {
+				return fmt.Sprintf("element %d: %s", i, difference)
+			}
+		}
+		return ""
+	case *object.CSV:
+		actual, ok := actual.(*object.CSV)
+		if !ok {
+			break
+		}
+		return csvDifference(actual, expected)
+	case *object.Row:
+		actual, ok := actual.(*object.Row)
+		if !ok {
+			break
+		}
+		return csvDifference(&object.CSV{Headers: actual.Headers, Rows: []map[string]string{actual.Fields()}}, &object.CSV{Headers: expected.Headers, Rows: []map[string]string{expected.Fields()}})
+	}
+
+	if objectsEqual(actual, expected) {
+		return ""
+	}
+	return "expected " + expected.Inspect() + ", got " + actual.Inspect()
+}
+
+// csvDifference compares the headers and cells of two CSVs, cells are compared as written in the file.
+func csvDifference(actual, expected *object.CSV) string {
+	if strings.Join(actual.Headers, ",") != strings.Join(expected.Headers, ",") {
+		return "expected columns [" + strings.Join(expected.Headers, ", ") + "], got [" + strings.Join(actual.Headers, ", ") + "]"
+	}
+	if len(actual.Rows) != len(expected.Rows) {
+		return fmt.Sprintf("expected %d rows, got %d", len(expected.Rows), len(actual.Rows))
+	}
+	for i := range expected.Rows {
+		for _, header := range expected.Headers {
+			if actual.Rows[i][header] != expected.Rows[i][header] {
+				return fmt.Sprintf("row %d, column %s: expected %q, got %q", i, header, expected.Rows[i][header], actual.Rows[i][header])
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,194 @@
+package evaluator
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// matchScoreColumn holds the similarity of the matched rows of fuzzy_match, between 0 and 1
+const matchScoreColumn = "match_score"
+
+// similarities are the measures fuzzy_match can compare values with, returning 1 for equal values
+var similarities = map[string]func(a, b string) float64{
+	"jaro_winkler": jaroWinkler,
+	"levenshtein":  levenshteinSimilarity,
+}
+
+func init() {
+	// fuzzy_match(a, b, "name"[, threshold[, "levenshtein"]]) links the rows of a to the most similar row of b
+	builtins["fuzzy_match"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 3 || len(args) > 5 {
+				return newError("wrong number of arguments: got=%d, want=3 to 5", len(args))
+			}
+			a, b, column, errObj := keyedArgs("fuzzy_match", args)
+			if errObj != nil {
+				return errObj
+			}
+			threshold := 0.85
+			if len(args) > 3 {
+				switch arg := args[3].(type) {
+				case *object.Float:
+					threshold = arg.Value
+				case *object.Integer:
+					threshold = float64(arg.Value)
+				default:
+					return newError("threshold of `fuzzy_match` must be a number between 0 and 1, got %s", args[3].Inspect())
+				}
+				if threshold < 0 || threshold > 1 {
+					return newError("threshold of `fuzzy_match` must be a number between 0 and 1, got %s", args[3].Inspect())
+				}
+			}
+			similarity := similarities["jaro_winkler"]
+			if len(args) > 4 {
+				method, ok := args[4].(*object.String)
+				if !ok || similarities[method.Value] == nil {
+					return newError("method of `fuzzy_match` must be \"jaro_winkler\" or \"levenshtein\", got %s", args[4].Inspect())
+				}
+				similarity = similarities[method.Value]
+			}
+			return fuzzyMatch(a, b, column, threshold, similarity)
+		},
+	}
+}
+
+// fuzzyMatch adds to every row of a the columns of the row of b whose value in column is the most similar, and its score.
+// Values are compared ignoring case and surrounding whitespace. Rows without a match scoring at least threshold
+// keep the columns of b and the score empty. Columns of b that a already has are suffixed with _match.
+func fuzzyMatch(a, b *object.CSV, column string, threshold float64, similarity func(a, b string) float64) object.Object {
+	names := map[string]string{}
+	headers := append([]string{}, a.Headers...)
+	for _, header := range b.Headers {
+		name := header
+		if containsString(a.Headers, header) {
+			name = header + "_match"
+		}
+		if containsString(headers, name) || name == matchScoreColumn {
+			return newError("column already exists: %s", name)
+		}
+		names[header] = name
+		headers = append(headers, name)
+	}
+	if containsString(headers, matchScoreColumn) {
+		return newError("column already exists: %s", matchScoreColumn)
+	}
+	headers = append(headers, matchScoreColumn)
+
+	candidates := make([]string, len(b.Rows))
+	for i, row := range b.Rows {
+		candidates[i] = normalizeForMatch(row[column])
+	}
+	rows := make([]map[string]string, len(a.Rows))
+	for i, row := range a.Rows {
+		newRow := copyRow(row)
+		for _, header := range b.Headers {
+			newRow[names[header]] = ""
+		}
+		newRow[matchScoreColumn] = ""
+
+		value := normalizeForMatch(row[column])
+		best, bestScore := -1, threshold
+		for j, candidate := range candidates {
+			if score := similarity(value, candidate); score >= bestScore && (best == -1 || score > bestScore) {
+				best, bestScore = j, score
+			}
+		}
+		if best != -1 {
+			for _, header := range b.Headers {
+				newRow[names[header]] = b.Rows[best][header]
+			}
+			newRow[matchScoreColumn] = strconv.FormatFloat(math.Round(bestScore*1000)/1000, 'f', -1, 64)
+		}
+		rows[i] = newRow
+	}
+	return newTransformedCSV(a, headers, rows)
+}
+
+func normalizeForMatch(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of two strings, which favours strings sharing a prefix
+func jaroWinkler(a, b string) float64 {
+	s, t := []rune(a), []rune(b)
+	if len(s) == 0 && len(t) == 0 {
+		return 1
+	}
+	if len(s) == 0 || len(t) == 0 {
+		return 0
+	}
+
+	// characters match if they are equal and not farther apart than half the longer string
+	window := max(len(s), len(t))/2 - 1
+	window = max(window, 0)
+	sMatched, tMatched := make([]bool, len(s)), make([]bool, len(t))
+	matches := 0
+	for i := range s {
+		for j := max(0, i-window); j < min(len(t), i+window+1); j++ {
+			if !tMatched[j] && s[i] == t[j] {
+				sMatched[i], tMatched[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	transpositions, j := 0, 0
+	for i := range s {
+		if !sMatched[i] {
+			continue
+		}
+		for !tMatched[j] {
+			j++
+		}
+		if s[i] != t[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(s)) + m/float64(len(t)) + (m-float64(transpositions/2))/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(s), len(t)) && s[prefix] == t[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// levenshteinSimilarity returns 1 minus the edit distance of two strings divided by the length of the longer one
+func levenshteinSimilarity(a, b string) float64 {
+	longest := max(utf8.RuneCountInString(a), utf8.RuneCountInString(b))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(a, b))/float64(longest)
+}
+
+// levenshtein returns the number of insertions, deletions and substitutions turning a into b
+func levenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	previous := make([]int, len(t)+1)
+	current := make([]int, len(t)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		current[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(t)]
+}
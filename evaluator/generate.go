@@ -0,0 +1,52 @@
+package evaluator
+
+import (
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// evalGenerateExpression builds a CSV without an input file, evaluating the value of every column once per row.
+// Each row has its own scope where row_number is the number of the row from 1, eg.
+// `generate 1000 rows with (id: row_number, amount: rand_int(1, 500))`. A null value leaves the cell empty.
+func evalGenerateExpression(ge *ast.GenerateExpression, env *object.Environment) object.Object {
+	countObj := Eval(ge.Count, env)
+	if isError(countObj) {
+		return countObj
+	}
+	count, ok := countObj.(*object.Integer)
+	if !ok || count.Value < 0 {
+		return newError("number of rows to generate must be a non-negative INTEGER, got %s", countObj.Inspect())
+	}
+	if count.Value > MaxGenerateRows {
+		return newError("cannot generate %d rows, the limit is %d", count.Value, MaxGenerateRows)
+	}
+
+	headers := make([]string, len(ge.Columns))
+	for i, column := range ge.Columns {
+		headers[i] = column.Name
+	}
+	rows := []map[string]string{}
+	for n := int64(1); n <= count.Value; n++ {
+		if errObj := checkStopped(env); errObj != nil {
+			return errObj
+		}
+		rowEnv := object.NewEnclosedEnvironment(env)
+		rowEnv.Set("row_number", &object.Integer{Value: n})
+
+		row := make(map[string]string, len(ge.Columns))
+		for _, column := range ge.Columns {
+			value := Eval(column.Value, rowEnv)
+			if isError(value) {
+				return value
+			}
+			if value != NULL {
+				row[column.Name] = value.Inspect()
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	csv := &object.CSV{Headers: headers, Rows: rows}
+	csv.InferColumnTypes()
+	return csv
+}
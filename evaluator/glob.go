@@ -0,0 +1,100 @@
+package evaluator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// sourceFileColumn names the file of each row of a glob load with the source_file option
+const sourceFileColumn = "_source_file"
+
+// isGlob reports whether a filename is a pattern matching several files, eg. "logs/2024-*.csv".
+// A file named like a pattern, eg. "report[2024].csv", isn't one when it exists.
+func isGlob(filename string) bool {
+	if !strings.ContainsAny(filename, "*?[") {
+		return false
+	}
+	_, err := os.Stat(filename)
+	return err != nil
+}
+
+// loadGlob loads every file matching a pattern, in the order of their names, into one CSV.
+// The files must have the same headers, in any order, the order of the first file is kept.
+func loadGlob(ls *ast.LoadStatement, env *object.Environment) object.Object {
+	pattern := ls.Filename.String()
+	csvObj, err := readGlob(pattern, ls.Trim, ls.NumberLocale, ls.SourceFile)
+	if err != nil {
+		return newError("could not load %s: %s", pattern, err)
+	}
+	env.SetOutsideBlocks("filename", &object.String{Value: pattern})
+	env.SetOutsideBlocks("csv", csvObj)
+	return csvObj
+}
+
+func readGlob(pattern string, trim bool, locale string, sourceFile bool) (*object.CSV, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files match")
+	}
+	sort.Strings(paths)
+
+	var result *object.CSV
+	var headers []string // the headers of the first file
+	for _, path := range paths {
+		csvObj, err := readFile(path, trim, locale)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		if result == nil {
+			headers = csvObj.Headers
+			result = &object.CSV{Headers: append([]string{}, headers...), Rows: []map[string]string{}}
+			if sourceFile {
+				if containsString(headers, sourceFileColumn) {
+					return nil, fmt.Errorf("%s already has a %s column", path, sourceFileColumn)
+				}
+				result.Headers = append(result.Headers, sourceFileColumn)
+			}
+		} else if !sameHeaders(csvObj.Headers, headers) {
+			return nil, fmt.Errorf("%s has the headers %s, expected %s", path, strings.Join(csvObj.Headers, ", "), strings.Join(headers, ", "))
+		}
+		for _, row := range csvObj.Rows {
+			if sourceFile {
+				row[sourceFileColumn] = path
+			}
+			result.Rows = append(result.Rows, row)
+		}
+	}
+	result.InferColumnTypes()
+	return result, nil
+}
+
+// sameHeaders reports whether two files have the same headers, in any order
+func sameHeaders(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, header := range a {
+		if !containsString(b, header) {
+			return false
+		}
+	}
+	return true
+}
+
+func readFile(path string, trim bool, locale string) (*object.CSV, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadCSV(file, trim, locale)
+}
@@ -0,0 +1,303 @@
+package evaluator
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// googleSheetPrefix marks Google Sheets in load and save statements, eg. load "gsheet://<sheet-id>/Sheet1"
+const googleSheetPrefix = "gsheet://"
+
+// sheetsAPI is the Google Sheets API, tests point it to a fake server
+var sheetsAPI = "https://sheets.googleapis.com/v4/spreadsheets"
+
+// sheetsClient sends the requests to Google
+var sheetsClient = &http.Client{Timeout: time.Minute}
+
+// googleToken caches the access token of the service account until it expires
+var googleToken struct {
+	sync.Mutex
+	value   string
+	expires time.Time
+	account string
+}
+
+// serviceAccount holds the fields used from a service account key file
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// parseGoogleSheet splits gsheet://<sheet-id>/<range> into the id of the spreadsheet and the range, eg. Sheet1 or Sheet1!A1:D20
+func parseGoogleSheet(name string) (id, sheetRange string, err error) {
+	id, sheetRange, _ = strings.Cut(strings.TrimPrefix(name, googleSheetPrefix), "/")
+	if id == "" || sheetRange == "" {
+		return "", "", fmt.Errorf("invalid Google Sheet %s, expected gsheet://<sheet-id>/<sheet name>", name)
+	}
+	return id, sheetRange, nil
+}
+
+// loadGoogleSheet reads a range of a Google Sheet, its first row is the header.
+// Google leaves out the empty cells at the end of rows, they are added back.
+func loadGoogleSheet(name string, trim bool, locale string) (*object.CSV, error) {
+	id, sheetRange, err := parseGoogleSheet(name)
+	if err != nil {
+		return nil, err
+	}
+	var response struct {
+		Values [][]string `json:"values"`
+	}
+	if err := sheetsRequest(http.MethodGet, valuesURL(id, sheetRange, ""), nil, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Values) == 0 {
+		return nil, fmt.Errorf("could not read CSV headers: %s is empty", name)
+	}
+
+	// written back as CSV, so the sheet is read exactly like a loaded file
+	var text bytes.Buffer
+	writer := csv.NewWriter(&text)
+	width := 0
+	for _, row := range response.Values {
+		width = max(width, len(row))
+	}
+	for _, row := range response.Values {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+	return ReadCSV(&text, trim, locale)
+}
+
+// saveToGoogleSheet replaces the content of a range of a Google Sheet with the headers and rows of a CSV.
+// Cells of numeric columns are sent as numbers, the rest as text, so nothing is read as a formula.
+// The old content is replaced in a single write, so a failed save leaves it as it was.
+func saveToGoogleSheet(data *object.CSV, name string) object.Object {
+	id, sheetRange, err := parseGoogleSheet(name)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	numeric := map[string]bool{}
+	for _, columnType := range data.ColumnTypes {
+		numeric[columnType.Name] = columnType.DataType == object.INTEGER_OBJ || columnType.DataType == object.FLOAT_OBJ
+	}
+	values := [][]interface{}{}
+	headers := make([]interface{}, len(data.Headers))
+	for i, header := range data.Headers {
+		headers[i] = header
+	}
+	values = append(values, headers)
+	for _, row := range data.Rows {
+		cells := make([]interface{}, len(data.Headers))
+		for i, header := range data.Headers {
+			cells[i] = row[header]
+			if number, err := strconv.ParseFloat(row[header], 64); numeric[header] && err == nil {
+				cells[i] = number
+			}
+		}
+		values = append(values, cells)
+	}
+
+	// the cells of the old content past the new one are written empty
+	var current struct {
+		Values [][]interface{} `json:"values"`
+	}
+	if err := sheetsRequest(http.MethodGet, valuesURL(id, sheetRange, ""), nil, &current); err != nil {
+		return newError("could not save to %s: %s", name, err)
+	}
+	values = padValues(values, current.Values)
+	body := map[string]interface{}{"range": sheetRange, "majorDimension": "ROWS", "values": values}
+	if err := sheetsRequest(http.MethodPut, valuesURL(id, sheetRange, "")+"?valueInputOption=RAW", body, nil); err != nil {
+		return newError("could not save to %s: %s", name, err)
+	}
+	return NULL
+}
+
+// padValues adds empty cells to values so they cover the old values of a range, Google leaves out the empty cells
+// at the end of rows and the empty rows at the end of a range, so the old values are as large as the old content
+func padValues(values, old [][]interface{}) [][]interface{} {
+	width := 0
+	for _, row := range old {
+		width = max(width, len(row))
+	}
+	for len(values) < len(old) {
+		values = append(values, []interface{}{})
+	}
+	for i, row := range values {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		values[i] = row
+	}
+	return values
+}
+
+func valuesURL(id, sheetRange, action string) string {
+	return fmt.Sprintf("%s/%s/values/%s%s", sheetsAPI, url.PathEscape(id), url.PathEscape(sheetRange), action)
+}
+
+// sheetsRequest sends a request to the Sheets API, the body and the response are JSON
+func sheetsRequest(method, endpoint string, body, response interface{}) error {
+	token, err := googleAccessToken()
+	if err != nil {
+		return err
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := sheetsClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return googleError(resp)
+	}
+	if response == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+// googleError reads the message of a failed request, eg. "Requested entity was not found."
+func googleError(resp *http.Response) error {
+	var failure struct {
+		Error json.RawMessage `json:"error"`
+	}
+	content, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(content, &failure) == nil && len(failure.Error) > 0 {
+		var detail struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(failure.Error, &detail) == nil && detail.Message != "" {
+			return fmt.Errorf("google returned %s: %s", resp.Status, detail.Message)
+		}
+		var message string
+		if json.Unmarshal(failure.Error, &message) == nil {
+			return fmt.Errorf("google returned %s: %s", resp.Status, message)
+		}
+	}
+	return fmt.Errorf("google returned %s", resp.Status)
+}
+
+// googleAccessToken returns an access token of the service account whose key file is named by GOOGLE_APPLICATION_CREDENTIALS.
+// The service account signs a JWT which Google exchanges for a token, valid for an hour.
+func googleAccessToken() (string, error) {
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		return "", fmt.Errorf("GOOGLE_APPLICATION_CREDENTIALS must name the key file of a service account the sheet is shared with")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var account serviceAccount
+	if err := json.Unmarshal(content, &account); err != nil || account.ClientEmail == "" || account.PrivateKey == "" {
+		return "", fmt.Errorf("%s is not the key file of a service account", path)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	googleToken.Lock()
+	defer googleToken.Unlock()
+	if googleToken.account == account.ClientEmail && time.Now().Before(googleToken.expires) {
+		return googleToken.value, nil
+	}
+
+	assertion, err := signJWT(account, time.Now())
+	if err != nil {
+		return "", err
+	}
+	resp, err := sheetsClient.PostForm(account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not sign in as %s: %s", account.ClientEmail, googleError(resp))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+
+	googleToken.value = token.AccessToken
+	googleToken.account = account.ClientEmail
+	// renewed a minute early, so a token doesn't expire during a request
+	googleToken.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return token.AccessToken, nil
+}
+
+// signJWT creates the assertion a service account exchanges for an access token to the Sheets API
+func signJWT(account serviceAccount, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("the private key of %s is not PEM encoded", account.ClientEmail)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("could not read the private key of %s: %s", account.ClientEmail, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("the private key of %s is not an RSA key", account.ClientEmail)
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   account.ClientEmail,
+		"scope": "https://www.googleapis.com/auth/spreadsheets",
+		"aud":   account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
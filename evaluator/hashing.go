@@ -0,0 +1,66 @@
+package evaluator
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+
+	"github.com/Rishabh570/csvlang/object"
+)
+
+func init() {
+	// md5("ann@x.io") and sha256("ann@x.io") return the hex digest of a value, eg. to build stable row keys
+	builtins["md5"] = hashBuiltin("md5", md5.New)
+	builtins["sha256"] = hashBuiltin("sha256", sha256.New)
+
+	// uuid() returns a random version 4 UUID, eg. "9b2f0c1e-5d7a-4c36-8f0e-2a1b3c4d5e6f"
+	builtins["uuid"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments: got=%d, want=0", len(args))
+			}
+			return &object.String{Value: newUUID()}
+		},
+	}
+}
+
+// hashBuiltin returns a builtin hashing a string, numbers are hashed as they are printed
+func hashBuiltin(name string, newHash func() hash.Hash) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			var value string
+			switch arg := args[0].(type) {
+			case *object.String:
+				value = arg.Value
+			case *object.Integer, *object.Float:
+				value = arg.Inspect()
+			default:
+				return newError("argument to `%s` must be STRING, got %s", name, args[0].Type())
+			}
+			h := newHash()
+			h.Write([]byte(value))
+			return &object.String{Value: hex.EncodeToString(h.Sum(nil))}
+		},
+	}
+}
+
+// newUUID returns a random UUID as defined by RFC 4122, version 4, drawn from the seeded source in deterministic runs
+func newUUID() string {
+	var b [16]byte
+	if deterministic {
+		random.Lock()
+		random.Read(b[:])
+		random.Unlock()
+	} else {
+		rand.Read(b[:])
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
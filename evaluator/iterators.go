@@ -0,0 +1,243 @@
+package evaluator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/object"
+	"github.com/Rishabh570/csvlang/token"
+)
+
+// maxLineSize is the longest line lines() reads, longer lines fail the loop reading them
+const maxLineSize = 16 << 20
+
+func init() {
+	// sequence(1000000) counts like range, but one integer at a time instead of building an array
+	builtins["sequence"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			start, end, step, errObj := rangeArguments("sequence", args)
+			if errObj != nil {
+				return errObj
+			}
+			return integerSequence(start, end, step)
+		},
+	}
+	// lines("app.log") reads the lines of a file as a for loop goes through them
+	builtins["lines"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			filename, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `lines` must be STRING, got %s", args[0].Type())
+			}
+			return fileLines(filename.Value)
+		},
+	}
+	// rows("big.csv") reads the rows of a file as a for loop goes through them, rows(csv) goes through a loaded or mapped CSV
+	builtins["rows"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			switch source := args[0].(type) {
+			case *object.String:
+				return fileRows(source.Value)
+			case *object.MappedCSV:
+				reader := csv.NewReader(bytes.NewReader(source.Data))
+				return recordRows("rows of "+source.Filename, append([]string{}, source.Headers...), reader, nil)
+			case *object.CSV:
+				return csvRows(source)
+			default:
+				return newError("argument to `rows` must be STRING, CSV or MAPPED_CSV, got %s", args[0].Type())
+			}
+		},
+	}
+	// collect(it) reads the rest of an iterator, rows are collected in a CSV and other values in an array
+	builtins["collect"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			it, ok := args[0].(*object.Iterator)
+			if !ok {
+				return newError("argument to `collect` must be ITERATOR, got %s", args[0].Type())
+			}
+			return collect(it, env)
+		},
+	}
+}
+
+// evalIteratorForLoop goes through the values of an iterator, eg. for i, line in lines("app.log") { ... }.
+// The iterator is closed when the loop ends, even when its body returns or fails.
+func evalIteratorForLoop(fl *ast.ForLoopExpression, it *object.Iterator, env *object.Environment) object.Object {
+	defer it.Close()
+	for i := 0; ; i++ {
+		if errObj := checkStopped(env); errObj != nil {
+			return errObj
+		}
+		value, ok, err := it.Next()
+		if err != nil {
+			return newError("could not read %s: %s", it.Of, err)
+		}
+		if !ok {
+			return NULL
+		}
+
+		// Create new scope for each iteration
+		loopEnv := object.NewBlockEnvironment(env)
+
+		// Bind index and value
+		loopEnv.Set(fl.IndexName.Value, &object.Integer{Value: int64(i)})
+		loopEnv.Set(fl.ElementName.Value, value)
+
+		result := Eval(fl.Body, loopEnv)
+		if isLoopExit(result) {
+			return result
+		}
+	}
+}
+
+// iteratorHead stops an iterator after its first n values, eg. head(lines("app.log"), 5)
+func iteratorHead(it *object.Iterator, n int64) *object.Iterator {
+	taken := int64(0)
+	return object.NewIterator(fmt.Sprintf("the first %d %s", n, it.Of), func() (object.Object, bool, error) {
+		if taken == n {
+			return nil, false, nil
+		}
+		taken++
+		return it.Next()
+	}, it.Close)
+}
+
+// integerSequence counts from start up to end, one integer at a time, see integerRange.
+// It stops before overflowing an int64, eg. sequence(9223372036854775800, 9223372036854775807, 5) has two integers.
+func integerSequence(start, end, step int64) *object.Iterator {
+	i, done := start, false
+	return object.NewIterator(fmt.Sprintf("sequence(%d, %d, %d)", start, end, step), func() (object.Object, bool, error) {
+		if done || (step > 0 && i >= end) || (step < 0 && i <= end) {
+			return nil, false, nil
+		}
+		value := &object.Integer{Value: i}
+		if addFits(i, step) {
+			i += step
+		} else {
+			done = true
+		}
+		return value, true, nil
+	}, nil)
+}
+
+// fileLines reads the lines of a file one at a time, without their line breaks
+func fileLines(filename string) object.Object {
+	if err := checkFileAccess("read", filename); err != nil {
+		return err
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return newError("could not open file: %s", err)
+	}
+	traceLoad(token.Position{}, filename, -1)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxLineSize)
+	return object.NewIterator("lines of "+filename, func() (object.Object, bool, error) {
+		if !scanner.Scan() {
+			return nil, false, scanner.Err()
+		}
+		return &object.String{Value: scanner.Text()}, true, nil
+	}, func() { file.Close() })
+}
+
+// fileRows reads the rows of a CSV file one at a time, its first line is the header
+func fileRows(filename string) object.Object {
+	if err := checkFileAccess("load", filename); err != nil {
+		return err
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return newError("could not open file: %s", err)
+	}
+	reader := csv.NewReader(file)
+	headers, err := reader.Read()
+	if err != nil {
+		file.Close()
+		return newError("could not read CSV headers: %s", err)
+	}
+	traceLoad(token.Position{}, filename, -1)
+	return recordRows("rows of "+filename, headers, reader, func() { file.Close() })
+}
+
+// recordRows turns the records of a reader into rows, each record has a cell per header
+func recordRows(of string, headers []string, reader *csv.Reader, close func()) *object.Iterator {
+	reader.FieldsPerRecord = len(headers)
+	reader.ReuseRecord = true
+	return object.NewIterator(of, func() (object.Object, bool, error) {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return &object.Row{Headers: headers, Values: recordToRow(headers, record, false, "")}, true, nil
+	}, close)
+}
+
+// csvRows goes through the rows of a loaded CSV, the rows are backed by the CSV as in a for loop over it
+func csvRows(csv *object.CSV) *object.Iterator {
+	i := 0
+	return object.NewIterator("rows of a CSV", func() (object.Object, bool, error) {
+		if i >= len(csv.Rows) {
+			return nil, false, nil
+		}
+		row := &object.Row{Headers: csv.Headers, Values: csv.Rows[i], CSV: csv, Index: i}
+		i++
+		return row, true, nil
+	}, nil)
+}
+
+// collect reads the rest of an iterator. Rows are collected in a CSV with the headers of the first row,
+// other values in an array.
+func collect(it *object.Iterator, env *object.Environment) object.Object {
+	defer it.Close()
+	values := []object.Object{}
+	for {
+		if errObj := checkStopped(env); errObj != nil {
+			return errObj
+		}
+		value, ok, err := it.Next()
+		if err != nil {
+			return newError("could not read %s: %s", it.Of, err)
+		}
+		if !ok {
+			break
+		}
+		values = append(values, value)
+	}
+
+	if len(values) == 0 {
+		return &object.Array{Elements: values}
+	}
+	rows := make([]map[string]string, len(values))
+	for i, value := range values {
+		row, ok := value.(*object.Row)
+		if !ok {
+			return &object.Array{Elements: values}
+		}
+		// a row of a CSV is shared with it, eg. collect(rows(csv))
+		if row.CSV != nil {
+			row.CSV.ShareRows()
+		}
+		rows[i] = row.Fields()
+	}
+	csvObj := &object.CSV{Headers: values[0].(*object.Row).Headers, Rows: rows}
+	csvObj.InferColumnTypes()
+	csvObj.ParseCells()
+	return csvObj
+}
@@ -0,0 +1,224 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// Builtins matching the rows of two CSVs by a key column (eg. diff, upsert, except) are registered here.
+// Rows are matched through a map of the keys, so large CSVs aren't compared row by row.
+func init() {
+	// diff(a, b, "id") or diff(a, b, "id", ["price", "qty"]) reports the rows added, removed and changed from a to b
+	builtins["diff"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 3 && len(args) != 4 {
+				return newError("wrong number of arguments: got=%d, want=3 or 4", len(args))
+			}
+			a, b, key, errObj := keyedArgs("diff", args)
+			if errObj != nil {
+				return errObj
+			}
+			var columns []string
+			if len(args) == 4 {
+				if columns, errObj = stringsArg("diff", args[3]); errObj != nil {
+					return errObj
+				}
+			}
+			result, err := Diff(a, b, key, columns)
+			if err != nil {
+				return newError("diff: %s", err)
+			}
+			return result
+		},
+	}
+
+	// except(a, b, "email") keeps the rows of a whose key isn't in b, eg. customers not yet in a mailing list
+	builtins["except"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			return keyedFilter("except", args, false)
+		},
+	}
+
+	// intersect(a, b, "email") keeps the rows of a whose key is also in b
+	builtins["intersect"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			return keyedFilter("intersect", args, true)
+		},
+	}
+
+	// upsert(target, updates, "id") replaces the rows of target whose key is in updates, and appends the others
+	builtins["upsert"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments: got=%d, want=3", len(args))
+			}
+			target, updates, key, errObj := keyedArgs("upsert", args)
+			if errObj != nil {
+				return errObj
+			}
+			result, err := upsert(target, updates, key)
+			if err != nil {
+				return newError("upsert: %s", err)
+			}
+			return result
+		},
+	}
+}
+
+// keyedArgs checks the arguments shared by the keyed builtins, two CSVs and a key column both have
+func keyedArgs(name string, args []object.Object) (*object.CSV, *object.CSV, string, object.Object) {
+	a, errObj := csvArg(name, args[0])
+	if errObj != nil {
+		return nil, nil, "", errObj
+	}
+	b, ok := args[1].(*object.CSV)
+	if !ok {
+		return nil, nil, "", newError("second argument to `%s` must be CSV, got %s", name, args[1].Type())
+	}
+	key, errObj := columnArg(name, a, args[2])
+	if errObj != nil {
+		return nil, nil, "", errObj
+	}
+	if !containsString(b.Headers, key) {
+		return nil, nil, "", newError("column not found in second CSV: %s", key)
+	}
+	return a, b, key, nil
+}
+
+// keyedFilter keeps the rows of a whose key is in b, or isn't when in is false. Duplicate keys are allowed in both.
+func keyedFilter(name string, args []object.Object, in bool) object.Object {
+	if len(args) != 3 {
+		return newError("wrong number of arguments: got=%d, want=3", len(args))
+	}
+	a, b, key, errObj := keyedArgs(name, args)
+	if errObj != nil {
+		return errObj
+	}
+	keys := make(map[string]bool, len(b.Rows))
+	for _, row := range b.Rows {
+		keys[row[key]] = true
+	}
+	// kept rows are shared with a
+	rows := []map[string]string{}
+	for _, row := range a.ShareRows() {
+		if keys[row[key]] == in {
+			rows = append(rows, row)
+		}
+	}
+	return newTransformedCSV(a, a.Headers, rows)
+}
+
+// keyIndex maps the keys of a CSV to the index of their row, keys must be unique
+func keyIndex(csv *object.CSV, key string) (map[string]int, error) {
+	index := make(map[string]int, len(csv.Rows))
+	for i, row := range csv.Rows {
+		if _, ok := index[row[key]]; ok {
+			return nil, fmt.Errorf("duplicate key %s=%q in row %d", key, row[key], i)
+		}
+		index[row[key]] = i
+	}
+	return index, nil
+}
+
+// diffHeaders are the columns of a diff besides the key column
+var diffHeaders = []string{"change", "column", "old", "new"}
+
+// Diff compares two CSVs whose rows are identified by a key column, and returns a CSV with a row per difference:
+// an "added" or "removed" row per key found in only one of them, and a "changed" row per cell that differs,
+// naming its column with its old and new value.
+// Only the given columns are compared, or every column when columns is empty. Removed and changed rows come
+// in the order of a, added rows in the order of b.
+func Diff(a, b *object.CSV, key string, columns []string) (*object.CSV, error) {
+	if containsString(diffHeaders, key) {
+		return nil, fmt.Errorf("the key column can't be named %s", key)
+	}
+	if !containsString(a.Headers, key) || !containsString(b.Headers, key) {
+		return nil, fmt.Errorf("both CSVs need the key column %s", key)
+	}
+	if len(columns) == 0 {
+		for _, header := range append(append([]string{}, a.Headers...), b.Headers...) {
+			if header != key && !containsString(columns, header) {
+				columns = append(columns, header)
+			}
+		}
+	}
+	for _, column := range columns {
+		if !containsString(a.Headers, column) && !containsString(b.Headers, column) {
+			return nil, fmt.Errorf("column not found: %s", column)
+		}
+	}
+	aIndex, err := keyIndex(a, key)
+	if err != nil {
+		return nil, fmt.Errorf("first CSV: %s", err)
+	}
+	bIndex, err := keyIndex(b, key)
+	if err != nil {
+		return nil, fmt.Errorf("second CSV: %s", err)
+	}
+
+	rows := []map[string]string{}
+	change := func(kind, id, column, before, after string) {
+		rows = append(rows, map[string]string{"change": kind, key: id, "column": column, "old": before, "new": after})
+	}
+	for _, row := range a.Rows {
+		i, ok := bIndex[row[key]]
+		if !ok {
+			change("removed", row[key], "", "", "")
+			continue
+		}
+		for _, column := range columns {
+			if before, after := row[column], b.Rows[i][column]; before != after {
+				change("changed", row[key], column, before, after)
+			}
+		}
+	}
+	for _, row := range b.Rows {
+		if _, ok := aIndex[row[key]]; !ok {
+			change("added", row[key], "", "", "")
+		}
+	}
+
+	headers := []string{"change", key, "column", "old", "new"}
+	result := &object.CSV{Headers: headers, Rows: rows}
+	result.InferColumnTypes()
+	return result, nil
+}
+
+// upsert returns the rows of target with the rows of updates merged in by key: a row of target whose key is in updates
+// takes the cells of the update, columns updates doesn't have are kept, and updates with a new key are appended in order.
+// Columns only updates has are added, empty in the rows it doesn't update.
+func upsert(target, updates *object.CSV, key string) (*object.CSV, error) {
+	targetIndex, err := keyIndex(target, key)
+	if err != nil {
+		return nil, fmt.Errorf("first CSV: %s", err)
+	}
+	if _, err := keyIndex(updates, key); err != nil {
+		return nil, fmt.Errorf("second CSV: %s", err)
+	}
+
+	headers := append([]string{}, target.Headers...)
+	for _, header := range updates.Headers {
+		if !containsString(headers, header) {
+			headers = append(headers, header)
+		}
+	}
+	rows := make([]map[string]string, len(target.Rows), len(target.Rows)+len(updates.Rows))
+	for i, row := range target.ShareRows() {
+		rows[i] = row
+	}
+	for _, update := range updates.Rows {
+		i, ok := targetIndex[update[key]]
+		if !ok {
+			rows = append(rows, copyRow(update))
+			continue
+		}
+		row := copyRow(rows[i])
+		for _, header := range updates.Headers {
+			row[header] = update[header]
+		}
+		rows[i] = row
+	}
+	// the types of the updated columns are inferred again, eg. an updated price may now be a FLOAT
+	return newTransformedCSV(target, headers, rows, updates.Headers...), nil
+}
@@ -0,0 +1,44 @@
+package evaluator
+
+import (
+	"context"
+
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// MaxCallDepth is how deep function calls can nest, so a runaway recursion fails with an error
+// instead of overflowing the stack, which would crash the process running it, eg. a server
+var MaxCallDepth int64 = 10000
+
+// MaxRangeLength is the number of integers a range can hold, eg. 0..n, so a range too long fails with an error
+// instead of taking all the memory of the process building it
+var MaxRangeLength int64 = 1 << 24
+
+// MaxGenerateRows is the number of rows generate can build, eg. generate n rows with (...), so a count too large
+// fails with an error instead of taking all the memory of the process building it
+var MaxGenerateRows int64 = 1 << 24
+
+// EvalContext evaluates a program like Eval, it stops with an error once ctx is done, eg. when a request times out.
+// A panic while evaluating, eg. in a builtin, is returned as an error too, so a script can't take down the process running it.
+// The run ends with the program, see EndRun, and is closed.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment) (result object.Object) {
+	env.SetContext(ctx)
+	defer env.Close()
+	defer func() {
+		if r := recover(); r != nil {
+			env.End(false)
+			result = newError("internal error: %v", r)
+		}
+	}()
+	return EndRun(env, Eval(node, env))
+}
+
+// checkStopped returns an error once the run env belongs to is stopped, see EvalContext.
+// Loops and calls check it, so every script that runs for long comes across it.
+func checkStopped(env *object.Environment) object.Object {
+	if err := env.Err(); err != nil {
+		return newError("script stopped: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package evaluator
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// CacheDir keeps the parsed rows and column types of the files loaded, so loading them again skips parsing,
+// eg. in a REPL session or when a script is rerun. Empty turns the cache off, as `csvlang -no-cache` does.
+var CacheDir = ""
+
+// cacheMinSize is the size of the smallest file cached, smaller files parse faster than the cache is read
+var cacheMinSize int64 = 1 << 20
+
+// cacheVersion changes when the entries are written differently, so older entries are parsed again
+const cacheVersion = 1
+
+// cacheEntry is a file parsed with some options, as of its size and modification time
+type cacheEntry struct {
+	Version     int
+	Size        int64
+	ModTime     time.Time
+	Headers     []string
+	ColumnTypes []object.ColumnType
+	Records     [][]string
+}
+
+// readCSVFile loads a CSV file, from CacheDir when it was parsed with the same options since it last changed
+func readCSVFile(filename string, trim bool, locale string) (*object.CSV, object.Object) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, newError("could not open file: %s", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || CacheDir == "" || !info.Mode().IsRegular() || info.Size() < cacheMinSize {
+		csvObj, err := ReadCSV(file, trim, locale)
+		if err != nil {
+			return nil, newError("%s", err)
+		}
+		return csvObj, nil
+	}
+
+	path := cachePath(filename, trim, locale)
+	if csvObj := readCacheEntry(path, info); csvObj != nil {
+		return csvObj, nil
+	}
+	csvObj, err := ReadCSV(file, trim, locale)
+	if err != nil {
+		return nil, newError("%s", err)
+	}
+	writeCacheEntry(path, info, csvObj)
+	return csvObj, nil
+}
+
+// cachePath returns the entry of a file loaded with some options, a file has one entry per options which is replaced when it changes
+func cachePath(filename string, trim bool, locale string) string {
+	if abs, err := filepath.Abs(filename); err == nil {
+		filename = abs
+	}
+	key := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%t\x00%s", filename, trim, locale)))
+	return filepath.Join(CacheDir, hex.EncodeToString(key[:])+".gob")
+}
+
+// readCacheEntry returns the CSV of an entry, nil when there is none or the file changed since it was written
+func readCacheEntry(path string, info os.FileInfo) *object.CSV {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var entry cacheEntry
+	if err := gob.NewDecoder(file).Decode(&entry); err != nil || entry.Version != cacheVersion ||
+		entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return nil
+	}
+	csvObj := entry.csv()
+	csvObj.ParseCells()
+	return csvObj
+}
+
+// csv returns the CSV an entry was written from
+func (entry *cacheEntry) csv() *object.CSV {
+	rows := make([]map[string]string, len(entry.Records))
+	for i, record := range entry.Records {
+		rows[i] = recordToRow(entry.Headers, record, false, "")
+	}
+	return &object.CSV{Headers: entry.Headers, ColumnTypes: entry.ColumnTypes, Rows: rows}
+}
+
+// writeCacheEntry stores a parsed file, the cache is only an optimization so it is left as it is if it can't be written
+func writeCacheEntry(path string, info os.FileInfo, csvObj *object.CSV) {
+	if err := os.MkdirAll(CacheDir, 0755); err != nil {
+		return
+	}
+	// written aside and renamed, so runs reading the entry at the same time never see half of it
+	temp, err := os.CreateTemp(CacheDir, "entry-*.tmp")
+	if err != nil {
+		return
+	}
+	entry := cacheEntry{
+		Version:     cacheVersion,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		Headers:     csvObj.Headers,
+		ColumnTypes: csvObj.ColumnTypes,
+		Records:     csvObj.Records(),
+	}
+	err = gob.NewEncoder(temp).Encode(entry)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+}
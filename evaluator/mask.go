@@ -0,0 +1,137 @@
+package evaluator
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode"
+
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// fakeFirstNames and fakeLastNames make up the names of mask(rows, "name", "fake")
+var (
+	fakeFirstNames = []string{"Alex", "Billie", "Casey", "Dana", "Eden", "Finley", "Gray", "Harper", "Indy", "Jordan", "Kai", "Logan", "Morgan", "Noel", "Oakley", "Parker", "Quinn", "Riley", "Sage", "Taylor"}
+	fakeLastNames  = []string{"Abbott", "Brooks", "Carter", "Dalton", "Ellis", "Foster", "Garner", "Hayes", "Irwin", "Jensen", "Keller", "Lowe", "Mercer", "Nolan", "Osborne", "Porter", "Reyes", "Sutton", "Turner", "Walsh"}
+)
+
+func init() {
+	// mask(rows, "email", "hash"[, secret]), mask(rows, "ssn", "redact") or mask(rows, "name", "fake"[, secret]) anonymizes a column
+	builtins["mask"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 3 && len(args) != 4 {
+				return newError("wrong number of arguments: got=%d, want=3 or 4", len(args))
+			}
+			csv, errObj := csvArg("mask", args[0])
+			if errObj != nil {
+				return errObj
+			}
+			column, errObj := columnArg("mask", csv, args[1])
+			if errObj != nil {
+				return errObj
+			}
+			method, ok := args[2].(*object.String)
+			if !ok {
+				return newError("method of `mask` must be \"hash\", \"redact\" or \"fake\", got %s", args[2].Inspect())
+			}
+			secret := ""
+			if len(args) == 4 {
+				value, ok := args[3].(*object.String)
+				if !ok || method.Value == "redact" {
+					return newError("only \"hash\" and \"fake\" take a secret, as a STRING, got %s", args[3].Inspect())
+				}
+				secret = value.Value
+			}
+
+			var mask func(string) string
+			switch method.Value {
+			case "hash":
+				mask = func(value string) string { return hashCell(value, secret) }
+			case "redact":
+				mask = redact
+			case "fake":
+				mask = func(value string) string { return fake(value, secret) }
+			default:
+				return newError("method of `mask` must be \"hash\", \"redact\" or \"fake\", got %s", args[2].Inspect())
+			}
+
+			// empty cells stay empty, so missing values are still missing, and their rows are shared with csv
+			rows := make([]map[string]string, len(csv.Rows))
+			for i, row := range csv.ShareRows() {
+				rows[i] = row
+				if row[column] != "" {
+					rows[i] = copyRow(row)
+					rows[i][column] = mask(row[column])
+				}
+			}
+			return newTransformedCSV(csv, csv.Headers, rows, column)
+		},
+	}
+}
+
+// hashCell replaces a value with the hex SHA-256 of it, or its HMAC with a secret so the values can't be guessed
+// by hashing candidates. Equal values have equal hashes, so the column can still be joined on.
+func hashCell(value, secret string) string {
+	return hex.EncodeToString(cellDigest(value, secret))
+}
+
+// cellDigest returns the SHA-256 of a value, or its HMAC with a secret, see hashCell
+func cellDigest(value, secret string) []byte {
+	if secret == "" {
+		sum := sha256.Sum256([]byte(value))
+		return sum[:]
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// redact replaces every letter and digit with *, keeping the punctuation so the format stays visible, eg. ***-**-****
+func redact(value string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return '*'
+		}
+		return r
+	}, value)
+}
+
+// fake replaces a value with a made up one of the same kind: an email, a number of as many digits or a name.
+// A value is always replaced by the same fake one, so the column can still be joined on. The fake values are made
+// from the digest of the value, see cellDigest: without a secret, values can be found back by faking candidates.
+func fake(value, secret string) string {
+	sum := cellDigest(value, secret)
+	seed := binary.BigEndian.Uint64(sum[:8])
+
+	if _, err := mail.ParseAddress(value); err == nil && strings.Contains(value, "@") {
+		return fmt.Sprintf("user%012d@example.com", seed%1000000000000)
+	}
+	if isDigits(value) {
+		digits := make([]byte, len(value))
+		for i := range digits {
+			digits[i] = '0' + sum[i%len(sum)]%10
+		}
+		// keep the number as long as the original
+		if len(digits) > 1 && digits[0] == '0' && value[0] != '0' {
+			digits[0] = '1'
+		}
+		return string(digits)
+	}
+	// the number tells apart the people given the same name, there are only so many names
+	first, last := seed%uint64(len(fakeFirstNames)), (seed/uint64(len(fakeFirstNames)))%uint64(len(fakeLastNames))
+	number := binary.BigEndian.Uint64(sum[8:16]) % 1000000
+	return fmt.Sprintf("%s %s %06d", fakeFirstNames[first], fakeLastNames[last], number)
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
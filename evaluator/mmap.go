@@ -0,0 +1,108 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"os"
+	"runtime"
+
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// errStopScan stops the scan of a mapped file, eg. when a where clause can't be evaluated
+var errStopScan = errors.New("scan stopped")
+
+// loadMapped maps a file in memory instead of loading its rows, eg. load big.csv readonly mmap.
+// Only the headers are read, rows are parsed by the reads, see readMapped.
+func loadMapped(ls *ast.LoadStatement, env *object.Environment) object.Object {
+	if ls.Trim || ls.NumberLocale != "" || len(ls.NAValues) > 0 {
+		return newError("mapped files are read as they are, they can't be loaded with trim, numbers or na values")
+	}
+	if ls.Quarantine != "" || ls.Database != "" || ls.StateFile != "" || isGlob(ls.Filename.String()) || ls.SourceFile {
+		return newError("only a single file can be mapped")
+	}
+	filename := ls.Filename.String()
+	file, err := os.Open(filename)
+	if err != nil {
+		return newError("could not open file: %s", err)
+	}
+	defer file.Close()
+
+	data, unmap, err := mapFile(file)
+	if err != nil {
+		return newError("could not map file: %s", err)
+	}
+	headerEnd := bytes.IndexByte(data, '\n') + 1
+	if headerEnd == 0 {
+		headerEnd = len(data)
+	}
+	headers, err := csv.NewReader(bytes.NewReader(data[:headerEnd])).Read()
+	if err != nil {
+		unmap()
+		return newError("could not read CSV headers: %s", err)
+	}
+
+	mapped := &object.MappedCSV{Filename: filename, Headers: headers, Data: data[headerEnd:]}
+	// the memory is released once nothing refers to the file, eg. after unload csv, reads copy the cells they return
+	runtime.SetFinalizer(mapped, func(*object.MappedCSV) { unmap() })
+
+	env.SetOutsideBlocks("filename", &object.String{Value: filename})
+	env.SetOutsideBlocks("csv", mapped)
+	return mapped
+}
+
+// readMapped evaluates a read of a mapped file, eg. read row 10 or read row * where status == "failed".
+// The selected rows are parsed and filtered one at a time, only the matching rows are kept.
+func readMapped(rs *ast.ReadExpression, mapped *object.MappedCSV, env *object.Environment) object.Object {
+	headers := append([]string{}, mapped.Headers...)
+	rows := []map[string]string{}
+	// the cells of a mapped file aren't typed, they are parsed as they are compared
+	scan := newRowScan(nil)
+	keep := func(i int, record []string) object.Object {
+		row := recordToRow(headers, record, false, "")
+		if rs.Location.Filter != nil {
+			matched, errObj := evaluateCondition(scan, i, row, rs.Location.Filter, env)
+			if errObj != nil || !matched {
+				return errObj
+			}
+		}
+		rows = append(rows, row)
+		return nil
+	}
+
+	switch index := rs.Location.RowIndex; {
+	case index == -2:
+		var errObj object.Object
+		err := mapped.Scan(func(i int, record []string) error {
+			if errObj = keep(i, record); errObj != nil {
+				return errStopScan
+			}
+			return nil
+		})
+		if errObj != nil {
+			return errObj
+		}
+		if err != nil {
+			return newError("%s", err)
+		}
+	case index >= 0:
+		record, ok, err := mapped.Row(index)
+		if err != nil {
+			return newError("%s", err)
+		}
+		if ok {
+			if errObj := keep(index, record); errObj != nil {
+				return errObj
+			}
+		}
+	}
+
+	csvObj := &object.CSV{Headers: headers, Rows: rows}
+	csvObj.InferColumnTypes()
+	if rs.Location.ColIndex != "" && rs.Location.ColIndex != "*" {
+		return extractColumn(csvObj, rows, rs.Location.ColIndex)
+	}
+	return csvObj
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package evaluator
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads a file in memory, files are only mapped on unix systems
+func mapFile(file *os.File) (data []byte, unmap func() error, err error) {
+	data, err = io.ReadAll(file)
+	return data, func() error { return nil }, err
+}
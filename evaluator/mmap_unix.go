@@ -0,0 +1,25 @@
+//go:build unix
+
+package evaluator
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps a file read only in memory, unmap releases the memory
+func mapFile(file *os.File) (data []byte, unmap func() error, err error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	// an empty file can't be mapped
+	if info.Size() == 0 {
+		return []byte{}, func() error { return nil }, nil
+	}
+	data, err = syscall.Mmap(int(file.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
@@ -0,0 +1,219 @@
+package evaluator
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// numberFormat describes how numbers are written in a locale, eg. 1.234,56 in "de"
+type numberFormat struct {
+	groupSeparators  []string
+	decimalSeparator string
+}
+
+// numberLocales holds the locales accepted by `load data.csv numbers "de"` and parse_number
+var numberLocales = map[string]numberFormat{
+	"en": {groupSeparators: []string{","}, decimalSeparator: "."},
+	"de": {groupSeparators: []string{"."}, decimalSeparator: ","},
+	"es": {groupSeparators: []string{"."}, decimalSeparator: ","},
+	"it": {groupSeparators: []string{"."}, decimalSeparator: ","},
+	"nl": {groupSeparators: []string{"."}, decimalSeparator: ","},
+	"pt": {groupSeparators: []string{"."}, decimalSeparator: ","},
+	"fr": {groupSeparators: []string{" ", "\u00a0", "\u202f"}, decimalSeparator: ","},
+}
+
+// numberPatterns caches the regular expression matching the numbers of each locale
+var numberPatterns = map[string]*regexp.Regexp{}
+
+func init() {
+	for locale, format := range numberLocales {
+		groups := make([]string, len(format.groupSeparators))
+		for i, separator := range format.groupSeparators {
+			groups[i] = regexp.QuoteMeta(separator)
+		}
+		// either digits without grouping (1234,5) or groups of three digits (1.234,5)
+		numberPatterns[locale] = regexp.MustCompile(`^[+-]?(\d+|\d{1,3}((` + strings.Join(groups, "|") + `)\d{3})+)(` + regexp.QuoteMeta(format.decimalSeparator) + `\d+)?$`)
+	}
+
+	// parse_number("1.234,56", "de") returns 1234.56
+	builtins["parse_number"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments: got=%d, want=2", len(args))
+			}
+			value, ok := args[0].(*object.String)
+			if !ok {
+				return newError("first argument to `parse_number` must be STRING, got %s", args[0].Type())
+			}
+			locale, errObj := localeArg("parse_number", args[1])
+			if errObj != nil {
+				return errObj
+			}
+
+			normalized, ok := normalizeNumber(value.Value, locale)
+			if !ok {
+				return newError("could not parse %q as a %s number", value.Value, locale)
+			}
+			return numberToObject(normalized)
+		},
+	}
+
+	// to_number("$1,234.50") returns 1234.5
+	builtins["to_number"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.Float:
+				return arg
+			case *object.String:
+				scrubbed, ok := scrubNumber(arg.Value)
+				if !ok {
+					return newError("could not convert %q to a number", arg.Value)
+				}
+				return numberToObject(scrubbed)
+			default:
+				return newError("argument to `to_number` must be STRING, got %s", args[0].Type())
+			}
+		},
+	}
+
+	// clean_numeric(rows, "amount") scrubs every cell of a column like to_number, empty cells are kept
+	builtins["clean_numeric"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments: got=%d, want=2", len(args))
+			}
+			csv, errObj := csvArg("clean_numeric", args[0])
+			if errObj != nil {
+				return errObj
+			}
+			column, errObj := columnArg("clean_numeric", csv, args[1])
+			if errObj != nil {
+				return errObj
+			}
+
+			rows := make([]map[string]string, len(csv.Rows))
+			for i, row := range csv.ShareRows() {
+				rows[i] = row
+				if value := row[column]; value != "" {
+					scrubbed, ok := scrubNumber(value)
+					if !ok {
+						return newError("could not convert %q in column %s at row %d to a number", value, column, i)
+					}
+					// clean cells are shared with csv
+					if scrubbed != value {
+						rows[i] = copyRow(row)
+						rows[i][column] = scrubbed
+					}
+				}
+			}
+			return newTransformedCSV(csv, csv.Headers, rows, column)
+		},
+	}
+}
+
+// scrubNumber strips currency symbols, whitespace and thousands separators from a number, eg. "$1,234.50" becomes "1234.50".
+// Accounting style negatives, eg. "(12.00)", become "-12.00".
+// The second return value is false if what's left is not a number, or its commas don't separate groups of three digits, eg. "1,23".
+func scrubNumber(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")")
+	if negative {
+		value = value[1 : len(value)-1]
+	}
+
+	var out strings.Builder
+	for _, r := range value {
+		if unicode.Is(unicode.Sc, r) || unicode.IsSpace(r) {
+			continue
+		}
+		out.WriteRune(r)
+	}
+
+	scrubbed := out.String()
+	if strings.Contains(scrubbed, ",") {
+		if !numberPatterns["en"].MatchString(scrubbed) {
+			return "", false
+		}
+		scrubbed = strings.ReplaceAll(scrubbed, ",", "")
+	}
+	if _, err := strconv.ParseFloat(scrubbed, 64); err != nil || strings.Trim(scrubbed, "+-.0123456789") != "" {
+		return "", false
+	}
+	if negative {
+		if strings.HasPrefix(scrubbed, "-") {
+			return "", false
+		}
+		scrubbed = "-" + strings.TrimPrefix(scrubbed, "+")
+	}
+	return scrubbed, true
+}
+
+// localeArg checks that an argument of a builtin names a supported number locale.
+func localeArg(name string, arg object.Object) (string, object.Object) {
+	locale, ok := arg.(*object.String)
+	if !ok {
+		return "", newError("locale of `%s` must be STRING, got %s", name, arg.Type())
+	}
+	if _, ok := numberLocales[locale.Value]; !ok {
+		return "", newError("unknown number locale: %s, supported locales are %s", locale.Value, strings.Join(supportedLocales(), ", "))
+	}
+	return locale.Value, nil
+}
+
+// checkLocale returns an error unless numbers can be read in the locale, "" is the way csvlang writes numbers
+func checkLocale(locale string) error {
+	if _, ok := numberLocales[locale]; locale != "" && !ok {
+		return fmt.Errorf("unknown number locale: %s, supported locales are %s", locale, strings.Join(supportedLocales(), ", "))
+	}
+	return nil
+}
+
+// supportedLocales returns the sorted names of the number locales.
+func supportedLocales() []string {
+	locales := make([]string, 0, len(numberLocales))
+	for locale := range numberLocales {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// normalizeNumber rewrites a number written in the given locale the way csvlang reads numbers, eg. "1.234,56" in "de" becomes "1234.56".
+// The second return value is false if the value is not a number in that locale.
+func normalizeNumber(value, locale string) (string, bool) {
+	pattern, ok := numberPatterns[locale]
+	if !ok || !pattern.MatchString(value) {
+		return "", false
+	}
+
+	format := numberLocales[locale]
+	integer, fraction, hasFraction := strings.Cut(value, format.decimalSeparator)
+	for _, separator := range format.groupSeparators {
+		integer = strings.ReplaceAll(integer, separator, "")
+	}
+	if hasFraction {
+		return integer + "." + fraction, true
+	}
+	return integer, true
+}
+
+// numberToObject converts a normalized number to an INTEGER, or a FLOAT if it has a fractional part.
+func numberToObject(value string) object.Object {
+	if integer, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return &object.Integer{Value: integer}
+	}
+	float, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return newError("number out of range: %s", value)
+	}
+	return &object.Float{Value: float}
+}
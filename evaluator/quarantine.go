@@ -0,0 +1,150 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// quarantineHeaders are the columns of the rows set aside by a load, eg. load data.csv quarantine bad_rows:
+// the line the row starts on, why it was set aside and the row as it was written in the file
+var quarantineHeaders = []string{"line", "reason", "raw"}
+
+// quarantinedRow is a row of the file that couldn't be loaded
+type quarantinedRow struct {
+	line   int
+	reason string
+	raw    string
+}
+
+// loadQuarantined loads a file, setting the rows that can't be read aside in the quarantine variable instead of failing,
+// and writing them to the quarantine file if there is one. The other rows are loaded as usual.
+func loadQuarantined(ls *ast.LoadStatement, env *object.Environment) object.Object {
+	filename := ls.Filename.String()
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return newError("could not open file: %s", err)
+	}
+	csvObj, bad, err := readQuarantined(content, ls.Trim, ls.NumberLocale, ls.NAValues)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	rows := make([]map[string]string, len(bad))
+	for i, row := range bad {
+		rows[i] = map[string]string{"line": strconv.Itoa(row.line), "reason": row.reason, "raw": row.raw}
+	}
+	quarantine := &object.CSV{Headers: append([]string{}, quarantineHeaders...), Rows: rows}
+	quarantine.InferColumnTypes()
+	if ls.QuarantineFile != "" {
+		quarantineFile := expandBatchFilename(ls.QuarantineFile, env)
+		if result := saveAs(quarantine, quarantineFile); isError(result) {
+			return result
+		}
+		traceSave(ls.Pos(), quarantineFile, len(quarantine.Rows))
+	}
+
+	env.SetOutsideBlocks(ls.Quarantine, quarantine)
+	env.SetOutsideBlocks("filename", &object.String{Value: filename})
+	env.SetOutsideBlocks("csv", csvObj)
+	return csvObj
+}
+
+// readQuarantined reads a CSV like ReadCSV, but sets aside the rows it can't parse, the rows without a cell per header,
+// and the rows with a cell that isn't a number in a column of numbers, in the order of their lines.
+// A column is a column of numbers when most of its non-empty cells are numbers, NA values are empty cells.
+func readQuarantined(content []byte, trim bool, locale string, na []string) (*object.CSV, []quarantinedRow, error) {
+	if err := checkLocale(locale); err != nil {
+		return nil, nil, err
+	}
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read CSV headers: %w", err)
+	}
+	if trim {
+		for i := range headers {
+			headers[i] = strings.TrimSpace(headers[i])
+		}
+	}
+
+	bad := []quarantinedRow{}
+	rows, read := []map[string]string{}, []quarantinedRow{}
+	for {
+		start := reader.InputOffset()
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		raw := strings.Trim(string(content[start:reader.InputOffset()]), "\r\n")
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			bad = append(bad, quarantinedRow{line: parseErr.StartLine, reason: parseErr.Err.Error(), raw: raw})
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read CSV records: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(headers) {
+			bad = append(bad, quarantinedRow{line: line, reason: fmt.Sprintf("%d fields, expected %d", len(record), len(headers)), raw: raw})
+			continue
+		}
+		rows = append(rows, recordToRow(headers, record, trim, locale))
+		read = append(read, quarantinedRow{line: line, raw: raw})
+	}
+	// NA values are missing, not cells of the wrong type
+	clearNAValues(&object.CSV{Headers: headers, Rows: rows}, na)
+
+	numeric := numericColumns(headers, rows)
+	good := []map[string]string{}
+	for i, row := range rows {
+		reason := ""
+		for _, header := range headers {
+			if _, err := strconv.ParseFloat(row[header], 64); numeric[header] && row[header] != "" && err != nil {
+				reason = fmt.Sprintf("%s is not a number: %s", header, row[header])
+				break
+			}
+		}
+		if reason != "" {
+			bad = append(bad, quarantinedRow{line: read[i].line, reason: reason, raw: read[i].raw})
+			continue
+		}
+		good = append(good, row)
+	}
+	sort.SliceStable(bad, func(i, j int) bool { return bad[i].line < bad[j].line })
+
+	csvObj := &object.CSV{Headers: headers, Rows: good}
+	csvObj.InferColumnTypes()
+	csvObj.ParseCells()
+	return csvObj, bad, nil
+}
+
+// numericColumns returns the columns whose non-empty cells are mostly numbers
+func numericColumns(headers []string, rows []map[string]string) map[string]bool {
+	numeric := map[string]bool{}
+	for _, header := range headers {
+		numbers, values := 0, 0
+		for _, row := range rows {
+			if row[header] == "" {
+				continue
+			}
+			values++
+			if _, err := strconv.ParseFloat(row[header], 64); err == nil {
+				numbers++
+			}
+		}
+		numeric[header] = numbers*2 > values
+	}
+	return numeric
+}
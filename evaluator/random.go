@@ -0,0 +1,125 @@
+package evaluator
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// random generates the values of rand_int, rand_choice and fake, seed(n) makes them repeat from run to run
+var random = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// fakeCities and fakeDomains make up the values of fake("city") and fake("email")
+var (
+	fakeCities  = []string{"Amsterdam", "Berlin", "Chicago", "Dublin", "Edinburgh", "Florence", "Geneva", "Helsinki", "Istanbul", "Johannesburg", "Kyoto", "Lisbon", "Madrid", "Nairobi", "Oslo", "Prague", "Quebec", "Rome", "Seoul", "Toronto"}
+	fakeDomains = []string{"example.com", "example.org", "example.net"}
+)
+
+// fakers make up a value of a kind for fake(kind), using the random source they are given
+var fakers = map[string]func(r *rand.Rand) string{
+	"first_name": func(r *rand.Rand) string { return fakeFirstNames[r.Intn(len(fakeFirstNames))] },
+	"last_name":  func(r *rand.Rand) string { return fakeLastNames[r.Intn(len(fakeLastNames))] },
+	"name": func(r *rand.Rand) string {
+		return fakeFirstNames[r.Intn(len(fakeFirstNames))] + " " + fakeLastNames[r.Intn(len(fakeLastNames))]
+	},
+	"email": func(r *rand.Rand) string {
+		first, last := fakeFirstNames[r.Intn(len(fakeFirstNames))], fakeLastNames[r.Intn(len(fakeLastNames))]
+		return fmt.Sprintf("%s.%s%d@%s", strings.ToLower(first), strings.ToLower(last), r.Intn(100), fakeDomains[r.Intn(len(fakeDomains))])
+	},
+	"phone": func(r *rand.Rand) string { return fmt.Sprintf("555-%03d-%04d", r.Intn(1000), r.Intn(10000)) },
+	"city":  func(r *rand.Rand) string { return fakeCities[r.Intn(len(fakeCities))] },
+}
+
+func init() {
+	// seed(42) makes the random values of the rest of the run the same every time it runs
+	builtins["seed"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			n, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument to `seed` must be INTEGER, got %s", args[0].Type())
+			}
+			random.Lock()
+			random.Seed(n.Value)
+			random.Unlock()
+			return NULL
+		},
+	}
+
+	// rand_int(1, 100) returns a random integer between the bounds, both included
+	builtins["rand_int"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments: got=%d, want=2", len(args))
+			}
+			low, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("arguments to `rand_int` must be INTEGER, got %s", args[0].Type())
+			}
+			high, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("arguments to `rand_int` must be INTEGER, got %s", args[1].Type())
+			}
+			if low.Value > high.Value {
+				return newError("`rand_int` needs the lower bound first, got %d and %d", low.Value, high.Value)
+			}
+			if high.Value-low.Value+1 <= 0 {
+				return newError("range of `rand_int` is too large: %d to %d", low.Value, high.Value)
+			}
+			random.Lock()
+			defer random.Unlock()
+			return &object.Integer{Value: low.Value + random.Int63n(high.Value-low.Value+1)}
+		},
+	}
+
+	// rand_choice(["red", "green"]) returns a random element of an array
+	builtins["rand_choice"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok || len(arr.Elements) == 0 {
+				return newError("argument to `rand_choice` must be a non-empty ARRAY, got %s", args[0].Inspect())
+			}
+			random.Lock()
+			defer random.Unlock()
+			return arr.Elements[random.Intn(len(arr.Elements))]
+		},
+	}
+
+	// fake("name") returns a made up value of a kind, eg. "Casey Foster"
+	builtins["fake"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			kind, ok := args[0].(*object.String)
+			if !ok || fakers[kind.Value] == nil {
+				return newError("argument to `fake` must be one of %s, got %s", strings.Join(fakeKinds(), ", "), args[0].Inspect())
+			}
+			random.Lock()
+			defer random.Unlock()
+			return &object.String{Value: fakers[kind.Value](random.Rand)}
+		},
+	}
+}
+
+func fakeKinds() []string {
+	kinds := make([]string, 0, len(fakers))
+	for kind := range fakers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
@@ -0,0 +1,70 @@
+package evaluator
+
+import (
+	"strconv"
+
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// schemaHeaders are the columns of schema(csv), one row per column of the CSV
+var schemaHeaders = []string{"column", "type", "nullable", "format", "description"}
+
+func init() {
+	// schema(rows) describes the columns of a CSV: their type, whether they have missing cells, their date format and description
+	builtins["schema"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			csv, errObj := csvArg("schema", args[0])
+			if errObj != nil {
+				return errObj
+			}
+			rows := make([]map[string]string, len(csv.Headers))
+			for i, header := range csv.Headers {
+				columnType := csv.TypeOf(header)
+				rows[i] = map[string]string{
+					"column":      header,
+					"type":        string(columnType.DataType),
+					"nullable":    strconv.FormatBool(columnType.Nullable),
+					"format":      columnType.Format,
+					"description": columnType.Description,
+				}
+			}
+			result := &object.CSV{Headers: append([]string{}, schemaHeaders...), Rows: rows}
+			result.InferColumnTypes()
+			return result
+		},
+	}
+
+	// describe_column(orders, "amount", "total in EUR") documents a column, the description is kept by transforms
+	// and written with the schema of JSON files
+	builtins["describe_column"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments: got=%d, want=3", len(args))
+			}
+			csv, errObj := csvArg("describe_column", args[0])
+			if errObj != nil {
+				return errObj
+			}
+			column, errObj := columnArg("describe_column", csv, args[1])
+			if errObj != nil {
+				return errObj
+			}
+			description, ok := args[2].(*object.String)
+			if !ok {
+				return newError("description of `describe_column` must be STRING, got %s", args[2].Type())
+			}
+
+			// the cells don't change, the rows are shared with csv
+			result := newTransformedCSV(csv, csv.Headers, append([]map[string]string(nil), csv.ShareRows()...))
+			for i := range result.ColumnTypes {
+				if result.ColumnTypes[i].Name == column {
+					result.ColumnTypes[i].Description = description.Value
+				}
+			}
+			return result
+		},
+	}
+}
@@ -0,0 +1,143 @@
+package evaluator
+
+import (
+	"encoding/gob"
+	"os"
+	"sync"
+
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// snapshotMaxCells is the size of the biggest snapshot kept in memory, bigger ones are written to a temp file
+var snapshotMaxCells = 1 << 20
+
+// snapshotFiles is the temp files of the snapshots taken, see RemoveSnapshotFiles.
+// A file is also removed when the run that took the snapshot is closed, see object.Environment.Close.
+var snapshotFiles = struct {
+	sync.Mutex
+	paths map[string]bool
+}{paths: map[string]bool{}}
+
+// csvSnapshot is a CSV saved by snapshot(csv, name), its state in memory or the temp file it was written to
+type csvSnapshot struct {
+	state *object.CSVState
+	path  string
+}
+
+// RemoveSnapshotFiles removes the temp files of the snapshots taken, eg. when the process exits
+func RemoveSnapshotFiles() {
+	snapshotFiles.Lock()
+	defer snapshotFiles.Unlock()
+	for path := range snapshotFiles.paths {
+		os.Remove(path)
+		delete(snapshotFiles.paths, path)
+	}
+}
+
+func init() {
+	// snapshot(csv, name) saves the rows of a CSV under a name for the rest of the run, eg. snapshot(csv, "before_cleanup"),
+	// a snapshot taken again under the same name replaces it
+	builtins["snapshot"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments: got=%d, want=2", len(args))
+			}
+			csv, ok := args[0].(*object.CSV)
+			if !ok {
+				return newError("first argument to `snapshot` must be CSV, got %s", args[0].Type())
+			}
+			name, ok := args[1].(*object.String)
+			if !ok {
+				return newError("second argument to `snapshot` must be STRING, got %s", args[1].Type())
+			}
+
+			snapshot := &csvSnapshot{}
+			if len(csv.Rows)*len(csv.Headers) <= snapshotMaxCells {
+				// the rows are shared with the CSV until they change, see CSV.Snapshot
+				snapshot.state = csv.Snapshot()
+			} else {
+				// without file access, eg. under `csvlang serve`, a snapshot too large for memory is refused
+				if errObj := checkFileAccess("write snapshot", name.Value); errObj != nil {
+					return newError("snapshot of %d cells is larger than %d: %s", len(csv.Rows)*len(csv.Headers), snapshotMaxCells, errObj.Message)
+				}
+				path, errObj := writeSnapshotFile(csv)
+				if errObj != nil {
+					return errObj
+				}
+				env.AtClose(func() { removeSnapshotFile(path) })
+				snapshot.path = path
+			}
+			if previous, ok := env.SetSnapshot(name.Value, snapshot).(*csvSnapshot); ok && previous.path != "" {
+				removeSnapshotFile(previous.path)
+			}
+			return NULL
+		},
+	}
+	// restore(name) returns a copy of the rows saved by snapshot(csv, name), eg. diff(restore("before_cleanup"), csv, "id"),
+	// or `csv = restore("before_cleanup")` to start over. A snapshot can be restored any number of times.
+	builtins["restore"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			name, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `restore` must be STRING, got %s", args[0].Type())
+			}
+			value, _ := env.Snapshot(name.Value)
+			snapshot, ok := value.(*csvSnapshot)
+			if !ok {
+				return newError("no snapshot named %q, take one with snapshot(csv, %q)", name.Value, name.Value)
+			}
+			if snapshot.state != nil {
+				return snapshot.state.CSV()
+			}
+			return readSnapshotFile(snapshot.path)
+		},
+	}
+}
+
+// writeSnapshotFile writes the rows of a CSV to a temp file the way the load cache does, see cacheEntry
+func writeSnapshotFile(csv *object.CSV) (string, object.Object) {
+	file, err := os.CreateTemp("", "csvlang-snapshot-*.gob")
+	if err != nil {
+		return "", newError("could not write snapshot: %s", err)
+	}
+	snapshotFiles.Lock()
+	snapshotFiles.paths[file.Name()] = true
+	snapshotFiles.Unlock()
+
+	entry := cacheEntry{Version: cacheVersion, Headers: csv.Headers, ColumnTypes: csv.ColumnTypes, Records: csv.Records()}
+	err = gob.NewEncoder(file).Encode(entry)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		removeSnapshotFile(file.Name())
+		return "", newError("could not write snapshot: %s", err)
+	}
+	return file.Name(), nil
+}
+
+// readSnapshotFile reads the rows written by writeSnapshotFile
+func readSnapshotFile(path string) object.Object {
+	file, err := os.Open(path)
+	if err != nil {
+		return newError("could not read snapshot: %s", err)
+	}
+	defer file.Close()
+
+	var entry cacheEntry
+	if err := gob.NewDecoder(file).Decode(&entry); err != nil {
+		return newError("could not read snapshot: %s", err)
+	}
+	return entry.csv()
+}
+
+// removeSnapshotFile removes the temp file of a snapshot that was replaced, or whose run was closed
+func removeSnapshotFile(path string) {
+	snapshotFiles.Lock()
+	defer snapshotFiles.Unlock()
+	os.Remove(path)
+	delete(snapshotFiles.paths, path)
+}
@@ -0,0 +1,220 @@
+package evaluator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Rishabh570/csvlang/object"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// missingTable finds the table SQLite couldn't find, eg. "SQL logic error: no such table: sales (1)"
+var missingTable = regexp.MustCompile(`no such table: (\S+)`)
+
+func init() {
+	// query_sql("SELECT city, avg(age) FROM csv GROUP BY city") runs SQL on CSV variables and returns the result as a CSV
+	builtins["query_sql"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			query, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `query_sql` must be STRING, got %s", args[0].Type())
+			}
+			return querySQL(query.Value, env)
+		},
+	}
+}
+
+// querySQL runs a query in an in-memory SQLite database. The tables are the CSV variables the query names,
+// copied in when SQLite reports them missing so only the ones used are copied. Empty cells are NULL,
+// and columns inferred as INTEGER or FLOAT are INTEGER or REAL columns.
+// The database can't attach others, so a query can't read or write files. The query is interrupted once the run is stopped.
+func querySQL(query string, env *object.Environment) object.Object {
+	ctx := env.Context()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return newError("query_sql: %s", err)
+	}
+	defer db.Close()
+	// every connection to :memory: is its own database, the tables and the query must share one
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return newError("query_sql: %s", err)
+	}
+	defer conn.Close()
+	if _, err := sqlite.Limit(conn, sqlite3.SQLITE_LIMIT_ATTACHED, 0); err != nil {
+		return newError("query_sql: %s", err)
+	}
+
+	created := map[string]bool{}
+	for {
+		rows, err := conn.QueryContext(ctx, query)
+		if err == nil {
+			defer rows.Close()
+			result, err := csvFromRows(rows)
+			if err != nil {
+				return sqlError(env, err)
+			}
+			return result
+		}
+		if errObj := checkStopped(env); errObj != nil {
+			return errObj
+		}
+		match := missingTable.FindStringSubmatch(err.Error())
+		if match == nil || created[match[1]] {
+			return newError("query_sql: %s", err)
+		}
+		name := match[1]
+		value, _ := env.Get(name)
+		data, ok := value.(*object.CSV)
+		if !ok {
+			return newError("query_sql: no such table: %s, tables are CSV variables", name)
+		}
+		if err := createTable(ctx, conn, name, data); err != nil {
+			if errObj := checkStopped(env); errObj != nil {
+				return errObj
+			}
+			return newError("query_sql: could not copy %s: %s", name, err)
+		}
+		created[name] = true
+	}
+}
+
+// sqlError is the error of a query that failed, or the reason the run was stopped when that interrupted it
+func sqlError(env *object.Environment, err error) object.Object {
+	if errObj := checkStopped(env); errObj != nil {
+		return errObj
+	}
+	return newError("query_sql: %s", err)
+}
+
+// createTable copies a CSV into a table of the same name
+func createTable(ctx context.Context, conn *sql.Conn, name string, data *object.CSV) error {
+	types := map[string]object.ObjectType{}
+	for _, columnType := range data.ColumnTypes {
+		types[columnType.Name] = columnType.DataType
+	}
+	columns := make([]string, len(data.Headers))
+	for i, header := range data.Headers {
+		sqlType := "TEXT"
+		switch types[header] {
+		case object.INTEGER_OBJ:
+			sqlType = "INTEGER"
+		case object.FLOAT_OBJ:
+			sqlType = "REAL"
+		}
+		columns[i] = quoteSQLName(header) + " " + sqlType
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (%s)", quoteSQLName(name), strings.Join(columns, ", "))); err != nil {
+		return err
+	}
+	if len(data.Headers) == 0 {
+		return nil
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(data.Headers)), ", ")
+	insert, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s VALUES (%s)", quoteSQLName(name), placeholders))
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	values := make([]interface{}, len(data.Headers))
+	for _, row := range data.Rows {
+		for i, header := range data.Headers {
+			values[i] = sqlValue(row[header], types[header])
+		}
+		if _, err := insert.ExecContext(ctx, values...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// sqlValue converts a cell to the type of its column, cells that don't parse are kept as text
+func sqlValue(cell string, dataType object.ObjectType) interface{} {
+	if cell == "" {
+		return nil
+	}
+	switch dataType {
+	case object.INTEGER_OBJ:
+		if i, err := strconv.ParseInt(cell, 10, 64); err == nil {
+			return i
+		}
+	case object.FLOAT_OBJ:
+		if f, err := strconv.ParseFloat(cell, 64); err == nil {
+			return f
+		}
+	}
+	return cell
+}
+
+// csvFromRows reads the result of a query, NULLs are empty cells
+func csvFromRows(rows *sql.Rows) (*object.CSV, error) {
+	headers, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	for i, header := range headers {
+		if containsString(headers[:i], header) {
+			return nil, fmt.Errorf("the result has two columns named %s, rename one with AS", header)
+		}
+	}
+
+	result := &object.CSV{Headers: headers, Rows: []map[string]string{}}
+	values := make([]interface{}, len(headers))
+	pointers := make([]interface{}, len(headers))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(headers))
+		for i, header := range headers {
+			row[header] = sqlCell(values[i])
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	result.InferColumnTypes()
+	return result, nil
+}
+
+func sqlCell(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case time.Time:
+		if value.Hour() == 0 && value.Minute() == 0 && value.Second() == 0 && value.Nanosecond() == 0 {
+			return value.Format(time.DateOnly)
+		}
+		return value.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(value)
+}
+
+// quoteSQLName quotes a table or column name, so names with spaces or keywords can be used
+func quoteSQLName(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
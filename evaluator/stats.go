@@ -0,0 +1,94 @@
+package evaluator
+
+import (
+	"strconv"
+
+	"github.com/Rishabh570/csvlang/object"
+)
+
+func init() {
+	// min(csv, column) returns the smallest value of a column, empty cells are skipped, eg. min(csv, "age")
+	builtins["min"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			stats, errObj := columnStats("min", withSingleColumn(args))
+			if errObj != nil {
+				return errObj
+			}
+			return cellObject(stats.Min)
+		},
+	}
+	// max(csv, column) returns the largest value of a column, empty cells are skipped, eg. max(csv, "age")
+	builtins["max"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			stats, errObj := columnStats("max", withSingleColumn(args))
+			if errObj != nil {
+				return errObj
+			}
+			return cellObject(stats.Max)
+		},
+	}
+	// describe(csv) returns a row per column with its type, its number of values, empty cells and distinct values,
+	// and its smallest and largest values
+	builtins["describe"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments: got=%d, want=1", len(args))
+			}
+			csv, ok := args[0].(*object.CSV)
+			if !ok {
+				return newError("argument to `describe` must be CSV, got %s", args[0].Type())
+			}
+
+			headers := []string{"column", "type", "count", "nulls", "distinct", "min", "max"}
+			records := make([][]string, len(csv.Headers))
+			for i, header := range csv.Headers {
+				stats := csv.Stats(header)
+				records[i] = []string{
+					header,
+					string(csv.TypeOf(header).DataType),
+					strconv.Itoa(stats.Count),
+					strconv.Itoa(stats.Nulls),
+					strconv.Itoa(stats.Distinct),
+					stats.Min.Text,
+					stats.Max.Text,
+				}
+			}
+			result, err := object.NewCSVFromRecords(headers, records)
+			if err != nil {
+				return newError("%s", err)
+			}
+			return result
+		},
+	}
+}
+
+// columnStats returns the stats of the column of a CSV passed to min or max, see object.CSV.Stats
+func columnStats(name string, args []object.Object) (object.ColumnStats, object.Object) {
+	if len(args) != 2 {
+		return object.ColumnStats{}, newError("wrong number of arguments: got=%d, want=2", len(args))
+	}
+	csv, ok := args[0].(*object.CSV)
+	if !ok {
+		return object.ColumnStats{}, newError("first argument to `%s` must be CSV, got %s", name, args[0].Type())
+	}
+	column, errObj := columnArg(name, csv, args[1])
+	if errObj != nil {
+		return object.ColumnStats{}, errObj
+	}
+	return csv.Stats(column), nil
+}
+
+// cellObject returns the value of a cell as an object of its type, eg. an INTEGER for a cell of an INTEGER column
+func cellObject(cell object.Cell) object.Object {
+	switch cell.Kind {
+	case object.NullCell:
+		return NULL
+	case object.IntegerCell:
+		return &object.Integer{Value: cell.Int}
+	case object.FloatCell:
+		return &object.Float{Value: cell.Float}
+	case object.BooleanCell:
+		return nativeBoolToBooleanObject(cell.Bool)
+	}
+	return &object.String{Value: cell.Text}
+}
@@ -0,0 +1,143 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/object"
+	"github.com/Rishabh570/csvlang/token"
+)
+
+// Tracer is told what scripts do as they run, eg. to audit the files a script touched, see Trace.
+// Positions are those of the statements in the script, they are zero for files read or written by builtins,
+// eg. rows |> save("out.csv"), which are reported during the statement calling them.
+// Scripts run concurrently by `csvlang serve` share the tracer, so it must be safe for concurrent use.
+type Tracer interface {
+	// OnStatement is called before a statement runs, including the statements in blocks, eg. of loops and functions
+	OnStatement(pos token.Position, statement string)
+	// OnLoad is called once a file is loaded, rows is -1 when they aren't counted, eg. a mapped file
+	OnLoad(pos token.Position, filename string, rows int)
+	// OnSave is called once a file is written, or would be in a dry run
+	OnSave(pos token.Position, filename string, rows int)
+	// OnError is called when an error happens, including the errors caught by try
+	OnError(pos token.Position, message string)
+}
+
+// Trace receives the statements, loads, saves and errors of the scripts being run, nil when they aren't traced.
+// `csvlang -trace` writes them to stderr, see NewTraceWriter.
+var Trace Tracer
+
+// NewTraceWriter returns a tracer writing a line per event, eg. "4:1 save adults.csv: 12 rows"
+func NewTraceWriter(w io.Writer) Tracer {
+	return &traceWriter{w: w}
+}
+
+type traceWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (t *traceWriter) OnStatement(pos token.Position, statement string) {
+	t.printf("%s %s", tracePosition(pos), statement)
+}
+
+func (t *traceWriter) OnLoad(pos token.Position, filename string, rows int) {
+	t.printf("%s load %s: %s", tracePosition(pos), filename, rowsTraced(rows))
+}
+
+func (t *traceWriter) OnSave(pos token.Position, filename string, rows int) {
+	t.printf("%s save %s: %s", tracePosition(pos), filename, rowsTraced(rows))
+}
+
+func (t *traceWriter) OnError(pos token.Position, message string) {
+	t.printf("%s error: %s", tracePosition(pos), message)
+}
+
+func (t *traceWriter) printf(format string, a ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.w, "trace: "+format+"\n", a...)
+}
+
+// tracePosition writes a position as line:column, "-" when it isn't known, eg. for files saved by builtins
+func tracePosition(pos token.Position) string {
+	if pos.Line == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+}
+
+// rowsTraced describes a number of rows, -1 when they aren't counted
+func rowsTraced(rows int) string {
+	switch rows {
+	case -1:
+		return "rows not counted"
+	case 1:
+		return "1 row"
+	default:
+		return fmt.Sprintf("%d rows", rows)
+	}
+}
+
+// traceStatement reports a node to Trace before it runs if it's a statement, blocks are reported statement by statement
+func traceStatement(node ast.Node) {
+	statement, ok := node.(ast.Statement)
+	if _, isBlock := node.(*ast.BlockStatement); !ok || isBlock {
+		return
+	}
+	Trace.OnStatement(statement.Pos(), statement.String())
+}
+
+// traceError reports an error to Trace once it has a position
+func traceError(err *object.Error) {
+	if Trace != nil && err.Pos.Line != 0 {
+		Trace.OnError(err.Pos, err.Message)
+	}
+}
+
+// traceLoad reports a file loaded to Trace
+func traceLoad(pos token.Position, filename string, rows int) {
+	if Trace != nil {
+		Trace.OnLoad(pos, filename, rows)
+	}
+}
+
+// traceSave reports a file written to Trace
+func traceSave(pos token.Position, filename string, rows int) {
+	if Trace != nil {
+		Trace.OnSave(pos, filename, rows)
+	}
+}
+
+// MultiTracer returns a tracer telling every tracer in turn, eg. to print a trace and keep an AuditLog
+func MultiTracer(tracers ...Tracer) Tracer {
+	return multiTracer(tracers)
+}
+
+type multiTracer []Tracer
+
+func (m multiTracer) OnStatement(pos token.Position, statement string) {
+	for _, t := range m {
+		t.OnStatement(pos, statement)
+	}
+}
+
+func (m multiTracer) OnLoad(pos token.Position, filename string, rows int) {
+	for _, t := range m {
+		t.OnLoad(pos, filename, rows)
+	}
+}
+
+func (m multiTracer) OnSave(pos token.Position, filename string, rows int) {
+	for _, t := range m {
+		t.OnSave(pos, filename, rows)
+	}
+}
+
+func (m multiTracer) OnError(pos token.Position, message string) {
+	for _, t := range m {
+		t.OnError(pos, message)
+	}
+}
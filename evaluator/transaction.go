@@ -0,0 +1,60 @@
+package evaluator
+
+import (
+	"github.com/Rishabh570/csvlang/object"
+)
+
+func init() {
+	// begin(csv) starts a transaction on a CSV, the updates, deletes and inserts made until commit(csv)
+	// can be undone with rollback(csv), eg. when a validation fails halfway through a cleanup
+	builtins["begin"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			csv, errObj := transactionCSV("begin", args)
+			if errObj != nil {
+				return errObj
+			}
+			if !csv.Begin() {
+				return newError("a transaction is already open on this CSV, commit or roll it back first")
+			}
+			return NULL
+		},
+	}
+	// commit(csv) keeps the changes made since begin(csv)
+	builtins["commit"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			csv, errObj := transactionCSV("commit", args)
+			if errObj != nil {
+				return errObj
+			}
+			if !csv.Commit() {
+				return newError("no transaction to commit, call begin(csv) first")
+			}
+			return NULL
+		},
+	}
+	// rollback(csv) undoes the changes made since begin(csv)
+	builtins["rollback"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			csv, errObj := transactionCSV("rollback", args)
+			if errObj != nil {
+				return errObj
+			}
+			if !csv.Rollback() {
+				return newError("no transaction to roll back, call begin(csv) first")
+			}
+			return NULL
+		},
+	}
+}
+
+// transactionCSV returns the CSV argument of begin, commit and rollback
+func transactionCSV(name string, args []object.Object) (*object.CSV, object.Object) {
+	if len(args) != 1 {
+		return nil, newError("wrong number of arguments: got=%d, want=1", len(args))
+	}
+	csv, ok := args[0].(*object.CSV)
+	if !ok {
+		return nil, newError("argument to `%s` must be CSV, got %s", name, args[0].Type())
+	}
+	return csv, nil
+}
@@ -0,0 +1,761 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// Builtins that reshape a CSV (eg. split_column) are registered here.
+// They never modify their input, a new CSV with updated Headers and ColumnTypes is returned.
+func init() {
+	builtins["split_column"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 4 {
+				return newError("wrong number of arguments: got=%d, want=4", len(args))
+			}
+
+			csv, errObj := csvArg("split_column", args[0])
+			if errObj != nil {
+				return errObj
+			}
+			column, errObj := columnArg("split_column", csv, args[1])
+			if errObj != nil {
+				return errObj
+			}
+			separator, ok := args[2].(*object.String)
+			if !ok || separator.Value == "" {
+				return newError("separator of `split_column` must be a non-empty STRING, got %s", args[2].Inspect())
+			}
+			names, errObj := stringsArg("split_column", args[3])
+			if errObj != nil {
+				return errObj
+			}
+
+			// the new columns take the place of the split column
+			headers := []string{}
+			for _, header := range csv.Headers {
+				if header == column {
+					headers = append(headers, names...)
+					continue
+				}
+				if containsString(names, header) {
+					return newError("column already exists: %s", header)
+				}
+				headers = append(headers, header)
+			}
+
+			// a cell with fewer parts leaves the remaining columns empty, extra parts stay in the last column
+			rows := make([]map[string]string, len(csv.Rows))
+			for i, row := range csv.Rows {
+				newRow := copyRow(row)
+				delete(newRow, column)
+				parts := strings.SplitN(row[column], separator.Value, len(names))
+				for j, name := range names {
+					newRow[name] = ""
+					if j < len(parts) {
+						newRow[name] = parts[j]
+					}
+				}
+				rows[i] = newRow
+			}
+
+			return newTransformedCSV(csv, headers, rows)
+		},
+	}
+
+	builtins["merge_columns"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 4 {
+				return newError("wrong number of arguments: got=%d, want=4", len(args))
+			}
+
+			csv, errObj := csvArg("merge_columns", args[0])
+			if errObj != nil {
+				return errObj
+			}
+			columns, errObj := stringsArg("merge_columns", args[1])
+			if errObj != nil {
+				return errObj
+			}
+			for _, column := range columns {
+				if !containsString(csv.Headers, column) {
+					return newError("column not found: %s", column)
+				}
+			}
+			separator, ok := args[2].(*object.String)
+			if !ok {
+				return newError("separator of `merge_columns` must be STRING, got %s", args[2].Type())
+			}
+			target, ok := args[3].(*object.String)
+			if !ok || target.Value == "" {
+				return newError("target column of `merge_columns` must be a non-empty STRING, got %s", args[3].Inspect())
+			}
+			if containsString(csv.Headers, target.Value) && !containsString(columns, target.Value) {
+				return newError("column already exists: %s", target.Value)
+			}
+
+			// the merged column takes the place of the first merged column
+			headers := []string{}
+			for _, header := range csv.Headers {
+				if header == columns[0] {
+					headers = append(headers, target.Value)
+				} else if !containsString(columns, header) {
+					headers = append(headers, header)
+				}
+			}
+
+			// empty cells are skipped, so no dangling separators are left behind
+			rows := make([]map[string]string, len(csv.Rows))
+			for i, row := range csv.Rows {
+				newRow := copyRow(row)
+				values := []string{}
+				for _, column := range columns {
+					if row[column] != "" {
+						values = append(values, row[column])
+					}
+					delete(newRow, column)
+				}
+				newRow[target.Value] = strings.Join(values, separator.Value)
+				rows[i] = newRow
+			}
+
+			return newTransformedCSV(csv, headers, rows)
+		},
+	}
+
+	builtins["one_hot"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments: got=%d, want=2", len(args))
+			}
+
+			csv, errObj := csvArg("one_hot", args[0])
+			if errObj != nil {
+				return errObj
+			}
+			column, errObj := columnArg("one_hot", csv, args[1])
+			if errObj != nil {
+				return errObj
+			}
+
+			// one column per distinct value, in order of first appearance, eg. color_red
+			values := []string{}
+			names := []string{}
+			for _, row := range csv.Rows {
+				value := row[column]
+				if value == "" || containsString(values, value) {
+					continue
+				}
+				name := column + "_" + value
+				if containsString(csv.Headers, name) {
+					return newError("column already exists: %s", name)
+				}
+				values = append(values, value)
+				names = append(names, name)
+			}
+
+			// the new columns take the place of the encoded column
+			headers := []string{}
+			for _, header := range csv.Headers {
+				if header == column {
+					headers = append(headers, names...)
+				} else {
+					headers = append(headers, header)
+				}
+			}
+
+			// an empty cell is false in every column
+			rows := make([]map[string]string, len(csv.Rows))
+			for i, row := range csv.Rows {
+				newRow := copyRow(row)
+				delete(newRow, column)
+				for j, name := range names {
+					newRow[name] = strconv.FormatBool(row[column] == values[j])
+				}
+				rows[i] = newRow
+			}
+
+			return newTransformedCSV(csv, headers, rows)
+		},
+	}
+
+	// normalize(rows, "price") scales a column to [0, 1], a constant column becomes 0
+	builtins["normalize"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			return scaleColumn("normalize", args, func(values []float64) []float64 {
+				if len(values) == 0 {
+					return values
+				}
+				low, high := values[0], values[0]
+				for _, value := range values {
+					low = math.Min(low, value)
+					high = math.Max(high, value)
+				}
+				scaled := make([]float64, len(values))
+				for i, value := range values {
+					if high > low {
+						scaled[i] = (value - low) / (high - low)
+					}
+				}
+				return scaled
+			})
+		},
+	}
+
+	// zscore(rows, "price") scales a column to standard scores using the population standard deviation
+	builtins["zscore"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			return scaleColumn("zscore", args, func(values []float64) []float64 {
+				if len(values) == 0 {
+					return values
+				}
+				average := mean(values)
+				variance := 0.0
+				for _, value := range values {
+					variance += (value - average) * (value - average)
+				}
+				deviation := math.Sqrt(variance / float64(len(values)))
+				scaled := make([]float64, len(values))
+				for i, value := range values {
+					if deviation > 0 {
+						scaled[i] = (value - average) / deviation
+					}
+				}
+				return scaled
+			})
+		},
+	}
+
+	// lag(rows, "price", 1) adds a column holding the value of the previous row, eg. price_lag_1
+	builtins["lag"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			return shiftColumn("lag", args, -1)
+		},
+	}
+
+	// lead(rows, "price", 1) adds a column holding the value of the next row, eg. price_lead_1
+	builtins["lead"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			return shiftColumn("lead", args, 1)
+		},
+	}
+
+	// rolling_avg(rows, "price", 7) adds a column holding the average of the row and the previous 6 rows, eg. price_rolling_avg_7
+	// It is empty until the window is full and when the window has an empty cell.
+	builtins["rolling_avg"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			csv, column, size, target, errObj := windowArgs("rolling_avg", args)
+			if errObj != nil {
+				return errObj
+			}
+
+			values := make([]string, len(csv.Rows))
+			cells := csv.Cells(column)
+			for i := range csv.Rows {
+				if i+1 < size {
+					continue
+				}
+				window := []float64{}
+				for _, cell := range cells[i+1-size : i+1] {
+					if cell.Kind == object.NullCell {
+						break
+					}
+					value, ok := cellNumber(cell)
+					if !ok {
+						return newError("column %s has non-numeric value %q", column, cell.Text)
+					}
+					window = append(window, value)
+				}
+				if len(window) == size {
+					values[i] = formatFloat(mean(window))
+				}
+			}
+
+			return addColumn(csv, target, values)
+		},
+	}
+
+	// select(rows, ["name", "age"]) keeps the given columns in the given order
+	builtins["select"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments: got=%d, want=2", len(args))
+			}
+			csv, errObj := csvArg("select", args[0])
+			if errObj != nil {
+				return errObj
+			}
+			columns, errObj := stringsArg("select", args[1])
+			if errObj != nil {
+				return errObj
+			}
+			for _, column := range columns {
+				if !containsString(csv.Headers, column) {
+					return newError("column not found: %s", column)
+				}
+			}
+
+			rows := make([]map[string]string, len(csv.Rows))
+			for i, row := range csv.Rows {
+				newRow := make(map[string]string, len(columns))
+				for _, column := range columns {
+					if value, ok := row[column]; ok {
+						newRow[column] = value
+					}
+				}
+				rows[i] = newRow
+			}
+			return newTransformedCSV(csv, columns, rows)
+		},
+	}
+
+	// drop_empty(rows) keeps the rows without an empty cell, drop_empty(rows, "email") the rows with an email
+	builtins["drop_empty"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments: got=%d, want=1 or 2", len(args))
+			}
+			csv, errObj := csvArg("drop_empty", args[0])
+			if errObj != nil {
+				return errObj
+			}
+			columns := csv.Headers
+			if len(args) == 2 {
+				column, errObj := columnArg("drop_empty", csv, args[1])
+				if errObj != nil {
+					return errObj
+				}
+				columns = []string{column}
+			}
+
+			rows := []map[string]string{}
+		next:
+			for _, row := range csv.ShareRows() {
+				for _, column := range columns {
+					if row[column] == "" {
+						continue next
+					}
+				}
+				rows = append(rows, row)
+			}
+			return newTransformedCSV(csv, csv.Headers, rows)
+		},
+	}
+
+	// row_number(rows) adds a row_number column numbering the rows from 1 in their current order
+	builtins["row_number"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments: got=%d, want=1 or 2", len(args))
+			}
+			csv, errObj := csvArg("row_number", args[0])
+			if errObj != nil {
+				return errObj
+			}
+			target := "row_number"
+			if len(args) == 2 {
+				targetArg, ok := args[1].(*object.String)
+				if !ok || targetArg.Value == "" {
+					return newError("column name of `row_number` must be a non-empty STRING, got %s", args[1].Inspect())
+				}
+				target = targetArg.Value
+			}
+
+			values := make([]string, len(csv.Rows))
+			for i := range csv.Rows {
+				values[i] = strconv.Itoa(i + 1)
+			}
+			return addColumn(csv, target, values)
+		},
+	}
+
+	// rank(rows, "score", "desc") adds a score_rank column, ties share a rank and leave a gap (1, 2, 2, 4)
+	// Rows keep their order, empty cells are not ranked.
+	builtins["rank"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 2 || len(args) > 4 {
+				return newError("wrong number of arguments: got=%d, want=2 to 4", len(args))
+			}
+			csv, errObj := csvArg("rank", args[0])
+			if errObj != nil {
+				return errObj
+			}
+			column, errObj := columnArg("rank", csv, args[1])
+			if errObj != nil {
+				return errObj
+			}
+			descending := false
+			if len(args) >= 3 {
+				descending, errObj = orderArg("rank", args[2])
+				if errObj != nil {
+					return errObj
+				}
+			}
+			target := column + "_rank"
+			if len(args) == 4 {
+				targetArg, ok := args[3].(*object.String)
+				if !ok || targetArg.Value == "" {
+					return newError("column name of `rank` must be a non-empty STRING, got %s", args[3].Inspect())
+				}
+				target = targetArg.Value
+			}
+
+			less := cellLess(csv.Rows, column)
+			ranked := []int{}
+			for i, row := range csv.Rows {
+				if row[column] != "" {
+					ranked = append(ranked, i)
+				}
+			}
+			sort.SliceStable(ranked, func(i, j int) bool {
+				a, b := csv.Rows[ranked[i]][column], csv.Rows[ranked[j]][column]
+				if descending {
+					return less(b, a)
+				}
+				return less(a, b)
+			})
+
+			values := make([]string, len(csv.Rows))
+			for position, i := range ranked {
+				rank := position + 1
+				if position > 0 {
+					previous := ranked[position-1]
+					if !less(csv.Rows[previous][column], csv.Rows[i][column]) && !less(csv.Rows[i][column], csv.Rows[previous][column]) {
+						rank, _ = strconv.Atoi(values[previous])
+					}
+				}
+				values[i] = strconv.Itoa(rank)
+			}
+			return addColumn(csv, target, values)
+		},
+	}
+}
+
+// sortCSV sorts the rows of a CSV by a column, eg. sort(csv, "age", "desc"). The sort is stable and empty cells come last.
+func sortCSV(csv *object.CSV, args []object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("wrong number of arguments: got=%d, want=2 or 3", len(args)+1)
+	}
+	column, errObj := columnArg("sort", csv, args[0])
+	if errObj != nil {
+		return errObj
+	}
+	descending := false
+	if len(args) == 2 {
+		descending, errObj = orderArg("sort", args[1])
+		if errObj != nil {
+			return errObj
+		}
+	}
+
+	less := cellLess(csv.Rows, column)
+	rows := make([]map[string]string, len(csv.Rows))
+	copy(rows, csv.ShareRows())
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i][column], rows[j][column]
+		if a == "" || b == "" {
+			return b == "" && a != ""
+		}
+		if descending {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+	return &object.CSV{Headers: csv.Headers, ColumnTypes: csv.ColumnTypes, Rows: rows}
+}
+
+// orderArg checks that an argument of a builtin is a sort order, it returns true for "desc".
+func orderArg(name string, arg object.Object) (bool, object.Object) {
+	order, ok := arg.(*object.String)
+	if !ok || (order.Value != "asc" && order.Value != "desc") {
+		return false, newError("order of `%s` must be \"asc\" or \"desc\", got %s", name, arg.Inspect())
+	}
+	return order.Value == "desc", nil
+}
+
+// cellLess compares the cells of a column, numeric columns are compared by value, other columns alphabetically.
+func cellLess(rows []map[string]string, column string) func(a, b string) bool {
+	columnType := inferColumnType(rows, column)
+	numeric := columnType == object.INTEGER_OBJ || columnType == object.FLOAT_OBJ
+	return func(a, b string) bool {
+		if numeric {
+			x, _ := strconv.ParseFloat(a, 64)
+			y, _ := strconv.ParseFloat(b, 64)
+			return x < y
+		}
+		return a < b
+	}
+}
+
+// shiftColumn adds a column holding the value of the row offset rows away (scaled by direction) for lag and lead.
+// Rows without a row at that offset are left empty.
+func shiftColumn(name string, args []object.Object, direction int) object.Object {
+	csv, column, offset, target, errObj := windowArgs(name, args)
+	if errObj != nil {
+		return errObj
+	}
+
+	values := make([]string, len(csv.Rows))
+	for i := range csv.Rows {
+		source := i + direction*offset
+		if source >= 0 && source < len(csv.Rows) {
+			values[i] = csv.Rows[source][column]
+		}
+	}
+
+	return addColumn(csv, target, values)
+}
+
+// windowArgs validates the arguments of the window builtins: the CSV, the column, an optional size (default 1)
+// and an optional name for the new column (default column_name_size, eg. price_lag_1).
+func windowArgs(name string, args []object.Object) (*object.CSV, string, int, string, object.Object) {
+	if len(args) < 2 || len(args) > 4 {
+		return nil, "", 0, "", newError("wrong number of arguments: got=%d, want=2 to 4", len(args))
+	}
+
+	csv, errObj := csvArg(name, args[0])
+	if errObj != nil {
+		return nil, "", 0, "", errObj
+	}
+	column, errObj := columnArg(name, csv, args[1])
+	if errObj != nil {
+		return nil, "", 0, "", errObj
+	}
+
+	size := int64(1)
+	if len(args) >= 3 {
+		sizeArg, ok := args[2].(*object.Integer)
+		if !ok || sizeArg.Value < 1 {
+			return nil, "", 0, "", newError("size of `%s` must be a positive INTEGER, got %s", name, args[2].Inspect())
+		}
+		size = sizeArg.Value
+	}
+
+	target := fmt.Sprintf("%s_%s_%d", column, name, size)
+	if len(args) == 4 {
+		targetArg, ok := args[3].(*object.String)
+		if !ok || targetArg.Value == "" {
+			return nil, "", 0, "", newError("column name of `%s` must be a non-empty STRING, got %s", name, args[3].Inspect())
+		}
+		target = targetArg.Value
+	}
+
+	return csv, column, int(size), target, nil
+}
+
+// addColumn returns a new CSV with a column appended, values holds the cell of every row.
+func addColumn(csv *object.CSV, column string, values []string) object.Object {
+	if containsString(csv.Headers, column) {
+		return newError("column already exists: %s", column)
+	}
+
+	rows := make([]map[string]string, len(csv.Rows))
+	for i, row := range csv.Rows {
+		rows[i] = copyRow(row)
+		rows[i][column] = values[i]
+	}
+
+	headers := append(append([]string{}, csv.Headers...), column)
+	return newTransformedCSV(csv, headers, rows)
+}
+
+// Policies for empty cells in numeric transforms (eg. normalize)
+const (
+	emptySkip  = "skip"  // leave empty cells empty
+	emptyZero  = "zero"  // treat empty cells as 0
+	emptyMean  = "mean"  // fill empty cells with the mean of the column
+	emptyError = "error" // fail on empty cells
+)
+
+// scaleColumn applies a scaling function to a numeric column and returns a new CSV.
+// name is the builtin it is called from, args are its arguments: the CSV, the column and an optional empty cell policy.
+// scale receives the values of the non-empty cells (after applying the policy) and returns them scaled.
+func scaleColumn(name string, args []object.Object, scale func(values []float64) []float64) object.Object {
+	if len(args) < 2 || len(args) > 3 {
+		return newError("wrong number of arguments: got=%d, want=2 or 3", len(args))
+	}
+
+	csv, errObj := csvArg(name, args[0])
+	if errObj != nil {
+		return errObj
+	}
+	column, errObj := columnArg(name, csv, args[1])
+	if errObj != nil {
+		return errObj
+	}
+	policy := emptySkip
+	if len(args) == 3 {
+		policyArg, ok := args[2].(*object.String)
+		if !ok {
+			return newError("policy of `%s` must be STRING, got %s", name, args[2].Type())
+		}
+		policy = policyArg.Value
+	}
+
+	// collect the numeric cells, empty cells are handled according to the policy
+	values := []float64{}
+	positions := []int{}
+	empty := []int{}
+	for i, cell := range csv.Cells(column) {
+		if cell.Kind == object.NullCell {
+			empty = append(empty, i)
+			continue
+		}
+		value, ok := cellNumber(cell)
+		if !ok {
+			return newError("column %s has non-numeric value %q in row %d", column, cell.Text, i)
+		}
+		values = append(values, value)
+		positions = append(positions, i)
+	}
+
+	switch policy {
+	case emptySkip:
+	case emptyError:
+		if len(empty) > 0 {
+			return newError("column %s has an empty value in row %d", column, empty[0])
+		}
+	case emptyZero, emptyMean:
+		fill := 0.0
+		if policy == emptyMean && len(values) > 0 {
+			fill = mean(values)
+		}
+		for _, i := range empty {
+			values = append(values, fill)
+			positions = append(positions, i)
+		}
+	default:
+		return newError("unknown policy %q for empty cells, want %s, %s, %s or %s", policy, emptySkip, emptyZero, emptyMean, emptyError)
+	}
+
+	// only the rows with a scaled cell are copied, the rows with an empty cell left empty are shared with csv
+	rows := append([]map[string]string(nil), csv.ShareRows()...)
+	for i, scaled := range scale(values) {
+		rows[positions[i]] = copyRow(rows[positions[i]])
+		rows[positions[i]][column] = formatFloat(scaled)
+	}
+
+	return newTransformedCSV(csv, csv.Headers, rows, column)
+}
+
+// mean returns the average of the values.
+func mean(values []float64) float64 {
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	return total / float64(len(values))
+}
+
+// csvArg checks that the first argument of a builtin is a CSV.
+func csvArg(name string, arg object.Object) (*object.CSV, object.Object) {
+	csv, ok := arg.(*object.CSV)
+	if !ok {
+		return nil, newError("first argument to `%s` must be CSV, got %s", name, arg.Type())
+	}
+	return csv, nil
+}
+
+// columnArg checks that an argument of a builtin names a column of the CSV.
+func columnArg(name string, csv *object.CSV, arg object.Object) (string, object.Object) {
+	column, ok := arg.(*object.String)
+	if !ok {
+		return "", newError("column name of `%s` must be STRING, got %s", name, arg.Type())
+	}
+	if !containsString(csv.Headers, column.Value) {
+		return "", newError("column not found: %s", column.Value)
+	}
+	return column.Value, nil
+}
+
+// stringsArg checks that an argument of a builtin is a non-empty array of strings, eg. a list of column names.
+func stringsArg(name string, arg object.Object) ([]string, object.Object) {
+	arr, ok := arg.(*object.Array)
+	if !ok || len(arr.Elements) == 0 {
+		return nil, newError("column names of `%s` must be a non-empty ARRAY, got %s", name, arg.Inspect())
+	}
+	values := make([]string, len(arr.Elements))
+	for i, element := range arr.Elements {
+		str, ok := element.(*object.String)
+		if !ok {
+			return nil, newError("column names of `%s` must be STRING, got %s", name, element.Type())
+		}
+		values[i] = str.Value
+	}
+	return values, nil
+}
+
+// copyRow returns a copy of a row, so a transform doesn't modify its input.
+func copyRow(row map[string]string) map[string]string {
+	newRow := make(map[string]string, len(row))
+	for header, value := range row {
+		newRow[header] = value
+	}
+	return newRow
+}
+
+// newTransformedCSV creates the CSV returned by a transform.
+// Columns kept from the source keep their type, the type of new columns and of the changed columns is inferred from their values.
+// Columns keep their description, whether they have missing cells and their date format are inferred again.
+func newTransformedCSV(source *object.CSV, headers []string, rows []map[string]string, changed ...string) *object.CSV {
+	columnTypes := make([]object.ColumnType, len(headers))
+	for i, header := range headers {
+		columnTypes[i] = object.ColumnType{Name: header, DataType: inferColumnType(rows, header)}
+		for j, sourceHeader := range source.Headers {
+			if sourceHeader != header || j >= len(source.ColumnTypes) {
+				continue
+			}
+			columnTypes[i].Description = source.ColumnTypes[j].Description
+			if !containsString(changed, header) {
+				columnTypes[i].DataType = source.ColumnTypes[j].DataType
+			}
+		}
+		columnTypes[i].Nullable, columnTypes[i].Format = object.InferColumnMetadata(rows, header, columnTypes[i].DataType)
+	}
+
+	return &object.CSV{
+		Headers:     headers,
+		ColumnTypes: columnTypes,
+		Rows:        rows,
+	}
+}
+
+// inferColumnType infers the type of a column from all its non-empty values.
+// It is INTEGER, FLOAT or BOOLEAN if every value parses as one, otherwise STRING.
+func inferColumnType(rows []map[string]string, header string) object.ObjectType {
+	seen, allInt, allFloat, allBool := false, true, true, true
+	for _, row := range rows {
+		value := row[header]
+		if value == "" {
+			continue
+		}
+		seen = true
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			allInt = false
+		}
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			allFloat = false
+		}
+		if value != "true" && value != "false" {
+			allBool = false
+		}
+	}
+
+	switch {
+	case !seen:
+		return object.STRING_OBJ
+	case allInt:
+		return object.INTEGER_OBJ
+	case allFloat:
+		return object.FLOAT_OBJ
+	case allBool:
+		return object.BOOLEAN_OBJ
+	default:
+		return object.STRING_OBJ
+	}
+}
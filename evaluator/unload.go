@@ -0,0 +1,130 @@
+package evaluator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/object"
+)
+
+func init() {
+	// free(rows) drops every variable holding rows, eg. free(big) once its summary is saved, so the rows can be freed
+	builtins["free"] = &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError("wrong number of arguments: got=%d, want at least 1", len(args))
+			}
+			for _, arg := range args {
+				env.Forget(arg)
+			}
+			return NULL
+		},
+	}
+}
+
+// evalUnloadStatement drops variables, eg. unload csv, so the CSVs they hold can be freed once nothing else refers to them
+func evalUnloadStatement(us *ast.UnloadStatement, env *object.Environment) object.Object {
+	for _, name := range us.Names {
+		if env.IsConst(name.Value) {
+			return newError("cannot unload constant %s", name.Value)
+		}
+		if !env.Delete(name.Value) {
+			return newError("identifier not found: " + name.Value)
+		}
+	}
+	return NULL
+}
+
+// saveFrom copies the rows of a file matching the where clause of a save one at a time, without loading the file,
+// so files larger than memory can be filtered, eg. save from big.csv where status == "failed" as failed.csv
+func saveFrom(node *ast.SaveStatement, env *object.Environment) object.Object {
+	source := expandBatchFilename(node.From, env)
+	if err := checkFileAccess("load", source); err != nil {
+		return err
+	}
+	filenames := append([]string{}, node.Filenames...)
+	if len(filenames) == 0 {
+		filenames = []string{node.Filename}
+	}
+	for i, filename := range filenames {
+		filenames[i] = expandBatchFilename(filename, env)
+		if !strings.HasSuffix(filenames[i], ".csv") {
+			return newError("rows saved from a file can only be written to CSV files, got %s", filenames[i])
+		}
+	}
+
+	file, err := os.Open(source)
+	if err != nil {
+		return newError("could not open file: %s", err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.ReuseRecord = true
+	headers, err := reader.Read()
+	if err != nil {
+		return newError("could not read CSV headers: %s", err)
+	}
+	headers = append([]string{}, headers...)
+
+	writers := []*csv.Writer{}
+	for _, filename := range filenames {
+		if DryRun {
+			continue
+		}
+		out, err := os.Create(filename)
+		if err != nil {
+			return newError("could not create file: %s", err)
+		}
+		defer out.Close()
+		writer := csv.NewWriter(out)
+		if err := writer.Write(headers); err != nil {
+			return newError("error writing headers: %s", err)
+		}
+		writers = append(writers, writer)
+	}
+
+	scan := newRowScan(nil)
+	saved := 0
+	for i := 0; ; i++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return newError("could not read CSV records: %s", err)
+		}
+		if node.Where != nil {
+			matched, errObj := evaluateCondition(scan, i, recordToRow(headers, record, false, ""), node.Where, env)
+			if errObj != nil {
+				return errObj
+			}
+			if !matched {
+				continue
+			}
+		}
+		saved++
+		for _, writer := range writers {
+			if err := writer.Write(record); err != nil {
+				return newError("error writing row: %s", err)
+			}
+		}
+	}
+
+	for i, filename := range filenames {
+		if DryRun {
+			fmt.Fprintf(DryRunOutput, "dry run: would write %d rows to %s\n", saved, filename)
+			traceSave(node.Pos(), filename, saved)
+			continue
+		}
+		writers[i].Flush()
+		if err := writers[i].Error(); err != nil {
+			return newError("error writing file: %s", err)
+		}
+		traceSave(node.Pos(), filename, saved)
+	}
+	return NULL
+}
@@ -0,0 +1,72 @@
+package evaluator
+
+import (
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// evalUpdateExpression changes cells in place in the rows of a CSV matching a where clause, every row without one,
+// and returns the number of rows matched, eg. `update csv set (status: "adult") where age > 17`.
+// The values are evaluated once per row, with the row bound to `row`, eg. `set (age: row.age + 1)`.
+// Every value is checked against the type of its column before a cell changes, so a failing update changes nothing.
+func evalUpdateExpression(ue *ast.UpdateExpression, env *object.Environment) object.Object {
+	target := Eval(ue.Target, env)
+	if isError(target) {
+		return target
+	}
+	csv, ok := target.(*object.CSV)
+	if !ok {
+		return newError("update target must be CSV, got %s", target.Type())
+	}
+	for _, column := range ue.Columns {
+		if !containsString(csv.Headers, column.Name) {
+			return newError("column not found: %s", column.Name)
+		}
+		if csv.ReadOnly {
+			return newError("cannot change %s: the file was loaded readonly", column.Name)
+		}
+	}
+
+	// the rows are matched before any changes, so the changes don't decide which rows match
+	matched := []int{}
+	scan := newRowScan(csv)
+	for i, row := range csv.Rows {
+		if ue.Where != nil {
+			ok, errObj := evaluateCondition(scan, i, row, ue.Where, env)
+			if errObj != nil {
+				return errObj
+			}
+			if !ok {
+				continue
+			}
+		}
+		matched = append(matched, i)
+	}
+
+	values := make([][]object.Object, len(matched))
+	for i, index := range matched {
+		rowEnv := object.NewEnclosedEnvironment(env)
+		rowEnv.Set("row", &object.Row{Headers: csv.Headers, Values: csv.Rows[index], CSV: csv, Index: index})
+		values[i] = make([]object.Object, len(ue.Columns))
+		for j, column := range ue.Columns {
+			value := Eval(column.Value, rowEnv)
+			if isError(value) {
+				return value
+			}
+			if errObj := checkColumnType(csv, column.Name, value); errObj != nil {
+				return errObj
+			}
+			values[i][j] = value
+		}
+	}
+
+	for i, index := range matched {
+		row := &object.Row{Headers: csv.Headers, Values: csv.Rows[index], CSV: csv, Index: index}
+		for j, column := range ue.Columns {
+			if errObj := assignRowCell(row, column.Name, "=", values[i][j]); isError(errObj) {
+				return errObj
+			}
+		}
+	}
+	return &object.Integer{Value: int64(len(matched))}
+}
@@ -0,0 +1,246 @@
+package evaluator
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// validationHeaders are the columns of the violations returned by validate, one row per cell breaking a rule
+var validationHeaders = []string{"row", "column", "rule", "value"}
+
+// cellTypes are the rules checking the type of a cell, eg. age: int, they can be compared, eg. age: int > 0
+var cellTypes = map[string]func(value string) (float64, bool){
+	"int": func(value string) (float64, bool) {
+		n, err := strconv.ParseInt(value, 10, 64)
+		return float64(n), err == nil
+	},
+	"float":  parseCellNumber,
+	"number": parseCellNumber,
+	// len compares the number of characters, eg. code: len == 3
+	"len": func(value string) (float64, bool) { return float64(len([]rune(value))), true },
+	"bool": func(value string) (float64, bool) {
+		lower := strings.ToLower(value)
+		return 0, lower == "true" || lower == "false"
+	},
+	"date": func(value string) (float64, bool) {
+		_, err := time.Parse(time.DateOnly, value)
+		return 0, err == nil
+	},
+}
+
+func parseCellNumber(value string) (float64, bool) {
+	n, err := strconv.ParseFloat(value, 64)
+	return n, err == nil
+}
+
+// cellCheck returns the rules a cell breaks
+type cellCheck func(value string) []string
+
+// evalValidateExpression checks every cell of the columns named by the rules, and returns a CSV of the violations
+// with the number of their row from 1. A summary of whether the rows passed is printed.
+// Empty cells only break `required`, so optional columns can still be typed, eg. (phone: required, age: int > 0).
+func evalValidateExpression(ve *ast.ValidateExpression, env *object.Environment) object.Object {
+	source := Eval(ve.Source, env)
+	if isError(source) {
+		return source
+	}
+	csv, ok := source.(*object.CSV)
+	if !ok {
+		return newError("validate needs a CSV, got %s", source.Type())
+	}
+
+	checks := make([]cellCheck, len(ve.Rules))
+	for i, rule := range ve.Rules {
+		if !containsString(csv.Headers, rule.Column) {
+			return newError("column not found: %s", rule.Column)
+		}
+		check, errObj := compileRule(rule.Column, rule.Rule, env)
+		if errObj != nil {
+			return errObj
+		}
+		checks[i] = check
+	}
+
+	violations := []map[string]string{}
+	failedRows := 0
+	for i, row := range csv.Rows {
+		before := len(violations)
+		for j, rule := range ve.Rules {
+			for _, broken := range checks[j](row[rule.Column]) {
+				violations = append(violations, map[string]string{
+					"row": strconv.Itoa(i + 1), "column": rule.Column, "rule": broken, "value": row[rule.Column],
+				})
+			}
+		}
+		if len(violations) > before {
+			failedRows++
+		}
+	}
+
+	if failedRows == 0 {
+		fmt.Printf("validation passed: %d rows\n", len(csv.Rows))
+	} else {
+		fmt.Printf("validation failed: %d violations in %d of %d rows\n", len(violations), failedRows, len(csv.Rows))
+	}
+	result := &object.CSV{Headers: append([]string{}, validationHeaders...), Rows: violations}
+	result.InferColumnTypes()
+	return result
+}
+
+// compileRule turns the rule of a column into a check of its cells, evaluating the values the rule is compared with once
+func compileRule(column string, rule ast.Expression, env *object.Environment) (cellCheck, object.Object) {
+	description := ruleString(rule)
+	unknown := func() (cellCheck, object.Object) {
+		return nil, newError("unknown rule for column %s: %s, expected required, unique, a type, a comparison, matches(...) or one_of(...)", column, description)
+	}
+	// a rule on a single cell, empty cells are only checked by required
+	leaf := func(ok func(value string) bool) (cellCheck, object.Object) {
+		return func(value string) []string {
+			if value == "" || ok(value) {
+				return nil
+			}
+			return []string{description}
+		}, nil
+	}
+
+	switch rule := rule.(type) {
+	case *ast.Identifier:
+		switch rule.Value {
+		case "required":
+			return func(value string) []string {
+				if strings.TrimSpace(value) != "" {
+					return nil
+				}
+				return []string{description}
+			}, nil
+		case "unique":
+			seen := map[string]bool{}
+			return leaf(func(value string) bool {
+				duplicate := seen[value]
+				seen[value] = true
+				return !duplicate
+			})
+		}
+		if parse, ok := cellTypes[rule.Value]; ok && rule.Value != "len" {
+			return leaf(func(value string) bool {
+				_, ok := parse(value)
+				return ok
+			})
+		}
+
+	case *ast.InfixExpression:
+		switch rule.Operator {
+		case "and", "&&":
+			left, errObj := compileRule(column, rule.Left, env)
+			if errObj != nil {
+				return nil, errObj
+			}
+			right, errObj := compileRule(column, rule.Right, env)
+			if errObj != nil {
+				return nil, errObj
+			}
+			return func(value string) []string {
+				return append(left(value), right(value)...)
+			}, nil
+		case "or", "||":
+			left, errObj := compileRule(column, rule.Left, env)
+			if errObj != nil {
+				return nil, errObj
+			}
+			right, errObj := compileRule(column, rule.Right, env)
+			if errObj != nil {
+				return nil, errObj
+			}
+			return func(value string) []string {
+				if len(left(value)) == 0 || len(right(value)) == 0 {
+					return nil
+				}
+				return []string{description}
+			}, nil
+		case "<", ">", "<=", ">=", "==", "!=":
+			kind, ok := rule.Left.(*ast.Identifier)
+			if !ok || cellTypes[kind.Value] == nil || kind.Value == "bool" || kind.Value == "date" {
+				return unknown()
+			}
+			bound := Eval(rule.Right, env)
+			if isError(bound) {
+				return nil, bound
+			}
+			if !isNumeric(bound) {
+				return nil, newError("rule for column %s must compare with a number, got %s", column, bound.Inspect())
+			}
+			parse, operator, limit := cellTypes[kind.Value], rule.Operator, toFloat(bound)
+			return leaf(func(value string) bool {
+				n, ok := parse(value)
+				return ok && compareNumbers(n, operator, limit)
+			})
+		}
+
+	case *ast.CallExpression:
+		name, ok := rule.Function.(*ast.Identifier)
+		if !ok || len(rule.Arguments) != 1 || (name.Value != "matches" && name.Value != "one_of") {
+			return unknown()
+		}
+		arg := Eval(rule.Arguments[0], env)
+		if isError(arg) {
+			return nil, arg
+		}
+		if name.Value == "matches" {
+			pattern, ok := arg.(*object.String)
+			if !ok {
+				return nil, newError("argument to `matches` must be STRING, got %s", arg.Type())
+			}
+			re, err := regexp.Compile(pattern.Value)
+			if err != nil {
+				return nil, newError("invalid pattern for column %s: %s", column, err)
+			}
+			return leaf(re.MatchString)
+		}
+		values, ok := arg.(*object.Array)
+		if !ok {
+			return nil, newError("argument to `one_of` must be ARRAY, got %s", arg.Type())
+		}
+		allowed := map[string]bool{}
+		for _, value := range values.Elements {
+			allowed[value.Inspect()] = true
+		}
+		return leaf(func(value string) bool { return allowed[value] })
+	}
+	return unknown()
+}
+
+// ruleString writes a rule the way it was written in the script, eg. age: int > 0 reads int > 0
+func ruleString(rule ast.Expression) string {
+	switch rule := rule.(type) {
+	case *ast.StringLiteral:
+		return strconv.Quote(rule.Value)
+	case *ast.ArrayLiteral:
+		elements := []string{}
+		for _, element := range rule.Elements {
+			elements = append(elements, ruleString(element))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *ast.CallExpression:
+		args := []string{}
+		for _, arg := range rule.Arguments {
+			args = append(args, ruleString(arg))
+		}
+		return rule.Function.String() + "(" + strings.Join(args, ", ") + ")"
+	case *ast.InfixExpression:
+		left, right := ruleString(rule.Left), ruleString(rule.Right)
+		if _, ok := rule.Left.(*ast.InfixExpression); ok {
+			left = "(" + left + ")"
+		}
+		if _, ok := rule.Right.(*ast.InfixExpression); ok {
+			right = "(" + right + ")"
+		}
+		return left + " " + rule.Operator + " " + right
+	}
+	return rule.String()
+}
@@ -0,0 +1,625 @@
+// explain package prints the logical plan of a script without running it, started with `csvlang explain -path job.csl`.
+//
+// Every load, read and save is listed with the file it touches, an estimate of the rows it scans, the columns it keeps,
+// and how the rows are accessed. Loaded files are only sampled to estimate their size, nothing is written.
+package explain
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/token"
+)
+
+// sampleSize is how much of a loaded file is read to estimate its number of rows
+const sampleSize = 64 * 1024
+
+// Step is one operation of the plan, eg. the scan of a read
+type Step struct {
+	Pos     token.Position
+	Summary string   // the statement, eg. `read row * where age > 17`
+	Details []string // eg. the rows scanned and the columns kept
+}
+
+// relation describes the rows a CSV value holds, as far as it is known before running the script
+type relation struct {
+	file    string   // the loaded file the rows come from, "" if unknown
+	rows    int      // number of rows, or an upper bound when filtered, -1 if unknown
+	exact   bool     // rows was counted, not estimated
+	bounded bool     // rows is an upper bound, eg. after a where clause
+	columns []string // nil if unknown
+}
+
+// planner walks a program in source order, tracking which rows each variable holds
+type planner struct {
+	steps  []*Step
+	loaded *relation            // the file of the last load, read by `read` without `from`
+	vars   map[string]*relation // CSV variables bound by let
+}
+
+// Plan returns the steps of a program in source order
+func Plan(program *ast.Program) []*Step {
+	p := &planner{vars: map[string]*relation{}}
+	for _, stmt := range program.Statements {
+		p.statement(stmt)
+	}
+	return p.steps
+}
+
+// Write prints the steps of a plan, each with its line and column
+func Write(out io.Writer, steps []*Step) {
+	if len(steps) == 0 {
+		fmt.Fprintln(out, "no loads, reads or saves")
+		return
+	}
+	for _, step := range steps {
+		location := fmt.Sprintf("%d:%d", step.Pos.Line, step.Pos.Column)
+		fmt.Fprintf(out, "%-6s %s\n", location, step.Summary)
+		for _, detail := range step.Details {
+			fmt.Fprintf(out, "%-6s   %s\n", "", detail)
+		}
+	}
+}
+
+func (p *planner) add(node ast.Node, summary string, details ...string) {
+	p.steps = append(p.steps, &Step{Pos: node.Pos(), Summary: summary, Details: details})
+}
+
+func (p *planner) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		if stmt == nil || stmt.Name == nil {
+			return
+		}
+		if rel := p.expression(stmt.Value); rel != nil {
+			p.vars[stmt.Name.Value] = rel
+		} else {
+			delete(p.vars, stmt.Name.Value)
+		}
+	case *ast.AssignmentStatement:
+		if stmt == nil || stmt.Name == nil {
+			return
+		}
+		if rel := p.expression(stmt.Value); rel != nil && stmt.Operator == "=" {
+			p.vars[stmt.Name.Value] = rel
+		} else {
+			delete(p.vars, stmt.Name.Value)
+		}
+	case *ast.ExpressionStatement:
+		if stmt != nil {
+			p.expression(stmt.Expression)
+		}
+	case *ast.LoadStatement:
+		p.expression(stmt)
+	case *ast.ReadStatement:
+		if stmt != nil && stmt.ReadExpression != nil {
+			p.expression(stmt.ReadExpression)
+		}
+	case *ast.SaveStatement:
+		if stmt != nil {
+			p.save(stmt)
+		}
+	case *ast.FunctionStatement:
+		if stmt != nil && stmt.Function != nil {
+			p.block(stmt.Function.Body)
+		}
+	case *ast.ForLoopStatement:
+		if stmt != nil && stmt.ForLoopExpression != nil {
+			p.expression(stmt.ForLoopExpression)
+		}
+	case *ast.BlockStatement:
+		p.block(stmt)
+	}
+}
+
+func (p *planner) block(block *ast.BlockStatement) {
+	if block == nil {
+		return
+	}
+	for _, stmt := range block.Statements {
+		p.statement(stmt)
+	}
+}
+
+// expression plans the loads, reads and saves of an expression and returns the rows it evaluates to, if it is a CSV
+func (p *planner) expression(expr ast.Expression) *relation {
+	switch expr := expr.(type) {
+	case *ast.LoadStatement:
+		if expr == nil || expr.Filename == nil {
+			return nil
+		}
+		p.loaded = p.load(expr)
+		return p.loaded
+	case *ast.ReadExpression:
+		if expr == nil {
+			return nil
+		}
+		return p.read(expr)
+	case *ast.Identifier:
+		if expr == nil {
+			return nil
+		}
+		if expr.Value == "csv" {
+			return p.loaded
+		}
+		return p.vars[expr.Value]
+	case *ast.PipeExpression:
+		if expr == nil {
+			return nil
+		}
+		left := p.expression(expr.Left)
+		if call, ok := expr.Right.(*ast.CallExpression); ok {
+			return p.call(call, left, call.Arguments)
+		}
+		p.expression(expr.Right)
+		return derived(left)
+	case *ast.CallExpression:
+		if expr == nil || len(expr.Arguments) == 0 {
+			return nil
+		}
+		return p.call(expr, p.expression(expr.Arguments[0]), expr.Arguments[1:])
+	case *ast.MethodCallExpression:
+		if expr == nil || expr.Method == nil {
+			return nil
+		}
+		call := &ast.CallExpression{Token: expr.Token, Function: expr.Method, Arguments: expr.Arguments}
+		return p.call(call, p.expression(expr.Receiver), expr.Arguments)
+	case *ast.IfExpression:
+		if expr != nil {
+			p.block(expr.Consequence)
+			p.block(expr.Alternative)
+		}
+	case *ast.TryExpression:
+		if expr != nil {
+			p.block(expr.Body)
+			p.block(expr.Handler)
+		}
+	case *ast.ForLoopExpression:
+		if expr != nil {
+			p.expression(expr.Iterable)
+			p.block(expr.Body)
+		}
+	case *ast.FunctionLiteral:
+		if expr != nil {
+			p.block(expr.Body)
+		}
+	case *ast.GenerateExpression:
+		// generated rows are made in memory, there is nothing to scan
+		if expr == nil {
+			return nil
+		}
+		rel := &relation{rows: -1}
+		if n, ok := expr.Count.(*ast.IntegerLiteral); ok {
+			rel.rows, rel.exact = int(n.Value), true
+		}
+		for _, column := range expr.Columns {
+			rel.columns = append(rel.columns, column.Name)
+		}
+		return rel
+	case *ast.ValidateExpression:
+		// the violations are a new CSV, the rows checked are planned on their own
+		if expr != nil {
+			p.expression(expr.Source)
+		}
+	case *ast.UpdateExpression:
+		if expr != nil {
+			p.update(expr)
+		}
+	}
+	return nil
+}
+
+// call plans a builtin applied to a CSV, the first argument or the left side of a pipe
+func (p *planner) call(call *ast.CallExpression, input *relation, args []ast.Expression) *relation {
+	for _, arg := range args {
+		p.expression(arg)
+	}
+	name, _ := call.Function.(*ast.Identifier)
+	if name == nil || input == nil {
+		return nil
+	}
+
+	switch name.Value {
+	case "save":
+		filename := "?"
+		if len(args) > 0 {
+			if lit, ok := args[0].(*ast.StringLiteral); ok {
+				filename = lit.Value
+			}
+		}
+		p.add(call, fmt.Sprintf("save(%q)", filename), writeDetail(input, filename))
+		return input
+	case "select":
+		if len(args) == 1 {
+			if columns, ok := stringArray(args[0]); ok {
+				return &relation{file: input.file, rows: input.rows, exact: input.exact, bounded: input.bounded, columns: columns}
+			}
+		}
+	case "head", "tail":
+		limit := &relation{file: input.file, rows: 10, exact: true, bounded: true, columns: input.columns}
+		if len(args) == 1 {
+			if n, ok := args[0].(*ast.IntegerLiteral); ok {
+				limit.rows = int(n.Value)
+			}
+		}
+		if input.rows >= 0 && input.rows < limit.rows {
+			limit.rows, limit.exact = input.rows, input.exact
+		}
+		return limit
+	case "count", "len", "sum", "avg":
+		return nil
+	}
+	return derived(input)
+}
+
+// derived returns the rows of a transform of a CSV, eg. unique() keeps at most as many rows as its input
+func derived(input *relation) *relation {
+	if input == nil {
+		return nil
+	}
+	return &relation{file: input.file, rows: input.rows, exact: input.exact, bounded: true}
+}
+
+func (p *planner) load(stmt *ast.LoadStatement) *relation {
+	filename := filenameOf(stmt.Filename)
+	rel := &relation{file: filename, rows: -1}
+	details := []string{}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		details = append(details, "can't estimate rows: "+err.Error())
+	} else {
+		rel.columns, rel.rows, rel.exact, err = sampleFile(filename, info.Size())
+		switch {
+		case err != nil:
+			details = append(details, "could not sample the file: "+err.Error())
+		case rel.exact:
+			details = append(details, fmt.Sprintf("scan %s: %s, %d rows", filename, formatSize(info.Size()), rel.rows))
+		default:
+			details = append(details, fmt.Sprintf("scan %s: %s, ~%d rows (estimated from the first %s)", filename, formatSize(info.Size()), rel.rows, formatSize(sampleSize)))
+		}
+		if rel.columns != nil {
+			details = append(details, fmt.Sprintf("columns: %s (%d)", strings.Join(rel.columns, ", "), len(rel.columns)))
+		}
+	}
+	if stmt.Mmap {
+		details = append(details, "access: the file is mapped in memory, rows are parsed as they are read")
+	} else {
+		details = append(details, "access: the whole file is read into memory, rows are not streamed")
+	}
+	p.add(stmt, stmt.String(), details...)
+	return rel
+}
+
+func (p *planner) read(expr *ast.ReadExpression) *relation {
+	source, sourceName := p.loaded, "csv"
+	if expr.Source != nil {
+		source, sourceName = p.vars[expr.Source.Value], expr.Source.Value
+	}
+	if source == nil {
+		source = &relation{rows: -1}
+	}
+
+	details := []string{"source: " + describe(sourceName, source)}
+	result := &relation{file: source.file, rows: source.rows, exact: source.exact, bounded: source.bounded, columns: source.columns}
+
+	location := expr.Location
+	if location.RowIndex == -2 {
+		details = append(details, "access: full scan of "+rowCount(source)+", no index")
+	} else {
+		details = append(details, fmt.Sprintf("access: row %d by position, no scan", location.RowIndex))
+		result.rows, result.exact, result.bounded = 1, true, true
+	}
+
+	if location.Filter != nil {
+		details = append(details, "filter: "+filterString(location.Filter))
+		result.bounded = true
+		for _, column := range filterColumns(location.Filter) {
+			if source.columns != nil && !containsString(source.columns, column) {
+				details = append(details, fmt.Sprintf("warning: column %s is not in %s", column, describeFile(source)))
+			}
+		}
+	}
+
+	if location.ColIndex != "" && location.ColIndex != "*" {
+		details = append(details, "project: column "+location.ColIndex)
+		if source.columns != nil && !containsString(source.columns, location.ColIndex) {
+			details = append(details, fmt.Sprintf("warning: column %s is not in %s", location.ColIndex, describeFile(source)))
+		}
+		// a single column evaluates to an array, not a CSV
+		p.add(expr, readString(expr), append(details, "output: one value per row, "+rowCount(result))...)
+		return nil
+	}
+
+	details = append(details, "project: all columns"+columnList(result.columns))
+	p.add(expr, readString(expr), append(details, "output: "+rowCount(result))...)
+	return result
+}
+
+// update plans cells changed in place, the update evaluates to the number of rows matched
+func (p *planner) update(expr *ast.UpdateExpression) {
+	source := p.expression(expr.Target)
+	if source == nil {
+		source = &relation{rows: -1}
+	}
+	details := []string{"source: " + describe(expr.Target.String(), source), "access: full scan of " + rowCount(source) + ", no index"}
+	if expr.Where != nil {
+		details = append(details, "filter: "+filterString(expr.Where))
+	}
+	columns := []string{}
+	for _, column := range expr.Columns {
+		columns = append(columns, column.Name)
+	}
+	details = append(details, "change: "+strings.Join(columns, ", ")+" in place")
+	if expr.Where != nil {
+		columns = append(columns, filterColumns(expr.Where)...)
+	}
+	for _, column := range columns {
+		if source.columns != nil && !containsString(source.columns, column) {
+			details = append(details, fmt.Sprintf("warning: column %s is not in %s", column, describeFile(source)))
+		}
+	}
+	p.add(expr, updateString(expr), append(details, "output: the number of rows matched")...)
+}
+
+func (p *planner) save(stmt *ast.SaveStatement) {
+	source := p.loaded
+	if stmt.Source != nil {
+		source = p.expression(stmt.Source)
+	}
+	if source == nil {
+		source = &relation{rows: -1}
+	}
+
+	details := []string{}
+	switch {
+	case stmt.From != "":
+		// the rows are copied one at a time, the file is never loaded
+		source = &relation{file: stmt.From, rows: -1}
+		details = append(details, "access: rows are streamed from "+stmt.From+", one at a time")
+		if stmt.Where != nil {
+			details = append(details, "filter: "+filterString(stmt.Where))
+		}
+		for _, filename := range stmt.Filenames {
+			details = append(details, writeDetail(source, filename))
+		}
+	case len(stmt.Bundle) > 0:
+		for _, entry := range stmt.Bundle {
+			details = append(details, writeDetail(derived(p.expression(entry.Value)), stmt.Filename+"/"+entry.Name))
+		}
+		details = append(details, "the directory is replaced once every file is written")
+	case stmt.SplitBy != "":
+		for _, filename := range stmt.Filenames {
+			details = append(details, writeDetail(source, filename)+", one file per value of "+stmt.SplitBy)
+		}
+		if source.columns != nil && !containsString(source.columns, stmt.SplitBy) {
+			details = append(details, fmt.Sprintf("warning: column %s is not in %s", stmt.SplitBy, describeFile(source)))
+		}
+	default:
+		filenames := stmt.Filenames
+		if len(filenames) == 0 {
+			filenames = []string{stmt.Filename}
+		}
+		for _, filename := range filenames {
+			details = append(details, writeDetail(source, filename))
+		}
+	}
+	p.add(stmt, stmt.String(), details...)
+}
+
+func writeDetail(source *relation, filename string) string {
+	format := "CSV"
+	if strings.HasSuffix(filename, ".json") {
+		format = "JSON"
+	}
+	if source == nil {
+		source = &relation{rows: -1}
+	}
+	return fmt.Sprintf("write %s as %s to %s", rowCount(source), format, filename)
+}
+
+// rowCount describes the number of rows of a relation, eg. "~420 rows" or "up to 12 rows"
+func rowCount(rel *relation) string {
+	if rel.rows < 0 {
+		return "an unknown number of rows"
+	}
+	count := fmt.Sprintf("%d rows", rel.rows)
+	if rel.rows == 1 {
+		count = "1 row"
+	}
+	if !rel.exact {
+		count = "~" + count
+	}
+	if rel.bounded {
+		count = "up to " + count
+	}
+	return count
+}
+
+func describe(name string, rel *relation) string {
+	if rel.file == "" {
+		return fmt.Sprintf("%s (%s)", name, rowCount(rel))
+	}
+	return fmt.Sprintf("%s from %s (%s)", name, rel.file, rowCount(rel))
+}
+
+func describeFile(rel *relation) string {
+	if rel.file == "" {
+		return "the source"
+	}
+	return rel.file
+}
+
+func columnList(columns []string) string {
+	if columns == nil {
+		return ""
+	}
+	return " (" + strings.Join(columns, ", ") + ")"
+}
+
+// readString renders a read the way it is written, the AST only keeps row and column indexes
+func readString(expr *ast.ReadExpression) string {
+	var out strings.Builder
+	out.WriteString("read ")
+	if expr.Source != nil {
+		out.WriteString("from " + expr.Source.Value + " ")
+	}
+	if expr.Location.RowIndex == -2 {
+		out.WriteString("row *")
+	} else {
+		fmt.Fprintf(&out, "row %d", expr.Location.RowIndex)
+	}
+	if expr.Location.ColIndex != "" {
+		out.WriteString(" col " + expr.Location.ColIndex)
+	}
+	if expr.Location.Filter != nil {
+		out.WriteString(" where " + filterString(expr.Location.Filter))
+	}
+	return out.String()
+}
+
+// updateString renders an update as written, eg. `update csv set (status: "adult") where age > 17`
+func updateString(expr *ast.UpdateExpression) string {
+	columns := []string{}
+	for _, column := range expr.Columns {
+		columns = append(columns, column.String())
+	}
+	out := "update " + expr.Target.String() + " set (" + strings.Join(columns, ", ") + ")"
+	if expr.Where != nil {
+		out += " where " + filterString(expr.Where)
+	}
+	return out
+}
+
+// filterString renders a where clause, eg. `age > 17 and city == "Oslo"`
+func filterString(filter ast.Expression) string {
+	switch filter := filter.(type) {
+	case *ast.ReadFilterExpression:
+		value := "?"
+		if filter.Value != nil {
+			value = filter.Value.String()
+			if str, ok := filter.Value.(*ast.StringLiteral); ok {
+				value = fmt.Sprintf("%q", str.Value)
+			}
+		}
+		return filter.ColumnName + " " + filter.Operator + " " + value
+	case *ast.InfixExpression:
+		return filterTerm(filter.Left) + " " + filter.Operator + " " + filterTerm(filter.Right)
+	case *ast.PrefixExpression:
+		return filter.Operator + " " + filterTerm(filter.Right)
+	case nil:
+		return "?"
+	}
+	return filter.String()
+}
+
+// filterTerm renders a part of a where clause, combined conditions are wrapped in parentheses
+func filterTerm(filter ast.Expression) string {
+	if _, ok := filter.(*ast.InfixExpression); ok {
+		return "(" + filterString(filter) + ")"
+	}
+	return filterString(filter)
+}
+
+// filterColumns returns the columns a where clause compares, in order
+func filterColumns(filter ast.Expression) []string {
+	switch filter := filter.(type) {
+	case *ast.ReadFilterExpression:
+		return []string{filter.ColumnName}
+	case *ast.InfixExpression:
+		return append(filterColumns(filter.Left), filterColumns(filter.Right)...)
+	case *ast.PrefixExpression:
+		return filterColumns(filter.Right)
+	}
+	return nil
+}
+
+// sampleFile reads the header of a CSV file and counts its rows, large files are estimated from their first lines
+func sampleFile(path string, size int64) ([]string, int, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer file.Close()
+
+	sample := make([]byte, sampleSize)
+	n, err := io.ReadFull(file, sample)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, 0, false, err
+	}
+	sample = sample[:n]
+
+	reader := csv.NewReader(bytes.NewReader(sample))
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, 0, false, err
+	}
+	headerBytes := reader.InputOffset()
+
+	// offsets[i] is where record i ends, the last record of a sample can be cut
+	offsets := []int64{}
+	for {
+		if _, err := reader.Read(); err != nil {
+			break
+		}
+		offsets = append(offsets, reader.InputOffset())
+	}
+	if int64(n) == size {
+		return header, len(offsets), true, nil
+	}
+	if len(offsets) < 2 {
+		return header, -1, false, nil
+	}
+	complete := offsets[:len(offsets)-1]
+	rowBytes := float64(complete[len(complete)-1]-headerBytes) / float64(len(complete))
+	return header, int(float64(size-headerBytes)/rowBytes + 0.5), false, nil
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d bytes", size)
+	}
+}
+
+func filenameOf(expr ast.Expression) string {
+	if lit, ok := expr.(*ast.StringLiteral); ok {
+		return lit.Value
+	}
+	return expr.String()
+}
+
+func stringArray(expr ast.Expression) ([]string, bool) {
+	arr, ok := expr.(*ast.ArrayLiteral)
+	if !ok {
+		return nil, false
+	}
+	values := make([]string, len(arr.Elements))
+	for i, element := range arr.Elements {
+		str, ok := element.(*ast.StringLiteral)
+		if !ok {
+			return nil, false
+		}
+		values[i] = str.Value
+	}
+	return values, true
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package explain
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Rishabh570/csvlang/lexer"
+	"github.com/Rishabh570/csvlang/parser"
+)
+
+func plan(t *testing.T, input string) string {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors)
+	}
+	var out bytes.Buffer
+	Write(&out, Plan(program))
+	return out.String()
+}
+
+func TestPlan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "people.csv")
+	if err := os.WriteFile(path, []byte("name,age,city\nAnn,30,Oslo\nBo,12,Rome\nCy,45,Oslo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	input := fmt.Sprintf(`load %q
+let adults = read row * where age > 17 and not city == "Rome"
+let names = read row * col name where agee > 3
+let first = read from adults row 0
+adults |> head(2) |> save("top.csv")
+save first as "first.json"`, path)
+
+	expected := fmt.Sprintf(`1:1    load %[1]s
+         scan %[1]s: 48 bytes, 3 rows
+         columns: name, age, city (3)
+         access: the whole file is read into memory, rows are not streamed
+2:14   read row * where age > 17 and not city == "Rome"
+         source: csv from %[1]s (3 rows)
+         access: full scan of 3 rows, no index
+         filter: age > 17 and not city == "Rome"
+         project: all columns (name, age, city)
+         output: up to 3 rows
+3:13   read row * col name where agee > 3
+         source: csv from %[1]s (3 rows)
+         access: full scan of 3 rows, no index
+         filter: agee > 3
+         warning: column agee is not in %[1]s
+         project: column name
+         output: one value per row, up to 3 rows
+4:13   read from adults row 0
+         source: adults from %[1]s (up to 3 rows)
+         access: row 0 by position, no scan
+         project: all columns (name, age, city)
+         output: up to 1 row
+5:22   save("top.csv")
+         write up to 2 rows as CSV to top.csv
+6:1    save first as first.json
+         write up to 1 row as JSON to first.json
+`, path)
+	if got := plan(t, input); got != expected {
+		t.Errorf("wrong plan.\nexpected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestPlanEstimatesLargeFiles(t *testing.T) {
+	var data strings.Builder
+	data.WriteString("id,value\n")
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&data, "%05d,x\n", i)
+	}
+	path := filepath.Join(t.TempDir(), "large.csv")
+	if err := os.WriteFile(path, []byte(data.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := plan(t, fmt.Sprintf("load %q\nread row *", path))
+	if !strings.Contains(got, "~20000 rows (estimated from the first 64.0 KB)") {
+		t.Errorf("expected an estimate of 20000 rows. got:\n%s", got)
+	}
+}
+
+func TestPlanWithoutData(t *testing.T) {
+	if got := plan(t, "let x = 1"); got != "no loads, reads or saves\n" {
+		t.Errorf("wrong plan. got=%q", got)
+	}
+	got := plan(t, "load missing.csv\nsave as \"out.csv\"")
+	if !strings.Contains(got, "can't estimate rows") || !strings.Contains(got, "write an unknown number of rows as CSV to out.csv") {
+		t.Errorf("missing files should be reported. got:\n%s", got)
+	}
+}
+
+func TestPlanSaveFrom(t *testing.T) {
+	got := plan(t, `save from "big.csv" where status == "failed" as failed.csv`)
+	for _, want := range []string{"access: rows are streamed from big.csv, one at a time", `filter: status == "failed"`, "write an unknown number of rows as CSV to failed.csv"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("plan should contain %q. got:\n%s", want, got)
+		}
+	}
+}
+
+func TestPlanMappedLoad(t *testing.T) {
+	got := plan(t, `load "big.csv" readonly mmap`)
+	if !strings.Contains(got, "access: the file is mapped in memory, rows are parsed as they are read") {
+		t.Errorf("mapped files should be described as mapped. got:\n%s", got)
+	}
+}
+
+func TestPlanGeneratedRows(t *testing.T) {
+	got := plan(t, "let rows = generate 50 rows with (id: row_number, name: fake(\"name\"))\nsave rows as \"fixtures.csv\"")
+	if !strings.Contains(got, "50 rows") || !strings.Contains(got, "fixtures.csv") {
+		t.Errorf("generated rows should be counted. got:\n%s", got)
+	}
+}
+
+func TestPlanUpdate(t *testing.T) {
+	got := plan(t, "load \"people.csv\"\nupdate csv set (status: \"adult\", agee: 1) where age > 17")
+	for _, detail := range []string{"filter: age > 17", "change: status, agee in place", "output: the number of rows matched"} {
+		if !strings.Contains(got, detail) {
+			t.Errorf("the update should be planned with %q. got:\n%s", detail, got)
+		}
+	}
+}
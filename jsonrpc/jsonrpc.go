@@ -0,0 +1,266 @@
+// jsonrpc package runs csvlang scripts for other programs over JSON-RPC 2.0, started with `csvlang rpc`.
+//
+// Messages are JSON objects, one per line, exchanged over stdin and stdout or over TCP connections with --listen.
+// The Execute method runs a script and returns its result as structured values:
+//
+//	{"jsonrpc": "2.0", "id": 1, "method": "Execute", "params": {"script": "read row * where age > 17", "inputs": {"csv": "name,age\nAnn,30\n"}}}
+//
+// Inputs are CSV texts bound to variables before the script runs, "csv" is the CSV read by `read`.
+// With "stream": true the rows of a CSV result are sent one by one as Execute.row notifications before the response,
+// which then only holds the headers and the number of rows.
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Rishabh570/csvlang/evaluator"
+	"github.com/Rishabh570/csvlang/lexer"
+	"github.com/Rishabh570/csvlang/object"
+	"github.com/Rishabh570/csvlang/parser"
+)
+
+// JSON-RPC error codes, scriptError and parseError are in the range reserved for applications
+const (
+	invalidRequest = -32600
+	methodNotFound = -32601
+	invalidParams  = -32602
+	scriptError    = -32000
+	parseError     = -32001
+)
+
+// maxMessage is the longest line read, inputs are sent inline so it is large
+const maxMessage = 64 << 20
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *responseError  `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// errorDetail locates an error of the script
+type errorDetail struct {
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+// ExecuteParams are the parameters of Execute
+type ExecuteParams struct {
+	Script  string            `json:"script"`
+	Inputs  map[string]string `json:"inputs,omitempty"`  // CSV texts by variable name
+	Outputs []string          `json:"outputs,omitempty"` // variables returned along with the result
+	Stream  bool              `json:"stream,omitempty"`  // send the rows of a CSV result as notifications
+}
+
+// ExecuteResult is the result of Execute, Value is the value of the last statement
+type ExecuteResult struct {
+	Value   *Value            `json:"value"`
+	Outputs map[string]*Value `json:"outputs,omitempty"`
+}
+
+// Value is a csvlang value, only the fields of its type are set
+type Value struct {
+	Type     string      `json:"type"` // eg. "CSV" or "INTEGER"
+	Value    interface{} `json:"value,omitempty"`
+	Elements []*Value    `json:"elements,omitempty"`
+	Headers  []string    `json:"headers,omitempty"`
+	Rows     [][]string  `json:"rows,omitempty"`
+	RowCount *int        `json:"rowCount,omitempty"` // number of rows of a CSV, its rows are left out when they were streamed
+}
+
+// Server answers requests, each execution runs in its own environment so the connections don't wait for each other
+type Server struct {
+	Timeout time.Duration // longest a script may run before it is stopped, 0 for no limit
+}
+
+// NewServer creates a server, call Serve for each connection
+func NewServer() *Server {
+	return &Server{}
+}
+
+// Listen accepts connections on a TCP listener and serves each of them until the listener is closed
+func (s *Server) Listen(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			s.Serve(conn, conn)
+		}()
+	}
+}
+
+// Serve reads requests from in and writes responses to out until in is closed
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxMessage)
+	encoder := json.NewEncoder(out)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		send := func(msg *response) error { return encoder.Encode(msg) }
+		if err := s.handle([]byte(line), send); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// handle answers one request, send writes a message to the client
+func (s *Server) handle(line []byte, send func(*response) error) error {
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+		return send(failure(nil, invalidRequest, "invalid request", nil))
+	}
+	// notifications get no response
+	if req.ID == nil {
+		send = func(*response) error { return nil }
+	}
+
+	switch req.Method {
+	case "Execute":
+		var params ExecuteParams
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.Script == "" {
+			return send(failure(req.ID, invalidParams, "Execute needs a script", nil))
+		}
+		return send(s.execute(req.ID, &params, send))
+	default:
+		return send(failure(req.ID, methodNotFound, "method not supported: "+req.Method, nil))
+	}
+}
+
+// execute runs a script and returns the response to send, the rows of a streamed result are sent before it
+func (s *Server) execute(id json.RawMessage, params *ExecuteParams, send func(*response) error) *response {
+	p := parser.New(lexer.New(params.Script))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		details := make([]errorDetail, len(p.Errors))
+		for i, err := range p.Errors {
+			details[i] = errorDetail{Message: err.Message, Line: err.Line, Column: err.Column}
+		}
+		return failure(id, parseError, "the script has parser errors", details)
+	}
+
+	env := object.NewEnvironment()
+	for name, text := range params.Inputs {
+		data, err := evaluator.ReadCSV(strings.NewReader(text), false, "")
+		if err != nil {
+			return failure(id, invalidParams, fmt.Sprintf("input %s: %s", name, err), nil)
+		}
+		env.Set(name, data)
+	}
+
+	// the script is stopped past the timeout, see evaluator.EvalContext
+	ctx := context.Background()
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	result := evaluator.EvalContext(ctx, program, env)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return failure(id, scriptError, fmt.Sprintf("script timed out after %s", s.Timeout), nil)
+	}
+
+	switch result := result.(type) {
+	case *object.Error:
+		return failure(id, scriptError, result.Message, errorDetail{Message: result.Message, Line: result.Pos.Line, Column: result.Pos.Column})
+	case *object.Exit:
+		if result.Code != 0 {
+			return failure(id, scriptError, fmt.Sprintf("script exited with code %d", result.Code), nil)
+		}
+	}
+
+	executed := &ExecuteResult{Value: encode(result, params.Stream)}
+	if data, ok := result.(*object.CSV); ok && params.Stream {
+		for _, row := range data.Rows {
+			row := &response{JSONRPC: "2.0", Method: "Execute.row", Params: map[string]interface{}{"id": id, "row": data.Values(row)}}
+			if err := send(row); err != nil {
+				return failure(id, scriptError, err.Error(), nil)
+			}
+		}
+	}
+
+	if len(params.Outputs) > 0 {
+		executed.Outputs = map[string]*Value{}
+		for _, name := range params.Outputs {
+			output, ok := env.Get(name)
+			if !ok {
+				return failure(id, scriptError, "output not found: "+name, nil)
+			}
+			executed.Outputs[name] = encode(output, false)
+		}
+	}
+	return &response{JSONRPC: "2.0", ID: id, Result: executed}
+}
+
+func failure(id json.RawMessage, code int, message string, data interface{}) *response {
+	return &response{JSONRPC: "2.0", ID: id, Error: &responseError{Code: code, Message: message, Data: data}}
+}
+
+// encode converts a value of a script, CSVs are written as their headers and the cells of each row in header order.
+// The rows of a streamed CSV are left out, only their number is kept.
+func encode(obj object.Object, streamed bool) *Value {
+	switch obj := obj.(type) {
+	case nil:
+		return &Value{Type: string(object.NULL_OBJ)}
+	case *object.Integer:
+		return &Value{Type: string(obj.Type()), Value: obj.Value}
+	case *object.Float:
+		return &Value{Type: string(obj.Type()), Value: obj.Value}
+	case *object.String:
+		return &Value{Type: string(obj.Type()), Value: obj.Value}
+	case *object.Boolean:
+		return &Value{Type: string(obj.Type()), Value: obj.Value}
+	case *object.Null, *object.Exit:
+		return &Value{Type: string(object.NULL_OBJ)}
+	case *object.Array:
+		elements := make([]*Value, len(obj.Elements))
+		for i, element := range obj.Elements {
+			elements[i] = encode(element, false)
+		}
+		return &Value{Type: string(obj.Type()), Elements: elements}
+	case *object.CSV:
+		count := len(obj.Rows)
+		if streamed {
+			return &Value{Type: string(obj.Type()), Headers: obj.Headers, RowCount: &count}
+		}
+		return &Value{Type: string(obj.Type()), Headers: obj.Headers, Rows: obj.Records(), RowCount: &count}
+	case *object.Row:
+		values := make([]string, len(obj.Headers))
+		for i, header := range obj.Headers {
+			values[i] = obj.Fields()[header]
+		}
+		return &Value{Type: string(obj.Type()), Headers: obj.Headers, Rows: [][]string{values}}
+	default:
+		return &Value{Type: string(obj.Type()), Value: obj.Inspect()}
+	}
+}
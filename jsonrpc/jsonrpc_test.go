@@ -0,0 +1,123 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exchange sends requests, one per line, and returns the lines written back
+func exchange(t *testing.T, requests ...string) []string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := NewServer().Serve(strings.NewReader(strings.Join(requests, "\n")), &out); err != nil {
+		t.Fatalf("serve failed: %s", err)
+	}
+	return strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		request  string
+		expected []string
+	}{
+		{
+			`{"jsonrpc":"2.0","id":1,"method":"Execute","params":{"script":"read row * where age > 17","inputs":{"csv":"name,age\nAnn,30\nBo,12\n"}}}`,
+			[]string{`{"jsonrpc":"2.0","id":1,"result":{"value":{"type":"CSV","headers":["name","age"],"rows":[["Ann","30"]],"rowCount":1}}}`},
+		},
+		{
+			`{"jsonrpc":"2.0","id":"a","method":"Execute","params":{"script":"let n = count(people)\n[n, 1.5, \"x\", false, null]","inputs":{"people":"name\nAnn\n"},"outputs":["n"]}}`,
+			[]string{`{"jsonrpc":"2.0","id":"a","result":{"value":{"type":"ARRAY","elements":[{"type":"INTEGER","value":1},{"type":"FLOAT","value":1.5},{"type":"STRING","value":"x"},{"type":"BOOLEAN","value":false},{"type":"NULL"}]},"outputs":{"n":{"type":"INTEGER","value":1}}}}`},
+		},
+		{
+			`{"jsonrpc":"2.0","id":2,"method":"Execute","params":{"script":"csv","inputs":{"csv":"a,b\n1,2\n3,4\n"},"stream":true}}`,
+			[]string{
+				`{"jsonrpc":"2.0","method":"Execute.row","params":{"id":2,"row":["1","2"]}}`,
+				`{"jsonrpc":"2.0","method":"Execute.row","params":{"id":2,"row":["3","4"]}}`,
+				`{"jsonrpc":"2.0","id":2,"result":{"value":{"type":"CSV","headers":["a","b"],"rowCount":2}}}`,
+			},
+		},
+		{
+			`{"jsonrpc":"2.0","id":3,"method":"Execute","params":{"script":"let x = 1\nmissing(x)"}}`,
+			[]string{`{"jsonrpc":"2.0","id":3,"error":{"code":-32000,"message":"identifier not found: missing","data":{"message":"identifier not found: missing","line":2,"column":1}}}`},
+		},
+		{
+			`{"jsonrpc":"2.0","id":4,"method":"Execute","params":{"script":"let = 1"}}`,
+			[]string{`{"jsonrpc":"2.0","id":4,"error":{"code":-32001,"message":"the script has parser errors","data":[{"message":"expected next token to be IDENT, got = instead","line":1,"column":5},{"message":"unexpected ` + "`=`" + `","line":1,"column":5}]}}`},
+		},
+		{
+			`{"jsonrpc":"2.0","id":5,"method":"Execute","params":{"script":"1","outputs":["missing"]}}`,
+			[]string{`{"jsonrpc":"2.0","id":5,"error":{"code":-32000,"message":"output not found: missing"}}`},
+		},
+		{
+			`{"jsonrpc":"2.0","id":6,"method":"Execute","params":{}}`,
+			[]string{`{"jsonrpc":"2.0","id":6,"error":{"code":-32602,"message":"Execute needs a script"}}`},
+		},
+		{
+			`{"jsonrpc":"2.0","id":7,"method":"Run"}`,
+			[]string{`{"jsonrpc":"2.0","id":7,"error":{"code":-32601,"message":"method not supported: Run"}}`},
+		},
+		{
+			`not json`,
+			[]string{`{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request"}}`},
+		},
+	}
+	for _, tt := range tests {
+		got := exchange(t, tt.request)
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong response to %s.\nexpected=%q\ngot=%q", tt.request, tt.expected, got)
+		}
+	}
+
+	// notifications get no response
+	var out bytes.Buffer
+	NewServer().Serve(strings.NewReader(`{"jsonrpc":"2.0","method":"Execute","params":{"script":"1"}}`), &out)
+	if out.Len() != 0 {
+		t.Errorf("notifications should not be answered. got=%q", out.String())
+	}
+}
+
+func TestExecuteRunaway(t *testing.T) {
+	// a runaway recursion fails, and the next request is answered
+	got := exchange(t,
+		`{"jsonrpc":"2.0","id":1,"method":"Execute","params":{"script":"fn f(n) { f(n + 1) }\nprint(f(0))"}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"Execute","params":{"script":"1 + 1"}}`,
+	)
+	expected := []string{
+		`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"maximum call depth of 10000 exceeded","data":{"message":"maximum call depth of 10000 exceeded","line":1,"column":11}}}`,
+		`{"jsonrpc":"2.0","id":2,"result":{"value":{"type":"INTEGER","value":2}}}`,
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong responses.\nexpected=%q\ngot=%q", expected, got)
+	}
+
+	var out bytes.Buffer
+	s := &Server{Timeout: 10 * time.Millisecond}
+	s.Serve(strings.NewReader(`{"jsonrpc":"2.0","id":3,"method":"Execute","params":{"script":"for i, x in sequence(1000000000000) { let y = x * 2 }"}}`), &out)
+	if expected := `{"jsonrpc":"2.0","id":3,"error":{"code":-32000,"message":"script timed out after 10ms"}}` + "\n"; out.String() != expected {
+		t.Errorf("expected a timeout. got=%q", out.String())
+	}
+}
+
+func TestListen(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("can't listen: %s", err)
+	}
+	defer listener.Close()
+	go NewServer().Listen(listener)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"Execute","params":{"script":"1 + 2"}}` + "\n"))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != `{"jsonrpc":"2.0","id":1,"result":{"value":{"type":"INTEGER","value":3}}}`+"\n" {
+		t.Errorf("wrong response. got=%q (%v)", line, err)
+	}
+}
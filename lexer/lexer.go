@@ -20,6 +20,9 @@ type Lexer struct {
 	ch           byte   // current char under examination
 	Line         int    // current line number
 	Column       int    // current column number
+
+	line      int // line of the current char, used for token positions
+	lineStart int // position of the first char of the current line
 }
 
 // New creates a new Lexer with the given input string.
@@ -28,12 +31,17 @@ func New(input string) *Lexer {
 		input:  input,
 		Line:   1,
 		Column: 1,
+		line:   1,
 	}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.lineStart = l.readPosition
+	}
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -72,6 +80,25 @@ func (l *Lexer) readComment() token.Token {
 	return token.Token{Type: token.SINGLE_LINE_COMMENT, Literal: commentedText}
 }
 
+// skipBlockComment skips a #[ ... ]# block comment, which can span multiple lines
+// it returns false if the input ends before the comment is closed
+func (l *Lexer) skipBlockComment() bool {
+	// move past the opening #[
+	l.readChar()
+	l.readChar()
+	for {
+		if l.ch == 0 {
+			return false
+		}
+		if l.ch == ']' && l.peekChar() == '#' {
+			l.readChar()
+			l.readChar()
+			return true
+		}
+		l.readChar()
+	}
+}
+
 func (l *Lexer) peekChar() byte {
 	if l.readPosition >= len(l.input) {
 		return 0
@@ -80,32 +107,93 @@ func (l *Lexer) peekChar() byte {
 	}
 }
 
+// charAfterPeekIs checks the character following the peeked one, eg. the "*" of "==*"
+func (l *Lexer) charAfterPeekIs(ch byte) bool {
+	return l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == ch
+}
+
+// readIdentifier reads an identifier, which starts with a letter and may contain digits after it, eg. md5 or sales2024.csv
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for (isLetter(l.ch) || isDigit(l.ch)) && !l.atRangeOperator() {
 		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// atRangeOperator checks if the lexer is at a .. range operator
+// dots are valid in identifiers (eg. input.csv), so .. followed by a slash is kept as a parent directory in a path (eg. ../input.csv)
+func (l *Lexer) atRangeOperator() bool {
+	if l.ch != '.' || l.peekChar() != '.' {
+		return false
+	}
+	return l.readPosition+1 >= len(l.input) || l.input[l.readPosition+1] != '/'
+}
+
+// atMethodDot checks if a dot chains a method call onto a call or index, eg. the second dot of csv.filter(f).head(10)
+// Elsewhere a dot is part of an identifier, eg. row.age or ./data.csv
+func (l *Lexer) atMethodDot() bool {
+	if l.ch != '.' || l.position == 0 {
+		return false
+	}
+	previous, next := l.input[l.position-1], l.peekChar()
+	return (previous == ')' || previous == ']') && ('a' <= next && next <= 'z' || 'A' <= next && next <= 'Z' || next == '_')
+}
+
+// readNumber reads an integer or a float literal (eg. 42 or 9.99)
+// a dot is only treated as a decimal point when a digit follows it
+func (l *Lexer) readNumber() token.Token {
 	position := l.position
+	tokenType := token.TokenType(token.INT)
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		for isDigit(l.ch) {
+			l.readChar()
+		}
+	}
+
+	return token.Token{Type: tokenType, Literal: l.input[position:l.position]}
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
+// readString reads a double-quoted string and decodes escape sequences (\", \\, \n, \t, \r)
+// unknown escape sequences are kept as-is, so paths like "C:\data" keep their backslash
+// the second return value is false when the string is not terminated on the same line
+func (l *Lexer) readString() (string, bool) {
+	var out strings.Builder
 	for {
 		l.readChar()
 
-		if l.ch == '"' || l.ch == 0 || l.ch == '\n' {
-			break
+		switch l.ch {
+		case '"':
+			return out.String(), true
+		case 0, '\n':
+			return out.String(), false
+		case '\\':
+			switch l.peekChar() {
+			case '"':
+				out.WriteByte('"')
+			case '\\':
+				out.WriteByte('\\')
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case 'r':
+				out.WriteByte('\r')
+			default:
+				out.WriteByte(l.ch)
+				continue
+			}
+			l.readChar()
+		default:
+			out.WriteByte(l.ch)
 		}
 	}
-	return l.input[position:l.position]
 }
 
 func (l *Lexer) skipWhitespace() {
@@ -116,29 +204,79 @@ func (l *Lexer) skipWhitespace() {
 
 // NextToken reads the next token from the input string.
 func (l *Lexer) NextToken() token.Token {
+	l.skipWhitespace()
+	pos := token.Position{Line: l.line, Column: l.position - l.lineStart + 1}
+
+	tok := l.readToken()
+	// a token following a block comment already has its position
+	if tok.Pos == (token.Position{}) {
+		tok.Pos = pos
+	}
+	return tok
+}
+
+// SourceLine returns the text of a line of the input, lines start at 1.
+func (l *Lexer) SourceLine(line int) string {
+	lines := strings.Split(l.input, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[line-1], "\r")
+}
+
+// readToken reads the token starting at the current char.
+func (l *Lexer) readToken() token.Token {
 	var tok token.Token
 	// fmt.Println("[l.NextToken] tok: ", tok.Literal, tok.Type)
 
-	l.skipWhitespace()
-
 	switch l.ch {
 	case '#':
+		if l.peekChar() == '[' {
+			if !l.skipBlockComment() {
+				return token.Token{Type: token.ILLEGAL, Literal: "#["}
+			}
+			return l.NextToken()
+		}
 		// skip to next line
 		tok = l.readComment()
 	case '=':
-		if l.peekChar() == '=' {
+		if l.peekChar() == '=' && l.charAfterPeekIs('*') {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.EQ_FOLD, Literal: "==*"}
+		} else if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
 			tok = token.Token{Type: token.EQ, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: string(ch) + string(l.ch)}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.PLUS_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
-	case '!':
 		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.MINUS_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
+	case '!':
+		if l.peekChar() == '=' && l.charAfterPeekIs('*') {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.NOT_EQ_FOLD, Literal: "!=*"}
+		} else if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
 			tok = token.Token{Type: token.NOT_EQ, Literal: string(ch) + string(l.ch)}
@@ -146,19 +284,76 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '"':
-		tok = token.Token{Type: token.STRING, Literal: l.readString()}
+		str, terminated := l.readString()
+		if !terminated {
+			// don't consume the newline, it still terminates the statement
+			return token.Token{Type: token.ILLEGAL, Literal: "\"" + str}
+		}
+		tok = token.Token{Type: token.STRING, Literal: str}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.SLASH_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.SLASH, l.ch)
+		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.POWER, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.ASTERISK_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.ASTERISK, l.ch)
+		}
+	case '%':
+		tok = newToken(token.MODULO, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.LT_EQ, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.GT_EQ, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.LOGICAL_AND, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.LOGICAL_OR, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.PIPE, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
@@ -179,15 +374,28 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default:
+		if l.ch == '.' && l.peekChar() == '.' && l.charAfterPeekIs('.') {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+			break
+		}
+		if l.atRangeOperator() {
+			l.readChar()
+			tok = token.Token{Type: token.RANGE, Literal: ".."}
+			break
+		}
+		if l.atMethodDot() {
+			tok = newToken(token.DOT, l.ch)
+			break
+		}
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		}
 		if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
-			return tok
+			return l.readNumber()
 		}
 
 		tok = newToken(token.ILLEGAL, l.ch)
@@ -90,6 +90,9 @@ func TestNextTokenTwo(t *testing.T) {
 	10 != 9;
 	"foobar"
 	"foo bar"
+	10 % 3 ** 2.5
+	1 <= 2 >= 1
+	a && b || not c
 `
 	tests := []struct {
 		expectedType    token.TokenType
@@ -191,6 +194,25 @@ func TestNextTokenTwo(t *testing.T) {
 		{token.NEWLINE, "\n"},
 		{token.STRING, "foo bar"},
 		{token.NEWLINE, "\n"},
+		{token.INT, "10"},
+		{token.MODULO, "%"},
+		{token.INT, "3"},
+		{token.POWER, "**"},
+		{token.FLOAT, "2.5"},
+		{token.NEWLINE, "\n"},
+		{token.INT, "1"},
+		{token.LT_EQ, "<="},
+		{token.INT, "2"},
+		{token.GT_EQ, ">="},
+		{token.INT, "1"},
+		{token.NEWLINE, "\n"},
+		{token.IDENT, "a"},
+		{token.LOGICAL_AND, "&&"},
+		{token.IDENT, "b"},
+		{token.LOGICAL_OR, "||"},
+		{token.NOT, "not"},
+		{token.IDENT, "c"},
+		{token.NEWLINE, "\n"},
 		// {token.EOF, ""},
 		// {token.EOF, ""},
 		{token.EOF, ""},
@@ -208,3 +230,179 @@ func TestNextTokenTwo(t *testing.T) {
 		}
 	}
 }
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"say \"hi\""`, token.STRING, `say "hi"`},
+		{`"a\tb"`, token.STRING, "a\tb"},
+		{`"line\nbreak"`, token.STRING, "line\nbreak"},
+		{`"back\\slash"`, token.STRING, `back\slash`},
+		{`"C:\data"`, token.STRING, `C:\data`},
+		{`"unterminated`, token.ILLEGAL, `"unterminated`},
+		{"\"broken\nlet", token.ILLEGAL, `"broken`},
+	}
+
+	for i, tt := range tests {
+		tok := New(tt.input).NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	// the newline after an unterminated string still terminates the statement
+	l := New("\"broken\nlet")
+	l.NextToken()
+	if tok := l.NextToken(); tok.Type != token.NEWLINE {
+		t.Fatalf("expected NEWLINE after unterminated string, got=%q", tok.Type)
+	}
+}
+
+func TestBlockComments(t *testing.T) {
+	input := `let a = 1 #[ inline ]# + 2
+	#[
+	load input.csv
+	read row *
+	]#
+	a`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "a"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.PLUS, "+"},
+		{token.INT, "2"},
+		{token.NEWLINE, "\n"},
+		{token.NEWLINE, "\n"},
+		{token.IDENT, "a"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	if tok := New("#[ never closed").NextToken(); tok.Type != token.ILLEGAL {
+		t.Fatalf("expected ILLEGAL for unterminated block comment, got=%q", tok.Type)
+	}
+}
+
+func TestMultiCharacterOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{`name ==* "bob"`, []token.Token{{Type: token.IDENT, Literal: "name"}, {Type: token.EQ_FOLD, Literal: "==*"}, {Type: token.STRING, Literal: "bob"}}},
+		{`name !=* "bob"`, []token.Token{{Type: token.IDENT, Literal: "name"}, {Type: token.NOT_EQ_FOLD, Literal: "!=*"}, {Type: token.STRING, Literal: "bob"}}},
+		{"rows |> unique", []token.Token{{Type: token.IDENT, Literal: "rows"}, {Type: token.PIPE, Literal: "|>"}, {Type: token.IDENT, Literal: "unique"}}},
+		{"f(x).head(2)", []token.Token{{Type: token.IDENT, Literal: "f"}, {Type: token.LPAREN, Literal: "("}, {Type: token.IDENT, Literal: "x"}, {Type: token.RPAREN, Literal: ")"}, {Type: token.DOT, Literal: "."}, {Type: token.IDENT, Literal: "head"}}},
+		{"a[0].b", []token.Token{{Type: token.IDENT, Literal: "a"}, {Type: token.LBRACKET, Literal: "["}, {Type: token.INT, Literal: "0"}, {Type: token.RBRACKET, Literal: "]"}, {Type: token.DOT, Literal: "."}, {Type: token.IDENT, Literal: "b"}}},
+		{"load ./x.csv", []token.Token{{Type: token.LOAD, Literal: "load"}, {Type: token.IDENT, Literal: "./x.csv"}}},
+		{"a == *", []token.Token{{Type: token.IDENT, Literal: "a"}, {Type: token.EQ, Literal: "=="}, {Type: token.ASTERISK, Literal: "*"}}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		for j, expected := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Fatalf("tests[%d] token %d wrong. expected=%+v, got=%+v", i, j, expected, tok)
+			}
+		}
+	}
+}
+
+func TestRangeOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"0..10", []token.Token{{Type: token.INT, Literal: "0"}, {Type: token.RANGE, Literal: ".."}, {Type: token.INT, Literal: "10"}}},
+		{"start..end", []token.Token{{Type: token.IDENT, Literal: "start"}, {Type: token.RANGE, Literal: ".."}, {Type: token.IDENT, Literal: "end"}}},
+		{"load ../data.csv", []token.Token{{Type: token.LOAD, Literal: "load"}, {Type: token.IDENT, Literal: "../data.csv"}}},
+		{"load ./data.csv", []token.Token{{Type: token.LOAD, Literal: "load"}, {Type: token.IDENT, Literal: "./data.csv"}}},
+		{"(...parts)", []token.Token{{Type: token.LPAREN, Literal: "("}, {Type: token.ELLIPSIS, Literal: "..."}, {Type: token.IDENT, Literal: "parts"}, {Type: token.RPAREN, Literal: ")"}}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		for j, expected := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Fatalf("tests[%d][%d] - token wrong. expected=%+v, got=%+v", i, j, expected, tok)
+			}
+		}
+	}
+}
+
+func TestIdentifiersWithDigits(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"md5(x)", []token.Token{{Type: token.IDENT, Literal: "md5"}, {Type: token.LPAREN, Literal: "("}, {Type: token.IDENT, Literal: "x"}}},
+		{"load sales2024.csv", []token.Token{{Type: token.LOAD, Literal: "load"}, {Type: token.IDENT, Literal: "sales2024.csv"}}},
+		{"x1..x2", []token.Token{{Type: token.IDENT, Literal: "x1"}, {Type: token.RANGE, Literal: ".."}, {Type: token.IDENT, Literal: "x2"}}},
+		{"2x", []token.Token{{Type: token.INT, Literal: "2"}, {Type: token.IDENT, Literal: "x"}}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		for j, expected := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Fatalf("tests[%d][%d] - token wrong. expected=%+v, got=%+v", i, j, expected, tok)
+			}
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 1;\n\tload \"a b.csv\" #[ note ]# trim\n\nx"
+	expected := []struct {
+		literal string
+		pos     token.Position
+	}{
+		{"let", token.Position{Line: 1, Column: 1}},
+		{"x", token.Position{Line: 1, Column: 5}},
+		{"=", token.Position{Line: 1, Column: 7}},
+		{"1", token.Position{Line: 1, Column: 9}},
+		{";", token.Position{Line: 1, Column: 10}},
+		{"\n", token.Position{Line: 1, Column: 11}},
+		{"load", token.Position{Line: 2, Column: 2}},
+		{"a b.csv", token.Position{Line: 2, Column: 7}},
+		{"trim", token.Position{Line: 2, Column: 28}},
+		{"\n", token.Position{Line: 2, Column: 32}},
+		{"\n", token.Position{Line: 3, Column: 1}},
+		{"x", token.Position{Line: 4, Column: 1}},
+	}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Literal != tt.literal || tok.Pos != tt.pos {
+			t.Fatalf("token %d wrong. expected=%q at %s, got=%q at %s", i, tt.literal, tt.pos, tok.Literal, tok.Pos)
+		}
+	}
+
+	if line := l.SourceLine(2); line != "\tload \"a b.csv\" #[ note ]# trim" {
+		t.Errorf("SourceLine(2) wrong. got=%q", line)
+	}
+}
@@ -0,0 +1,216 @@
+package lsp
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/evaluator"
+	"github.com/Rishabh570/csvlang/lexer"
+	"github.com/Rishabh570/csvlang/parser"
+	"github.com/Rishabh570/csvlang/token"
+)
+
+// document is an open script, parsed again on every change
+type document struct {
+	path    string // path of the script on disk, used to find the files it loads
+	text    string
+	program *ast.Program
+	errors  []*parser.ParserError
+}
+
+// definition is a name bound by a let or fn statement
+type definition struct {
+	name string
+	pos  token.Position
+}
+
+func newDocument(path, text string) *document {
+	p := parser.New(lexer.New(text))
+	program := p.ParseProgram()
+	return &document{path: path, text: text, program: program, errors: p.Errors}
+}
+
+// wordAt returns the identifier under a position, and where it starts.
+// Dotted identifiers are split, eg. on `csv.filter` the cursor is either on csv or on filter.
+func (d *document) wordAt(pos token.Position) (string, token.Position, bool) {
+	l := lexer.New(d.text)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type != token.IDENT || tok.Pos.Line != pos.Line {
+			continue
+		}
+		if pos.Column < tok.Pos.Column || pos.Column > tok.Pos.Column+len(tok.Literal) {
+			continue
+		}
+
+		start := tok.Pos.Column
+		for _, part := range strings.Split(tok.Literal, ".") {
+			if pos.Column <= start+len(part) {
+				return part, token.Position{Line: tok.Pos.Line, Column: start}, part != ""
+			}
+			start += len(part) + 1
+		}
+	}
+	return "", token.Position{}, false
+}
+
+// definitions returns the names bound by let and fn statements anywhere in the script, in source order
+func (d *document) definitions() []definition {
+	defs := []definition{}
+	for _, stmt := range d.program.Statements {
+		collectDefinitions(stmt, &defs)
+	}
+	return defs
+}
+
+// definitionOf returns the definition of a name used at a position, the closest one before it wins
+func (d *document) definitionOf(name string, pos token.Position) (definition, bool) {
+	var found definition
+	ok := false
+	for _, def := range d.definitions() {
+		if def.name != name {
+			continue
+		}
+		if !ok || before(def.pos, pos) {
+			found, ok = def, true
+		}
+	}
+	return found, ok
+}
+
+func before(a, b token.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column <= b.Column
+}
+
+func collectDefinitions(node ast.Node, defs *[]definition) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		if node == nil || node.Name == nil {
+			return
+		}
+		*defs = append(*defs, definition{name: node.Name.Value, pos: node.Name.Pos()})
+		collectDefinitions(node.Value, defs)
+	case *ast.FunctionStatement:
+		if node == nil || node.Name == nil {
+			return
+		}
+		*defs = append(*defs, definition{name: node.Name.Value, pos: node.Name.Pos()})
+		collectDefinitions(node.Function, defs)
+	case *ast.ExpressionStatement:
+		if node != nil {
+			collectDefinitions(node.Expression, defs)
+		}
+	case *ast.ForLoopStatement:
+		if node != nil && node.ForLoopExpression != nil {
+			collectDefinitions(node.Body, defs)
+		}
+	case *ast.ForLoopExpression:
+		if node != nil {
+			collectDefinitions(node.Body, defs)
+		}
+	case *ast.FunctionLiteral:
+		if node != nil {
+			collectDefinitions(node.Body, defs)
+		}
+	case *ast.IfExpression:
+		if node != nil {
+			collectDefinitions(node.Consequence, defs)
+			collectDefinitions(node.Alternative, defs)
+		}
+	case *ast.TryExpression:
+		if node != nil {
+			collectDefinitions(node.Body, defs)
+			collectDefinitions(node.Handler, defs)
+		}
+	case *ast.BlockStatement:
+		if node != nil {
+			for _, stmt := range node.Statements {
+				collectDefinitions(stmt, defs)
+			}
+		}
+	}
+}
+
+// columns returns the header of every CSV file loaded by the script, files that can't be read are skipped
+func (d *document) columns() []string {
+	seen := map[string]bool{}
+	columns := []string{}
+	for _, stmt := range d.program.Statements {
+		load := loadStatementOf(stmt)
+		if load == nil {
+			continue
+		}
+		for _, column := range d.readHeader(load.Filename) {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	return columns
+}
+
+// loadStatementOf returns the load statement of a statement, including the load starting a pipeline
+func loadStatementOf(stmt ast.Statement) *ast.LoadStatement {
+	switch stmt := stmt.(type) {
+	case *ast.LoadStatement:
+		return stmt
+	case *ast.ExpressionStatement:
+		expr := stmt.Expression
+		for {
+			pipe, ok := expr.(*ast.PipeExpression)
+			if !ok {
+				break
+			}
+			expr = pipe.Left
+		}
+		load, _ := expr.(*ast.LoadStatement)
+		return load
+	}
+	return nil
+}
+
+// readHeader reads the header line of a loaded file, relative paths are tried next to the script first
+func (d *document) readHeader(filename ast.Expression) []string {
+	var name string
+	switch filename := filename.(type) {
+	case *ast.StringLiteral:
+		name = filename.Value
+	case *ast.Identifier:
+		name = filename.Value
+	default:
+		return nil
+	}
+	if !strings.HasSuffix(name, ".csv") {
+		return nil
+	}
+
+	candidates := []string{name}
+	if !filepath.IsAbs(name) && d.path != "" {
+		candidates = append([]string{filepath.Join(filepath.Dir(d.path), name)}, candidates...)
+	}
+	for _, candidate := range candidates {
+		file, err := os.Open(candidate)
+		if err != nil {
+			continue
+		}
+		header, err := csv.NewReader(file).Read()
+		file.Close()
+		if err == nil {
+			return header
+		}
+	}
+	return nil
+}
+
+// isBuiltin reports whether a name is a builtin function
+func isBuiltin(name string) bool {
+	for _, builtin := range evaluator.BuiltinNames() {
+		if builtin == name {
+			return true
+		}
+	}
+	return false
+}
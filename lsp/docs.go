@@ -0,0 +1,99 @@
+package lsp
+
+// builtinDocs holds the signature and a short description of the builtins, shown on hover and in completions
+var builtinDocs = map[string]string{
+	"abs":             "abs(number)\n\nReturns the absolute value of an INTEGER or FLOAT.",
+	"assert":          "assert(condition[, message])\n\nFails the script with the message if the condition is falsy.",
+	"assert_rows":     "assert_rows(csv, n[, message])\n\nFails the test unless the CSV has n rows.",
+	"assert_schema":   "assert_schema(csv, columns[, types])\n\nFails the test unless the CSV has these columns in this order, and optionally these types, eg. [\"STRING\", \"INTEGER\"].",
+	"avg":             "avg(csv[, column])\n\nReturns the average of a numeric column, empty cells are skipped.",
+	"begin":           "begin(csv)\n\nStarts a transaction, the changes made to the CSV until commit(csv) can be undone with rollback(csv).",
+	"ceil":            "ceil(number)\n\nRounds a number up to the nearest integer.",
+	"clean_numeric":   "clean_numeric(csv, column)\n\nStrips currency symbols and thousands separators from every cell of a column.",
+	"collect":         "collect(iterator)\n\nReads the rest of an iterator, returns its rows as a CSV or its other values as an array.",
+	"commit":          "commit(csv)\n\nKeeps the changes made to the CSV since begin(csv).",
+	"contains":        "contains(array|string, value)\n\nReports whether an array has an element or a string has a substring.",
+	"count":           "count(array|csv)\n\nReturns the number of elements of an array or rows of a CSV.",
+	"describe":        "describe(csv)\n\nReturns a row per column with its type, number of values, empty cells and distinct values, and its smallest and largest values.",
+	"describe_column": "describe_column(csv, column, description)\n\nDocuments a column, the description is kept by transforms and written in the schema of JSON files.",
+	"diff":            "diff(a, b, key[, columns])\n\nCompares two CSVs by a key column, returns a row per added or removed key and per changed cell.",
+	"drop_empty":      "drop_empty(csv[, column])\n\nRemoves the rows with an empty cell, or with an empty cell in the column.",
+	"except":          "except(a, b, key)\n\nKeeps the rows of a whose key isn't in b.",
+	"exit":            "exit([code])\n\nStops the script with an exit code between 0 and 255.",
+	"expect":          "expect(actual, expected[, message])\n\nFails the test with the first difference unless the values are equal, arrays and CSVs are compared cell by cell.",
+	"expect_golden":   "expect_golden(csv, path)\n\nFails the test with a row-level diff unless the CSV matches the golden file, `csvlang test --update-golden` writes it instead.",
+	"fill_empty":      "fill_empty(csv, column, value)\n\nReplaces the empty cells of a column with a fallback value.",
+	"fake":            "fake(kind)\n\nReturns a made up value of a kind: \"name\", \"first_name\", \"last_name\", \"email\", \"phone\" or \"city\".",
+	"filter":          "filter(array|csv, fn)\n\nKeeps the elements or rows for which the function returns a truthy value.",
+	"filter_rows":     "filter_rows(csv, fn(row))\n\nKeeps the rows for which the function returns a truthy value.",
+	"first":           "first(array)\n\nReturns the first element of an array.",
+	"floor":           "floor(number)\n\nRounds a number down to the nearest integer.",
+	"format":          "format(template, values...)\n\nFormats values into a template string.",
+	"free":            "free(values...)\n\nDrops every variable holding the values, so large CSVs can be freed once they are no longer needed.",
+	"fuzzy_match":     "fuzzy_match(a, b, column[, threshold[, method]])\n\nAdds to every row of a the columns of the most similar row of b and a match_score column, threshold is 0.85 and method \"jaro_winkler\" or \"levenshtein\".",
+	"head":            "head(array|csv|iterator[, n])\n\nReturns the first n elements or rows, 10 by default. The head of an iterator stops after its first n values.",
+	"index_of":        "index_of(array|string, value)\n\nReturns the index of a value, or -1 if it is missing.",
+	"intersect":       "intersect(a, b, key)\n\nKeeps the rows of a whose key is also in b.",
+	"is_null":         "is_null(value)\n\nReports whether a value is null.",
+	"lag":             "lag(csv, column, n)\n\nAdds a column holding the value of the row n rows before, eg. price_lag_1.",
+	"last":            "last(array)\n\nReturns the last element of an array.",
+	"lead":            "lead(csv, column, n)\n\nAdds a column holding the value of the row n rows after, eg. price_lead_1.",
+	"len":             "len(value)\n\nReturns the length of a string, in characters, array or CSV.",
+	"lines":           "lines(path)\n\nReturns an iterator over the lines of a file, read as a for loop goes through them.",
+	"mask":            "mask(csv, column, method[, secret])\n\nAnonymizes a column: \"hash\" replaces cells with their SHA-256 (an HMAC with a secret), \"redact\" with * and \"fake\" with made up values of the same kind, keyed by the secret. Without a secret, hashed and fake values can be found back from candidates.",
+	"max":             "max(csv[, column])\n\nReturns the largest value of a column, empty cells are skipped.",
+	"md5":             "md5(value)\n\nReturns the hex MD5 digest of a value.",
+	"merge_columns":   "merge_columns(csv, columns, separator, target)\n\nJoins several columns into a new column.",
+	"min":             "min(csv[, column])\n\nReturns the smallest value of a column, empty cells are skipped.",
+	"normalize":       "normalize(csv, column)\n\nScales a column to [0, 1].",
+	"now":             "now()\n\nReturns the current time in RFC 3339, eg. \"2024-05-01T09:30:00Z\", fixed by csvlang -deterministic.",
+	"one_hot":         "one_hot(csv, column)\n\nAdds a 0/1 column for every distinct value of a column.",
+	"parse_number":    "parse_number(string, locale)\n\nParses a number written in a locale, eg. parse_number(\"1.234,56\", \"de\").",
+	"pop":             "pop(array)\n\nReturns the array without its last element.",
+	"print":           "print(values...)\n\nPrints values to the output.",
+	"push":            "push(array|csv, value)\n\nReturns a copy of the array or CSV with the value appended.",
+	"range":           "range([start,] end[, step])\n\nReturns an array of integers from start up to end, at most 16777216 of them, see sequence for longer ones.",
+	"rows":            "rows(path|csv)\n\nReturns an iterator over the rows of a CSV file, read as a for loop goes through them, or over the rows of a loaded or mapped CSV.",
+	"rand_choice":     "rand_choice(array)\n\nReturns a random element of an array.",
+	"rand_int":        "rand_int(min, max)\n\nReturns a random integer between min and max, both included.",
+	"rank":            "rank(csv, column[, \"asc\"|\"desc\"[, target]])\n\nAdds a rank column, ties share a rank and leave a gap.",
+	"regex_extract":   "regex_extract(string, pattern[, group])\n\nReturns the first match of a pattern, or one of its groups.",
+	"regex_match":     "regex_match(string, pattern)\n\nReports whether a string matches a pattern.",
+	"regex_replace":   "regex_replace(string, pattern, replacement)\n\nReplaces every match of a pattern.",
+	"rest":            "rest(array)\n\nReturns the array without its first element.",
+	"restore":         "restore(name)\n\nReturns a copy of the rows saved by snapshot(csv, name).",
+	"reverse":         "reverse(array|string)\n\nReturns the elements or characters in reverse order.",
+	"rolling_avg":     "rolling_avg(csv, column, window)\n\nAdds a column holding the average of the last window rows.",
+	"rollback":        "rollback(csv)\n\nUndoes the changes made to the CSV since begin(csv).",
+	"round":           "round(number[, precision])\n\nRounds a number to a number of decimals.",
+	"row_number":      "row_number(csv[, column])\n\nAdds a column numbering the rows from 1.",
+	"save":            "save(csv, filename)\n\nSaves a CSV as .csv or .json, eg. rows |> save(\"out.csv\").",
+	"query_sql":       "query_sql(query)\n\nRuns SQL with an embedded SQLite, the tables are the CSV variables the query names, eg. query_sql(\"SELECT city, count(*) FROM csv GROUP BY city\").",
+	"schema":          "schema(csv)\n\nDescribes the columns of a CSV: their type, whether they have missing cells, the format of their dates and their description.",
+	"seed":            "seed(n)\n\nSeeds the random values of rand_int, rand_choice and fake, so a run repeats them.",
+	"select":          "select(csv, columns)\n\nKeeps the given columns in the given order.",
+	"sequence":        "sequence([start,] end[, step])\n\nReturns an iterator counting like range, one integer at a time instead of building an array.",
+	"sha256":          "sha256(value)\n\nReturns the hex SHA-256 digest of a value.",
+	"slice":           "slice(array|string|csv, start[, end])\n\nReturns the elements, or characters of a string, from start up to end.",
+	"snapshot":        "snapshot(csv, name)\n\nSaves the rows of a CSV under a name for the rest of the run, see restore(name).",
+	"sort":            "sort(array|csv[, column[, \"asc\"|\"desc\"]])\n\nSorts an array, or the rows of a CSV by a column.",
+	"split_column":    "split_column(csv, column, separator, targets)\n\nSplits a column into several columns.",
+	"sum":             "sum(csv[, column])\n\nReturns the sum of a numeric column, empty cells are skipped.",
+	"tail":            "tail(array|csv[, n])\n\nReturns the last n elements or rows, 10 by default.",
+	"to_number":       "to_number(value)\n\nConverts a value to a number, eg. to_number(\"$1,234.50\") returns 1234.5.",
+	"today":           "today()\n\nReturns the current date, eg. \"2024-05-01\", fixed by csvlang -deterministic.",
+	"type":            "type(value)\n\nReturns the type of a value, eg. \"INTEGER\".",
+	"unique":          "unique(array|csv)\n\nRemoves duplicate rows.",
+	"upsert":          "upsert(target, updates, key)\n\nReplaces the cells of the rows of target whose key is in updates, and appends the rows with new keys.",
+	"uuid":            "uuid()\n\nReturns a random version 4 UUID.",
+	"zip":             "zip(arrays...)\n\nCombines arrays element by element.",
+	"zscore":          "zscore(csv, column)\n\nScales a column to standard scores.",
+}
+
+// builtinDoc returns the documentation of a builtin, or a short fallback for builtins without one
+func builtinDoc(name string) string {
+	if doc, ok := builtinDocs[name]; ok {
+		return doc
+	}
+	return name + "(...)\n\nBuiltin function."
+}
@@ -0,0 +1,348 @@
+// lsp package implements a Language Server Protocol server for csvlang scripts, started with `csvlang lsp`.
+//
+// It offers diagnostics for parser errors, hover documentation for builtins, go to definition for names bound by let
+// and fn statements, and completion of column names from the files loaded by the script.
+// Messages are exchanged over stdin and stdout using JSON-RPC, documents are synced in full on every change.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Rishabh570/csvlang/evaluator"
+	"github.com/Rishabh570/csvlang/token"
+)
+
+// JSON-RPC error codes
+const (
+	methodNotFound = -32601
+	invalidParams  = -32602
+)
+
+// LSP completion item kinds
+const (
+	kindFunction = 3
+	kindField    = 5
+	kindVariable = 6
+)
+
+// Server is a language server reading requests from in and writing responses to out
+type Server struct {
+	in        *bufio.Reader
+	out       io.Writer
+	documents map[string]*document // open documents by URI
+}
+
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type textRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+type location struct {
+	URI   string    `json:"uri"`
+	Range textRange `json:"range"`
+}
+
+type diagnostic struct {
+	Range    textRange `json:"range"`
+	Severity int       `json:"severity"`
+	Source   string    `json:"source"`
+	Message  string    `json:"message"`
+}
+
+type markupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type completionItem struct {
+	Label         string         `json:"label"`
+	Kind          int            `json:"kind"`
+	Detail        string         `json:"detail,omitempty"`
+	Documentation *markupContent `json:"documentation,omitempty"`
+}
+
+type textDocumentPosition struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position position `json:"position"`
+}
+
+// NewServer creates a language server, nothing is read until Run is called
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{in: bufio.NewReader(in), out: out, documents: map[string]*document{}}
+}
+
+// Run serves requests until the client sends exit or closes the input
+func (s *Server) Run() error {
+	for {
+		msg, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// read reads one message, each message is preceded by a Content-Length header
+func (s *Server) read() (*message, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid Content-Length: %s", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+	msg := &message{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (s *Server) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}) error {
+	if result == nil {
+		// null results are valid replies, eg. no hover
+		result = json.RawMessage("null")
+	}
+	return s.write(&message{ID: id, Result: result})
+}
+
+func (s *Server) replyError(id *json.RawMessage, code int, text string) error {
+	return s.write(&message{ID: id, Error: &responseError{Code: code, Message: text}})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return s.write(&message{Method: method, Params: raw})
+}
+
+func (s *Server) handle(msg *message) error {
+	switch msg.Method {
+	case "initialize":
+		return s.reply(msg.ID, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1, // full document on every change
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"completionProvider": map[string]interface{}{},
+			},
+			"serverInfo": map[string]string{"name": "csvlang"},
+		})
+	case "shutdown":
+		return s.reply(msg.ID, nil)
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil
+		}
+		return s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil || len(params.ContentChanges) == 0 {
+			return nil
+		}
+		return s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var params textDocumentPosition
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": params.TextDocument.URI, "diagnostics": []diagnostic{}})
+	case "textDocument/hover", "textDocument/definition", "textDocument/completion":
+		var params textDocumentPosition
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.replyError(msg.ID, invalidParams, err.Error())
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return s.reply(msg.ID, nil)
+		}
+		pos := token.Position{Line: params.Position.Line + 1, Column: params.Position.Character + 1}
+		switch msg.Method {
+		case "textDocument/hover":
+			return s.reply(msg.ID, hover(doc, pos))
+		case "textDocument/definition":
+			return s.reply(msg.ID, definitionLocation(doc, params.TextDocument.URI, pos))
+		default:
+			return s.reply(msg.ID, completions(doc))
+		}
+	}
+
+	// notifications without a handler are ignored, requests get an error
+	if msg.ID != nil {
+		return s.replyError(msg.ID, methodNotFound, "method not supported: "+msg.Method)
+	}
+	return nil
+}
+
+// update parses a document again and publishes its parser errors
+func (s *Server) update(uri, text string) error {
+	doc := newDocument(uriToPath(uri), text)
+	s.documents[uri] = doc
+	return s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": diagnostics(doc)})
+}
+
+func diagnostics(doc *document) []diagnostic {
+	diags := []diagnostic{}
+	for _, err := range doc.errors {
+		message := err.Message
+		if err.Hint != "" {
+			message += "\nhint: " + err.Hint
+		}
+		start := toPosition(token.Position{Line: err.Line, Column: err.Column})
+		diags = append(diags, diagnostic{
+			Range:    textRange{Start: start, End: position{Line: start.Line, Character: start.Character + 1}},
+			Severity: 1,
+			Source:   "csvlang",
+			Message:  message,
+		})
+	}
+	return diags
+}
+
+func hover(doc *document, pos token.Position) interface{} {
+	word, _, ok := doc.wordAt(pos)
+	if !ok || !isBuiltin(word) {
+		return nil
+	}
+	return map[string]interface{}{"contents": markupContent{Kind: "markdown", Value: builtinMarkdown(word)}}
+}
+
+func definitionLocation(doc *document, uri string, pos token.Position) interface{} {
+	word, _, ok := doc.wordAt(pos)
+	if !ok {
+		return nil
+	}
+	def, ok := doc.definitionOf(word, pos)
+	if !ok {
+		return nil
+	}
+	start := toPosition(def.pos)
+	end := position{Line: start.Line, Character: start.Character + len(def.name)}
+	return location{URI: uri, Range: textRange{Start: start, End: end}}
+}
+
+func completions(doc *document) []completionItem {
+	items := []completionItem{}
+	for _, column := range doc.columns() {
+		items = append(items, completionItem{Label: column, Kind: kindField, Detail: "column"})
+	}
+	seen := map[string]bool{}
+	for _, def := range doc.definitions() {
+		if !seen[def.name] {
+			seen[def.name] = true
+			items = append(items, completionItem{Label: def.name, Kind: kindVariable})
+		}
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		signature, _, _ := strings.Cut(builtinDoc(name), "\n")
+		items = append(items, completionItem{Label: name, Kind: kindFunction, Detail: signature, Documentation: &markupContent{Kind: "markdown", Value: builtinMarkdown(name)}})
+	}
+	return items
+}
+
+// builtinMarkdown renders the documentation of a builtin with its signature as code
+func builtinMarkdown(name string) string {
+	signature, description, _ := strings.Cut(builtinDoc(name), "\n\n")
+	return "```\n" + signature + "\n```\n" + description
+}
+
+// toPosition converts a position of the lexer to an LSP position, LSP lines and characters start at 0.
+// Columns count bytes, which matches the characters of the client for ASCII scripts.
+func toPosition(pos token.Position) position {
+	line, character := pos.Line-1, pos.Column-1
+	if line < 0 {
+		line = 0
+	}
+	if character < 0 {
+		character = 0
+	}
+	return position{Line: line, Character: character}
+}
+
+// uriToPath returns the path of a file:// URI, other URIs have no path
+func uriToPath(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" {
+		return ""
+	}
+	return parsed.Path
+}
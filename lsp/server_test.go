@@ -0,0 +1,160 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// session sends requests to a server and returns the messages it wrote, in order
+func session(t *testing.T, requests ...map[string]interface{}) []map[string]interface{} {
+	t.Helper()
+	var in bytes.Buffer
+	for _, request := range requests {
+		request["jsonrpc"] = "2.0"
+		body, err := json.Marshal(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	var out bytes.Buffer
+	if err := NewServer(&in, &out).Run(); err != nil {
+		t.Fatalf("server failed: %s", err)
+	}
+
+	messages := []map[string]interface{}{}
+	reader := bufio.NewReader(&out)
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		var length int
+		fmt.Sscanf(header, "Content-Length: %d", &length)
+		reader.ReadString('\n') // blank line ending the headers
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			t.Fatal(err)
+		}
+		decoded := map[string]interface{}{}
+		if err := json.Unmarshal(body, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, decoded)
+	}
+	return messages
+}
+
+func openDocument(uri, text string) map[string]interface{} {
+	return map[string]interface{}{
+		"method": "textDocument/didOpen",
+		"params": map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri, "text": text}},
+	}
+}
+
+func positionRequest(id int, method, uri string, line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"id":     id,
+		"method": method,
+		"params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri},
+			"position":     map[string]interface{}{"line": line, "character": character},
+		},
+	}
+}
+
+func TestLanguageServer(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "people.csv"), []byte("name,age,city\nAnn,30,Oslo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	uri := "file://" + filepath.Join(dir, "script.csl")
+	script := "load people.csv\nlet adults = read row * where age > 17\nlet total = sum(adults, \"age\")\nprint(adults)"
+
+	messages := session(t,
+		map[string]interface{}{"id": 1, "method": "initialize", "params": map[string]interface{}{}},
+		openDocument(uri, script),
+		positionRequest(2, "textDocument/hover", uri, 2, 13),
+		positionRequest(3, "textDocument/definition", uri, 3, 8),
+		positionRequest(4, "textDocument/completion", uri, 3, 0),
+		positionRequest(5, "textDocument/hover", uri, 3, 8),
+		map[string]interface{}{"id": 6, "method": "shutdown"},
+		map[string]interface{}{"method": "exit"},
+	)
+	if len(messages) != 7 {
+		t.Fatalf("expected 7 messages, got=%d: %v", len(messages), messages)
+	}
+
+	capabilities := messages[0]["result"].(map[string]interface{})["capabilities"].(map[string]interface{})
+	if capabilities["hoverProvider"] != true || capabilities["definitionProvider"] != true {
+		t.Errorf("wrong capabilities. got=%v", capabilities)
+	}
+
+	if messages[1]["method"] != "textDocument/publishDiagnostics" {
+		t.Fatalf("expected diagnostics after didOpen. got=%v", messages[1])
+	}
+	if diags := messages[1]["params"].(map[string]interface{})["diagnostics"].([]interface{}); len(diags) != 0 {
+		t.Errorf("expected no diagnostics. got=%v", diags)
+	}
+
+	contents := messages[2]["result"].(map[string]interface{})["contents"].(map[string]interface{})
+	if !strings.Contains(contents["value"].(string), "sum(csv[, column])") {
+		t.Errorf("hover should document sum. got=%v", contents)
+	}
+
+	start := messages[3]["result"].(map[string]interface{})["range"].(map[string]interface{})["start"].(map[string]interface{})
+	if start["line"] != 1.0 || start["character"] != 4.0 {
+		t.Errorf("definition of adults should be on the let. got=%v", start)
+	}
+
+	labels := map[string]float64{}
+	for _, item := range messages[4]["result"].([]interface{}) {
+		item := item.(map[string]interface{})
+		labels[item["label"].(string)] = item["kind"].(float64)
+	}
+	for label, kind := range map[string]float64{"name": kindField, "city": kindField, "adults": kindVariable, "total": kindVariable, "filter": kindFunction} {
+		if labels[label] != kind {
+			t.Errorf("completion %s missing or of the wrong kind. got=%v", label, labels[label])
+		}
+	}
+
+	if result, ok := messages[5]["result"]; !ok || result != nil {
+		t.Errorf("hover on a variable should be null. got=%v", messages[5])
+	}
+	if _, ok := messages[6]["result"]; !ok {
+		t.Errorf("shutdown should be answered. got=%v", messages[6])
+	}
+}
+
+func TestLanguageServerDiagnostics(t *testing.T) {
+	uri := "file:///tmp/broken.csl"
+	messages := session(t,
+		openDocument(uri, "let x = 1\nload *.csv"),
+		map[string]interface{}{"id": 1, "method": "textDocument/formatting", "params": map[string]interface{}{}},
+	)
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got=%d: %v", len(messages), messages)
+	}
+
+	diags := messages[0]["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic. got=%v", diags)
+	}
+	diag := diags[0].(map[string]interface{})
+	start := diag["range"].(map[string]interface{})["start"].(map[string]interface{})
+	if start["line"] != 1.0 || start["character"] != 5.0 || !strings.HasPrefix(diag["message"].(string), "unexpected `*`\nhint: did you mean to quote the filename?") {
+		t.Errorf("wrong diagnostic. got=%v", diag)
+	}
+
+	if messages[1]["error"].(map[string]interface{})["code"] != float64(methodNotFound) {
+		t.Errorf("unsupported requests should fail. got=%v", messages[1])
+	}
+}
@@ -6,11 +6,65 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 
+	"github.com/Rishabh570/csvlang/evaluator"
+	"github.com/Rishabh570/csvlang/jsonrpc"
+	"github.com/Rishabh570/csvlang/lsp"
+	"github.com/Rishabh570/csvlang/object"
+	"github.com/Rishabh570/csvlang/plugins"
 	"github.com/Rishabh570/csvlang/repl"
+	"github.com/Rishabh570/csvlang/server"
 )
 
 func main() {
+	// `csvlang lsp` serves editors over stdin and stdout
+	if len(os.Args) > 1 && os.Args[1] == "lsp" {
+		runLanguageServer()
+		return
+	}
+	// `csvlang explain -path job.csl` prints the plan of a script without running it
+	if len(os.Args) > 1 && os.Args[1] == "explain" {
+		runExplain(os.Args[2:])
+		return
+	}
+	// `csvlang serve --port 8080` runs posted scripts over HTTP, see the server package
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		runServer(os.Args[2:])
+		return
+	}
+	// `csvlang rpc` runs scripts for other programs over JSON-RPC, see the jsonrpc package
+	if len(os.Args) > 1 && os.Args[1] == "rpc" {
+		runRPC(os.Args[2:])
+		return
+	}
+	// `csvlang batch -path clean.csl -input-dir raw/ -output-dir clean/` runs a script per file, see repl.RunBatch
+	if len(os.Args) > 1 && os.Args[1] == "batch" {
+		runBatch(os.Args[2:])
+		return
+	}
+	// `csvlang diff -key id old.csv new.csv` prints the differences between two CSVs, see evaluator.Diff
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		runDiff(os.Args[2:])
+		return
+	}
+	// `csvlang repl` runs lines as they are typed, see repl.Start
+	if len(os.Args) > 1 && os.Args[1] == "repl" {
+		repl.Start(os.Stdin, os.Stdout)
+		return
+	}
+	// `csvlang test [paths...]` runs test scripts, see repl.RunTests
+	if len(os.Args) > 1 && os.Args[1] == "test" {
+		runTests(os.Args[2:])
+		return
+	}
+
 	// user, err := user.Current()
 	// if err != nil {
 	// 	panic(err)
@@ -21,19 +75,67 @@ func main() {
 
 	// Define a string flag called "path" with a default value of "" and a brief description.
 	filePath := flag.String("path", "", "Path to the file")
+	lenient := flag.Bool("lenient", false, "Skip cells that are not numbers in where comparisons instead of failing")
+	dryRun := flag.Bool("dry-run", false, "Run the script without writing files, report what would be saved instead")
+	trace := flag.Bool("trace", false, "Print the statements run, the files loaded and saved, and errors to stderr")
+	audit := flag.String("audit", "", "Write the files read and written, with their rows, size and checksum, to a JSON file when the script ends")
+	deterministic := flag.Bool("deterministic", false, "Seed the random builtins and fix the time of now() and today(), so reruns print and write the same bytes")
+	now := flag.String("now", deterministicNow, "The time of now() and today() with -deterministic, in RFC 3339")
+	noCache := flag.Bool("no-cache", false, "Parse the files loaded every time instead of caching them between runs")
+	emitAST := flag.Bool("emit-ast", false, "Print the AST of the script as JSON instead of running it")
+	astPath := flag.String("ast", "", "Run a program from its AST in JSON, as printed by -emit-ast")
+	query := flag.String("sql", "", "Run a SQL query, eg. -sql \"SELECT name FROM load('people.csv') WHERE age > 25\"")
+	var pluginPaths pluginList
+	flag.Var(&pluginPaths, "plugin", "Load builtins from a WebAssembly module (.wasm), a Go plugin (.so) or a plugin executable, can be repeated")
 
 	// Parse the command line flags.
 	flag.Parse()
 
+	evaluator.LenientNumbers = *lenient
+	evaluator.DryRun = *dryRun
+	setDeterministic(*deterministic, *now)
+	setCache(*noCache)
+	defer setTracers(*trace, *audit)()
+	defer evaluator.RemoveSnapshotFiles()
+	loadPlugins(pluginPaths)
+
+	if *astPath != "" {
+		repl.StartAST(*astPath)
+		return
+	}
+	if *query != "" {
+		// keep stdout for the rows, anything printed while running goes to stderr
+		out := os.Stdout
+		os.Stdout = os.Stderr
+		repl.RunSQL(*query, out)
+		return
+	}
+
 	// Use the file path after parsing. If it's empty, it means the flag was not provided.
 	if *filePath == "" {
 		fmt.Println("Please provide a file path using the -path flag.")
 		return
 	}
 
-	// file path must have .csl extension
-	if len(*filePath) < 4 || (*filePath)[len(*filePath)-4:] != ".csl" {
-		fmt.Println("File path must have .csl extension")
+	// file path must have .csl extension, or .sql for queries
+	isSQL := strings.HasSuffix(*filePath, ".sql")
+	if !isSQL && !strings.HasSuffix(*filePath, ".csl") {
+		fmt.Println("File path must have .csl or .sql extension")
+		return
+	}
+
+	if *emitAST {
+		// keep stdout for the JSON, anything printed while parsing goes to stderr
+		out := os.Stdout
+		os.Stdout = os.Stderr
+		repl.EmitAST(*filePath, out)
+		return
+	}
+
+	if isSQL {
+		out := os.Stdout
+		os.Stdout = os.Stderr
+		repl.RunSQLFile(*filePath, out)
 		return
 	}
 
@@ -44,3 +146,271 @@ func main() {
 	repl.StartFileAllAtOnce(*filePath)
 	// repl.StartLexer(*filePath)
 }
+
+// runLanguageServer starts the language server, stdout is reserved for protocol messages
+// so anything else printed while parsing goes to stderr.
+func runLanguageServer() {
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	if err := lsp.NewServer(os.Stdin, out).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "lsp: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func runTests(args []string) {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	updateGolden := flags.Bool("update-golden", false, "Write the output of expect_golden to the golden files instead of comparing them")
+	var pluginPaths pluginList
+	flags.Var(&pluginPaths, "plugin", "Load builtins from a WebAssembly module (.wasm), a Go plugin (.so) or a plugin executable, can be repeated")
+	flags.Parse(args)
+	evaluator.UpdateGolden = *updateGolden
+	loadPlugins(pluginPaths)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	// keep stdout for the results, anything the scripts print goes to stderr
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	ok := repl.RunTests(paths, out)
+	evaluator.RemoveSnapshotFiles()
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+func runBatch(args []string) {
+	flags := flag.NewFlagSet("batch", flag.ExitOnError)
+	filePath := flags.String("path", "", "Path to the script run for every file")
+	inputDir := flags.String("input-dir", "", "Directory of the CSV files to process")
+	outputDir := flags.String("output-dir", "", "Directory the script saves to, created if missing")
+	workers := flags.Int("workers", runtime.NumCPU(), "Number of files processed at the same time")
+	dryRun := flags.Bool("dry-run", false, "Run the script without writing files, report what would be saved instead")
+	trace := flags.Bool("trace", false, "Print the statements run, the files loaded and saved, and errors to stderr")
+	audit := flags.String("audit", "", "Write the files read and written, with their rows, size and checksum, to a JSON file when the files are processed")
+	deterministic := flags.Bool("deterministic", false, "Seed the random builtins, fix the time of now() and today() and process one file at a time, so reruns write the same bytes")
+	now := flags.String("now", deterministicNow, "The time of now() and today() with -deterministic, in RFC 3339")
+	noCache := flags.Bool("no-cache", false, "Parse the files loaded every time instead of caching them between runs")
+	var pluginPaths pluginList
+	flags.Var(&pluginPaths, "plugin", "Load builtins from a WebAssembly module (.wasm), a Go plugin (.so) or a plugin executable, can be repeated")
+	flags.Parse(args)
+	if *filePath == "" || *inputDir == "" || *outputDir == "" {
+		fmt.Println("Please provide a script, an input and an output directory using the -path, -input-dir and -output-dir flags.")
+		os.Exit(1)
+	}
+	evaluator.DryRun = *dryRun
+	if setDeterministic(*deterministic, *now) {
+		// the files share the random source, in parallel they would draw its values in a different order every run
+		*workers = 1
+	}
+	setCache(*noCache)
+	writeAudit := setTracers(*trace, *audit)
+	loadPlugins(pluginPaths)
+
+	// keep stdout for the results, anything the scripts print goes to stderr
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	ok := repl.RunBatch(*filePath, *inputDir, *outputDir, *workers, out)
+	writeAudit()
+	evaluator.RemoveSnapshotFiles()
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// deterministicNow is the time of now() and today() in deterministic runs without -now
+const deterministicNow = "2000-01-01T00:00:00Z"
+
+// setDeterministic makes the run deterministic as the -deterministic and -now flags ask, it reports whether it is
+func setDeterministic(deterministic bool, now string) bool {
+	if !deterministic {
+		return false
+	}
+	fixed, err := time.Parse(time.RFC3339, now)
+	if err != nil {
+		fmt.Printf("-now must be a time in RFC 3339, eg. %s, got %s\n", deterministicNow, now)
+		os.Exit(1)
+	}
+	evaluator.Deterministic(0, fixed)
+	return true
+}
+
+// setCache caches the files loaded in the user's cache directory, eg. ~/.cache/csvlang, unless -no-cache is given
+func setCache(noCache bool) {
+	if noCache {
+		return
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		evaluator.CacheDir = filepath.Join(dir, "csvlang")
+	}
+}
+
+// setTracers traces the scripts run as the -trace and -audit flags ask. The returned function writes the audit log,
+// it is also called if the run exits the process, eg. on an error.
+func setTracers(trace bool, auditPath string) (writeAudit func()) {
+	tracers := []evaluator.Tracer{}
+	if trace {
+		tracers = append(tracers, evaluator.NewTraceWriter(os.Stderr))
+	}
+	writeAudit = func() {}
+	if auditPath != "" {
+		audit := evaluator.NewAuditLog()
+		tracers = append(tracers, audit)
+		writeAudit = func() {
+			file, err := os.Create(auditPath)
+			if err == nil {
+				err = audit.WriteJSON(file)
+				if closeErr := file.Close(); err == nil {
+					err = closeErr
+				}
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "could not write audit log: %s\n", err)
+			}
+		}
+	}
+
+	switch len(tracers) {
+	case 0:
+	case 1:
+		evaluator.Trace = tracers[0]
+	default:
+		evaluator.Trace = evaluator.MultiTracer(tracers...)
+	}
+	repl.AtExit = writeAudit
+	return writeAudit
+}
+
+func runDiff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	key := flags.String("key", "", "Column identifying the rows, eg. id")
+	columns := flags.String("columns", "", "Comma separated columns to compare, every column by default")
+	flags.Parse(args)
+	if *key == "" || flags.NArg() != 2 {
+		fmt.Println("Usage: csvlang diff -key id [-columns price,qty] old.csv new.csv")
+		os.Exit(2)
+	}
+
+	csvs := make([]*object.CSV, 2)
+	for i, path := range flags.Args() {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "diff: %s\n", err)
+			os.Exit(2)
+		}
+		csvs[i], err = evaluator.ReadCSV(file, false, "")
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "diff: %s: %s\n", path, err)
+			os.Exit(2)
+		}
+	}
+	var compared []string
+	if *columns != "" {
+		compared = strings.Split(*columns, ",")
+	}
+	result, err := evaluator.Diff(csvs[0], csvs[1], *key, compared)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diff: %s\n", err)
+		os.Exit(2)
+	}
+	evaluator.WriteCSV(os.Stdout, result)
+	// like diff(1), differences exit with 1 so exports can be checked in CI
+	if len(result.Rows) > 0 {
+		os.Exit(1)
+	}
+}
+
+func runExplain(args []string) {
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	filePath := flags.String("path", "", "Path to the file")
+	flags.Parse(args)
+	if *filePath == "" {
+		fmt.Println("Please provide a file path using the -path flag.")
+		os.Exit(1)
+	}
+
+	// keep stdout for the plan, anything printed while parsing goes to stderr
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	repl.Explain(*filePath, out)
+}
+
+func runServer(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	port := flags.Int("port", 8080, "Port to listen on")
+	scripts := flags.String("scripts", "", "Directory of the scripts that can be run by name, eg. POST /scripts/clean runs clean.csl")
+	timeout := flags.Duration("timeout", 30*time.Second, "Longest a script may run")
+	maxBytes := flags.Int64("max-body", 32<<20, "Largest request body accepted, in bytes")
+	flags.Parse(args)
+
+	// scripts only see the CSV they are sent
+	evaluator.FileAccess = false
+
+	s := &server.Server{Scripts: *scripts, Timeout: *timeout, MaxBytes: *maxBytes}
+	addr := fmt.Sprintf(":%d", *port)
+	fmt.Fprintf(os.Stderr, "csvlang serving on %s\n", addr)
+	if err := http.ListenAndServe(addr, s.Handler()); err != nil {
+		fmt.Fprintf(os.Stderr, "serve: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func runRPC(args []string) {
+	flags := flag.NewFlagSet("rpc", flag.ExitOnError)
+	listen := flags.String("listen", "", "TCP address to accept connections on, eg. localhost:7070, stdin and stdout are used when empty")
+	sandbox := flags.Bool("sandbox", false, "Don't let scripts load, import or save files, the default with -listen")
+	timeout := flags.Duration("timeout", 30*time.Second, "Longest a script may run")
+	flags.Parse(args)
+	// anyone reaching the address can run scripts, so they only get the files with an explicit -sandbox=false
+	sandboxSet := false
+	flags.Visit(func(f *flag.Flag) { sandboxSet = sandboxSet || f.Name == "sandbox" })
+	if *listen != "" && !sandboxSet {
+		*sandbox = true
+	}
+	evaluator.FileAccess = !*sandbox
+
+	s := jsonrpc.NewServer()
+	s.Timeout = *timeout
+	if *listen == "" {
+		// keep stdout for responses, anything the scripts print goes to stderr
+		out := os.Stdout
+		os.Stdout = os.Stderr
+		if err := s.Serve(os.Stdin, out); err != nil {
+			fmt.Fprintf(os.Stderr, "rpc: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rpc: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "csvlang rpc listening on %s\n", listener.Addr())
+	if err := s.Listen(listener); err != nil {
+		fmt.Fprintf(os.Stderr, "rpc: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// pluginList collects the paths of repeated -plugin flags
+type pluginList []string
+
+func (l *pluginList) String() string { return strings.Join(*l, ",") }
+func (l *pluginList) Set(path string) error {
+	*l = append(*l, path)
+	return nil
+}
+
+func loadPlugins(paths []string) {
+	for _, path := range paths {
+		if err := plugins.Load(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading plugin: %s\n", err)
+			os.Exit(1)
+		}
+	}
+}
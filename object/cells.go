@@ -0,0 +1,129 @@
+package object
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CellKind is the type of the value of a cell
+type CellKind int
+
+const (
+	NullCell    CellKind = iota // an empty cell, ie. a missing value
+	StringCell                  // a cell of a STRING column, or a cell that isn't of the type of its column
+	IntegerCell                 // a cell of an INTEGER column
+	FloatCell                   // a cell of a FLOAT column, or a number with a fraction in an INTEGER column
+	BooleanCell                 // a cell of a BOOLEAN column
+	DateCell                    // a cell of a STRING column of dates, ie. a column with a Format
+)
+
+// Cell is the value of a cell parsed according to the type of its column, see CSV.Cells.
+// Text is the cell as written in the file, rows keep it so saving a CSV writes back what was loaded.
+type Cell struct {
+	Kind  CellKind
+	Text  string
+	Int   int64
+	Float float64 // the number of an INTEGER or FLOAT cell
+	Bool  bool
+	Time  time.Time
+}
+
+// ParseCell parses the text of a cell according to the type of its column, eg. "42" in an INTEGER column is an IntegerCell.
+// A cell that can't be parsed as the type of its column is a StringCell.
+func ParseCell(text string, columnType ColumnType) Cell {
+	cell := Cell{Kind: StringCell, Text: text}
+	if text == "" {
+		cell.Kind = NullCell
+		return cell
+	}
+
+	switch columnType.DataType {
+	case INTEGER_OBJ, FLOAT_OBJ:
+		if integer, err := strconv.ParseInt(text, 10, 64); err == nil && columnType.DataType == INTEGER_OBJ {
+			cell.Kind, cell.Int, cell.Float = IntegerCell, integer, float64(integer)
+		} else if float, err := strconv.ParseFloat(text, 64); err == nil {
+			cell.Kind, cell.Float = FloatCell, float
+		}
+	case BOOLEAN_OBJ:
+		if boolean, err := strconv.ParseBool(text); err == nil {
+			cell.Kind, cell.Bool = BooleanCell, boolean
+		}
+	case STRING_OBJ:
+		if columnType.Format == "" {
+			break
+		}
+		if date, err := time.Parse(columnType.Format, text); err == nil {
+			cell.Kind, cell.Time = DateCell, date
+		}
+	}
+	return cell
+}
+
+// cellCache keeps the parsed cells of the columns of a CSV, so where clauses and aggregations don't parse them again
+type cellCache struct {
+	sync.Mutex
+	columns map[string]cachedColumn
+	stats   map[string]cachedStats
+}
+
+type cachedColumn struct {
+	columnType ColumnType
+	cells      []Cell
+	stale      []int // the rows handed out for changing since the cells were parsed, see rowChanged
+}
+
+// rowChanged marks the cells of row i as stale in every parsed column, a column is dropped once most of its rows changed
+func (cache *cellCache) rowChanged(i int) {
+	cache.Lock()
+	defer cache.Unlock()
+	for header, cached := range cache.columns {
+		if len(cached.stale) >= len(cached.cells)/2 {
+			delete(cache.columns, header)
+			continue
+		}
+		cached.stale = append(cached.stale, i)
+		cache.columns[header] = cached
+	}
+}
+
+// ParseCells parses the cells of every column, eg. when a file is loaded, so queries on the CSV don't parse them again
+func (c *CSV) ParseCells() {
+	for _, header := range c.Headers {
+		c.Cells(header)
+	}
+}
+
+// Cells returns the cells of a column parsed according to its type, one per row.
+// A column is parsed the first time it is asked for, later calls only parse the cells of the rows changed since,
+// eg. by an assignment, see WritableRow.
+func (c *CSV) Cells(header string) []Cell {
+	if c.cells == nil {
+		c.cells = &cellCache{}
+	}
+	c.cells.Lock()
+	defer c.cells.Unlock()
+	if c.cells.columns == nil {
+		c.cells.columns = map[string]cachedColumn{}
+	}
+
+	columnType := c.TypeOf(header)
+	cached, ok := c.cells.columns[header]
+	if !ok || cached.columnType != columnType || len(cached.cells) != len(c.Rows) {
+		cached = cachedColumn{columnType: columnType, cells: make([]Cell, len(c.Rows))}
+		for i, row := range c.Rows {
+			cached.cells[i] = ParseCell(row[header], columnType)
+		}
+		c.cells.columns[header] = cached
+		return cached.cells
+	}
+
+	for _, i := range cached.stale {
+		cached.cells[i] = ParseCell(c.Rows[i][header], columnType)
+	}
+	if cached.stale != nil {
+		cached.stale = nil
+		c.cells.columns[header] = cached
+	}
+	return cached.cells
+}
@@ -2,47 +2,377 @@
 // It also contains a reference to an outer environment, which is used to implement lexical scoping (eg. enables closures)
 package object
 
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
 // Environment is a map of string to Object that represents the environment in which an object is evaluated.
 // It also contains a reference to an outer environment, which is used to implement lexical scoping (eg. enables closures)
+//
+// Environments are safe for concurrent use, eg. by scripts run in parallel on a shared environment.
+// They guard the names they bind, not the objects bound to them: a CSV changed by two scripts at once still races.
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	mu        sync.RWMutex // guards store, constants, imports and snapshots
+	store     map[string]Object
+	constants map[string]bool // names declared with const in this environment
+	outer     *Environment
+	block     bool         // the environment of an if, try or for body, see NewBlockEnvironment
+	imports   *importSet   // the scripts being imported by the run, kept by the outermost environment
+	snapshots *snapshotSet // the snapshots taken by the run, kept by the outermost environment
+	run       *runState    // how the run stands, shared by its environments from creation as it is checked on every call
+}
+
+// importSet is the absolute paths of the scripts being imported, shared by the environments of a run
+type importSet struct {
+	sync.Mutex
+	paths map[string]bool
+}
+
+// snapshotSet is the snapshots taken by a run by name, eg. by snapshot(csv, "before_cleanup")
+type snapshotSet struct {
+	sync.Mutex
+	values map[string]any
+}
+
+// runState is the calls in progress in a run, the context stopping it and the work left for its end,
+// see SetContext, EnterCall, AtEnd and AtClose
+type runState struct {
+	ctx   atomic.Value // context.Context
+	calls atomic.Int64
+
+	mu    sync.Mutex
+	end   []func() error
+	close []func()
 }
 
 // NewEnclosedEnvironment creates a new environment with the given outer environment.
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
+	return &Environment{store: make(map[string]Object), outer: outer, run: outer.run}
+}
+
+// NewBlockEnvironment creates the environment of an if, try or for body. The names declared in the block stay in it,
+// while the names bound by statements, eg. csv by load, are set outside of it, see SetOutsideBlocks.
+func NewBlockEnvironment(outer *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.block = true
 	return env
 }
 
 // NewEnvironment creates a new environment without an outer environment.
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, outer: nil, run: &runState{}}
+}
+
+// NewModuleEnvironment creates the environment of a script imported with an alias. It has no outer environment,
+// but shares the scripts being imported with the importer so import cycles are detected, its snapshots and its run.
+func NewModuleEnvironment(importer *Environment) *Environment {
+	env := NewEnvironment()
+	env.imports = importer.importSet()
+	env.snapshots = importer.snapshotSet()
+	env.run = importer.run
+	return env
+}
+
+// SetContext stops the run the environment belongs to once ctx is done, eg. a script posted to a server past its timeout.
+// The evaluator checks it in loops and calls, see Err.
+func (e *Environment) SetContext(ctx context.Context) {
+	e.run.ctx.Store(ctx)
+}
+
+// Context returns the context stopping the run the environment belongs to, see SetContext,
+// a background context when it has none. A nil environment has none.
+func (e *Environment) Context() context.Context {
+	if e != nil {
+		if ctx, ok := e.run.ctx.Load().(context.Context); ok {
+			return ctx
+		}
+	}
+	return context.Background()
+}
+
+// Err returns why the run the environment belongs to was stopped, nil while it may go on, see SetContext
+func (e *Environment) Err() error {
+	if ctx, ok := e.run.ctx.Load().(context.Context); ok {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// EnterCall counts a function call of the run the environment belongs to,
+// it returns the number of calls in progress, ie. how deep calls are nested. ExitCall ends the call.
+func (e *Environment) EnterCall() int64 {
+	return e.run.calls.Add(1)
+}
+
+// ExitCall ends a call counted by EnterCall
+func (e *Environment) ExitCall() {
+	e.run.calls.Add(-1)
+}
+
+// AtEnd registers work to do once the run the environment belongs to succeeds, eg. recording how far a delta load read a file,
+// see End.
+func (e *Environment) AtEnd(work func() error) {
+	e.run.mu.Lock()
+	defer e.run.mu.Unlock()
+	e.run.end = append(e.run.end, work)
+}
+
+// End does the work registered by AtEnd in order if the run the environment belongs to succeeded, and forgets it either way.
+// It returns the first error of the work, the work after it isn't done.
+func (e *Environment) End(succeeded bool) error {
+	e.run.mu.Lock()
+	end := e.run.end
+	e.run.end = nil
+	e.run.mu.Unlock()
+	if !succeeded {
+		return nil
+	}
+	for _, work := range end {
+		if err := work(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AtClose registers work to do once the run the environment belongs to is over, succeeded or not,
+// eg. removing the temp files of its snapshots, see Close.
+func (e *Environment) AtClose(work func()) {
+	e.run.mu.Lock()
+	defer e.run.mu.Unlock()
+	e.run.close = append(e.run.close, work)
+}
+
+// Close does the work registered by AtClose in order and forgets it, once the run is over for good,
+// eg. when a script posted to a server returns. Unlike End, a run may end several times before it is closed,
+// eg. once per line in interactive mode.
+func (e *Environment) Close() {
+	e.run.mu.Lock()
+	closing := e.run.close
+	e.run.close = nil
+	e.run.mu.Unlock()
+	for _, work := range closing {
+		work()
+	}
+}
+
+// StartImport marks the script at an absolute path as being imported by the run the environment belongs to.
+// It returns false if the script is already being imported, ie. the import is a cycle. Each run has its own imports,
+// so runs of the same script in parallel don't see each other's.
+func (e *Environment) StartImport(path string) bool {
+	imports := e.importSet()
+	imports.Lock()
+	defer imports.Unlock()
+	if imports.paths[path] {
+		return false
+	}
+	imports.paths[path] = true
+	return true
+}
+
+// EndImport marks the script at an absolute path as imported, see StartImport.
+func (e *Environment) EndImport(path string) {
+	imports := e.importSet()
+	imports.Lock()
+	defer imports.Unlock()
+	delete(imports.paths, path)
+}
+
+// importSet returns the imports of the outermost environment, created the first time they are needed
+func (e *Environment) importSet() *importSet {
+	for e.outer != nil {
+		e = e.outer
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.imports == nil {
+		e.imports = &importSet{paths: map[string]bool{}}
+	}
+	return e.imports
 }
 
-// GetStore returns the store of the environment.
-func (e *Environment) GetStore() map[string]Object {
-	return e.store
+// SetSnapshot keeps a snapshot by name for the run the environment belongs to, functions and imported scripts included.
+// It returns the snapshot the name had, nil if none.
+func (e *Environment) SetSnapshot(name string, snapshot any) any {
+	snapshots := e.snapshotSet()
+	snapshots.Lock()
+	defer snapshots.Unlock()
+	previous := snapshots.values[name]
+	snapshots.values[name] = snapshot
+	return previous
+}
+
+// Snapshot returns the snapshot kept by name, see SetSnapshot.
+func (e *Environment) Snapshot(name string) (any, bool) {
+	snapshots := e.snapshotSet()
+	snapshots.Lock()
+	defer snapshots.Unlock()
+	snapshot, ok := snapshots.values[name]
+	return snapshot, ok
+}
+
+// snapshotSet returns the snapshots of the outermost environment, created the first time they are needed
+func (e *Environment) snapshotSet() *snapshotSet {
+	for e.outer != nil {
+		e = e.outer
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.snapshots == nil {
+		e.snapshots = &snapshotSet{values: map[string]any{}}
+	}
+	return e.snapshots
 }
 
 // Get retrieves the object with the given name from the environment.
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
 	return obj, ok
 }
 
+// Bindings returns a copy of the names bound in this environment, without those of the outer environments
+func (e *Environment) Bindings() map[string]Object {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	bindings := make(map[string]Object, len(e.store))
+	for name, value := range e.store {
+		bindings[name] = value
+	}
+	return bindings
+}
+
+// LoadedCSV returns the CSV loaded last, false when none is loaded in memory, eg. when the file is mapped.
+// A nil environment has none.
+func (e *Environment) LoadedCSV() (*CSV, bool) {
+	if e == nil {
+		return nil, false
+	}
+	value, ok := e.Get("csv")
+	if !ok {
+		return nil, false
+	}
+	csv, ok := value.(*CSV)
+	return csv, ok
+}
+
 // Set sets the object with the given name in the environment.
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.store[name] = val
+	return val
+}
+
+// SetOutsideBlocks sets the object with the given name in the nearest environment that isn't a block,
+// ie. the environment of the function or script, eg. a file loaded in an if body is still loaded after it.
+func (e *Environment) SetOutsideBlocks(name string, val Object) Object {
+	for e.block && e.outer != nil {
+		e = e.outer
+	}
+	return e.Set(name, val)
+}
+
+// SetConst sets the object with the given name in the environment and marks it as a constant.
+func (e *Environment) SetConst(name string, val Object) Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.constants == nil {
+		e.constants = make(map[string]bool)
+	}
+	e.constants[name] = true
 	e.store[name] = val
 	return val
 }
 
+// IsConst checks if the nearest environment defining the given name declared it as a constant.
+func (e *Environment) IsConst(name string) bool {
+	e.mu.RLock()
+	_, ok := e.store[name]
+	constant := e.constants[name]
+	e.mu.RUnlock()
+	if ok {
+		return constant
+	}
+	if e.outer != nil {
+		return e.outer.IsConst(name)
+	}
+	return false
+}
+
+// IsLocalConst checks if the given name is declared as a constant in this environment, ignoring outer environments.
+func (e *Environment) IsLocalConst(name string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.constants[name]
+}
+
+// Assign updates the object with the given name in the nearest environment that defines it.
+// It returns false if the name is not defined in this or any outer environment.
+// Unlike Set, it lets nested scopes (eg. loop bodies) update variables declared outside of them.
+func (e *Environment) Assign(name string, val Object) bool {
+	e.mu.Lock()
+	_, ok := e.store[name]
+	if ok {
+		e.store[name] = val
+	}
+	e.mu.Unlock()
+	if ok {
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return false
+}
+
+// Delete removes the object with the given name from the nearest environment that defines it, eg. unload csv.
+// It returns false if the name is not defined in this or any outer environment.
+func (e *Environment) Delete(name string) bool {
+	e.mu.Lock()
+	_, ok := e.store[name]
+	if ok {
+		delete(e.store, name)
+		delete(e.constants, name)
+	}
+	e.mu.Unlock()
+	if ok {
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Delete(name)
+	}
+	return false
+}
+
+// Forget removes every name bound to the given object in this and the outer environments, eg. free(rows).
+// Constants are kept, it returns the number of names removed.
+func (e *Environment) Forget(obj Object) int {
+	removed := 0
+	e.mu.Lock()
+	for name, value := range e.store {
+		if value == obj && !e.constants[name] {
+			delete(e.store, name)
+			removed++
+		}
+	}
+	e.mu.Unlock()
+	if e.outer != nil {
+		removed += e.outer.Forget(obj)
+	}
+	return removed
+}
+
 // Unset removes the object with the given name from the environment.
 func (e *Environment) Unset(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	delete(e.store, name)
 }
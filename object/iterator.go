@@ -0,0 +1,56 @@
+package object
+
+import "fmt"
+
+// Iterator is a lazy sequence, its values are produced one at a time as a for loop asks for them,
+// eg. the lines of a file with lines("app.log"), so sequences larger than memory can flow through scripts.
+// An iterator is consumed by going through it, a second loop over it has nothing left to go through.
+type Iterator struct {
+	Of string // what the values are, eg. "lines of app.log"
+
+	next  func() (Object, bool, error)
+	close func()
+	done  bool
+}
+
+// NewIterator returns an iterator calling next for each value, until next returns false or an error.
+// close releases what the values are read from, eg. a file, it may be nil.
+func NewIterator(of string, next func() (Object, bool, error), close func()) *Iterator {
+	return &Iterator{Of: of, next: next, close: close}
+}
+
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *Iterator) Inspect() string {
+	if it.done {
+		return fmt.Sprintf("iterator over %s (done)", it.Of)
+	}
+	return fmt.Sprintf("iterator over %s", it.Of)
+}
+func (it *Iterator) ToCSV(env *Environment) (*CSV, error) {
+	return nil, fmt.Errorf("cannot convert an iterator over %s to CSV, collect its values first, eg. collect(it)", it.Of)
+}
+
+// Next returns the next value, false once the iterator is done.
+// The iterator is closed once it's done or fails.
+func (it *Iterator) Next() (Object, bool, error) {
+	if it.done {
+		return nil, false, nil
+	}
+	value, ok, err := it.next()
+	if !ok || err != nil {
+		it.Close()
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Close stops the iterator and releases what its values are read from, eg. when a loop breaks out early
+func (it *Iterator) Close() {
+	if it.done {
+		return
+	}
+	it.done = true
+	if it.close != nil {
+		it.close()
+	}
+}
@@ -0,0 +1,93 @@
+package object
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MappedCSV is a CSV file mapped read only in memory, eg. load big.csv readonly mmap.
+// Its rows are parsed when they are read, eg. read row 10 or read row * where status == "failed",
+// so files larger than memory can be queried: only the cells of the rows a read returns are copied.
+type MappedCSV struct {
+	Filename string
+	Headers  []string
+	Data     []byte // the rows of the file, after its header line
+
+	offsets []int // where the rows indexed so far start in Data, see Row
+	next    int   // where indexing continues in Data
+}
+
+func (m *MappedCSV) Type() ObjectType { return MAPPED_CSV_OBJ }
+func (m *MappedCSV) Inspect() string {
+	return fmt.Sprintf("%s mapped in memory (%s)", m.Filename, strings.Join(m.Headers, ", "))
+}
+func (m *MappedCSV) ToCSV(env *Environment) (*CSV, error) {
+	return nil, fmt.Errorf("cannot convert mapped file %s to CSV, read its rows instead, eg. read row * where ...", m.Filename)
+}
+
+// Row returns the cells of row i, false when the file has fewer rows.
+// The rows before it are indexed the first time, so reading a row again, or an earlier one, doesn't scan the file.
+func (m *MappedCSV) Row(i int) ([]string, bool, error) {
+	for len(m.offsets) <= i && m.next < len(m.Data) {
+		end := recordEnd(m.Data, m.next)
+		// empty lines aren't rows
+		if len(bytes.TrimRight(m.Data[m.next:end], "\r\n")) > 0 {
+			m.offsets = append(m.offsets, m.next)
+		}
+		m.next = end
+	}
+	if i < 0 || i >= len(m.offsets) {
+		return nil, false, nil
+	}
+
+	start := m.offsets[i]
+	record, err := csv.NewReader(bytes.NewReader(m.Data[start:recordEnd(m.Data, start)])).Read()
+	if err != nil {
+		return nil, false, fmt.Errorf("could not read row %d: %w", i, err)
+	}
+	if len(record) != len(m.Headers) {
+		return nil, false, fmt.Errorf("could not read row %d: %d fields, expected %d", i, len(record), len(m.Headers))
+	}
+	return record, true, nil
+}
+
+// Scan calls fn with the cells of every row in order, until fn returns an error.
+// The record is reused for the next row, fn must copy it to keep it.
+func (m *MappedCSV) Scan(fn func(i int, record []string) error) error {
+	reader := csv.NewReader(bytes.NewReader(m.Data))
+	reader.FieldsPerRecord = len(m.Headers)
+	reader.ReuseRecord = true
+	for i := 0; ; i++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not read CSV records: %w", err)
+		}
+		if err := fn(i, record); err != nil {
+			return err
+		}
+	}
+}
+
+// recordEnd returns where the record starting at start ends in data, after its line break.
+// Line breaks inside quoted cells are part of the record.
+func recordEnd(data []byte, start int) int {
+	quoted := false
+	for i := start; i < len(data); i++ {
+		switch data[i] {
+		case '"':
+			// a quote in a quoted cell is doubled, so it toggles twice
+			quoted = !quoted
+		case '\n':
+			if !quoted {
+				return i + 1
+			}
+		}
+	}
+	return len(data)
+}
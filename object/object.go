@@ -3,6 +3,10 @@
 // The object package contains the definition of the Object interface, which is implemented by all objects in our language.
 // All object types have a Type method that returns the type of the object, and an Inspect method that returns a string representation of the object.
 // The object package also contains the definition of the ObjectType type, which is a string that represents the type of an object.
+//
+// Go programs embedding csvlang build the CSVs they pass to scripts with NewCSVFromRecords (or evaluator.ReadCSV),
+// and read the CSVs scripts return with Records, Values and TypeOf, rather than through the fields of CSV,
+// eg. rows shared between CSVs are copied on write, see WritableRow. ToCSV converts any other value to a CSV.
 package object
 
 import (
@@ -10,8 +14,10 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/token"
 )
 
 type ObjectType string
@@ -20,14 +26,19 @@ const (
 	NULL_OBJ         = "NULL"
 	ERROR_OBJ        = "ERROR"
 	CSV_OBJ          = "CSV"
+	MAPPED_CSV_OBJ   = "MAPPED_CSV"
+	ITERATOR_OBJ     = "ITERATOR"
 	CSV_ROW          = "CSV_ROW"
 	CSV_VAL          = "CSV_VAL"
 	STRING_OBJ       = "STRING"
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
 	FUNCTION_OBJ     = "FUNCTION"
 	ARRAY            = "ARRAY"
+	MODULE_OBJ       = "MODULE"
+	EXIT_OBJ         = "EXIT"
 
 	BUILTIN_OBJ = "BUILTIN"
 )
@@ -42,7 +53,8 @@ const (
 type Object interface {
 	Type() ObjectType
 	Inspect() string
-	// Add method to attempt conversion to CSV
+	// ToCSV converts the value to a CSV, eg. to save it. Scalars and arrays take the headers of the CSV loaded in env
+	// when there is one, env may be nil.
 	ToCSV(env *Environment) (*CSV, error)
 }
 
@@ -59,8 +71,7 @@ func (i *Integer) ToCSV(env *Environment) (*CSV, error) {
 	// Get headers from environment if present
 	var header string
 	var columnType ColumnType
-	if csvObj, ok := env.Get("csv"); ok {
-		currentCSV := csvObj.(*CSV)
+	if currentCSV, ok := env.LoadedCSV(); ok {
 		if len(currentCSV.Headers) > 0 {
 			header = currentCSV.Headers[0]
 			columnType = currentCSV.ColumnTypes[0]
@@ -83,6 +94,39 @@ func (i *Integer) ToCSV(env *Environment) (*CSV, error) {
 	}, nil
 }
 
+// Float struct represents a floating point number object in our language.
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Inspect() string  { return strconv.FormatFloat(f.Value, 'f', -1, 64) }
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) ToCSV(env *Environment) (*CSV, error) {
+	var header string
+	var columnType ColumnType
+	if currentCSV, ok := env.LoadedCSV(); ok {
+		if len(currentCSV.Headers) > 0 {
+			header = currentCSV.Headers[0]
+			columnType = currentCSV.ColumnTypes[0]
+			// Validate type compatibility
+			if columnType.DataType != FLOAT_OBJ && columnType.DataType != STRING_OBJ {
+				return nil, fmt.Errorf("type mismatch: cannot convert FLOAT to %s", columnType.DataType)
+			}
+		}
+	}
+
+	if header == "" {
+		header = "col1"
+		columnType = ColumnType{DataType: FLOAT_OBJ}
+	}
+
+	return &CSV{
+		Headers:     []string{header},
+		ColumnTypes: []ColumnType{columnType},
+		Rows:        []map[string]string{{header: f.Inspect()}},
+	}, nil
+}
+
 // String struct represents a string object in our language.
 type String struct {
 	Value string
@@ -93,8 +137,7 @@ func (s *String) Inspect() string  { return s.Value }
 func (s *String) ToCSV(env *Environment) (*CSV, error) {
 	var header string
 	var columnType ColumnType
-	if csvObj, ok := env.Get("csv"); ok {
-		currentCSV := csvObj.(*CSV)
+	if currentCSV, ok := env.LoadedCSV(); ok {
 		if len(currentCSV.Headers) > 0 {
 			header = currentCSV.Headers[0]
 			columnType = currentCSV.ColumnTypes[0]
@@ -123,8 +166,7 @@ func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
 func (b *Boolean) ToCSV(env *Environment) (*CSV, error) {
 	var header string
 	var columnType ColumnType
-	if csvObj, ok := env.Get("csv"); ok {
-		currentCSV := csvObj.(*CSV)
+	if currentCSV, ok := env.LoadedCSV(); ok {
 		if len(currentCSV.Headers) > 0 {
 			header = currentCSV.Headers[0]
 			columnType = currentCSV.ColumnTypes[0]
@@ -169,6 +211,20 @@ func (rv *ReturnValue) ToCSV(env *Environment) (*CSV, error) {
 // Error struct represents an error object in our language.
 type Error struct {
 	Message string
+	Pos     token.Position // where in the script the error happened, set by the evaluator
+}
+
+// Location returns the message with the line and column of the error, if known.
+// They follow the first line of the message, so details on the next lines (eg. a diff) stay readable.
+func (e *Error) Location() string {
+	if e.Pos.Line == 0 {
+		return e.Message
+	}
+	first, details, multiline := strings.Cut(e.Message, "\n")
+	if multiline {
+		return fmt.Sprintf("%s at %s\n%s", first, e.Pos, details)
+	}
+	return fmt.Sprintf("%s at %s", e.Message, e.Pos)
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
@@ -177,6 +233,18 @@ func (e *Error) ToCSV(env *Environment) (*CSV, error) {
 	return nil, fmt.Errorf("cannot convert error to CSV: %s", e.Message)
 }
 
+// Exit struct represents a request to stop the program with a status code, eg. exit(1)
+// It unwinds evaluation like an error and the CLI terminates with Code.
+type Exit struct {
+	Code int
+}
+
+func (e *Exit) Type() ObjectType { return EXIT_OBJ }
+func (e *Exit) Inspect() string  { return fmt.Sprintf("exit %d", e.Code) }
+func (e *Exit) ToCSV(env *Environment) (*CSV, error) {
+	return nil, fmt.Errorf("cannot convert exit to CSV")
+}
+
 // Built-in functionality to our lang which the host lang (Go) doesn't provide
 // This allows us to add new functions to our language without modifying the host language (eg. fill_empty())
 type Builtin struct {
@@ -189,9 +257,24 @@ func (b *Builtin) ToCSV(env *Environment) (*CSV, error) {
 	return nil, fmt.Errorf("cannot convert builtin function to CSV")
 }
 
+// Module struct represents a script imported under an alias, eg. import "lib/cleaning.cl" as cleaning
+// Its definitions are accessed with dot syntax, eg. cleaning.trim_all(rows)
+type Module struct {
+	Name string
+	Env  *Environment
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return "module " + m.Name }
+func (m *Module) ToCSV(env *Environment) (*CSV, error) {
+	return nil, fmt.Errorf("cannot convert module to CSV")
+}
+
 // Function struct represents a function object in our language.
 type Function struct {
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // default value of each parameter, nil when the parameter has none
+	Rest       *ast.Identifier  // variadic parameter, nil when the function is not variadic
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -199,13 +282,9 @@ type Function struct {
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
 	out.WriteString("fn")
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ast.FormatParameters(f.Parameters, f.Defaults, f.Rest))
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
@@ -218,14 +297,63 @@ func (f *Function) ToCSV(env *Environment) (*CSV, error) {
 // ColumnType struct stores data type info about columns in a CSV object
 type ColumnType struct {
 	Name     string
-	DataType ObjectType // STRING_OBJ or INTEGER_OBJ
+	DataType ObjectType // STRING_OBJ, INTEGER_OBJ, FLOAT_OBJ or BOOLEAN_OBJ
+
+	Nullable    bool   // some cells are empty, ie. missing
+	Format      string // the layout of the dates of a STRING column, eg. "2006-01-02", see DateLayouts
+	Description string // what the column holds, eg. describe_column(orders, "amount", "total in EUR")
+}
+
+// DateLayouts are the layouts a column of dates can be written in, the Format of the column is the first its cells all follow
+var DateLayouts = []string{time.DateOnly, time.DateTime, time.RFC3339}
+
+// InferColumnMetadata returns whether a column has missing cells, and the layout of its dates if it's a STRING column of dates
+func InferColumnMetadata(rows []map[string]string, header string, dataType ObjectType) (nullable bool, format string) {
+	values := []string{}
+	for _, row := range rows {
+		if row[header] == "" {
+			nullable = true
+			continue
+		}
+		values = append(values, row[header])
+	}
+	if dataType != STRING_OBJ || len(values) == 0 {
+		return nullable, ""
+	}
+layouts:
+	for _, layout := range DateLayouts {
+		for _, value := range values {
+			if _, err := time.Parse(layout, value); err != nil {
+				continue layouts
+			}
+		}
+		return nullable, layout
+	}
+	return nullable, ""
+}
+
+// TypeOf returns the type of a column, a STRING column when its type isn't known, eg. before rows are loaded
+func (c *CSV) TypeOf(header string) ColumnType {
+	for _, columnType := range c.ColumnTypes {
+		if columnType.Name == header {
+			return columnType
+		}
+	}
+	return ColumnType{Name: header, DataType: STRING_OBJ}
 }
 
 // CSV struct represents a CSV object in our language.
 type CSV struct {
 	Headers     []string
 	ColumnTypes []ColumnType
-	Rows        []map[string]string
+	Rows        []map[string]string // change a row with WritableRow, the cached cells of rows changed otherwise aren't parsed again
+	ReadOnly    bool                // the rows can't be changed, eg. load data.csv readonly
+
+	cells   *cellCache // the parsed cells of the columns, see Cells
+	owned   []bool     // the rows this CSV copied and can change in place, nil until a row is changed, see WritableRow
+	changes uint64     // counts the rows handed out for changing them and the restores, so cached stats know they are stale, see Stats
+
+	savepoint *CSVState // the state Rollback returns to, nil outside of a transaction, see Begin
 }
 
 func (c *CSV) Type() ObjectType { return CSV_OBJ }
@@ -271,27 +399,167 @@ func (c *CSV) Inspect() string {
 }
 
 // InferColumnTypes infers the data types of the columns in the CSV object.
+// A column takes the type of its first non-empty cell, as empty cells are missing values.
 func (c *CSV) InferColumnTypes() {
 	if len(c.Rows) == 0 {
 		return
 	}
 
-	firstRow := c.Rows[0]
+	previous := c.ColumnTypes
 	c.ColumnTypes = make([]ColumnType, len(c.Headers))
 
 	for i, header := range c.Headers {
-		value := firstRow[header]
+		value := ""
+		for _, row := range c.Rows {
+			if value = row[header]; value != "" {
+				break
+			}
+		}
 		if _, err := strconv.Atoi(value); err == nil {
 			c.ColumnTypes[i] = ColumnType{Name: header, DataType: INTEGER_OBJ}
+		} else if _, err := strconv.ParseFloat(value, 64); err == nil {
+			c.ColumnTypes[i] = ColumnType{Name: header, DataType: FLOAT_OBJ}
 		} else {
 			c.ColumnTypes[i] = ColumnType{Name: header, DataType: STRING_OBJ}
 		}
+		c.ColumnTypes[i].Nullable, c.ColumnTypes[i].Format = InferColumnMetadata(c.Rows, header, c.ColumnTypes[i].DataType)
+		// descriptions are written by the user, they are kept
+		for _, columnType := range previous {
+			if columnType.Name == header {
+				c.ColumnTypes[i].Description = columnType.Description
+			}
+		}
 	}
 }
 func (csv *CSV) ToCSV(env *Environment) (*CSV, error) {
 	return csv, nil // Already a CSV
 }
 
+// NewCSVFromRecords builds a CSV from its headers and the cells of its rows in header order, eg. records read with encoding/csv.
+// The types of the columns are inferred and the cells parsed, as for a loaded file.
+func NewCSVFromRecords(headers []string, records [][]string) (*CSV, error) {
+	headers = append([]string{}, headers...)
+	rows := make([]map[string]string, len(records))
+	for i, record := range records {
+		if len(record) != len(headers) {
+			return nil, fmt.Errorf("row %d has %d cells for %d headers", i, len(record), len(headers))
+		}
+		row := make(map[string]string, len(headers))
+		for j, header := range headers {
+			row[header] = record[j]
+		}
+		rows[i] = row
+	}
+
+	csv := &CSV{Headers: headers, Rows: rows}
+	csv.InferColumnTypes()
+	csv.ParseCells()
+	return csv, nil
+}
+
+// Records returns the cells of the rows in header order, eg. for writing them with encoding/csv
+func (c *CSV) Records() [][]string {
+	records := make([][]string, len(c.Rows))
+	for i, row := range c.Rows {
+		records[i] = c.Values(row)
+	}
+	return records
+}
+
+// Values returns the cells of a row in header order.
+// Rows are maps, anything built from the cells of a row should use it so the result doesn't depend on Go's random map order.
+func (c *CSV) Values(row map[string]string) []string {
+	values := make([]string, len(c.Headers))
+	for i, header := range c.Headers {
+		values[i] = row[header]
+	}
+	return values
+}
+
+// WritableRow returns the row at index i for changing it in place, the row must be changed right away as it counts as changed.
+// Rows are shared between a CSV and the CSVs derived from it, eg. unique(rows) keeps the rows of rows that aren't duplicates,
+// so a CSV copies its list of rows and a row the first time the row is changed since they were shared, see ShareRows,
+// and the other CSVs keep their values.
+func (c *CSV) WritableRow(i int) map[string]string {
+	c.changes++
+	if c.cells != nil {
+		c.cells.rowChanged(i)
+	}
+	if len(c.owned) != len(c.Rows) {
+		c.Rows = append([]map[string]string(nil), c.Rows...)
+		c.owned = make([]bool, len(c.Rows))
+	}
+	if !c.owned[i] {
+		row := make(map[string]string, len(c.Rows[i]))
+		for header, value := range c.Rows[i] {
+			row[header] = value
+		}
+		c.Rows[i], c.owned[i] = row, true
+	}
+	return c.Rows[i]
+}
+
+// ShareRows returns the rows for a CSV derived from this one, eg. by head or a where clause.
+// The rows are then shared, so this CSV copies a row before changing it again, see WritableRow.
+func (c *CSV) ShareRows() []map[string]string {
+	c.owned = nil
+	return c.Rows
+}
+
+// Row struct represents a single row of a CSV object in our language.
+// A row read from a CSV, eg. csv[0] or the rows of a for loop, belongs to it: changes to the row are reflected in the CSV.
+type Row struct {
+	Headers []string
+	Values  map[string]string // the cells of a row that doesn't belong to a CSV, use Fields to read the cells
+
+	CSV   *CSV // the CSV the row belongs to, nil for a row built on its own
+	Index int  // the position of the row in CSV
+}
+
+// Fields returns the cells of the row, the current ones of the CSV it belongs to
+func (r *Row) Fields() map[string]string {
+	if r.CSV != nil && r.Index < len(r.CSV.Rows) {
+		return r.CSV.Rows[r.Index]
+	}
+	return r.Values
+}
+
+// Writable returns the cells of the row for changing them in place, see CSV.WritableRow
+func (r *Row) Writable() map[string]string {
+	if r.CSV != nil && r.Index < len(r.CSV.Rows) {
+		r.Values = r.CSV.WritableRow(r.Index)
+	}
+	return r.Values
+}
+
+func (r *Row) Type() ObjectType { return CSV_ROW }
+func (r *Row) Inspect() string {
+	var out bytes.Buffer
+	fields := []string{}
+	for _, header := range r.Headers {
+		if value, ok := r.Fields()[header]; ok {
+			fields = append(fields, header+": "+value)
+		}
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+func (r *Row) ToCSV(env *Environment) (*CSV, error) {
+	fields := r.Fields()
+	row := make(map[string]string, len(fields))
+	for header, value := range fields {
+		row[header] = value
+	}
+	csv := &CSV{
+		Headers: r.Headers,
+		Rows:    []map[string]string{row},
+	}
+	csv.InferColumnTypes()
+	return csv, nil
+}
+
 // Array struct represents an array object in our language.
 type Array struct {
 	Elements []Object
@@ -313,14 +581,15 @@ func (arr *Array) ToCSV(env *Environment) (*CSV, error) {
 	return ArrayToCSV(arr, env)
 }
 
-// Array to CSV utils
+// ArrayToCSV converts an array to a CSV: an array of values is a single row, an array of arrays of the same length a row per array.
+// The columns are those of the CSV loaded in env, when there is one, and the values must be of their types.
+// Otherwise the columns are named col1, col2, ... and typed after the values of the first row. env may be nil.
 func ArrayToCSV(arr *Array, env *Environment) (*CSV, error) {
 	// Get current CSV headers if present in environment
 	var headers []string
 	var columnTypes []ColumnType
 
-	if csvObj, ok := env.Get("csv"); ok {
-		currentCSV := csvObj.(*CSV)
+	if currentCSV, ok := env.LoadedCSV(); ok {
 		headers = currentCSV.Headers
 		columnTypes = currentCSV.ColumnTypes
 	}
@@ -468,6 +737,8 @@ func InferType(obj Object) ColumnType {
 	switch obj.(type) {
 	case *Integer:
 		return ColumnType{DataType: INTEGER_OBJ}
+	case *Float:
+		return ColumnType{DataType: FLOAT_OBJ}
 	case *String:
 		return ColumnType{DataType: STRING_OBJ}
 	case *Boolean:
@@ -482,6 +753,12 @@ func isCompatibleType(value Object, colType ColumnType) bool {
 	case INTEGER_OBJ:
 		_, ok := value.(*Integer)
 		return ok
+	case FLOAT_OBJ:
+		switch value.(type) {
+		case *Float, *Integer:
+			return true
+		}
+		return false
 	case STRING_OBJ:
 		_, ok := value.(*String)
 		return ok
@@ -0,0 +1,70 @@
+package object
+
+// ColumnStats summarizes the cells of a column, see CSV.Stats
+type ColumnStats struct {
+	Count    int  // the cells that aren't empty
+	Nulls    int  // the empty cells
+	Distinct int  // the different values of the cells that aren't empty
+	Min      Cell // the smallest cell that isn't empty, a NullCell when every cell is empty
+	Max      Cell // the largest cell that isn't empty, a NullCell when every cell is empty
+}
+
+// cachedStats is the stats of a column as of the changes of its CSV, see CSV.changes
+type cachedStats struct {
+	columnType ColumnType
+	changes    uint64
+	rows       int
+	stats      ColumnStats
+}
+
+// Stats returns the stats of a column. They are computed the first time they are asked for and kept until the CSV changes,
+// eg. by an assignment or an update, so describing or aggregating the same CSV again doesn't go through its cells.
+// Numbers are compared by value and other cells alphabetically, as sort does.
+func (c *CSV) Stats(header string) ColumnStats {
+	columnType := c.TypeOf(header)
+	if c.cells != nil {
+		c.cells.Lock()
+		cached, ok := c.cells.stats[header]
+		c.cells.Unlock()
+		if ok && cached.columnType == columnType && cached.changes == c.changes && cached.rows == len(c.Rows) {
+			return cached.stats
+		}
+	}
+
+	cells := c.Cells(header)
+	stats := ColumnStats{}
+	distinct := map[string]bool{}
+	for _, cell := range cells {
+		if cell.Kind == NullCell {
+			stats.Nulls++
+			continue
+		}
+		if stats.Count == 0 || cellLess(cell, stats.Min) {
+			stats.Min = cell
+		}
+		if stats.Count == 0 || cellLess(stats.Max, cell) {
+			stats.Max = cell
+		}
+		stats.Count++
+		distinct[cell.Text] = true
+	}
+	stats.Distinct = len(distinct)
+
+	c.cells.Lock()
+	defer c.cells.Unlock()
+	if c.cells.stats == nil {
+		c.cells.stats = map[string]cachedStats{}
+	}
+	c.cells.stats[header] = cachedStats{columnType: columnType, changes: c.changes, rows: len(c.Rows), stats: stats}
+	return stats
+}
+
+// cellLess compares two cells that aren't empty, numbers by value and other cells by their text
+func cellLess(a, b Cell) bool {
+	aNumber := a.Kind == IntegerCell || a.Kind == FloatCell
+	bNumber := b.Kind == IntegerCell || b.Kind == FloatCell
+	if aNumber && bNumber {
+		return a.Float < b.Float
+	}
+	return a.Text < b.Text
+}
@@ -0,0 +1,92 @@
+package object
+
+// CSVState is the content of a CSV at some point, see CSV.Snapshot
+type CSVState struct {
+	headers     []string
+	columnTypes []ColumnType
+	rows        []map[string]string
+}
+
+// Snapshot returns the content of the CSV, so it can be restored after the CSV changes, see Restore.
+// Nothing is copied but the headers: the CSV gives up its rows, so it copies them as they change, see ShareRows.
+func (c *CSV) Snapshot() *CSVState {
+	return &CSVState{
+		headers:     append([]string{}, c.Headers...),
+		columnTypes: append([]ColumnType{}, c.ColumnTypes...),
+		rows:        c.ShareRows(),
+	}
+}
+
+// Restore brings the CSV back to a state returned by Snapshot, a state can be restored any number of times
+func (c *CSV) Restore(state *CSVState) {
+	c.Headers = append([]string{}, state.headers...)
+	c.ColumnTypes = append([]ColumnType{}, state.columnTypes...)
+	c.Rows = state.rows
+	c.owned, c.cells = nil, nil
+	c.changes++
+}
+
+// CSV returns a new CSV holding the state, it shares the rows of the state until they change, see WritableRow
+func (state *CSVState) CSV() *CSV {
+	return &CSV{
+		Headers:     append([]string{}, state.headers...),
+		ColumnTypes: append([]ColumnType{}, state.columnTypes...),
+		Rows:        state.rows,
+	}
+}
+
+// Changed reports whether the CSV changed since a state returned by Snapshot
+func (c *CSV) Changed(since *CSVState) bool {
+	if len(c.Rows) != len(since.rows) || len(c.Headers) != len(since.headers) || len(c.ColumnTypes) != len(since.columnTypes) {
+		return true
+	}
+	// a changed row is written to a copy of the rows, see WritableRow
+	if len(c.Rows) > 0 && &c.Rows[0] != &since.rows[0] {
+		return true
+	}
+	for i, header := range c.Headers {
+		if header != since.headers[i] {
+			return true
+		}
+	}
+	for i, columnType := range c.ColumnTypes {
+		if columnType != since.columnTypes[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Begin starts a transaction on the CSV, the changes made to it until Commit can be undone with Rollback,
+// eg. when a validation fails halfway through a cleanup. It returns false if a transaction is already open.
+func (c *CSV) Begin() bool {
+	if c.savepoint != nil {
+		return false
+	}
+	c.savepoint = c.Snapshot()
+	return true
+}
+
+// Commit keeps the changes made since Begin, it returns false if no transaction is open
+func (c *CSV) Commit() bool {
+	if c.savepoint == nil {
+		return false
+	}
+	c.savepoint = nil
+	return true
+}
+
+// Rollback undoes the changes made since Begin, it returns false if no transaction is open
+func (c *CSV) Rollback() bool {
+	if c.savepoint == nil {
+		return false
+	}
+	c.Restore(c.savepoint)
+	c.savepoint = nil
+	return true
+}
+
+// InTransaction reports whether a transaction is open on the CSV, see Begin
+func (c *CSV) InTransaction() bool {
+	return c.savepoint != nil
+}
@@ -0,0 +1,185 @@
+package object
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// TypeUnmarshaller is implemented by the field types that parse their own cells, as in gocsv
+type TypeUnmarshaller interface {
+	UnmarshalCSV(string) error
+}
+
+// Unmarshal stores the rows of a script's result in v, a pointer to a slice of structs or of struct pointers, eg.
+//
+//	type Person struct {
+//		Name string `csv:"name"`
+//		Age  int    `csv:"age"`
+//	}
+//	var people []Person
+//	err := object.Unmarshal(result, &people)
+//
+// Fields are matched to columns by their csv tag, or by their name when they have none, and tagged "-" are skipped,
+// as in gocsv. Columns without a field and fields without a column are left out, empty cells are zero values (nil pointers).
+// The result is converted with ToCSV, so a row is a slice of one struct.
+func Unmarshal(result Object, v interface{}) error {
+	slice := reflect.ValueOf(v)
+	if slice.Kind() != reflect.Pointer || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("cannot unmarshal into %T, expected a pointer to a slice of structs", v)
+	}
+	slice = slice.Elem()
+	elemType := slice.Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot unmarshal into %T, expected a pointer to a slice of structs", v)
+	}
+
+	if result == nil {
+		return fmt.Errorf("cannot unmarshal a missing result")
+	}
+	csv, err := result.ToCSV(nil)
+	if err != nil {
+		return err
+	}
+
+	fields := csvFields(structType, nil)
+	rows := reflect.MakeSlice(slice.Type(), len(csv.Rows), len(csv.Rows))
+	for i, row := range csv.Rows {
+		elem := reflect.New(structType).Elem()
+		for _, header := range csv.Headers {
+			index, ok := fields[header]
+			if !ok {
+				continue
+			}
+			field := allocateField(elem, index)
+			if err := setField(field, row[header]); err != nil {
+				return fmt.Errorf("cannot unmarshal %q in column %s of row %d into %s: %w", row[header], header, i, field.Type(), err)
+			}
+		}
+		if elemType.Kind() == reflect.Pointer {
+			elem = elem.Addr()
+		}
+		rows.Index(i).Set(elem)
+	}
+	slice.Set(rows)
+	return nil
+}
+
+// csvFields returns the index of the field of every column of a struct, embedded structs are flattened
+func csvFields(structType reflect.Type, parent []int) map[string][]int {
+	fields := map[string][]int{}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		index := append(append([]int{}, parent...), i)
+		tag, _, _ := strings.Cut(field.Tag.Get("csv"), ",")
+		// unexported embedded structs are flattened, unless they are pointers that couldn't be allocated
+		if tag == "-" || !field.IsExported() && (!field.Anonymous || field.Type.Kind() == reflect.Pointer) {
+			continue
+		}
+
+		embedded := field.Type
+		if embedded.Kind() == reflect.Pointer {
+			embedded = embedded.Elem()
+		}
+		if field.Anonymous && tag == "" && embedded.Kind() == reflect.Struct && !parsesItself(field.Type) {
+			for column, fieldIndex := range csvFields(embedded, index) {
+				if _, ok := fields[column]; !ok {
+					fields[column] = fieldIndex
+				}
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if tag == "" {
+			tag = field.Name
+		}
+		// the fields of the struct win over those of embedded structs
+		fields[tag] = index
+	}
+	return fields
+}
+
+// allocateField returns the field at index, allocating the embedded struct pointers on the way, eg. for a column of *Address
+func allocateField(elem reflect.Value, index []int) reflect.Value {
+	value := elem
+	for _, i := range index {
+		if value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		value = value.Field(i)
+	}
+	return value
+}
+
+// parsesItself reports whether the values of a type parse their own cells, eg. a time.Time
+func parsesItself(t reflect.Type) bool {
+	pointer := reflect.PointerTo(t)
+	return pointer.Implements(reflect.TypeOf((*TypeUnmarshaller)(nil)).Elem()) ||
+		pointer.Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem())
+}
+
+// setField parses a cell into a field, an empty cell leaves the field at its zero value
+func setField(field reflect.Value, text string) error {
+	if field.Kind() == reflect.Pointer {
+		if text == "" {
+			return nil
+		}
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+	switch parser := field.Addr().Interface().(type) {
+	case TypeUnmarshaller:
+		return parser.UnmarshalCSV(text)
+	case encoding.TextUnmarshaler:
+		if text == "" {
+			return nil
+		}
+		return parser.UnmarshalText([]byte(text))
+	}
+	if text == "" {
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		field.SetBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value, err := strconv.ParseInt(text, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value, err := strconv.ParseUint(text, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(value)
+	case reflect.Float32, reflect.Float64:
+		value, err := strconv.ParseFloat(text, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(value)
+	default:
+		return fmt.Errorf("unsupported field type")
+	}
+	return nil
+}
@@ -18,28 +18,50 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	PIPE        // |>
+	LOGICAL_OR  // or, ||
+	LOGICAL_AND // and, &&
 	EQUALS      // ==
-	LESSGREATER // > or <
+	LESSGREATER // >, <, >= or <=
+	RANGE       // 0..10
 	SUM         // +
-	PRODUCT     // *
+	PRODUCT     // * or / or %
 	PREFIX      // -X or !X
+	POWER       // **
 	CALL        // myFunction(X)
 	INDEX       // array[index]
 	ASSIGN      // =
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
-	token.ASSIGN:   ASSIGN,
+	token.PIPE:            PIPE,
+	token.OR:              LOGICAL_OR,
+	token.LOGICAL_OR:      LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.LOGICAL_AND:     LOGICAL_AND,
+	token.EQ:              EQUALS,
+	token.EQ_FOLD:         EQUALS,
+	token.NOT_EQ_FOLD:     EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.RANGE:           RANGE,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.MODULO:          PRODUCT,
+	token.POWER:           POWER,
+	token.LPAREN:          CALL,
+	token.DOT:             CALL,
+	token.LBRACKET:        INDEX,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
 }
 
 type (
@@ -63,6 +85,9 @@ type Parser struct {
 	prefixParseFns     map[token.TokenType]prefixParseFn
 	infixParseFns      map[token.TokenType]infixParseFn
 	prefixParseReadFns map[token.TokenType]prefixParseReadFn
+
+	// tokens read ahead of peekToken while looking past newlines, see readToken
+	pending []token.Token
 }
 
 // New creates a new Parser instance with the given lexer
@@ -78,14 +103,25 @@ func New(l *lexer.Lexer) *Parser {
 	// Register only the parse functions we need for now
 	// p.registerReadPrefix(token.ROW, p.parseLocationExpression)
 
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
+	// `row` is only a keyword inside read statements, elsewhere it can name a loop variable
+	p.registerPrefix(token.ROW, p.parseIdentifier)
+	// `save` is a statement, and the save builtin when called, eg. rows |> save("out.csv")
+	p.registerPrefix(token.SAVE, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.NOT, p.parseNotExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNullLiteral)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+	p.registerPrefix(token.UPDATE, p.parseUpdateExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.READ, p.parseReadAsExpression)
@@ -93,16 +129,33 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.FOR, p.parseForLoopAsExpression)
 
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
+	p.registerInfix(token.RANGE, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.MODULO, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parsePipeExpression)
+	p.registerInfix(token.DOT, p.parseMethodCallExpression)
+	p.registerInfix(token.EQ_FOLD, p.parseInfixExpression)
+	p.registerInfix(token.NOT_EQ_FOLD, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseLogicalExpression)
+	p.registerInfix(token.OR, p.parseLogicalExpression)
+	p.registerInfix(token.LOGICAL_AND, p.parseLogicalExpression)
+	p.registerInfix(token.LOGICAL_OR, p.parseLogicalExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 	p.registerInfix(token.ASSIGN, p.parseIndexAssignment)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseIndexAssignment)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseIndexAssignment)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseIndexAssignment)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseIndexAssignment)
 
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
@@ -114,19 +167,95 @@ func New(l *lexer.Lexer) *Parser {
 func (p *Parser) nextToken() {
 	p.prevToken = p.curToken
 	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	p.peekToken = p.readToken()
+}
+
+// Statements end at a newline or a semicolon. A newline doesn't end a statement when
+//  1. it follows a token that can't end one, eg. `+`, `=`, `,`, `(` or `and`, or
+//  2. the next line starts with a token that can't start one, eg. `)`, `]`, `|>` or `else`
+//
+// so long expressions, argument lists and pipelines can be split over several lines:
+//
+//	let total = price *
+//	    quantity
+//	load data.csv
+//	    |> unique()
+//
+// `*` is not in the first list as it ends statements like `read row *`.
+
+// continuesAfter holds the tokens a newline can follow without ending the statement
+var continuesAfter = map[token.TokenType]bool{
+	token.ASSIGN: true, token.PLUS_ASSIGN: true, token.MINUS_ASSIGN: true, token.ASTERISK_ASSIGN: true, token.SLASH_ASSIGN: true,
+	token.PLUS: true, token.MINUS: true, token.SLASH: true, token.MODULO: true, token.POWER: true, token.BANG: true, token.RANGE: true,
+	token.LT: true, token.GT: true, token.LT_EQ: true, token.GT_EQ: true, token.EQ: true, token.NOT_EQ: true,
+	token.EQ_FOLD: true, token.NOT_EQ_FOLD: true, token.ARROW: true,
+	token.LOGICAL_AND: true, token.LOGICAL_OR: true, token.AND: true, token.OR: true, token.NOT: true, token.PIPE: true,
+	token.COMMA: true, token.COLON: true, token.DOT: true, token.IN: true,
+	token.LPAREN: true, token.LBRACKET: true, token.LBRACE: true,
+}
+
+// continuesBefore holds the tokens a line can start with to continue the statement of the previous line
+var continuesBefore = map[token.TokenType]bool{
+	token.RPAREN: true, token.RBRACKET: true, token.PIPE: true,
+	token.ELSE: true, token.CATCH: true, token.WHERE: true, token.COL: true,
+}
+
+// readToken returns the next token for peekToken, dropping the newlines and comments that don't end a statement.
+func (p *Parser) readToken() token.Token {
+	tok := p.lexToken()
+	if !isLineBreak(tok) {
+		return tok
+	}
+
+	// look past the line breaks, they are kept if the statement ends here
+	breaks := []token.Token{}
+	for isLineBreak(tok) {
+		breaks = append(breaks, tok)
+		tok = p.lexToken()
+	}
+	if continuesAfter[p.curToken.Type] || continuesBefore[tok.Type] {
+		return tok
+	}
+	p.pending = append(append(breaks[1:], tok), p.pending...)
+	return breaks[0]
+}
+
+// lexToken returns the next token of the lexer, or of the tokens read ahead by readToken.
+func (p *Parser) lexToken() token.Token {
+	if len(p.pending) > 0 {
+		tok := p.pending[0]
+		p.pending = p.pending[1:]
+		return tok
+	}
+	return p.l.NextToken()
+}
+
+func isLineBreak(tok token.Token) bool {
+	return tok.Type == token.NEWLINE || tok.Type == token.SINGLE_LINE_COMMENT
 }
 
 // addError creates a new ParserError with the given message, line, column, and stack trace
+// The error points at the current token, see addErrorAt
 func (p *Parser) addError(message string) {
+	p.recordError(p.curToken, message, "")
+}
+
+// addErrorAt creates a new ParserError pointing at the given token, with an optional hint to fix it
+func (p *Parser) addErrorAt(tok token.Token, message, hint string) {
+	p.recordError(tok, message, hint)
+}
+
+func (p *Parser) recordError(tok token.Token, message, hint string) {
 	stack := make([]uintptr, 50)
-	length := runtime.Callers(2, stack[:]) // Skip first two frames
+	length := runtime.Callers(3, stack[:]) // Skip the frames of the error helpers
 
 	er := &ParserError{
 		Message: message,
 		Stack:   stack[:length],
-		Line:    p.l.Line,
-		Column:  p.l.Column,
+		Line:    tok.Pos.Line,
+		Column:  tok.Pos.Column,
+		Source:  p.l.SourceLine(tok.Pos.Line),
+		Hint:    hint,
 	}
 
 	p.Errors = append(p.Errors, er)
@@ -137,12 +266,13 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 	for p.curToken.Type != token.EOF {
-		if p.curTokenIs(token.NEWLINE) || p.curTokenIs(token.SINGLE_LINE_COMMENT) {
+		// Skip empty lines, empty statements and comments
+		if p.curTokenIs(token.NEWLINE) || p.curTokenIs(token.SEMICOLON) || p.curTokenIs(token.SINGLE_LINE_COMMENT) {
 			p.nextToken()
 			continue
 		}
 
-		stmt := p.parseStatement()
+		stmt := p.parseStatementLine()
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
@@ -151,21 +281,73 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+// parseStatementLine parses a statement and checks that it ends at a newline, a semicolon, the end of the block or of the input
+// so two statements on one line must be separated, eg. `let x = 1; let y = 2`
+func (p *Parser) parseStatementLine() ast.Statement {
+	errors := len(p.Errors)
+	stmt := p.parseStatement()
+	if len(p.Errors) > errors || p.atStatementEnd() {
+		return stmt
+	}
+
+	hint := "put statements on separate lines, or separate them with ;"
+	switch stmt.(type) {
+	case *ast.LoadStatement, *ast.SaveStatement, *ast.ImportStatement:
+		hint = fmt.Sprintf("did you mean to quote the filename? eg. %s \"my data.csv\"", stmt.TokenLiteral())
+	}
+	p.addErrorAt(p.peekToken, fmt.Sprintf("expected newline or ; after statement, got %s", p.peekToken.Type), hint)
+	// skip the rest of the line, it would only produce more errors
+	for !p.peekTokenIs(token.NEWLINE) && !p.peekTokenIs(token.SEMICOLON) && !p.peekTokenIs(token.EOF) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// atStatementEnd checks if the statement that was just parsed is followed by its end, statements may consume their terminator
+func (p *Parser) atStatementEnd() bool {
+	if p.curTokenIs(token.SEMICOLON) || p.curTokenIs(token.NEWLINE) {
+		return true
+	}
+	return p.isTerminator() || p.peekTokenIs(token.EOF) || p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.SINGLE_LINE_COMMENT)
+}
+
 // parseStatement parses a statement and returns the AST node
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 	case token.LOAD:
-		return p.parseLoadStatement()
+		stmt := p.parseLoadStatement()
+		if stmt != nil && p.peekTokenIs(token.PIPE) {
+			return p.parsePipelineStatement(stmt)
+		}
+		return stmt
 	case token.READ:
-		return p.parseReadStatement()
+		stmt := p.parseReadStatement()
+		if p.peekTokenIs(token.PIPE) {
+			return p.parsePipelineStatement(stmt.ReadExpression)
+		}
+		return stmt
 	case token.RETURN:
 		return p.parseReturnStatement()
 	case token.SAVE:
 		return p.parseSaveStatement()
 	case token.FOR:
 		return p.parseForLoopStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.IDENT:
+		// unload is only a keyword when it names variables, eg. unload csv
+		if p.curToken.Literal == "unload" && p.peekTokenIs(token.IDENT) {
+			return p.parseUnloadStatement()
+		}
+		return p.parseExpressionStatement()
+	case token.FUNCTION:
+		// fn followed by a name declares a function, otherwise it's a function literal
+		if p.peekTokenIs(token.IDENT) {
+			return p.parseFunctionStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -193,37 +375,205 @@ func (p *Parser) parseIndexAssignment(left ast.Expression) ast.Expression {
 // Two options:
 // 1. save as filtered.csv/filtered.json
 // 2. save myCustomRows as filtered.csv/filtered.json
+//
+// Several destinations are separated by commas, eg. save rows as out.csv, out.json
+// and `split by <column>` writes one file per value, eg. save rows split by region as "out_{region}.csv"
 func (p *Parser) parseSaveStatement() *ast.SaveStatement {
 	stmt := &ast.SaveStatement{Token: p.curToken}
 
-	if p.peekTokenIs(token.IDENT) {
-		stmt.Source = &ast.Identifier{Token: p.peekToken, Value: p.peekToken.Literal}
+	if p.peekTokenIs(token.LBRACE) {
+		return p.parseSaveBundle(stmt)
+	}
+
+	// save from big.csv where status == "failed" as failed.csv copies the matching rows without loading the file
+	if p.peekTokenIs(token.FROM) {
+		p.nextToken()
+		p.nextToken()
+		if !p.curTokenIs(token.IDENT) && !p.curTokenIs(token.STRING) {
+			p.addError(fmt.Sprintf("expected the file to save from, got %s", p.curToken.Type))
+			return nil
+		}
+		stmt.From = p.curToken.Literal
+		if p.peekTokenIs(token.WHERE) {
+			p.nextToken()
+			p.nextToken()
+			if stmt.Where = p.parseFilterOr(); stmt.Where == nil {
+				return nil
+			}
+		}
+	}
+
+	var source *ast.Identifier
+	if stmt.From == "" && p.peekTokenIs(token.IDENT) {
+		source = &ast.Identifier{Token: p.peekToken, Value: p.peekToken.Literal}
+		stmt.Source = source
 
 		p.nextToken()
 	}
 
-	if !p.expectPeek(token.AS) {
-		return nil
+	// `save split by region as ...` has no source, split was read as one
+	split := false
+	if source != nil && source.Value == "split" && p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "by" {
+		stmt.Source = nil
+		split = true
+	} else if p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "split" {
+		p.nextToken()
+		split = true
 	}
-	p.nextToken() // move past AS
 
-	// Parse filename
-	if !p.curTokenIs(token.IDENT) && !p.curTokenIs(token.STRING) {
-		p.addError("expected filename")
+	if split {
+		if !p.expectPeek(token.IDENT) || p.curToken.Literal != "by" {
+			p.addError("expected by to follow split")
+			return nil
+		}
+		p.nextToken()
+		if !p.curTokenIs(token.IDENT) && !p.curTokenIs(token.STRING) {
+			p.addError(fmt.Sprintf("expected column name after split by, got %s", p.curToken.Type))
+			return nil
+		}
+		stmt.SplitBy = p.curToken.Literal
+	}
+
+	if !p.expectPeek(token.AS) {
 		return nil
 	}
 
-	stmt.Filename = p.curToken.Literal
+	for {
+		p.nextToken() // move past AS or the comma
+
+		// Parse filename
+		if !p.curTokenIs(token.IDENT) && !p.curTokenIs(token.STRING) {
+			p.addErrorAt(p.curToken, "expected filename", "did you mean to quote the filename? eg. save rows as \"my data.csv\"")
+			return nil
+		}
+
+		filename := p.curToken.Literal
+		// `save rows as clipboard` copies the rows instead of writing a file
+		isClipboard := p.curTokenIs(token.IDENT) && filename == "clipboard"
+		isSheet := strings.HasPrefix(filename, "gsheet://")
+		// `csvlang batch` names the file to write, eg. save rows as "{output}"
+		isBatchOutput := strings.Contains(filename, "{output}")
+		if !isClipboard && !isSheet && !isBatchOutput && !strings.HasSuffix(filename, ".json") && !strings.HasSuffix(filename, ".csv") {
+			p.addError("unsupported file format")
+			return nil
+		}
+		stmt.Filenames = append(stmt.Filenames, filename)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
 
 	// Determine format from filename extension
+	stmt.Filename = stmt.Filenames[0]
 	if strings.HasSuffix(stmt.Filename, ".json") {
 		stmt.Format = "json"
-	} else if strings.HasSuffix(stmt.Filename, ".csv") {
-		stmt.Format = "csv"
 	} else {
-		p.addError("unsupported file format")
+		stmt.Format = "csv"
+	}
+
+	if p.isTerminator() {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseUnloadStatement parses the variables to drop, eg. unload csv, rows
+func (p *Parser) parseUnloadStatement() *ast.UnloadStatement {
+	stmt := &ast.UnloadStatement{Token: p.curToken}
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Names = append(stmt.Names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if p.isTerminator() {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseSaveBundle parses a report bundle, eg. save {summary: stats, detail: rows} as report/
+// every entry is written to its own file in the directory, names without an extension are saved as .csv
+func (p *Parser) parseSaveBundle(stmt *ast.SaveStatement) *ast.SaveStatement {
+	p.nextToken() // move to LBRACE
+
+	for {
+		p.skipNewlines()
+		if p.peekTokenIs(token.RBRACE) && len(stmt.Bundle) > 0 {
+			break
+		}
+		p.nextToken()
+		if !p.curTokenIs(token.IDENT) && !p.curTokenIs(token.STRING) {
+			p.addError(fmt.Sprintf("expected bundle entry name, got %s", p.curToken.Type))
+			return nil
+		}
+		name := p.curToken.Literal
+		if !strings.HasSuffix(name, ".json") && !strings.HasSuffix(name, ".csv") {
+			name += ".csv"
+		}
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		if value == nil {
+			return nil
+		}
+		stmt.Bundle = append(stmt.Bundle, &ast.BundleEntry{Name: name, Value: value})
+
+		p.skipNewlines()
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACE) || !p.expectPeek(token.AS) {
+		return nil
+	}
+	p.nextToken()
+	if !p.curTokenIs(token.IDENT) && !p.curTokenIs(token.STRING) {
+		p.addError("expected directory")
 		return nil
 	}
+	stmt.Filename = p.curToken.Literal
+	stmt.Filenames = []string{stmt.Filename}
+
+	if p.isTerminator() {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// Two options:
+// 1. import "lib/cleaning.cl"
+// 2. import "lib/cleaning.cl" as cleaning
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	p.nextToken() // move past IMPORT
+	if !p.curTokenIs(token.IDENT) && !p.curTokenIs(token.STRING) {
+		p.addError("expected path to import")
+		return nil
+	}
+	stmt.Path = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.AS) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
 
 	if p.isTerminator() {
 		p.nextToken()
@@ -247,12 +597,41 @@ func (p *Parser) parseArrayLiteralAsExpression() ast.Expression {
 	return p.parseArrayLiteral()
 }
 
+// parseIndexExpression parses an index expression (eg. arr[1])
+// or a slice expression when the brackets contain a colon (eg. arr[1:3])
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
 
 	p.nextToken()
 
+	// arr[:end]
+	if p.curTokenIs(token.COLON) {
+		return p.parseSliceExpression(&ast.SliceExpression{Token: exp.Token, Left: left})
+	}
+
 	exp.Index = p.parseExpression(LOWEST)
+
+	// arr[start:] or arr[start:end]
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(&ast.SliceExpression{Token: exp.Token, Left: left, Start: exp.Index})
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return exp
+}
+
+// parseSliceExpression parses the optional end bound of a slice, the current token is the colon
+func (p *Parser) parseSliceExpression(exp *ast.SliceExpression) ast.Expression {
+	if p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		return exp
+	}
+
+	p.nextToken()
+	exp.End = p.parseExpression(LOWEST)
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
@@ -324,6 +703,10 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 		Left:     left,
 	}
 	precedence := p.curPrecedence()
+	// ** is right-associative, i.e., 2 ** 3 ** 2 is 2 ** (3 ** 2)
+	if p.curTokenIs(token.POWER) {
+		precedence--
+	}
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
 
@@ -333,9 +716,9 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 func (p *Parser) parseExpressionStatement() ast.Statement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
-	// If current token is identifier and next token is =, it's an assignment
+	// If current token is identifier and next token is = (or +=, -=, etc.), it's an assignment
 	// user can choose to reassign values to a variable
-	if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.ASSIGN) {
+	if p.curTokenIs(token.IDENT) && p.peekIsAssignment() {
 		return p.parseAssignmentStatement()
 	}
 
@@ -346,6 +729,43 @@ func (p *Parser) parseExpressionStatement() ast.Statement {
 	return stmt
 }
 
+// parsePipelineStatement parses a pipeline starting with a load or read statement, eg. load in.csv |> unique()
+func (p *Parser) parsePipelineStatement(left ast.Expression) ast.Statement {
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
+	for p.peekTokenIs(token.PIPE) {
+		p.nextToken()
+		left = p.parsePipeExpression(left)
+	}
+	stmt.Expression = left
+	if p.isTerminator() {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parsePipeExpression parses the right side of a pipe, a call (rows |> fill_empty("name", "-")) or a function (rows |> unique)
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	expression := &ast.PipeExpression{Token: p.curToken, Left: left}
+	precedence := p.curPrecedence()
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+	return expression
+}
+
+// parseMethodCallExpression parses a method call chained onto a call or index, eg. .head(10) in csv.filter(f).head(10)
+func (p *Parser) parseMethodCallExpression(receiver ast.Expression) ast.Expression {
+	expression := &ast.MethodCallExpression{Token: p.curToken, Receiver: receiver}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	expression.Method = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	expression.Arguments = p.parseExpressionList(token.RPAREN)
+	return expression
+}
+
 func (p *Parser) parseAssignmentStatement() *ast.AssignmentStatement {
 	stmt := &ast.AssignmentStatement{Token: p.curToken}
 
@@ -354,6 +774,7 @@ func (p *Parser) parseAssignmentStatement() *ast.AssignmentStatement {
 
 	// Move past identifier
 	p.nextToken()
+	stmt.Operator = p.curToken.Literal
 	// Move past '='
 	p.nextToken()
 
@@ -376,6 +797,33 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	return expression
 }
 
+// parseNotExpression parses the NOT keyword, eg. not (status == "ok")
+// unlike !, it binds looser than comparisons, so not a == b is parsed as not (a == b)
+func (p *Parser) parseNotExpression() ast.Expression {
+	expression := &ast.PrefixExpression{
+		Token:    p.curToken,
+		Operator: strings.ToLower(p.curToken.Literal),
+	}
+	p.nextToken()
+	expression.Right = p.parseExpression(LOGICAL_AND)
+	return expression
+}
+
+// parseLogicalExpression parses and/or (or their && and || counterparts)
+// keyword operators are lowercased since keywords are case-insensitive
+func (p *Parser) parseLogicalExpression(left ast.Expression) ast.Expression {
+	expression := &ast.InfixExpression{
+		Token:    p.curToken,
+		Operator: strings.ToLower(p.curToken.Literal),
+		Left:     left,
+	}
+	precedence := p.curPrecedence()
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+
+	return expression
+}
+
 func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
 	if !p.expectPeek(token.LPAREN) {
@@ -402,12 +850,223 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+// parseTryExpression parses a try expression, the catch parameter is optional
+// eg. try { load extra.csv } catch (e) { print(e) }
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Body = p.parseBlockStatement()
+
+	if !p.expectPeek(token.CATCH) {
+		return nil
+	}
+	if p.peekTokenIs(token.LPAREN) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		expression.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Handler = p.parseBlockStatement()
+
+	return expression
+}
+
+// parseMatchExpression parses a match expression, arms are separated by newlines or semicolons
+// eg. match (status) {
+//
+//	"ok" => 1
+//	"failed", "error" => 2
+//	_ => 0
+//
+// }
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Subject = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) {
+		if p.curTokenIs(token.EOF) {
+			p.addError("MATCH: expected } to close the match expression")
+			return nil
+		}
+
+		// Skip arm separators and comments
+		if p.curTokenIs(token.NEWLINE) || p.curTokenIs(token.SEMICOLON) || p.curTokenIs(token.SINGLE_LINE_COMMENT) {
+			p.nextToken()
+			continue
+		}
+
+		arm := &ast.MatchArm{}
+		// _ is the catch-all arm
+		if !(p.curTokenIs(token.IDENT) && p.curToken.Literal == "_") {
+			arm.Patterns = append(arm.Patterns, p.parseExpression(LOWEST))
+			for p.peekTokenIs(token.COMMA) {
+				p.nextToken()
+				p.nextToken()
+				arm.Patterns = append(arm.Patterns, p.parseExpression(LOWEST))
+			}
+		}
+
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+		p.nextToken()
+		arm.Value = p.parseExpression(LOWEST)
+		expression.Arms = append(expression.Arms, arm)
+
+		p.nextToken()
+	}
+
+	return expression
+}
+
+// parseGenerateExpression parses a CSV made from scratch, eg. generate 1000 rows with (id: row_number, name: fake("name"))
+func (p *Parser) parseGenerateExpression() ast.Expression {
+	expression := &ast.GenerateExpression{Token: p.curToken}
+	p.nextToken()
+	expression.Count = p.parseExpression(LOWEST)
+	if expression.Count == nil {
+		return nil
+	}
+	if !p.peekTokenIs(token.IDENT) || p.peekToken.Literal != "rows" {
+		p.addErrorAt(p.peekToken, fmt.Sprintf("expected rows after the number of rows to generate, got %s", p.peekToken.Type), `eg. generate 10 rows with (id: row_number)`)
+		return nil
+	}
+	p.nextToken()
+	names, values := p.parseColumnExpressions("generate", "with", `eg. generate 10 rows with (id: row_number)`)
+	if names == nil {
+		return nil
+	}
+	for i, name := range names {
+		expression.Columns = append(expression.Columns, &ast.GenerateColumn{Name: name, Value: values[i]})
+	}
+	return expression
+}
+
+// parseValidateExpression parses the rules rows are checked against, eg. validate rows with (age: int > 0, name: required)
+func (p *Parser) parseValidateExpression() ast.Expression {
+	expression := &ast.ValidateExpression{Token: p.curToken}
+	p.nextToken()
+	expression.Source = p.parseExpression(LOWEST)
+	if expression.Source == nil {
+		return nil
+	}
+	names, rules := p.parseColumnExpressions("validate", "with", `eg. validate rows with (age: int > 0, name: required)`)
+	if names == nil {
+		return nil
+	}
+	for i, name := range names {
+		expression.Rules = append(expression.Rules, &ast.ValidateRule{Column: name, Rule: rules[i]})
+	}
+	return expression
+}
+
+// parseUpdateExpression parses cells changed in place, eg. update csv set (status: "adult") where age > 17
+func (p *Parser) parseUpdateExpression() ast.Expression {
+	expression := &ast.UpdateExpression{Token: p.curToken}
+	p.nextToken()
+	expression.Target = p.parseExpression(LOWEST)
+	if expression.Target == nil {
+		return nil
+	}
+	names, values := p.parseColumnExpressions("update", "set", `eg. update csv set (status: "adult") where age > 17`)
+	if names == nil {
+		return nil
+	}
+	for i, name := range names {
+		expression.Columns = append(expression.Columns, &ast.UpdateColumn{Name: name, Value: values[i]})
+	}
+	if p.peekTokenIs(token.WHERE) {
+		p.nextToken()
+		p.nextToken()
+		if expression.Where = p.parseFilterOr(); expression.Where == nil {
+			return nil
+		}
+	}
+	return expression
+}
+
+// parseColumnExpressions parses `with (name: expression, ...)` following the current token, naming a column once each.
+// The word before the columns is `with`, or `set` for update. The columns can span several lines. It returns nil names after an error.
+func (p *Parser) parseColumnExpressions(keyword, word, example string) ([]string, []ast.Expression) {
+	if !p.peekTokenIs(token.IDENT) || p.peekToken.Literal != word {
+		p.addErrorAt(p.peekToken, fmt.Sprintf("expected %s after %s, got %s", word, p.curToken.Literal, p.peekToken.Type), example)
+		return nil, nil
+	}
+	p.nextToken()
+	if !p.expectPeek(token.LPAREN) {
+		return nil, nil
+	}
+
+	names, values := []string{}, []ast.Expression{}
+	for {
+		p.skipNewlines()
+		if p.peekTokenIs(token.RPAREN) && len(names) > 0 {
+			break
+		}
+		p.nextToken()
+		if !p.curTokenIs(token.IDENT) && !p.curTokenIs(token.STRING) {
+			p.addErrorAt(p.curToken, fmt.Sprintf("expected column name, got %s", p.curToken.Type), example)
+			return nil, nil
+		}
+		name := p.curToken.Literal
+		for _, previous := range names {
+			if previous == name {
+				p.addErrorAt(p.curToken, fmt.Sprintf("duplicate column in %s: %s", keyword, name), "")
+				return nil, nil
+			}
+		}
+		if !p.expectPeek(token.COLON) {
+			return nil, nil
+		}
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		if value == nil {
+			return nil, nil
+		}
+		names, values = append(names, name), append(values, value)
+
+		p.skipNewlines()
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil, nil
+	}
+	return names, values
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
-	lit.Parameters = p.parseFunctionParameters()
+	lit.Parameters, lit.Defaults, lit.Rest = p.parseFunctionParameters()
+	if lit.Parameters == nil {
+		return nil
+	}
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
@@ -415,6 +1074,27 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
+// parseFunctionStatement parses a named function declaration, eg. fn clean(row) { ... }
+func (p *Parser) parseFunctionStatement() *ast.FunctionStatement {
+	stmt := &ast.FunctionStatement{Token: p.curToken}
+
+	p.nextToken()
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	// the rest is parsed like a function literal, it starts at the name instead of the fn token
+	function, ok := p.parseFunctionLiteral().(*ast.FunctionLiteral)
+	if !ok {
+		return nil
+	}
+	function.Token = stmt.Token
+	stmt.Function = function
+
+	if p.isTerminator() {
+		p.nextToken()
+	}
+	return stmt
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
@@ -426,13 +1106,13 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block.Statements = []ast.Statement{}
 	p.nextToken()
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		// Skip newlines or comments within the block
-		if p.curTokenIs(token.NEWLINE) || p.curTokenIs(token.SINGLE_LINE_COMMENT) {
+		// Skip newlines, empty statements or comments within the block
+		if p.curTokenIs(token.NEWLINE) || p.curTokenIs(token.SEMICOLON) || p.curTokenIs(token.SINGLE_LINE_COMMENT) {
 			p.nextToken()
 			continue
 		}
 
-		stmt := p.parseStatement()
+		stmt := p.parseStatementLine()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
@@ -441,33 +1121,57 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	return block
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// parseFunctionParameters parses the parameter list of a function, eg. (value, factor = 100, ...rest)
+// It returns the parameters, their default values (nil when no parameter has one) and the variadic parameter.
+// The parameters are nil if the list could not be parsed.
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []ast.Expression, *ast.Identifier) {
 	identifiers := []*ast.Identifier{}
+	var defaults []ast.Expression
+	var rest *ast.Identifier
+
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return identifiers, defaults, rest
 	}
 
-	// move past opening LPAREN "("
-	p.nextToken()
-
-	// create an ident for the first param and append to identifiers slice
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
-
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
+	for {
+		// move past opening LPAREN "(" or the COMMA before the param
 		p.nextToken()
 
-		// add the following (second, third, and so on) params to identifiers slice
+		// variadic param collects the remaining arguments, it must come last
+		if p.curTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
+
+		// create an ident for the param and append to identifiers slice
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 		identifiers = append(identifiers, ident)
+
+		// param with a default value, eg. factor = 100
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			for len(defaults) < len(identifiers)-1 {
+				defaults = append(defaults, nil)
+			}
+			defaults = append(defaults, p.parseExpression(LOWEST))
+		} else if len(defaults) > 0 {
+			p.addError(fmt.Sprintf("parameter %s without default value follows parameter with default value", ident.Value))
+			return nil, nil, nil
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return nil, nil, nil
 	}
-	return identifiers
+	return identifiers, defaults, rest
 }
 
 // func (p *Parser) parseReadPrefixInLetStatement() ast.Expression {
@@ -486,6 +1190,30 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addError(msg)
+		return nil
+	}
+	lit.Value = value
+	return lit
+}
+
+// parseIllegal reports ILLEGAL tokens produced by the lexer, eg. unterminated strings
+func (p *Parser) parseIllegal() ast.Expression {
+	if strings.HasPrefix(p.curToken.Literal, "\"") {
+		p.addError(fmt.Sprintf("unterminated string: %s", p.curToken.Literal))
+	} else if p.curToken.Literal == "#[" {
+		p.addError("unterminated block comment: missing closing ]#")
+	} else {
+		p.addError(fmt.Sprintf("illegal character: %s", p.curToken.Literal))
+	}
+	return nil
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
@@ -494,6 +1222,10 @@ func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
+func (p *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: p.curToken}
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 	p.nextToken()
@@ -532,14 +1264,122 @@ func (p *Parser) parseLoadStatement() *ast.LoadStatement {
 
 	fmt.Printf("\n[ParseLoad] cur token: %s, %s\n", p.curToken.Type, p.curToken.Literal)
 
-	// Parse the filename as an expression instead of identifier
-	filename := p.parseExpression(LOWEST)
-	fmt.Printf("filenameee: %s\n", filename.TokenLiteral())
+	// load pg "SELECT ..." loads the rows of a database query
+	if p.curTokenIs(token.IDENT) && (p.curToken.Literal == "pg" || p.curToken.Literal == "mysql") && p.peekTokenIs(token.STRING) {
+		stmt.Database = p.curToken.Literal
+		p.nextToken()
+	}
+
+	// load delta of big.csv since state ".csvlang-state" loads the rows appended since the last run
+	delta := p.curTokenIs(token.IDENT) && p.curToken.Literal == "delta" && p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "of"
+	if delta {
+		p.nextToken()
+		p.nextToken()
+	}
+
+	// Parse the filename as an expression instead of identifier, stopping before a pipe (load in.csv |> unique())
+	filename := p.parseExpression(PIPE)
 	if filename == nil {
 		return nil
 	}
+	fmt.Printf("filenameee: %s\n", filename.TokenLiteral())
 	stmt.Filename = filename
 
+	// load options, eg. `load data.csv trim numbers "de"`
+options:
+	for p.peekTokenIs(token.IDENT) {
+		switch strings.ToLower(p.peekToken.Literal) {
+		case "trim":
+			// trim the whitespace around headers and cells
+			p.nextToken()
+			stmt.Trim = true
+		case "source_file":
+			// name the file of each row when loading several files, eg. load "logs/*.csv" source_file
+			p.nextToken()
+			stmt.SourceFile = true
+		case "numbers":
+			// read numbers written in a locale, eg. 1.234,56 in "de"
+			p.nextToken()
+			if !p.expectPeek(token.STRING) {
+				return nil
+			}
+			stmt.NumberLocale = p.curToken.Literal
+		case "since":
+			// the file the offset of a delta load is kept in
+			p.nextToken()
+			if !delta {
+				p.addErrorAt(p.curToken, "since state is only allowed in delta loads", `eg. load delta of big.csv since state ".csvlang-state"`)
+				return nil
+			}
+			if !p.peekTokenIs(token.IDENT) || p.peekToken.Literal != "state" {
+				p.addErrorAt(p.peekToken, fmt.Sprintf("expected state after since, got %s", p.peekToken.Type), "")
+				return nil
+			}
+			p.nextToken()
+			if !p.expectPeek(token.STRING) {
+				return nil
+			}
+			stmt.StateFile = p.curToken.Literal
+		case "na":
+			// the cells that mean missing, eg. na values ["", "NA", "N/A", "-"]
+			p.nextToken()
+			if !p.peekTokenIs(token.IDENT) || p.peekToken.Literal != "values" {
+				p.addErrorAt(p.peekToken, fmt.Sprintf("expected values after na, got %s", p.peekToken.Type), `eg. load data.csv na values ["NA", "-"]`)
+				return nil
+			}
+			p.nextToken()
+			if !p.expectPeek(token.LBRACKET) {
+				return nil
+			}
+			stmt.NAValues = []string{}
+			for _, value := range p.parseExpressionList(token.RBRACKET) {
+				if value == nil {
+					return nil
+				}
+				literal, ok := value.(*ast.StringLiteral)
+				if !ok {
+					p.addErrorAt(p.curToken, fmt.Sprintf("na values must be strings, got %s", value), `eg. load data.csv na values ["NA", "-"]`)
+					return nil
+				}
+				stmt.NAValues = append(stmt.NAValues, literal.Value)
+			}
+		case "quarantine":
+			// set the rows that can't be read aside in a variable, and a file after to, eg. quarantine bad_rows to "bad.csv"
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			stmt.Quarantine = p.curToken.Literal
+			if p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "to" {
+				p.nextToken()
+				if !p.peekTokenIs(token.IDENT) && !p.peekTokenIs(token.STRING) {
+					p.addErrorAt(p.peekToken, fmt.Sprintf("expected the file to quarantine rows to, got %s", p.peekToken.Type), `eg. quarantine bad_rows to "bad.csv"`)
+					return nil
+				}
+				p.nextToken()
+				stmt.QuarantineFile = p.curToken.Literal
+			}
+		case "readonly":
+			// rows that can't be changed
+			p.nextToken()
+			stmt.ReadOnly = true
+		case "mmap":
+			// map the file in memory instead of loading it, only readonly files can be mapped
+			p.nextToken()
+			stmt.Mmap = true
+		default:
+			break options
+		}
+	}
+	if stmt.Mmap && !stmt.ReadOnly {
+		p.addErrorAt(stmt.Token, "only readonly files can be mapped", "eg. load big.csv readonly mmap")
+		return nil
+	}
+	if delta && stmt.StateFile == "" {
+		p.addErrorAt(stmt.Token, "delta loads need a state file", `eg. load delta of big.csv since state ".csvlang-state"`)
+		return nil
+	}
+
 	fmt.Printf("returning load stmt: type: %s, lit: %s, filename: %s, stmt: %s\n", stmt.Token.Type, stmt.Token.Literal, stmt.Filename.String(), stmt.String())
 	return stmt
 }
@@ -554,6 +1394,15 @@ func (p *Parser) parseReadExpression() *ast.ReadExpression {
 
 	p.nextToken()
 
+	// read from a CSV variable, eg. read from sales row *
+	if p.curTokenIs(token.FROM) {
+		if !p.expectPeek(token.IDENT) {
+			return expr
+		}
+		expr.Source = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.nextToken()
+	}
+
 	// Parse location
 	location := p.parseLocationExpression()
 	expr.Location = location
@@ -584,8 +1433,10 @@ func (p *Parser) parseForLoopExpression() *ast.ForLoopExpression {
 		return nil
 	}
 
-	// Parse element identifier
-	if !p.expectPeek(token.IDENT) {
+	// Parse element identifier, `row` is allowed so CSV loops read naturally
+	if p.peekTokenIs(token.ROW) {
+		p.nextToken()
+	} else if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 	stmt.ElementName = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
@@ -639,9 +1490,19 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
+// peekIsAssignment checks if the next token is = or a compound assignment operator
+func (p *Parser) peekIsAssignment() bool {
+	switch p.peekToken.Type {
+	case token.ASSIGN, token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.ASTERISK_ASSIGN, token.SLASH_ASSIGN:
+		return true
+	default:
+		return false
+	}
+}
+
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.addError(msg)
+	p.addErrorAt(p.peekToken, msg, "")
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -666,6 +1527,14 @@ Prefix handlers
 
 // 1. prefix as identifier
 func (p *Parser) parseIdentifier() ast.Expression {
+	// generate is only a keyword when the number of rows follows, so it stays usable as a name
+	if p.curToken.Literal == "generate" && (p.peekTokenIs(token.INT) || p.peekTokenIs(token.IDENT)) {
+		return p.parseGenerateExpression()
+	}
+	// so is validate, when the rows to check follow
+	if p.curToken.Literal == "validate" && p.peekTokenIs(token.IDENT) {
+		return p.parseValidateExpression()
+	}
 	return &ast.Identifier{
 		Token: p.curToken,
 		Value: p.curToken.Literal,
@@ -719,6 +1588,9 @@ func (p *Parser) parseLocationExpression() ast.LocationExpression {
 		p.nextToken()
 		return locExpr
 	}
+	if p.readEndsAtPeek() {
+		return locExpr
+	}
 
 	p.nextToken()
 
@@ -745,6 +1617,9 @@ func (p *Parser) parseLocationExpression() ast.LocationExpression {
 			p.nextToken()
 			return locExpr
 		}
+		if p.readEndsAtPeek() {
+			return locExpr
+		}
 
 		if !p.peekTokenIs(token.WHERE) {
 			errMsg := fmt.Sprintf("READ: expected WHERE token to follow COL, got %s", p.peekToken.Type)
@@ -759,54 +1634,119 @@ func (p *Parser) parseLocationExpression() ast.LocationExpression {
 	}
 
 	// 🏁🏁🏁 3. the cur token is WHERE, start parsing the filter expression
-	filterExpr := ast.ReadFilterExpression{Token: p.curToken}
-
 	p.nextToken()
 
-	if p.curToken.Type != token.IDENT {
-		errMsg := fmt.Sprintf("READ: expected column name to be IDENT, got %s", p.curToken.Type)
-		p.addError(errMsg)
+	filter := p.parseFilterOr()
+	if filter == nil {
 		return ast.LocationExpression{
 			RowIndex: -1,
 			ColIndex: "",
 		}
 	}
+	locExpr.Filter = filter
+
+	if p.isTerminator() {
+		p.nextToken()
+	}
+
+	return locExpr
+}
+
+// parseFilterOr parses where conditions joined by OR (eg. age > 30 or name == "Bob")
+// OR binds looser than AND, so a and b or c is parsed as (a and b) or c
+func (p *Parser) parseFilterOr() ast.Expression {
+	left := p.parseFilterAnd()
+	for left != nil && (p.peekTokenIs(token.OR) || p.peekTokenIs(token.LOGICAL_OR)) {
+		p.nextToken()
+		exp := &ast.InfixExpression{Token: p.curToken, Left: left, Operator: strings.ToLower(p.curToken.Literal)}
+		p.nextToken()
+		exp.Right = p.parseFilterAnd()
+		if exp.Right == nil {
+			return nil
+		}
+		left = exp
+	}
+	return left
+}
+
+// parseFilterAnd parses where conditions joined by AND (eg. age > 30 and age < 40)
+func (p *Parser) parseFilterAnd() ast.Expression {
+	left := p.parseFilterUnary()
+	for left != nil && (p.peekTokenIs(token.AND) || p.peekTokenIs(token.LOGICAL_AND)) {
+		p.nextToken()
+		exp := &ast.InfixExpression{Token: p.curToken, Left: left, Operator: strings.ToLower(p.curToken.Literal)}
+		p.nextToken()
+		exp.Right = p.parseFilterUnary()
+		if exp.Right == nil {
+			return nil
+		}
+		left = exp
+	}
+	return left
+}
+
+// parseFilterUnary parses a negated where condition (eg. not (status == "ok")),
+// a parenthesized group of conditions, or a single column comparison
+func (p *Parser) parseFilterUnary() ast.Expression {
+	switch p.curToken.Type {
+	case token.NOT, token.BANG:
+		exp := &ast.PrefixExpression{Token: p.curToken, Operator: strings.ToLower(p.curToken.Literal)}
+		p.nextToken()
+		exp.Right = p.parseFilterUnary()
+		if exp.Right == nil {
+			return nil
+		}
+		return exp
+	case token.LPAREN:
+		p.nextToken()
+		exp := p.parseFilterOr()
+		if exp == nil || !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+		return exp
+	default:
+		return p.parseFilterComparison()
+	}
+}
+
+// parseFilterComparison parses a single column comparison in a where clause (eg. age > 30)
+func (p *Parser) parseFilterComparison() ast.Expression {
+	filterExpr := &ast.ReadFilterExpression{Token: p.curToken}
+
+	if p.curToken.Type != token.IDENT {
+		errMsg := fmt.Sprintf("READ: expected column name to be IDENT, got %s", p.curToken.Type)
+		p.addError(errMsg)
+		return nil
+	}
 	filterExpr.ColumnName = p.curToken.Literal
 
 	p.nextToken()
 
 	if p.curToken.Type != token.EQ &&
 		p.curToken.Type != token.NOT_EQ &&
+		p.curToken.Type != token.EQ_FOLD &&
+		p.curToken.Type != token.NOT_EQ_FOLD &&
 		p.curToken.Type != token.LT &&
-		p.curToken.Type != token.GT {
-		errMsg := fmt.Sprintf("READ: expected operator to be one of [EQ, NOT_EQ, LT, GT] got %s", p.curToken.Type)
+		p.curToken.Type != token.GT &&
+		p.curToken.Type != token.LT_EQ &&
+		p.curToken.Type != token.GT_EQ {
+		errMsg := fmt.Sprintf("READ: expected operator to be one of [EQ, NOT_EQ, EQ_FOLD, NOT_EQ_FOLD, LT, GT, LT_EQ, GT_EQ] got %s", p.curToken.Type)
 		p.addError(errMsg)
-		return ast.LocationExpression{
-			RowIndex: -1,
-			ColIndex: "",
-		}
+		return nil
 	}
 	filterExpr.Operator = p.curToken.Literal
 
 	p.nextToken()
 
-	if p.curToken.Type != token.STRING && p.curToken.Type != token.INT {
-		errMsg := fmt.Sprintf("READ: expected value to be either STRING or INT, got %s", p.curToken.Type)
+	if p.curToken.Type != token.STRING && p.curToken.Type != token.INT && p.curToken.Type != token.FLOAT && p.curToken.Type != token.NULL {
+		errMsg := fmt.Sprintf("READ: expected value to be one of STRING, INT, FLOAT or NULL, got %s", p.curToken.Type)
 		p.addError(errMsg)
-		return ast.LocationExpression{
-			RowIndex: -1,
-			ColIndex: "",
-		}
-	}
-	filterExpr.Value = p.parseExpression(LOWEST)
-
-	locExpr.Filter = &filterExpr
-
-	if p.isTerminator() {
-		p.nextToken()
+		return nil
 	}
+	// stop before AND/OR so they are treated as condition separators
+	filterExpr.Value = p.parseExpression(LOGICAL_AND)
 
-	return locExpr
+	return filterExpr
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
@@ -822,11 +1762,48 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.Token) {
-	msg := fmt.Sprintf("no prefix parse function for token type: %s and literal: %s", t.Type, t.Literal)
-	p.addError(msg)
+	var msg, hint string
+	switch {
+	case t.Type == token.NEWLINE || t.Type == token.SEMICOLON || t.Type == token.SINGLE_LINE_COMMENT:
+		msg = "unexpected end of statement"
+		hint = "the expression is incomplete, a value is missing here"
+	case t.Type == token.EOF:
+		msg = "unexpected end of input"
+		hint = "the expression is incomplete, a value is missing here"
+	case t.Type == token.RPAREN || t.Type == token.RBRACKET || t.Type == token.RBRACE:
+		msg = fmt.Sprintf("unexpected `%s`", t.Literal)
+		hint = "check for an unmatched bracket, or a missing value before it"
+	case token.LookupIdent(t.Literal) == t.Type:
+		msg = fmt.Sprintf("unexpected keyword `%s`", t.Literal)
+		hint = fmt.Sprintf("`%s` can't start an expression", t.Literal)
+	default:
+		msg = fmt.Sprintf("unexpected `%s`", t.Literal)
+		if _, ok := p.infixParseFns[t.Type]; ok {
+			hint = fmt.Sprintf("`%s` needs a value on its left", t.Literal)
+		}
+	}
+
+	// filenames with spaces or dashes, eg. load my-data.csv, have to be quoted
+	if p.prevToken.Type == token.LOAD || p.prevToken.Type == token.IMPORT || p.prevToken.Type == token.AS {
+		hint = fmt.Sprintf("did you mean to quote the filename? eg. %s \"my data.csv\"", p.prevToken.Literal)
+	}
+	p.addErrorAt(t, msg, hint)
 }
 
 // isTerminator checks if the token is a statement terminator
+// readEndsAtPeek checks if a read expression ends without a terminator, eg. at the end of the input or in sum(read row * col amount)
+func (p *Parser) readEndsAtPeek() bool {
+	return p.peekTokenIs(token.EOF) || p.peekTokenIs(token.RPAREN) || p.peekTokenIs(token.COMMA) ||
+		p.peekTokenIs(token.RBRACKET) || p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.PIPE)
+}
+
 func (p *Parser) isTerminator() bool {
 	return p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.NEWLINE)
 }
+
+// skipNewlines moves past the newlines following the current token, so bracketed lists can span several lines.
+func (p *Parser) skipNewlines() {
+	for p.peekTokenIs(token.NEWLINE) {
+		p.nextToken()
+	}
+}
@@ -1,20 +1,30 @@
 package parser
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/Rishabh570/csvlang/ast"
 	"github.com/Rishabh570/csvlang/lexer"
+	"github.com/Rishabh570/csvlang/token"
 )
 
 func TestLoadStatement(t *testing.T) {
 	tests := []struct {
-		input         string
-		expectedValue string
+		input          string
+		expectedValue  string
+		expectedTrim   bool
+		expectedLocale string
 	}{
-		{`LOAD input.csv`, "input.csv"},
-		{`load filename`, "filename"},
+		{`LOAD input.csv`, "input.csv", false, ""},
+		{`load filename`, "filename", false, ""},
+		{`load input.csv trim`, "input.csv", true, ""},
+		{`load input.csv TRIM`, "input.csv", true, ""},
+		{`load input.csv numbers "de"`, "input.csv", false, "de"},
+		{`load input.csv trim numbers "fr"`, "input.csv", true, "fr"},
+		{`load input.csv source_file trim`, "input.csv", true, ""},
 	}
 
 	for _, tt := range tests {
@@ -44,6 +54,275 @@ func TestLoadStatement(t *testing.T) {
 		default:
 			t.Errorf("type of exp not handled. got=%T", exp)
 		}
+		if stmt.Trim != tt.expectedTrim {
+			t.Errorf("stmt.Trim wrong for %q. expected=%t, got=%t", tt.input, tt.expectedTrim, stmt.Trim)
+		}
+		if stmt.NumberLocale != tt.expectedLocale {
+			t.Errorf("stmt.NumberLocale wrong for %q. expected=%q, got=%q", tt.input, tt.expectedLocale, stmt.NumberLocale)
+		}
+	}
+}
+
+func TestLoadFromDatabase(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedDatabase string
+		expectedString   string
+	}{
+		{`load pg "SELECT * FROM orders"`, "pg", `load pg SELECT * FROM orders`},
+		{`load mysql "SELECT id FROM users" trim`, "mysql", `load mysql SELECT id FROM users trim`},
+		{`load pg`, "", `load pg`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.LoadStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.LoadStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Database != tt.expectedDatabase {
+			t.Errorf("stmt.Database wrong for %q. expected=%q, got=%q", tt.input, tt.expectedDatabase, stmt.Database)
+		}
+		if stmt.String() != tt.expectedString {
+			t.Errorf("stmt.String() wrong for %q. expected=%q, got=%q", tt.input, tt.expectedString, stmt.String())
+		}
+	}
+}
+
+func TestLoadDelta(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedState string
+		expectedError string
+	}{
+		{`load delta of big.csv since state ".csvlang-state"`, ".csvlang-state", ""},
+		{`load delta of "logs/app.csv" trim since state "st.json"`, "st.json", ""},
+		{`load delta of big.csv`, "", "delta loads need a state file"},
+		{`load big.csv since state "st.json"`, "", "since state is only allowed in delta loads"},
+		{`load delta of big.csv since "st.json"`, "", "expected state after since, got STRING"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if tt.expectedError != "" {
+			if len(p.Errors) == 0 || p.Errors[0].Message != tt.expectedError {
+				t.Errorf("%q: expected error %q, got=%v", tt.input, tt.expectedError, p.Errors)
+			}
+			continue
+		}
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.LoadStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.LoadStatement. got=%T", program.Statements[0])
+		}
+		if stmt.StateFile != tt.expectedState {
+			t.Errorf("stmt.StateFile wrong for %q. expected=%q, got=%q", tt.input, tt.expectedState, stmt.StateFile)
+		}
+		if stmt.String() != strings.ReplaceAll(tt.input, `"logs/app.csv"`, "logs/app.csv") {
+			t.Errorf("stmt.String() wrong. expected=%q, got=%q", tt.input, stmt.String())
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		input         string
+		expected      string
+		expectedError string
+	}{
+		{`generate 1000 rows with (id: row_number, name: fake("name"), amount: rand_int(1, 500))`,
+			"generate 1000 rows with (id: row_number, name: fake(name), amount: rand_int(1, 500))", ""},
+		{"let rows = generate n * 2 rows with (\n  id: row_number,\n  \"full name\": \"x\"\n)",
+			"let rows = generate (n * 2) rows with (id: row_number, full name: x);", ""},
+		{`let generate = 1`, "let generate = 1;", ""},
+		{`generate 10 with (id: 1)`, "", "expected rows after the number of rows to generate, got IDENT"},
+		{`generate 10 rows (id: 1)`, "", "expected with after rows, got ("},
+		{`generate 10 rows with ()`, "", "expected column name, got )"},
+		{`generate 10 rows with (id: 1, id: 2)`, "", "duplicate column in generate: id"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if tt.expectedError != "" {
+			if len(p.Errors) == 0 || p.Errors[0].Message != tt.expectedError {
+				t.Errorf("%q: expected error %q, got=%v", tt.input, tt.expectedError, p.Errors)
+			}
+			continue
+		}
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		input         string
+		expected      string
+		expectedError string
+	}{
+		{`validate rows with (age: int > 0, email: matches(".+@.+"), name: required)`,
+			"validate rows with (age: (int > 0), email: matches(.+@.+), name: required)", ""},
+		{"let bad = validate csv with (\n  name: required and unique\n)", "let bad = validate csv with (name: (required and unique));", ""},
+		{`validate rows by (age: int)`, "", "expected with after rows, got IDENT"},
+		{`validate rows with (age: int, age: required)`, "", "duplicate column in validate: age"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if tt.expectedError != "" {
+			if len(p.Errors) == 0 || p.Errors[0].Message != tt.expectedError {
+				t.Errorf("%q: expected error %q, got=%v", tt.input, tt.expectedError, p.Errors)
+			}
+			continue
+		}
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		input         string
+		expected      string
+		expectedError string
+	}{
+		{`update csv set (status: "adult", age: row.age + 1) where age > 17 and city == "Oslo"`,
+			"update csv set (status: adult, age: (row.age + 1)) where (Column: age, Operator: >, Value: 17 and Column: city, Operator: ==, Value: Oslo)", ""},
+		{"let n = update people set (\n  status: null\n)", "let n = update people set (status: null);", ""},
+		{`update csv with (status: "adult")`, "", "expected set after csv, got IDENT"},
+		{`update csv set (a: 1, a: 2)`, "", "duplicate column in update: a"},
+		{`update csv set (a: 1) where a`, "", "READ: expected operator to be one of [EQ, NOT_EQ, EQ_FOLD, NOT_EQ_FOLD, LT, GT, LT_EQ, GT_EQ] got EOF"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if tt.expectedError != "" {
+			if len(p.Errors) == 0 || p.Errors[0].Message != tt.expectedError {
+				t.Errorf("%q: expected error %q, got=%v", tt.input, tt.expectedError, p.Errors)
+			}
+			continue
+		}
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestLoadOptions(t *testing.T) {
+	tests := []struct {
+		input         string
+		expected      string
+		expectedError string
+	}{
+		{`load data.csv quarantine bad_rows`, "load data.csv quarantine bad_rows", ""},
+		{`load data.csv trim quarantine bad_rows to "rejected.csv"`, `load data.csv trim quarantine bad_rows to "rejected.csv"`, ""},
+		{`load data.csv quarantine bad_rows to rejected.csv`, `load data.csv quarantine bad_rows to "rejected.csv"`, ""},
+		{`load data.csv quarantine "bad"`, "", "expected next token to be IDENT, got STRING instead"},
+		{`load data.csv quarantine bad_rows to 1`, "", "expected the file to quarantine rows to, got INT"},
+		{`load data.csv na values ["NA", "", "-"]`, `load data.csv na values ["NA", "", "-"]`, ""},
+		{`load data.csv trim na values ["NA"] quarantine bad`, `load data.csv trim quarantine bad na values ["NA"]`, ""},
+		{`load data.csv na ["NA"]`, "", "expected values after na, got ["},
+		{`load data.csv na values ["NA", 0]`, "", "na values must be strings, got 0"},
+		{`load data.csv readonly`, "load data.csv readonly", ""},
+		{`load big.csv readonly mmap`, "load big.csv readonly mmap", ""},
+		{`load big.csv mmap readonly`, "load big.csv readonly mmap", ""},
+		{`load big.csv mmap`, "", "only readonly files can be mapped"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if tt.expectedError != "" {
+			if len(p.Errors) == 0 || p.Errors[0].Message != tt.expectedError {
+				t.Errorf("%q: expected error %q, got=%v", tt.input, tt.expectedError, p.Errors)
+			}
+			continue
+		}
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	input := "const THRESHOLD = 18;"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	letStmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+	if letStmt.Name.Value != "THRESHOLD" {
+		t.Errorf("letStmt.Name.Value not 'THRESHOLD'. got=%s", letStmt.Name.Value)
+	}
+	if letStmt.Token.Type != token.CONST {
+		t.Errorf("letStmt.Token.Type not CONST. got=%s", letStmt.Token.Type)
+	}
+	if letStmt.String() != "const THRESHOLD = 18;" {
+		t.Errorf("letStmt.String() wrong. got=%q", letStmt.String())
+	}
+}
+
+func TestImportStatement(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedPath  string
+		expectedAlias string
+	}{
+		{`import "lib/cleaning.cl"`, "lib/cleaning.cl", ""},
+		{`import lib/cleaning.cl`, "lib/cleaning.cl", ""},
+		{`IMPORT "lib/cleaning.cl" as cleaning;`, "lib/cleaning.cl", "cleaning"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ImportStatement. got=%T",
+				program.Statements[0])
+		}
+
+		if stmt.Path.String() != tt.expectedPath {
+			t.Errorf("path wrong. expected=%q, got=%q", tt.expectedPath, stmt.Path.String())
+		}
+
+		alias := ""
+		if stmt.Alias != nil {
+			alias = stmt.Alias.Value
+		}
+		if alias != tt.expectedAlias {
+			t.Errorf("alias wrong. expected=%q, got=%q", tt.expectedAlias, alias)
+		}
 	}
 }
 
@@ -79,6 +358,28 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestCompoundAssignmentParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"total += 5", "total += 5"},
+		{"total -= a * 2", "total -= (a * 2)"},
+		{"total *= 2", "total *= 2"},
+		{"total /= 2", "total /= 2"},
+		{"arr[0] += 1", "(arr[0]) += 1"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	input := `
 	return 5;
@@ -183,6 +484,19 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestUnterminatedStringError(t *testing.T) {
+	l := lexer.New(`let name = "Bob`)
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors) == 0 {
+		t.Fatalf("expected a parser error for an unterminated string")
+	}
+	if p.Errors[0].Message != `unterminated string: "Bob` {
+		t.Errorf("wrong error message. got=%q", p.Errors[0].Message)
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input        string
@@ -234,10 +548,14 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 - 5;", 5, "-", 5},
 		{"5 * 5;", 5, "*", 5},
 		{"5 / 5;", 5, "/", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"5 ** 5;", 5, "**", 5},
 		{"5 > 5;", 5, ">", 5},
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
@@ -299,6 +617,22 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"a * b / c",
 			"((a * b) / c)",
 		},
+		{
+			"a + b % c",
+			"(a + (b % c))",
+		},
+		{
+			"a * b ** c",
+			"(a * (b ** c))",
+		},
+		{
+			"a ** b ** c",
+			"(a ** (b ** c))",
+		},
+		{
+			"-a ** b",
+			"(-(a ** b))",
+		},
 		{
 			"a + b / c",
 			"(a + (b / c))",
@@ -383,6 +717,18 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a[1:b + 1]",
+			"(a[1:(b + 1)])",
+		},
+		{
+			"a[:2]",
+			"(a[:2])",
+		},
+		{
+			"a[-3:]",
+			"(a[(-3):])",
+		},
 	}
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
@@ -487,6 +833,75 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		param    string
+		expected string
+	}{
+		{"try { load extra.csv } catch (e) { e }", "e", "try load extra.csv catch (e) e"},
+		{"try { x / 0 } catch { 0 }", "", "try (x / 0) catch 0"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+		exp, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.TryExpression. got=%T", stmt.Expression)
+		}
+		if tt.param == "" && exp.Param != nil {
+			t.Errorf("expected no catch parameter. got=%s", exp.Param)
+		}
+		if tt.param != "" && !testIdentifier(t, exp.Param, tt.param) {
+			return
+		}
+		if exp.String() != tt.expected {
+			t.Errorf("exp.String() wrong. expected=%q, got=%q", tt.expected, exp.String())
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match (status) {
+		"ok" => 1
+		"failed", "error" => 2; _ => 0
+	}`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	exp, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MatchExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Subject, "status") {
+		return
+	}
+	if len(exp.Arms) != 3 {
+		t.Fatalf("match has wrong number of arms. got=%d", len(exp.Arms))
+	}
+	if len(exp.Arms[1].Patterns) != 2 {
+		t.Errorf("second arm has wrong number of patterns. got=%d", len(exp.Arms[1].Patterns))
+	}
+	if len(exp.Arms[2].Patterns) != 0 {
+		t.Errorf("default arm should not have patterns. got=%d", len(exp.Arms[2].Patterns))
+	}
+	testIntegerLiteral(t, exp.Arms[2].Value, 0)
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 	l := lexer.New(input)
@@ -527,6 +942,67 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestFunctionStatementParsing(t *testing.T) {
+	input := `fn add(x, y) { x + y; }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.FunctionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.FunctionStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Name.Value != "add" {
+		t.Fatalf("function name wrong. want add, got=%s", stmt.Name.Value)
+	}
+	if len(stmt.Function.Parameters) != 2 {
+		t.Fatalf("function parameters wrong. want 2, got=%d\n",
+			len(stmt.Function.Parameters))
+	}
+
+	testLiteralExpression(t, stmt.Function.Parameters[0], "x")
+	testLiteralExpression(t, stmt.Function.Parameters[1], "y")
+
+	if stmt.String() != "fn add(x, y) (x + y)" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+func TestFunctionDefaultAndVariadicParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(value, factor = 100) { value }", "fn(value, factor = 100) value"},
+		{"fn(a = 1, b = a + 1) { b }", "fn(a = 1, b = (a + 1)) b"},
+		{"fn(...parts) { parts }", "fn(...parts) parts"},
+		{"fn(sep, limit = 3, ...parts) { parts }", "fn(sep, limit = 3, ...parts) parts"},
+		{"fn join(sep = \",\", ...parts) { parts }", "fn join(sep = ,, ...parts) parts"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	l := lexer.New("fn(a = 1, b) { b }")
+	p := New(l)
+	p.ParseProgram()
+	if len(p.Errors) == 0 {
+		t.Errorf("expected error for parameter without default after default")
+	}
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -607,6 +1083,417 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+// a read ends without a terminator at the end of the input or a closing delimiter, eg. as a call argument
+func TestReadColumnWithoutTerminator(t *testing.T) {
+	inputs := []string{
+		"read row * col amount",
+		"read row 1 col 0",
+		"sum(read row * col amount)",
+		"zip(read row * col name, read row *)",
+	}
+	for _, input := range inputs {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: expected 1 statement. got=%d", input, len(program.Statements))
+		}
+	}
+}
+
+func TestReadWhereOperators(t *testing.T) {
+	for _, operator := range []string{"==", "!=", "<", ">", "<=", ">="} {
+		input := fmt.Sprintf("read row * where age %s 18", operator)
+		l := lexer.New(input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ReadStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ReadStatement. got=%T", program.Statements[0])
+		}
+		filter, ok := stmt.Location.Filter.(*ast.ReadFilterExpression)
+		if !ok {
+			t.Fatalf("stmt.Location.Filter is not ast.ReadFilterExpression. got=%T", stmt.Location.Filter)
+		}
+		if filter.ColumnName != "age" || filter.Operator != operator {
+			t.Errorf("wrong filter. want=age %s, got=%s %s", operator, filter.ColumnName, filter.Operator)
+		}
+		testIntegerLiteral(t, filter.Value, 18)
+	}
+}
+
+func TestPipeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"rows |> unique", "(rows |> unique)"},
+		{`rows |> fill_empty("name", "-") |> unique()`, `((rows |> fill_empty(name, -)) |> unique())`},
+		{"a + 1 |> f(2)", "((a + 1) |> f(2))"},
+		{"x == 1 or y |> f", "(((x == 1) or y) |> f)"},
+		{`load in.csv |> save("out.csv")`, "(load in.csv |> save(out.csv))"},
+		{`load in.csv trim |> unique`, "(load in.csv trim |> unique)"},
+		{`read row * col name |> unique`, "(read Row: -2, Column: name |> unique)"},
+		{`let names = read row * |> select(["name"])`, "let names = (read Row: -2, Column:  |> select([name]));"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: expected 1 statement. got=%d", tt.input, len(program.Statements))
+		}
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestMethodCallExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`csv.filter(f).sort("age").head(10)`, "csv.filter(f).sort(age).head(10)"},
+		{"rows[0].keys()", "(rows[0]).keys()"},
+		{"f(x).g() + 1", "(f(x).g() + 1)"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+
+	p := New(lexer.New("f(x).head"))
+	p.ParseProgram()
+	if len(p.Errors) == 0 {
+		t.Errorf("expected an error for a method without arguments")
+	}
+}
+
+func TestReadFromSource(t *testing.T) {
+	input := `read from sales row * col amount where region == "EU"`
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ReadStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ReadStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Source == nil || stmt.Source.Value != "sales" {
+		t.Fatalf("stmt.Source wrong. got=%+v", stmt.Source)
+	}
+	if stmt.Location.RowIndex != -2 || stmt.Location.ColIndex != "amount" {
+		t.Errorf("stmt.Location wrong. got=%+v", stmt.Location)
+	}
+
+	p = New(lexer.New("read from row *"))
+	p.ParseProgram()
+	if len(p.Errors) == 0 {
+		t.Errorf("expected an error for a read from without a source")
+	}
+}
+
+func TestSaveStatements(t *testing.T) {
+	tests := []struct {
+		input     string
+		source    string
+		splitBy   string
+		filenames []string
+	}{
+		{"save as out.csv", "", "", []string{"out.csv"}},
+		{"save rows as out.csv, out.json", "rows", "", []string{"out.csv", "out.json"}},
+		{`save rows split by region as "out_{region}.csv"`, "rows", "region", []string{"out_{region}.csv"}},
+		{`save split by region as "a_{region}.csv", "b_{region}.json"`, "", "region", []string{"a_{region}.csv", "b_{region}.json"}},
+		{"save split as out.json", "split", "", []string{"out.json"}},
+		{"save rows as clipboard", "rows", "", []string{"clipboard"}},
+		{`save rows as "gsheet://1AbC/Q3 Sales", rows.csv`, "rows", "", []string{"gsheet://1AbC/Q3 Sales", "rows.csv"}},
+		{`save rows as "{output}"`, "rows", "", []string{"{output}"}},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.SaveStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.SaveStatement. got=%T", program.Statements[0])
+		}
+		source := ""
+		if stmt.Source != nil {
+			source = stmt.Source.String()
+		}
+		if source != tt.source || stmt.SplitBy != tt.splitBy {
+			t.Errorf("%q: source or split wrong. got=%q, %q", tt.input, source, stmt.SplitBy)
+		}
+		if strings.Join(stmt.Filenames, " ") != strings.Join(tt.filenames, " ") || stmt.Filename != tt.filenames[0] {
+			t.Errorf("%q: filenames wrong. got=%v", tt.input, stmt.Filenames)
+		}
+	}
+
+	for _, input := range []string{"save rows as out.txt", "save rows split region as out.csv", "save rows as out.csv,", `save rows as "clipboard"`} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors) == 0 {
+			t.Errorf("%q: expected a parser error", input)
+		}
+	}
+}
+
+func TestSaveFrom(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"save from big.csv as copy.csv", "save from big.csv as copy.csv"},
+		{`save from "big.csv" where status == "failed" and code >= 500 as failed.csv, "all failed.csv"`, `save from big.csv where (Column: status, Operator: ==, Value: failed and Column: code, Operator: >=, Value: 500) as failed.csv, all failed.csv`},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got := program.Statements[0].String(); got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	for _, input := range []string{"save from 5 as out.csv", "save from big.csv where as out.csv", "save from big.csv"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors) == 0 {
+			t.Errorf("%q: expected a parser error", input)
+		}
+	}
+}
+
+func TestUnload(t *testing.T) {
+	p := New(lexer.New("unload csv, rows\nlet unload = 1"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.UnloadStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.UnloadStatement. got=%T", program.Statements[0])
+	}
+	if stmt.String() != "unload csv, rows" {
+		t.Errorf("wrong statement. got=%q", stmt.String())
+	}
+	if _, ok := program.Statements[1].(*ast.LetStatement); !ok {
+		t.Errorf("unload should only be a keyword before a variable. got=%T", program.Statements[1])
+	}
+}
+
+func TestSaveBundle(t *testing.T) {
+	input := "save {summary: stats,\n  \"detail.json\": read row * where age > 20,\n} as report/"
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.SaveStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.SaveStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Filename != "report/" || len(stmt.Bundle) != 2 {
+		t.Fatalf("bundle wrong. got=%s", stmt.String())
+	}
+	if stmt.Bundle[0].Name != "summary.csv" || stmt.Bundle[1].Name != "detail.json" {
+		t.Errorf("bundle names wrong. got=%q, %q", stmt.Bundle[0].Name, stmt.Bundle[1].Name)
+	}
+
+	for _, input := range []string{"save {} as report/", "save {summary stats} as report/", "save {summary: stats}"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors) == 0 {
+			t.Errorf("%q: expected a parser error", input)
+		}
+	}
+}
+
+func TestStatementTermination(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1;\n;\nlet y = 2;;", "let x = 1;let y = 2;"},
+		{"let x = 1; let y = 2", "let x = 1;let y = 2;"},
+		{"let x = 1 +\n  2", "let x = (1 + 2);"},
+		{"let x = 1 + # one more\n  2", "let x = (1 + 2);"},
+		{"let a = [\n  1,\n  2\n]", "let a = [1, 2];"},
+		{"print(1,\n  2\n)", "print(1, 2)"},
+		{"x = x &&\n  y", "x = (x && y)"},
+		{"load data.csv\n  |> unique()\n  |> save(\"out.csv\")", "((load data.csv |> unique()) |> save(out.csv))"},
+		{"if (x > 1) {\n  1\n}\nelse {\n  2\n}", "if(x > 1) 1else 2"},
+		{"let x = 1 # comment\nlet y = 2", "let x = 1;let y = 2;"},
+		{"read row *\nlet x = 1", "read Row: -2, Column: let x = 1;"},
+		{"let r = read row *\n  col name\n  where age > 1", "let r = read Row: -2, Column: name;"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("let x = 1 let y = 2\nlet z = 3"))
+	program := p.ParseProgram()
+	if len(p.Errors) != 1 || p.Errors[0].Message != "expected newline or ; after statement, got LET" {
+		t.Errorf("statements on one line must be separated. got=%v", p.Errors)
+	}
+	if len(program.Statements) != 2 || program.Statements[1].String() != "let z = 3;" {
+		t.Errorf("parsing should resume on the next line. got=%q", program.String())
+	}
+}
+
+func TestErrorDiagnostics(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+		line    int
+		column  int
+		hint    string
+	}{
+		{"let x = 1\nload *.csv", "unexpected `*`", 2, 6, `did you mean to quote the filename? eg. load "my data.csv"`},
+		{"load my data.csv", "expected newline or ; after statement, got IDENT", 1, 9, `did you mean to quote the filename? eg. load "my data.csv"`},
+		{"let x = (1 + )", "unexpected `)`", 1, 14, "check for an unmatched bracket, or a missing value before it"},
+		{"let y = 2 +", "unexpected end of input", 1, 12, "the expression is incomplete, a value is missing here"},
+		{"let x = else", "unexpected keyword `else`", 1, 9, "`else` can't start an expression"},
+		{"let x = * 2", "unexpected `*`", 1, 9, "`*` needs a value on its left"},
+		{"let z = [1, 2", "expected next token to be ], got EOF instead", 1, 14, ""},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors) == 0 {
+			t.Errorf("%q: expected a parser error", tt.input)
+			continue
+		}
+		err := p.Errors[0]
+		if err.Message != tt.message || err.Line != tt.line || err.Column != tt.column || err.Hint != tt.hint {
+			t.Errorf("%q: wrong error. got=%q at %d:%d, hint=%q", tt.input, err.Message, err.Line, err.Column, err.Hint)
+		}
+	}
+
+	p := New(lexer.New("let x = 1\n\tload *.csv"))
+	p.ParseProgram()
+	expected := "unexpected `*` at line 2, column 7\n  2 | \tload *.csv\n    | \t     ^\nhint: did you mean to quote the filename? eg. load \"my data.csv\""
+	if len(p.Errors) != 1 || p.Errors[0].Diagnostic() != expected {
+		t.Errorf("wrong diagnostic. expected=\n%s\ngot=\n%v", expected, p.Errors)
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := "let x = 1\n  total + price * 2\nrows |> unique()\nf(a)[0]"
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	infix := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	pipe := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.PipeExpression)
+	index := program.Statements[3].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression)
+
+	tests := []struct {
+		node     ast.Node
+		expected token.Position
+	}{
+		{program, token.Position{Line: 1, Column: 1}},
+		{let, token.Position{Line: 1, Column: 1}},
+		{let.Value, token.Position{Line: 1, Column: 9}},
+		{infix, token.Position{Line: 2, Column: 3}},
+		{infix.Right, token.Position{Line: 2, Column: 11}},
+		{pipe, token.Position{Line: 3, Column: 1}},
+		{pipe.Right, token.Position{Line: 3, Column: 9}},
+		{index, token.Position{Line: 4, Column: 1}},
+		{index.Index, token.Position{Line: 4, Column: 6}},
+	}
+	for _, tt := range tests {
+		if tt.node.Pos() != tt.expected {
+			t.Errorf("%q: wrong position. expected=%s, got=%s", tt.node.String(), tt.expected, tt.node.Pos())
+		}
+	}
+}
+
+func TestReadWhereLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`read row * where age > 18 and name == "Bob"`, `(age > 18 and name == "Bob")`},
+		{`read row * where age > 18 AND age < 30 or name == "Bob"`, `((age > 18 and age < 30) or name == "Bob")`},
+		{`read row * where age > 18 and (age < 30 || name == "Bob")`, `(age > 18 and (age < 30 || name == "Bob"))`},
+		{`read row * where not (status == "ok")`, `(not status == "ok")`},
+		{`read row * where !status == "ok" && age != 5`, `((! status == "ok") && age != 5)`},
+		{`read row * where price > 9.99 and price < 20`, `(price > 9.99 and price < 20)`},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ReadStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ReadStatement. got=%T", program.Statements[0])
+		}
+		actual := filterString(stmt.Location.Filter)
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestLogicalOperatorParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a and b or c", "((a and b) or c)"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a == b and c < d", "((a == b) and (c < d))"},
+		{"not a == b", "(not (a == b))"},
+		{"not a and b", "((not a) and b)"},
+		{"!a && b", "((!a) && b)"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+// filterString renders a where clause condition tree in a compact form for assertions
+func filterString(exp ast.Expression) string {
+	switch exp := exp.(type) {
+	case *ast.ReadFilterExpression:
+		value := exp.Value.String()
+		if _, ok := exp.Value.(*ast.StringLiteral); ok {
+			value = fmt.Sprintf("%q", value)
+		}
+		return fmt.Sprintf("%s %s %s", exp.ColumnName, exp.Operator, value)
+	case *ast.InfixExpression:
+		return fmt.Sprintf("(%s %s %s)", filterString(exp.Left), exp.Operator, filterString(exp.Right))
+	case *ast.PrefixExpression:
+		return fmt.Sprintf("(%s %s)", exp.Operator, filterString(exp.Right))
+	default:
+		return ""
+	}
+}
+
 /*
 *
 Helper fns
@@ -738,3 +1625,136 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	}
 	t.FailNow()
 }
+
+func TestProgramJSONRoundTrip(t *testing.T) {
+	input := `load "people.csv"
+let adults = read row * where age > 17 && city == "Oslo"
+fn double(x) { return x * 2 }
+let names = ["a", "b"][0:1]
+if (len(adults) > 0) { print(double(2.5)) } else { print(null) }
+for i, row in adults { print(-i) }
+let rows = try { read row 1 col name } catch (err) { print(err) }
+adults |> unique() |> save("adults.csv")
+save adults split by city as "out/{city}.csv"
+save { people: adults } as "reports"
+let fixtures = generate 10 rows with (id: row_number, name: fake("name"))
+let violations = validate fixtures with (id: int > 0 and unique, name: required)
+save from "big.csv" where status == "failed" as failed.csv
+let changed = update fixtures set (name: "x") where id > 5
+unload fixtures, violations`
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	data, err := ast.ProgramToJSON(program)
+	if err != nil {
+		t.Fatalf("ProgramToJSON failed: %s", err)
+	}
+	decoded, err := ast.ProgramFromJSON(data)
+	if err != nil {
+		t.Fatalf("ProgramFromJSON failed: %s", err)
+	}
+
+	if decoded.String() != program.String() {
+		t.Errorf("program changed.\nexpected=%q\ngot=%q", program.String(), decoded.String())
+	}
+	for i, stmt := range program.Statements {
+		if decoded.Statements[i].Pos() != stmt.Pos() {
+			t.Errorf("statement %d: wrong position. expected=%s, got=%s", i, stmt.Pos(), decoded.Statements[i].Pos())
+		}
+	}
+}
+
+func TestParseSQL(t *testing.T) {
+	tests := []struct {
+		sql    string
+		script string
+	}{
+		{"SELECT * FROM load('people.csv') LIMIT 5", "load \"people.csv\"\nread row * |> head(5)"},
+		{"select name, age from load('people.csv') where age > 25 and city = 'Oslo'",
+			"load \"people.csv\"\nread row * where age > 25 and city == \"Oslo\" |> select([\"name\", \"age\"])"},
+		{"SELECT * FROM sales WHERE NOT (total <> 1.5 OR total <= 2) ORDER BY total DESC LIMIT 3",
+			"read from sales row * where not (total != 1.5 or total <= 2) |> sort(\"total\", \"desc\") |> head(3)"},
+		{"SELECT \"unit price\" FROM csv WHERE email IS NULL OR name IS NOT NULL ORDER BY name ASC",
+			"read from csv row * where email == \"\" or name != \"\" |> sort(\"name\") |> select([\"unit price\"])"},
+		{"SELECT name FROM a; -- names\nSELECT * FROM b WHERE name = 'O''Brien' LIMIT 1;",
+			"read from a row * |> select([\"name\"])\nread from b row * where name == \"O'Brien\" |> head(1)"},
+	}
+	for _, tt := range tests {
+		program, errors := ParseSQL(tt.sql)
+		if len(errors) != 0 {
+			t.Errorf("%q: parser errors: %v", tt.sql, errors)
+			continue
+		}
+		p := New(lexer.New(tt.script))
+		expected := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got, want := astWithoutTokens(t, program), astWithoutTokens(t, expected); got != want {
+			t.Errorf("%q: wrong AST.\nexpected=%s\ngot=%s", tt.sql, want, got)
+		}
+	}
+}
+
+// astWithoutTokens returns the JSON of a program without its tokens, so programs written differently can be compared
+func astWithoutTokens(t *testing.T, program *ast.Program) string {
+	t.Helper()
+	data, err := ast.ProgramToJSON(program)
+	if err != nil {
+		t.Fatalf("ProgramToJSON failed: %s", err)
+	}
+	var decoded interface{}
+	json.Unmarshal(data, &decoded)
+	var strip func(value interface{})
+	strip = func(value interface{}) {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			delete(value, "token")
+			for _, v := range value {
+				strip(v)
+			}
+		case []interface{}:
+			for _, v := range value {
+				strip(v)
+			}
+		}
+	}
+	strip(decoded)
+	stripped, _ := json.Marshal(decoded)
+	return string(stripped)
+}
+
+func TestParseSQLErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+		line    int
+		column  int
+	}{
+		{"UPDATE people SET age = 1", "SQL: expected SELECT, got `UPDATE`", 1, 1},
+		{"SELECT name age FROM people", "SQL: expected FROM, got `age`", 1, 13},
+		{"SELECT * FROM load(people.csv)", "SQL: expected STRING, got `people`", 1, 20},
+		{"SELECT *\nFROM people WHERE city = Oslo", "SQL: expected a string or a number, got `Oslo`", 2, 26},
+		{"SELECT * FROM people WHERE age = NULL", "SQL: expected a string or a number, got `NULL`", 1, 34},
+		{"SELECT * FROM people LIMIT 1 WHERE age > 1", "SQL: expected ; or the end of the query, got `WHERE`", 1, 30},
+		{"SELECT * FROM people ORDER BY age, name", "SQL: ORDER BY supports a single column", 1, 34},
+		{"SELECT * FROM people WHERE name = 'Bob", "SQL: unterminated string", 1, 35},
+	}
+	for _, tt := range tests {
+		_, errors := ParseSQL(tt.input)
+		if len(errors) == 0 {
+			t.Errorf("%q: expected a parser error", tt.input)
+			continue
+		}
+		err := errors[0]
+		if err.Message != tt.message || err.Line != tt.line || err.Column != tt.column {
+			t.Errorf("%q: wrong error. got=%q at %d:%d", tt.input, err.Message, err.Line, err.Column)
+		}
+	}
+
+	// an error skips to the next query
+	program, errors := ParseSQL("SELECT FROM a; SELECT * FROM b")
+	if len(errors) != 1 || len(program.Statements) != 1 {
+		t.Errorf("expected 1 error and 1 statement. got=%v, %d statements", errors, len(program.Statements))
+	}
+}
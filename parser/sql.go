@@ -0,0 +1,507 @@
+package parser
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/token"
+)
+
+// sqlName is a column or variable name written in double quotes, eg. "unit price", it is never a keyword
+const sqlName token.TokenType = "NAME"
+
+// sqlKeywords are the reserved words of the SQL dialect, matched case-insensitively
+var sqlKeywords = map[string]bool{
+	"select": true, "from": true, "where": true, "order": true, "by": true, "asc": true, "desc": true,
+	"limit": true, "and": true, "or": true, "not": true, "is": true, "null": true, "load": true,
+}
+
+// sqlParser compiles queries to the AST of the equivalent csvlang statements
+type sqlParser struct {
+	lines  []string
+	tokens []token.Token
+	pos    int
+	Errors []*ParserError
+}
+
+// ParseSQL parses queries written in a constrained SQL dialect, separated by semicolons:
+//
+//	SELECT <* | column, ...> FROM <load('file.csv') | variable>
+//	  [WHERE conditions] [ORDER BY column [ASC | DESC]] [LIMIT n]
+//
+// Conditions compare a column to a literal with =, <>, !=, <, >, <= or >=, or test if its cell is empty with IS [NOT] NULL,
+// and are combined with AND, OR, NOT and parentheses. Strings are in single quotes, names with spaces in double quotes.
+//
+// A query compiles to the statements a csvlang script would use, so it runs on the same evaluator:
+//
+//	SELECT name, age FROM load('people.csv') WHERE age > 25 ORDER BY age DESC LIMIT 10
+//
+// is the same as
+//
+//	load "people.csv"
+//	read row * where age > 25 |> sort("age", "desc") |> head(10) |> select(["name", "age"])
+//
+// The query itself is always an ast.ExpressionStatement, a load before it is a separate statement.
+func ParseSQL(input string) (*ast.Program, []*ParserError) {
+	p := &sqlParser{lines: strings.Split(input, "\n")}
+	p.scan(input)
+
+	program := &ast.Program{Statements: []ast.Statement{}}
+	for !p.curIs(token.EOF) {
+		if p.curIs(token.SEMICOLON) {
+			p.next()
+			continue
+		}
+		errors := len(p.Errors)
+		statements := p.parseQuery()
+		if len(p.Errors) > errors {
+			// skip to the next query, the rest of this one would only produce more errors
+			for !p.curIs(token.SEMICOLON) && !p.curIs(token.EOF) {
+				p.next()
+			}
+			continue
+		}
+		program.Statements = append(program.Statements, statements...)
+	}
+	return program, p.Errors
+}
+
+func (p *sqlParser) cur() token.Token {
+	return p.tokens[p.pos]
+}
+
+func (p *sqlParser) curIs(t token.TokenType) bool {
+	return p.cur().Type == t
+}
+
+func (p *sqlParser) next() {
+	if p.pos < len(p.tokens)-1 {
+		p.pos++
+	}
+}
+
+// curIsKeyword checks if the current token is an unquoted keyword, eg. curIsKeyword("where")
+func (p *sqlParser) curIsKeyword(keyword string) bool {
+	return p.curIs(token.IDENT) && strings.ToLower(p.cur().Literal) == keyword
+}
+
+// curIsName checks if the current token names a column or a variable
+func (p *sqlParser) curIsName() bool {
+	return p.curIs(sqlName) || p.curIs(token.IDENT) && !sqlKeywords[strings.ToLower(p.cur().Literal)]
+}
+
+func (p *sqlParser) addError(tok token.Token, msg, hint string) {
+	source := ""
+	if tok.Pos.Line >= 1 && tok.Pos.Line <= len(p.lines) {
+		source = strings.TrimRight(p.lines[tok.Pos.Line-1], "\r")
+	}
+	stack := make([]uintptr, 50)
+	length := runtime.Callers(2, stack[:])
+	p.Errors = append(p.Errors, &ParserError{Message: msg, Stack: stack[:length], Line: tok.Pos.Line, Column: tok.Pos.Column, Source: source, Hint: hint})
+}
+
+// expected records that the current token isn't what the query needs at this point
+func (p *sqlParser) expected(what, hint string) {
+	got := fmt.Sprintf("`%s`", p.cur().Literal)
+	if p.curIs(token.EOF) {
+		got = "end of input"
+	}
+	p.addError(p.cur(), fmt.Sprintf("SQL: expected %s, got %s", what, got), hint)
+}
+
+// expectKeyword moves past a keyword, or records an error if the current token isn't it
+func (p *sqlParser) expectKeyword(keyword, hint string) bool {
+	if !p.curIsKeyword(keyword) {
+		p.expected(strings.ToUpper(keyword), hint)
+		return false
+	}
+	p.next()
+	return true
+}
+
+func (p *sqlParser) expect(t token.TokenType) (token.Token, bool) {
+	tok := p.cur()
+	if !p.curIs(t) {
+		p.expected(string(t), "")
+		return tok, false
+	}
+	p.next()
+	return tok, true
+}
+
+func (p *sqlParser) parseQuery() []ast.Statement {
+	selectTok := p.cur()
+	if !p.expectKeyword("select", "only SELECT queries are supported") {
+		return nil
+	}
+
+	// the projected columns, nil for *
+	var columns []token.Token
+	if p.curIs(token.ASTERISK) {
+		p.next()
+	} else {
+		for {
+			if !p.curIsName() {
+				p.expected("a column name or *", "")
+				return nil
+			}
+			columns = append(columns, p.cur())
+			p.next()
+			if !p.curIs(token.COMMA) {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if !p.expectKeyword("from", "eg. SELECT * FROM load('data.csv')") {
+		return nil
+	}
+	statements := []ast.Statement{}
+	read := &ast.ReadExpression{
+		Token:    token.Token{Type: token.READ, Literal: "read", Pos: selectTok.Pos},
+		Location: ast.LocationExpression{RowIndex: -2}, // every row, as for read row *
+	}
+	switch {
+	case p.curIsKeyword("load"):
+		load := p.parseLoad()
+		if load == nil {
+			return nil
+		}
+		statements = append(statements, load)
+	case p.curIsName():
+		read.Source = &ast.Identifier{Token: p.cur(), Value: p.cur().Literal}
+		p.next()
+	default:
+		p.expected("load('file.csv') or a variable", "")
+		return nil
+	}
+
+	if p.curIsKeyword("where") {
+		p.next()
+		filter := p.parseOr()
+		if filter == nil {
+			return nil
+		}
+		read.Location.Filter = filter
+	}
+
+	var expr ast.Expression = read
+	if p.curIsKeyword("order") {
+		orderTok := p.cur()
+		p.next()
+		if !p.expectKeyword("by", "") {
+			return nil
+		}
+		if !p.curIsName() {
+			p.expected("a column name", "")
+			return nil
+		}
+		arguments := []ast.Expression{stringLiteral(p.cur(), p.cur().Literal)}
+		p.next()
+		if p.curIsKeyword("desc") {
+			arguments = append(arguments, stringLiteral(p.cur(), "desc"))
+			p.next()
+		} else if p.curIsKeyword("asc") {
+			p.next()
+		}
+		if p.curIs(token.COMMA) {
+			p.addError(p.cur(), "SQL: ORDER BY supports a single column", "")
+			return nil
+		}
+		expr = pipeCall(orderTok, expr, "sort", arguments...)
+	}
+
+	if p.curIsKeyword("limit") {
+		limitTok := p.cur()
+		p.next()
+		countTok, ok := p.expect(token.INT)
+		if !ok {
+			return nil
+		}
+		count, err := strconv.ParseInt(countTok.Literal, 10, 64)
+		if err != nil {
+			p.addError(countTok, fmt.Sprintf("SQL: invalid LIMIT %s", countTok.Literal), "")
+			return nil
+		}
+		expr = pipeCall(limitTok, expr, "head", &ast.IntegerLiteral{Token: countTok, Value: count})
+	}
+
+	// columns are selected last, so rows can be sorted by a column that isn't selected
+	if columns != nil {
+		names := &ast.ArrayLiteral{Token: token.Token{Type: token.LBRACKET, Literal: "[", Pos: columns[0].Pos}}
+		for _, column := range columns {
+			names.Elements = append(names.Elements, stringLiteral(column, column.Literal))
+		}
+		expr = pipeCall(selectTok, expr, "select", names)
+	}
+
+	if !p.curIs(token.SEMICOLON) && !p.curIs(token.EOF) {
+		p.expected("; or the end of the query", "queries end after WHERE, ORDER BY and LIMIT, in that order")
+		return nil
+	}
+	return append(statements, &ast.ExpressionStatement{Token: read.Token, Expression: expr})
+}
+
+// parseLoad parses load('file.csv') as a load statement
+func (p *sqlParser) parseLoad() *ast.LoadStatement {
+	load := &ast.LoadStatement{Token: token.Token{Type: token.LOAD, Literal: "load", Pos: p.cur().Pos}}
+	p.next()
+	if _, ok := p.expect(token.LPAREN); !ok {
+		return nil
+	}
+	filename, ok := p.expect(token.STRING)
+	if !ok {
+		return nil
+	}
+	load.Filename = stringLiteral(filename, filename.Literal)
+	if _, ok := p.expect(token.RPAREN); !ok {
+		return nil
+	}
+	return load
+}
+
+// parseOr parses conditions joined by OR, which binds looser than AND like in where clauses of read
+func (p *sqlParser) parseOr() ast.Expression {
+	left := p.parseAnd()
+	for left != nil && p.curIsKeyword("or") {
+		exp := &ast.InfixExpression{Token: token.Token{Type: token.OR, Literal: "or", Pos: p.cur().Pos}, Left: left, Operator: "or"}
+		p.next()
+		if exp.Right = p.parseAnd(); exp.Right == nil {
+			return nil
+		}
+		left = exp
+	}
+	return left
+}
+
+func (p *sqlParser) parseAnd() ast.Expression {
+	left := p.parseUnary()
+	for left != nil && p.curIsKeyword("and") {
+		exp := &ast.InfixExpression{Token: token.Token{Type: token.AND, Literal: "and", Pos: p.cur().Pos}, Left: left, Operator: "and"}
+		p.next()
+		if exp.Right = p.parseUnary(); exp.Right == nil {
+			return nil
+		}
+		left = exp
+	}
+	return left
+}
+
+func (p *sqlParser) parseUnary() ast.Expression {
+	switch {
+	case p.curIsKeyword("not"):
+		exp := &ast.PrefixExpression{Token: token.Token{Type: token.NOT, Literal: "not", Pos: p.cur().Pos}, Operator: "not"}
+		p.next()
+		if exp.Right = p.parseUnary(); exp.Right == nil {
+			return nil
+		}
+		return exp
+	case p.curIs(token.LPAREN):
+		p.next()
+		exp := p.parseOr()
+		if exp == nil {
+			return nil
+		}
+		if _, ok := p.expect(token.RPAREN); !ok {
+			return nil
+		}
+		return exp
+	default:
+		return p.parseComparison()
+	}
+}
+
+// parseComparison parses a column compared to a literal, eg. age > 25 or email IS NULL
+func (p *sqlParser) parseComparison() ast.Expression {
+	if !p.curIsName() {
+		p.expected("a column name", "conditions compare a column to a value, eg. age > 25")
+		return nil
+	}
+	filter := &ast.ReadFilterExpression{Token: p.cur(), ColumnName: p.cur().Literal}
+	p.next()
+
+	if p.curIsKeyword("is") {
+		p.next()
+		filter.Operator = "=="
+		if p.curIsKeyword("not") {
+			filter.Operator = "!="
+			p.next()
+		}
+		if !p.curIsKeyword("null") {
+			p.expected("NULL", "")
+			return nil
+		}
+		// cells are never missing in a CSV, a NULL is an empty cell
+		filter.Value = stringLiteral(p.cur(), "")
+		p.next()
+		return filter
+	}
+
+	switch p.cur().Type {
+	case token.EQ, token.NOT_EQ, token.LT, token.GT, token.LT_EQ, token.GT_EQ:
+		filter.Operator = string(p.cur().Type)
+	default:
+		p.expected("one of =, <>, !=, <, >, <=, >= or IS", "")
+		return nil
+	}
+	p.next()
+
+	if filter.Value = p.parseLiteral(); filter.Value == nil {
+		return nil
+	}
+	return filter
+}
+
+// parseLiteral parses the value a column is compared to
+func (p *sqlParser) parseLiteral() ast.Expression {
+	tok := p.cur()
+	switch {
+	case p.curIs(token.STRING):
+		p.next()
+		return stringLiteral(tok, tok.Literal)
+	case p.curIs(token.INT):
+		value, err := strconv.ParseInt(tok.Literal, 10, 64)
+		if err != nil {
+			p.addError(tok, fmt.Sprintf("SQL: could not parse %s as integer", tok.Literal), "")
+			return nil
+		}
+		p.next()
+		return &ast.IntegerLiteral{Token: tok, Value: value}
+	case p.curIs(token.FLOAT):
+		value, err := strconv.ParseFloat(tok.Literal, 64)
+		if err != nil {
+			p.addError(tok, fmt.Sprintf("SQL: could not parse %s as float", tok.Literal), "")
+			return nil
+		}
+		p.next()
+		return &ast.FloatLiteral{Token: tok, Value: value}
+	case p.curIs(token.MINUS):
+		p.next()
+		right := p.parseLiteral()
+		if right == nil {
+			return nil
+		}
+		return &ast.PrefixExpression{Token: tok, Operator: "-", Right: right}
+	}
+	p.expected("a string or a number", "strings are written in single quotes, eg. city = 'Oslo', and NULL is tested with IS NULL")
+	return nil
+}
+
+func stringLiteral(tok token.Token, value string) *ast.StringLiteral {
+	return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: value, Pos: tok.Pos}, Value: value}
+}
+
+// pipeCall pipes left into a call of a builtin, eg. left |> head(10)
+func pipeCall(tok token.Token, left ast.Expression, function string, arguments ...ast.Expression) ast.Expression {
+	return &ast.PipeExpression{
+		Token: token.Token{Type: token.PIPE, Literal: "|>", Pos: tok.Pos},
+		Left:  left,
+		Right: &ast.CallExpression{
+			Token:     token.Token{Type: token.LPAREN, Literal: "(", Pos: tok.Pos},
+			Function:  &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: function, Pos: tok.Pos}, Value: function},
+			Arguments: arguments,
+		},
+	}
+}
+
+// scan splits a query into tokens, the last one is always EOF
+func (p *sqlParser) scan(input string) {
+	line, lineStart := 1, 0
+	for i := 0; i < len(input); {
+		ch := input[i]
+		pos := token.Position{Line: line, Column: i - lineStart + 1}
+		add := func(t token.TokenType, literal string, length int) {
+			p.tokens = append(p.tokens, token.Token{Type: t, Literal: literal, Pos: pos})
+			i += length
+		}
+
+		switch {
+		case ch == '\n':
+			i++
+			line, lineStart = line+1, i
+		case ch == ' ' || ch == '\t' || ch == '\r':
+			i++
+		case strings.HasPrefix(input[i:], "--"):
+			// a comment runs to the end of the line
+			for i < len(input) && input[i] != '\n' {
+				i++
+			}
+		case ch == '\'' || ch == '"':
+			// quotes are escaped by doubling them, eg. 'O''Brien'
+			var value strings.Builder
+			end := i + 1
+			for end < len(input) {
+				if input[end] == ch {
+					if end+1 < len(input) && input[end+1] == ch {
+						value.WriteByte(ch)
+						end += 2
+						continue
+					}
+					break
+				}
+				value.WriteByte(input[end])
+				end++
+			}
+			t, kind := token.TokenType(token.STRING), "string"
+			if ch == '"' {
+				t, kind = sqlName, "name"
+			}
+			if end >= len(input) {
+				p.addError(token.Token{Pos: pos}, "SQL: unterminated "+kind, "")
+				i = len(input)
+				continue
+			}
+			add(t, value.String(), end+1-i)
+		case isSQLDigit(ch):
+			end, t := i, token.TokenType(token.INT)
+			for end < len(input) && isSQLDigit(input[end]) {
+				end++
+			}
+			if end+1 < len(input) && input[end] == '.' && isSQLDigit(input[end+1]) {
+				t = token.FLOAT
+				for end++; end < len(input) && isSQLDigit(input[end]); end++ {
+				}
+			}
+			add(t, input[i:end], end-i)
+		case isSQLLetter(ch):
+			end := i
+			for end < len(input) && (isSQLLetter(input[end]) || isSQLDigit(input[end])) {
+				end++
+			}
+			add(token.IDENT, input[i:end], end-i)
+		default:
+			operators := []struct {
+				text string
+				t    token.TokenType
+			}{
+				{"<>", token.NOT_EQ}, {"!=", token.NOT_EQ}, {"<=", token.LT_EQ}, {">=", token.GT_EQ}, {"==", token.EQ},
+				{"=", token.EQ}, {"<", token.LT}, {">", token.GT}, {"(", token.LPAREN}, {")", token.RPAREN},
+				{",", token.COMMA}, {"*", token.ASTERISK}, {";", token.SEMICOLON}, {"-", token.MINUS},
+			}
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(input[i:], op.text) {
+					add(op.t, op.text, len(op.text))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				add(token.ILLEGAL, string(ch), 1)
+			}
+		}
+	}
+	p.tokens = append(p.tokens, token.Token{Type: token.EOF, Pos: token.Position{Line: line, Column: len(input) - lineStart + 1}})
+}
+
+func isSQLDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+func isSQLLetter(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+}
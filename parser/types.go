@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"runtime"
+	"strings"
 )
 
 // ParserError is an error type that is returned when a parsing error occurs.
@@ -11,6 +12,42 @@ type ParserError struct {
 	Stack   []uintptr // Stack trace
 	Line    int       // Line number where the error occurred
 	Column  int       // Column number where the error occurred
+	Source  string    // Source line where the error occurred
+	Hint    string    // Optional: suggestion to fix the error, eg. did you mean to quote the filename?
+}
+
+// Diagnostic returns the error message followed by the offending source line with a caret under the token, and the hint if there is one.
+//
+//	unexpected `-` at line 1, column 6
+//	  1 | load -data.csv
+//	    |      ^
+//	hint: did you mean to quote the filename? eg. load "my data.csv"
+func (e *ParserError) Diagnostic() string {
+	var out strings.Builder
+	out.WriteString(e.Error())
+	if e.Source != "" && e.Column > 0 {
+		gutter := fmt.Sprintf("%d", e.Line)
+		out.WriteString(fmt.Sprintf("\n  %s | %s\n", gutter, e.Source))
+		out.WriteString("  " + strings.Repeat(" ", len(gutter)) + " | " + caretPadding(e.Source, e.Column) + "^")
+	}
+	if e.Hint != "" {
+		out.WriteString("\nhint: " + e.Hint)
+	}
+	return out.String()
+}
+
+// caretPadding returns the whitespace that puts a caret under a column of the line, tabs are kept so the caret lines up.
+func caretPadding(line string, column int) string {
+	if column-1 > len(line) {
+		column = len(line) + 1
+	}
+	padding := []byte(line[:column-1])
+	for i, ch := range padding {
+		if ch != '\t' {
+			padding[i] = ' '
+		}
+	}
+	return string(padding)
 }
 
 // Error creates a new ParserError with the given message, line, column, and stack trace.
@@ -25,7 +62,7 @@ func (e *ParserError) Format(s fmt.State, verb rune) {
 		if s.Flag('+') {
 			// Print full stack trace
 			frames := runtime.CallersFrames(e.Stack)
-			fmt.Fprintf(s, "%s at line %d, column %d\n", e.Message, e.Line, e.Column)
+			fmt.Fprintf(s, "%s\n", e.Diagnostic())
 			for {
 				frame, more := frames.Next()
 				fmt.Fprintf(s, "\t%s\n\t\t%s:%d\n", frame.Function, frame.File, frame.Line)
@@ -0,0 +1,271 @@
+// plugins package adds builtins from outside the interpreter, loaded at startup with `csvlang -plugin ./geo.so`.
+//
+// A plugin is either
+//   - a WebAssembly module (a file ending with .wasm), whose exported functions run sandboxed, see loadWasm
+//   - a Go plugin (a file ending with .so, built with `go build -buildmode=plugin`) exporting
+//     `var Builtins map[string]*object.Builtin`
+//   - any other executable, started once and called over its stdin and stdout with one JSON message per line
+//
+// An executable is first asked for its functions, then called with the arguments of each call:
+//
+//	-> {"method": "functions"}
+//	<- {"functions": ["geocode"]}
+//	-> {"method": "call", "function": "geocode", "args": ["Karl Johans gate 1, Oslo"]}
+//	<- {"result": [59.91, 10.74]}
+//
+// A call fails with {"error": "message"}. Values are JSON: numbers, strings, booleans, null and arrays,
+// CSVs are objects with "headers" and "rows", each row an array of cells in header order.
+package plugins
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"plugin"
+	"strings"
+	"sync"
+
+	"github.com/Rishabh570/csvlang/evaluator"
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// Load loads a plugin and registers its builtins, it fails if a builtin already exists
+func Load(path string) error {
+	switch {
+	case strings.HasSuffix(path, ".wasm"):
+		return loadWasm(path)
+	case strings.HasSuffix(path, ".so"):
+		return loadGoPlugin(path)
+	}
+	return loadProcess(path)
+}
+
+func loadGoPlugin(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return err
+	}
+	symbol, err := p.Lookup("Builtins")
+	if err != nil {
+		return err
+	}
+	builtins, ok := symbol.(*map[string]*object.Builtin)
+	if !ok {
+		return fmt.Errorf("%s: Builtins must be a map[string]*object.Builtin, got %T", path, symbol)
+	}
+	for name, builtin := range *builtins {
+		if err := evaluator.RegisterBuiltin(name, builtin); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+	}
+	return nil
+}
+
+// process is a running plugin executable, calls are sent one at a time
+type process struct {
+	path string
+	mu   sync.Mutex
+	in   io.Writer
+	out  *bufio.Reader
+}
+
+type request struct {
+	Method   string        `json:"method"`
+	Function string        `json:"function,omitempty"`
+	Args     []interface{} `json:"args,omitempty"`
+}
+
+type reply struct {
+	Functions []string        `json:"functions"`
+	Result    json.RawMessage `json:"result"`
+	Error     string          `json:"error"`
+}
+
+func loadProcess(path string) error {
+	cmd := exec.Command(path)
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	p := &process{path: path, in: in, out: bufio.NewReader(out)}
+	functions, err := p.send(request{Method: "functions"})
+	if err != nil {
+		cmd.Process.Kill()
+		return err
+	}
+	for _, name := range functions.Functions {
+		if err := evaluator.RegisterBuiltin(name, p.builtin(name)); err != nil {
+			cmd.Process.Kill()
+			return fmt.Errorf("%s: %s", path, err)
+		}
+	}
+	return nil
+}
+
+// send writes a request and reads its reply
+func (p *process) send(req request) (*reply, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.in.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("plugin %s: %s", p.path, err)
+	}
+	line, err := p.out.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s stopped: %s", p.path, err)
+	}
+	var rep reply
+	if err := json.Unmarshal(line, &rep); err != nil {
+		return nil, fmt.Errorf("plugin %s: invalid reply: %s", p.path, err)
+	}
+	return &rep, nil
+}
+
+func (p *process) builtin(name string) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			values := make([]interface{}, len(args))
+			for i, arg := range args {
+				value, err := toJSON(arg)
+				if err != nil {
+					return &object.Error{Message: fmt.Sprintf("argument %d to `%s`: %s", i+1, name, err)}
+				}
+				values[i] = value
+			}
+
+			rep, err := p.send(request{Method: "call", Function: name, Args: values})
+			if err != nil {
+				return &object.Error{Message: err.Error()}
+			}
+			if rep.Error != "" {
+				return &object.Error{Message: fmt.Sprintf("%s: %s", name, rep.Error)}
+			}
+			result, err := fromJSON(rep.Result)
+			if err != nil {
+				return &object.Error{Message: fmt.Sprintf("%s returned %s", name, err)}
+			}
+			return result
+		},
+	}
+}
+
+// csvJSON is how CSVs are sent to and received from plugins
+type csvJSON struct {
+	Headers []string   `json:"headers"`
+	Rows    [][]string `json:"rows"`
+}
+
+func toJSON(obj object.Object) (interface{}, error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.Float:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Null:
+		return nil, nil
+	case *object.Array:
+		values := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			value, err := toJSON(element)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	case *object.CSV:
+		rows := make([][]string, len(obj.Rows))
+		for i, row := range obj.Rows {
+			rows[i] = obj.Values(row)
+		}
+		return csvJSON{Headers: obj.Headers, Rows: rows}, nil
+	}
+	return nil, fmt.Errorf("%s can't be sent to a plugin", obj.Type())
+}
+
+func fromJSON(data json.RawMessage) (object.Object, error) {
+	if len(data) == 0 {
+		return evaluator.NULL, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return fromValue(value)
+}
+
+func fromValue(value interface{}) (object.Object, error) {
+	switch value := value.(type) {
+	case nil:
+		return evaluator.NULL, nil
+	case bool:
+		if value {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case string:
+		return &object.String{Value: value}, nil
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return &object.Integer{Value: i}, nil
+		}
+		f, err := value.Float64()
+		if err != nil || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("an invalid number %s", value)
+		}
+		return &object.Float{Value: f}, nil
+	case []interface{}:
+		elements := make([]object.Object, len(value))
+		for i, element := range value {
+			obj, err := fromValue(element)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = obj
+		}
+		return &object.Array{Elements: elements}, nil
+	case map[string]interface{}:
+		return csvFromValue(value)
+	}
+	return nil, fmt.Errorf("an unsupported value %v", value)
+}
+
+// csvFromValue reads a CSV sent by a plugin, rows must have a cell for every header
+func csvFromValue(value map[string]interface{}) (object.Object, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded csvJSON
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Headers == nil {
+		return nil, fmt.Errorf("an object that isn't a CSV, CSVs need headers and rows")
+	}
+
+	csv, err := object.NewCSVFromRecords(decoded.Headers, decoded.Rows)
+	if err != nil {
+		return nil, fmt.Errorf("a CSV whose %w", err)
+	}
+	return csv, nil
+}
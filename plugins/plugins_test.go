@@ -0,0 +1,238 @@
+package plugins
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Rishabh570/csvlang/evaluator"
+	"github.com/Rishabh570/csvlang/lexer"
+	"github.com/Rishabh570/csvlang/object"
+	"github.com/Rishabh570/csvlang/parser"
+)
+
+// TestMain runs the test binary as a plugin executable when CSVLANG_TEST_PLUGIN is set
+func TestMain(m *testing.M) {
+	if os.Getenv("CSVLANG_TEST_PLUGIN") == "1" {
+		servePlugin()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// servePlugin answers like a plugin offering plugin_double, plugin_fail and plugin_upper
+func servePlugin() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req struct {
+			Method   string            `json:"method"`
+			Function string            `json:"function"`
+			Args     []json.RawMessage `json:"args"`
+		}
+		json.Unmarshal(scanner.Bytes(), &req)
+
+		var reply interface{}
+		switch {
+		case req.Method == "functions":
+			reply = map[string]interface{}{"functions": []string{"plugin_double", "plugin_fail", "plugin_upper"}}
+		case req.Function == "plugin_double":
+			var n float64
+			json.Unmarshal(req.Args[0], &n)
+			reply = map[string]interface{}{"result": n * 2}
+		case req.Function == "plugin_upper":
+			var data struct {
+				Headers []string   `json:"headers"`
+				Rows    [][]string `json:"rows"`
+			}
+			json.Unmarshal(req.Args[0], &data)
+			for _, row := range data.Rows {
+				for i := range row {
+					row[i] = strings.ToUpper(row[i])
+				}
+			}
+			reply = map[string]interface{}{"result": data}
+		default:
+			reply = map[string]interface{}{"error": "address not found"}
+		}
+		data, _ := json.Marshal(reply)
+		fmt.Println(string(data))
+	}
+}
+
+func eval(t *testing.T, input string) object.Object {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors)
+	}
+	return evaluator.Eval(program, object.NewEnvironment())
+}
+
+func TestProcessPlugin(t *testing.T) {
+	t.Setenv("CSVLANG_TEST_PLUGIN", "1")
+	if err := Load(os.Args[0]); err != nil {
+		t.Fatalf("loading the plugin failed: %s", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"plugin_double(21)", "42"},
+		{"plugin_double(1.25)", "2.5"},
+		{"plugin_fail(\"somewhere\")", "ERROR: plugin_fail: address not found"},
+		{"plugin_double(fn(x) { x })", "ERROR: argument 1 to `plugin_double`: FUNCTION can't be sent to a plugin"},
+	}
+	for _, tt := range tests {
+		if got := eval(t, tt.input).Inspect(); got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	path := t.TempDir() + "/people.csv"
+	if err := os.WriteFile(path, []byte("name,city\nAnn,oslo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	csv, ok := eval(t, fmt.Sprintf("load %q\nplugin_upper(csv)", path)).(*object.CSV)
+	if !ok || len(csv.Rows) != 1 || csv.Rows[0]["name"] != "ANN" || csv.Rows[0]["city"] != "OSLO" {
+		t.Errorf("wrong CSV from the plugin. got=%+v", csv)
+	}
+
+	if err := Load(os.Args[0]); err == nil || !strings.Contains(err.Error(), "builtin plugin_double already exists") {
+		t.Errorf("loading a builtin twice should fail. got=%v", err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if err := Load(t.TempDir() + "/missing.so"); err == nil {
+		t.Errorf("expected an error for a missing Go plugin")
+	}
+	if err := Load(t.TempDir() + "/missing"); err == nil {
+		t.Errorf("expected an error for a missing executable")
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`3`, "3"},
+		{`3.5`, "3.5"},
+		{`"x"`, "x"},
+		{`[true, null]`, "[true, null]"},
+		{`{"headers": ["a"], "rows": [["1", "2"]]}`, "error: a CSV whose row 0 has 2 cells for 1 headers"},
+		{`{"name": "x"}`, "error: an object that isn't a CSV, CSVs need headers and rows"},
+	}
+	for _, tt := range tests {
+		obj, err := fromJSON(json.RawMessage(tt.input))
+		got := ""
+		if err != nil {
+			got = "error: " + err.Error()
+		} else {
+			got = obj.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("fromJSON(%s): expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// testWasmModule is a WebAssembly module exporting
+//
+//	wasm_double(i64) i64, wasm_add(f64, f64) f64, wasm_trap(i64) i64,
+//	wasm_tail(ptr, len) which drops the first character of a string, alloc, memory and wasm_spin(i64) i64 which never returns
+func testWasmModule() []byte {
+	section := func(id byte, entries ...[]byte) []byte {
+		body := []byte{byte(len(entries))}
+		for _, entry := range entries {
+			body = append(body, entry...)
+		}
+		return append([]byte{id, byte(len(body))}, body...)
+	}
+	export := func(name string, kind, index byte) []byte {
+		return append(append([]byte{byte(len(name))}, name...), kind, index)
+	}
+	code := func(instructions ...byte) []byte {
+		return append([]byte{byte(len(instructions) + 1), 0}, instructions...)
+	}
+
+	module := []byte{0, 'a', 's', 'm', 1, 0, 0, 0}
+	module = append(module, section(1,
+		[]byte{0x60, 1, 0x7e, 1, 0x7e},       // (i64) i64
+		[]byte{0x60, 2, 0x7c, 0x7c, 1, 0x7c}, // (f64, f64) f64
+		[]byte{0x60, 1, 0x7f, 1, 0x7f},       // (i32) i32
+		[]byte{0x60, 2, 0x7f, 0x7f, 1, 0x7e}, // (i32, i32) i64
+	)...)
+	module = append(module, section(3, []byte{0}, []byte{1}, []byte{0}, []byte{2}, []byte{3}, []byte{0})...)
+	module = append(module, section(5, []byte{0, 1})...)
+	module = append(module, section(7,
+		export("wasm_double", 0, 0),
+		export("wasm_add", 0, 1),
+		export("wasm_trap", 0, 2),
+		export("alloc", 0, 3),
+		export("wasm_tail", 0, 4),
+		export("memory", 2, 0),
+		export("wasm_spin", 0, 5),
+	)...)
+	module = append(module, section(10,
+		code(0x20, 0, 0x42, 2, 0x7e, 0x0b), // x * 2
+		code(0x20, 0, 0x20, 1, 0xa0, 0x0b), // a + b
+		code(0x00, 0x0b),                   // unreachable
+		code(0x41, 0x80, 0x08, 0x0b),       // always 1024
+		code(0x20, 0, 0x41, 1, 0x6a, 0xad, 0x42, 32, 0x86, // (ptr+1) << 32
+			0x20, 1, 0x41, 1, 0x6b, 0xad, 0x84, 0x0b), // | len-1
+		code(0x03, 0x40, 0x0c, 0, 0x0b, 0x42, 0, 0x0b), // loop forever
+	)...)
+	return module
+}
+
+func TestWasmPlugin(t *testing.T) {
+	path := t.TempDir() + "/test.wasm"
+	if err := os.WriteFile(path, testWasmModule(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(path); err != nil {
+		t.Fatalf("loading the module failed: %s", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"wasm_double(21)", "42"},
+		{"wasm_add(1.5, 2)", "3.5"},
+		{"wasm_tail(\"#1042\")", "1042"},
+		{"wasm_tail(51)", "1"},
+		{"wasm_double(1.5)", "ERROR: argument 1 to `wasm_double` must be INTEGER, got FLOAT"},
+		{"wasm_double(1, 2)", "ERROR: wrong number of arguments. got=2, want=1"},
+		{"wasm_tail([1])", "ERROR: argument to `wasm_tail` must be STRING, got ARRAY"},
+	}
+	for _, tt := range tests {
+		if got := eval(t, tt.input).Inspect(); got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	if got := eval(t, "wasm_trap(1)").Inspect(); !strings.HasPrefix(got, "ERROR: wasm_trap: wasm error: unreachable") {
+		t.Errorf("a trap should be an error. got=%q", got)
+	}
+	if got := eval(t, "alloc(1)").Inspect(); got != "ERROR: identifier not found: alloc" {
+		t.Errorf("alloc shouldn't be registered as a builtin. got=%q", got)
+	}
+
+	// a call running too long is stopped, and the module is closed
+	defer func(timeout time.Duration) { wasmCallTimeout = timeout }(wasmCallTimeout)
+	wasmCallTimeout = 50 * time.Millisecond
+	if got := eval(t, "wasm_spin(1)").Inspect(); !strings.Contains(got, "context deadline exceeded") {
+		t.Errorf("a call past the timeout should be stopped. got=%q", got)
+	}
+	if got := eval(t, "wasm_double(21)").Inspect(); !strings.HasPrefix(got, "ERROR: wasm_double: ") {
+		t.Errorf("the module should be closed. got=%q", got)
+	}
+}
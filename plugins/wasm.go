@@ -0,0 +1,252 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Rishabh570/csvlang/evaluator"
+	"github.com/Rishabh570/csvlang/object"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// wasmMemoryPages caps the memory of a module at 64MB
+const wasmMemoryPages = 1024
+
+// wasmCallTimeout is how long a call to a module can run, eg. a function stuck in a loop.
+// A call past it, or past the context of the run, closes the module, so its builtins fail from then on.
+var wasmCallTimeout = 10 * time.Second
+
+// wasmModule is an instantiated WebAssembly plugin, calls are sent one at a time
+type wasmModule struct {
+	path    string
+	mu      sync.Mutex
+	module  api.Module
+	alloc   api.Function // alloc(size i32) i32, needed for string functions
+	dealloc api.Function // dealloc(ptr i32, size i32), optional
+}
+
+// loadWasm registers the exported functions of a WebAssembly module as builtins.
+// The module runs sandboxed: WASI imports are provided but it can't reach files, the network or the environment.
+//
+// Functions taking and returning numbers are called directly. When the module exports alloc and memory, functions
+// with the signature (ptr i32, len i32) -> i64 take a string, written into the memory returned by alloc, and return
+// a string as its pointer in the upper 32 bits and its length in the lower 32 bits.
+// Functions starting with an underscore and functions of other signatures aren't registered.
+func loadWasm(path string) error {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	// calls are stopped once their context is done, see wasmCallTimeout
+	config := wazero.NewRuntimeConfig().WithMemoryLimitPages(wasmMemoryPages).WithCloseOnContextDone(true)
+	runtime := wazero.NewRuntimeWithConfig(ctx, config)
+	// a module that fails to load leaves nothing behind
+	fail := func(err error) error {
+		runtime.Close(ctx)
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		return fail(err)
+	}
+	compiled, err := runtime.CompileModule(ctx, code)
+	if err != nil {
+		return fail(err)
+	}
+	// reactors (eg. TinyGo's c-shared build mode) are initialized, commands aren't started
+	module, err := runtime.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize"))
+	if err != nil {
+		return fail(err)
+	}
+
+	m := &wasmModule{path: path, module: module}
+	if alloc := module.ExportedFunction("alloc"); alloc != nil && module.Memory() != nil && isSignature(alloc.Definition(), []api.ValueType{api.ValueTypeI32}, []api.ValueType{api.ValueTypeI32}) {
+		m.alloc = alloc
+	}
+	if dealloc := module.ExportedFunction("dealloc"); dealloc != nil && isSignature(dealloc.Definition(), []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, nil) {
+		m.dealloc = dealloc
+	}
+
+	for name, definition := range compiled.ExportedFunctions() {
+		if strings.HasPrefix(name, "_") || name == "alloc" || name == "dealloc" {
+			continue
+		}
+		var builtin *object.Builtin
+		switch {
+		case m.alloc != nil && isStringSignature(definition):
+			builtin = m.stringBuiltin(name)
+		case isNumberSignature(definition):
+			builtin = m.numberBuiltin(name, definition)
+		default:
+			continue
+		}
+		if err := evaluator.RegisterBuiltin(name, builtin); err != nil {
+			return fail(err)
+		}
+	}
+	return nil
+}
+
+func isSignature(definition api.FunctionDefinition, params, results []api.ValueType) bool {
+	return string(definition.ParamTypes()) == string(params) && string(definition.ResultTypes()) == string(results)
+}
+
+func isStringSignature(definition api.FunctionDefinition) bool {
+	return isSignature(definition, []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, []api.ValueType{api.ValueTypeI64})
+}
+
+func isNumberSignature(definition api.FunctionDefinition) bool {
+	if len(definition.ResultTypes()) > 1 {
+		return false
+	}
+	for _, t := range append(definition.ParamTypes(), definition.ResultTypes()...) {
+		switch t {
+		case api.ValueTypeI32, api.ValueTypeI64, api.ValueTypeF32, api.ValueTypeF64:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (m *wasmModule) numberBuiltin(name string, definition api.FunctionDefinition) *object.Builtin {
+	params := definition.ParamTypes()
+	return &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != len(params) {
+				return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%d", len(args), len(params))}
+			}
+			values := make([]uint64, len(args))
+			for i, arg := range args {
+				value, err := encodeWasm(arg, params[i])
+				if err != nil {
+					return &object.Error{Message: fmt.Sprintf("argument %d to `%s` %s", i+1, name, err)}
+				}
+				values[i] = value
+			}
+
+			ctx, cancel := context.WithTimeout(env.Context(), wasmCallTimeout)
+			defer cancel()
+			m.mu.Lock()
+			results, err := m.module.ExportedFunction(name).Call(ctx, values...)
+			m.mu.Unlock()
+			if err != nil {
+				return &object.Error{Message: fmt.Sprintf("%s: %s", name, err)}
+			}
+			if len(results) == 0 {
+				return evaluator.NULL
+			}
+			return decodeWasm(results[0], definition.ResultTypes()[0])
+		},
+	}
+}
+
+func (m *wasmModule) stringBuiltin(name string) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(args))}
+			}
+			var input string
+			switch arg := args[0].(type) {
+			case *object.String:
+				input = arg.Value
+			case *object.Integer, *object.Float:
+				input = arg.Inspect()
+			default:
+				return &object.Error{Message: fmt.Sprintf("argument to `%s` must be STRING, got %s", name, arg.Type())}
+			}
+
+			ctx, cancel := context.WithTimeout(env.Context(), wasmCallTimeout)
+			defer cancel()
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			output, err := m.callString(ctx, name, input)
+			if err != nil {
+				return &object.Error{Message: fmt.Sprintf("%s: %s", name, err)}
+			}
+			return &object.String{Value: output}
+		},
+	}
+}
+
+// callString copies the input into the module, calls the function and copies its result out
+func (m *wasmModule) callString(ctx context.Context, name, input string) (string, error) {
+	allocated, err := m.alloc.Call(ctx, uint64(len(input)))
+	if err != nil {
+		return "", err
+	}
+	ptr := uint32(allocated[0])
+	if !m.module.Memory().Write(ptr, []byte(input)) {
+		m.free(ctx, ptr, uint32(len(input)))
+		return "", fmt.Errorf("alloc returned memory out of range")
+	}
+	results, err := m.module.ExportedFunction(name).Call(ctx, uint64(ptr), uint64(len(input)))
+	m.free(ctx, ptr, uint32(len(input)))
+	if err != nil {
+		return "", err
+	}
+
+	resultPtr, resultLen := uint32(results[0]>>32), uint32(results[0])
+	output, ok := m.module.Memory().Read(resultPtr, resultLen)
+	if !ok {
+		return "", fmt.Errorf("returned memory out of range")
+	}
+	// the memory is reused once freed, so the result is copied first
+	value := string(output)
+	m.free(ctx, resultPtr, resultLen)
+	return value, nil
+}
+
+func (m *wasmModule) free(ctx context.Context, ptr, size uint32) {
+	if m.dealloc != nil && size > 0 {
+		m.dealloc.Call(ctx, uint64(ptr), uint64(size))
+	}
+}
+
+func encodeWasm(arg object.Object, t api.ValueType) (uint64, error) {
+	switch arg := arg.(type) {
+	case *object.Integer:
+		switch t {
+		case api.ValueTypeI32:
+			if arg.Value < math.MinInt32 || arg.Value > math.MaxInt32 {
+				return 0, fmt.Errorf("is out of range for a 32 bit integer: %d", arg.Value)
+			}
+			return api.EncodeI32(int32(arg.Value)), nil
+		case api.ValueTypeI64:
+			return api.EncodeI64(arg.Value), nil
+		case api.ValueTypeF32:
+			return api.EncodeF32(float32(arg.Value)), nil
+		case api.ValueTypeF64:
+			return api.EncodeF64(float64(arg.Value)), nil
+		}
+	case *object.Float:
+		switch t {
+		case api.ValueTypeF32:
+			return api.EncodeF32(float32(arg.Value)), nil
+		case api.ValueTypeF64:
+			return api.EncodeF64(arg.Value), nil
+		}
+		return 0, fmt.Errorf("must be INTEGER, got FLOAT")
+	}
+	return 0, fmt.Errorf("must be a number, got %s", arg.Type())
+}
+
+func decodeWasm(value uint64, t api.ValueType) object.Object {
+	switch t {
+	case api.ValueTypeI32:
+		return &object.Integer{Value: int64(api.DecodeI32(value))}
+	case api.ValueTypeI64:
+		return &object.Integer{Value: int64(value)}
+	case api.ValueTypeF32:
+		return &object.Float{Value: float64(api.DecodeF32(value))}
+	}
+	return &object.Float{Value: api.DecodeF64(value)}
+}
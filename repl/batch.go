@@ -0,0 +1,99 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/evaluator"
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// RunBatch runs a script once for every CSV file of inputDir, using up to workers files at a time, and writes a line per file
+// in the order of their names once every file is done.
+// Each run has its own environment where `input` is the path of the file and `output` the path of the file
+// of the same name in outputDir, they can be used in filenames, eg. load "{input}" and save rows as "{output}".
+// A failed file doesn't stop the others. It reports whether every file was processed.
+func RunBatch(path, inputDir, outputDir string, workers int, out io.Writer) bool {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(out, "Error reading file: %s\n", err)
+		return false
+	}
+	program, errors := parseScript(path, string(content))
+	if len(errors) != 0 {
+		printParserErrors(out, errors)
+		return false
+	}
+	inputs, err := filepath.Glob(filepath.Join(inputDir, "*.csv"))
+	if err != nil {
+		fmt.Fprintf(out, "Error listing %s: %s\n", inputDir, err)
+		return false
+	}
+	if len(inputs) == 0 {
+		fmt.Fprintf(out, "no CSV files found in %s\n", inputDir)
+		return false
+	}
+	if sameDir(inputDir, outputDir) {
+		fmt.Fprintf(out, "the output directory must not be the input directory, the files would be overwritten\n")
+		return false
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		fmt.Fprintf(out, "Error creating %s: %s\n", outputDir, err)
+		return false
+	}
+
+	// the files are independent, each worker takes the next file until none are left
+	messages := make([]string, len(inputs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(1, min(workers, len(inputs))); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				messages[i] = runBatchFile(program, inputs[i], batchOutput(outputDir, inputs[i]))
+			}
+		}()
+	}
+	for i := range inputs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	failed := 0
+	for i, input := range inputs {
+		if messages[i] != "" {
+			failed++
+			fmt.Fprintf(out, "FAIL %s\n    %s\n", input, strings.ReplaceAll(messages[i], "\n", "\n    "))
+			continue
+		}
+		fmt.Fprintf(out, "ok   %s -> %s\n", input, batchOutput(outputDir, input))
+	}
+	fmt.Fprintf(out, "\n%d processed, %d failed\n", len(inputs)-failed, failed)
+	return failed == 0
+}
+
+func batchOutput(outputDir, input string) string {
+	return filepath.Join(outputDir, filepath.Base(input))
+}
+
+// runBatchFile runs the script for one file, and returns why it failed or ""
+func runBatchFile(program *ast.Program, input, output string) string {
+	env := object.NewEnvironment()
+	env.Set("input", &object.String{Value: input})
+	env.Set("output", &object.String{Value: output})
+	message, _ := testOutcome(evaluator.EndRun(env, evaluator.Eval(program, env)))
+	return message
+}
+
+func sameDir(a, b string) bool {
+	a, errA := filepath.Abs(a)
+	b, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && a == b
+}
@@ -0,0 +1,86 @@
+package repl
+
+import (
+	"github.com/Rishabh570/csvlang/object"
+)
+
+// historySize is the number of lines `:undo` can take back in interactive mode
+const historySize = 20
+
+// history keeps the variables of an interactive session as they were before the lines that changed them, see Start
+type history struct {
+	entries []*historyEntry
+}
+
+// historyEntry is the variables before a line ran, and the content of the CSVs bound to them.
+// The CSVs share their rows with the entry until they change, so an entry costs the rows changed by its line.
+type historyEntry struct {
+	line     string
+	bindings map[string]object.Object
+	csvs     map[*object.CSV]*object.CSVState
+}
+
+// record returns the state of the variables of env before a line runs, see keep
+func (h *history) record(line string, env *object.Environment) *historyEntry {
+	entry := &historyEntry{line: line, bindings: env.Bindings(), csvs: map[*object.CSV]*object.CSVState{}}
+	for _, value := range entry.bindings {
+		if csv, ok := value.(*object.CSV); ok && entry.csvs[csv] == nil {
+			entry.csvs[csv] = csv.Snapshot()
+		}
+	}
+	return entry
+}
+
+// keep adds an entry to the history once its line ran, unless the line changed no variable and no CSV, eg. print(csv).
+// The oldest entry is dropped when the history is full.
+func (h *history) keep(entry *historyEntry, env *object.Environment) {
+	if !entry.changed(env) {
+		return
+	}
+	if len(h.entries) == historySize {
+		h.entries = h.entries[1:]
+	}
+	h.entries = append(h.entries, entry)
+}
+
+// changed reports whether the variables of env or their CSVs changed since the entry was recorded
+func (entry *historyEntry) changed(env *object.Environment) bool {
+	bindings := env.Bindings()
+	if len(bindings) != len(entry.bindings) {
+		return true
+	}
+	for name, value := range bindings {
+		if before, ok := entry.bindings[name]; !ok || before != value {
+			return true
+		}
+	}
+	for csv, state := range entry.csvs {
+		if csv.Changed(state) {
+			return true
+		}
+	}
+	return false
+}
+
+// undo brings the variables of env and their CSVs back to before the last line that changed them,
+// it returns the line taken back, false when there is none
+func (h *history) undo(env *object.Environment) (string, bool) {
+	if len(h.entries) == 0 {
+		return "", false
+	}
+	entry := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+
+	for name := range env.Bindings() {
+		if _, ok := entry.bindings[name]; !ok {
+			env.Delete(name)
+		}
+	}
+	for name, value := range entry.bindings {
+		env.Set(name, value)
+	}
+	for csv, state := range entry.csvs {
+		csv.Restore(state)
+	}
+	return entry.line, true
+}
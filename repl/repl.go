@@ -11,7 +11,9 @@ import (
 	"os"
 	"strings"
 
+	"github.com/Rishabh570/csvlang/ast"
 	"github.com/Rishabh570/csvlang/evaluator"
+	"github.com/Rishabh570/csvlang/explain"
 	"github.com/Rishabh570/csvlang/lexer"
 	"github.com/Rishabh570/csvlang/object"
 	"github.com/Rishabh570/csvlang/parser"
@@ -20,9 +22,26 @@ import (
 
 const PROMPT = ">> "
 
+// AtExit is called before a run exits the process, eg. on an error or exit(), to write what the run leaves behind
+// such as its audit log. Runs returning normally don't call it.
+var AtExit func()
+
+// exitProcess exits the process with a code once AtExit is done and the temp files of the snapshots are removed
+func exitProcess(code int) {
+	if AtExit != nil {
+		AtExit()
+	}
+	evaluator.RemoveSnapshotFiles()
+	os.Exit(code)
+}
+
+// Start runs the lines read from in one by one, printing their results to out.
+// `:undo` takes back the last line that changed a variable or a CSV bound to one, up to the historySize last ones,
+// eg. an update that matched more rows than expected.
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
+	var undo history
 
 	for {
 		fmt.Printf(PROMPT)
@@ -31,6 +50,14 @@ func Start(in io.Reader, out io.Writer) {
 			return
 		}
 		line := scanner.Text()
+		if strings.TrimSpace(line) == ":undo" {
+			if undone, ok := undo.undo(env); ok {
+				fmt.Fprintf(out, "undone: %s\n", undone)
+			} else {
+				io.WriteString(out, "nothing to undo\n")
+			}
+			continue
+		}
 		l := lexer.New(line)
 		p := parser.New(l)
 		program := p.ParseProgram()
@@ -38,7 +65,12 @@ func Start(in io.Reader, out io.Writer) {
 			printParserErrors(out, p.Errors)
 			continue
 		}
-		evaluated := evaluator.Eval(program, env)
+		entry := undo.record(line, env)
+		evaluated := evaluator.EndRun(env, evaluator.Eval(program, env))
+		undo.keep(entry, env)
+		if exit, ok := evaluated.(*object.Exit); ok {
+			exitProcess(exit.Code)
+		}
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
@@ -53,7 +85,7 @@ func StartFileAllAtOnce(path string) {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		fmt.Printf("Error reading file: %s\n", err)
-		os.Exit(1)
+		exitProcess(1)
 	}
 
 	// Create environment
@@ -78,20 +110,139 @@ func StartFileAllAtOnce(path string) {
 		return
 	}
 
+	runProgram(program, env)
+}
+
+// runProgram evaluates the statements of a program one by one, printing their results.
+// The process exits on the first error or on exit(), the run ends with the program, see evaluator.EndRun.
+func runProgram(program *ast.Program, env *object.Environment) {
 	// Evaluate each statement in the program
 	for _, statement := range program.Statements {
 		fmt.Printf("🚧 evaluating program statement: %s\n", statement.String())
 		evaluated := evaluator.Eval(statement, env)
+		// exit(0) ends the run as if it returned
+		if _, ok := evaluated.(*object.Exit); ok {
+			evaluated = evaluator.EndRun(env, evaluated)
+		}
+		if exit, ok := evaluated.(*object.Exit); ok {
+			exitProcess(exit.Code)
+		}
+		// Stop further execution if an error is encountered, failing the run (eg. a broken assert)
+		if err, ok := evaluated.(*object.Error); ok {
+			io.WriteString(os.Stdout, "ERROR: "+err.Location()+"\n")
+			exitProcess(1)
+		}
 		if evaluated != nil {
 			io.WriteString(os.Stdout, evaluated.Inspect())
 			io.WriteString(os.Stdout, "\n")
+		}
+	}
+	if err, ok := evaluator.EndRun(env, nil).(*object.Error); ok {
+		io.WriteString(os.Stdout, "ERROR: "+err.Location()+"\n")
+		exitProcess(1)
+	}
+}
 
-			// Stop further execution if an error is encountered
-			if evaluated.Type() == object.ERROR_OBJ {
-				return
-			}
+// parseScript parses a script, or the queries of a .sql file, see parser.ParseSQL
+func parseScript(path, content string) (*ast.Program, []*parser.ParserError) {
+	if strings.HasSuffix(path, ".sql") {
+		return parser.ParseSQL(content)
+	}
+	p := parser.New(lexer.New(content))
+	program := p.ParseProgram()
+	return program, p.Errors
+}
+
+// RunSQLFile runs the queries of a .sql file, see RunSQL.
+func RunSQLFile(path string, out io.Writer) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(out, "Error reading file: %s\n", err)
+		exitProcess(1)
+	}
+	RunSQL(string(content), out)
+}
+
+// RunSQL runs queries written in the SQL dialect of parser.ParseSQL and writes the rows of each query to out as CSV.
+// Unlike scripts, the CSVs loaded by the queries aren't printed.
+// The process exits on the first error.
+func RunSQL(queries string, out io.Writer) {
+	program, errors := parser.ParseSQL(queries)
+	if len(errors) != 0 {
+		printParserErrors(out, errors)
+		exitProcess(1)
+	}
+
+	env := object.NewEnvironment()
+	for _, statement := range program.Statements {
+		evaluated := evaluator.Eval(statement, env)
+		if err, ok := evaluated.(*object.Error); ok {
+			io.WriteString(out, "ERROR: "+err.Location()+"\n")
+			exitProcess(1)
 		}
+		if _, ok := statement.(*ast.ExpressionStatement); !ok {
+			continue
+		}
+		if rows, ok := evaluated.(*object.CSV); ok {
+			evaluator.WriteCSV(out, rows)
+		}
+	}
+}
+
+// EmitAST parses a script, or the queries of a .sql file, and writes its AST as JSON to out, see ast.ProgramToJSON.
+func EmitAST(path string, out io.Writer) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %s\n", err)
+		exitProcess(1)
+	}
+
+	program, errors := parseScript(path, string(content))
+	if len(errors) != 0 {
+		printParserErrors(os.Stderr, errors)
+		exitProcess(1)
+	}
+
+	data, err := ast.ProgramToJSON(program)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing AST: %s\n", err)
+		exitProcess(1)
+	}
+	out.Write(append(data, '\n'))
+}
+
+// Explain parses a script, or the queries of a .sql file, and writes the plan of its loads, reads and saves to out, see explain.Plan.
+func Explain(path string, out io.Writer) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %s\n", err)
+		exitProcess(1)
+	}
+
+	program, errors := parseScript(path, string(content))
+	if len(errors) != 0 {
+		printParserErrors(os.Stderr, errors)
+		exitProcess(1)
+	}
+
+	explain.Write(out, explain.Plan(program))
+}
+
+// StartAST evaluates a program read from JSON, eg. written by EmitAST or generated by another tool.
+func StartAST(path string) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Error reading file: %s\n", err)
+		exitProcess(1)
+	}
+
+	program, err := ast.ProgramFromJSON(content)
+	if err != nil {
+		fmt.Printf("Error reading AST: %s\n", err)
+		exitProcess(1)
 	}
+
+	runProgram(program, object.NewEnvironment())
 }
 
 func StartLexer(path string) {
@@ -99,7 +250,7 @@ func StartLexer(path string) {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		fmt.Printf("Error reading file: %s\n", err)
-		os.Exit(1)
+		exitProcess(1)
 	}
 
 	// Parse and evaluate the entire program
@@ -120,7 +271,7 @@ func StartFile(path string) {
 	lines, err := readLines(path)
 	if err != nil {
 		fmt.Printf("Error reading file: %s\n", err)
-		os.Exit(1)
+		exitProcess(1)
 	}
 
 	// Create a single environment to be used across all evaluations
@@ -144,7 +295,10 @@ func StartFile(path string) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		evaluated := evaluator.EndRun(env, evaluator.Eval(program, env))
+		if exit, ok := evaluated.(*object.Exit); ok {
+			exitProcess(exit.Code)
+		}
 		if evaluated != nil {
 			io.WriteString(os.Stdout, evaluated.Inspect())
 			io.WriteString(os.Stdout, "\n")
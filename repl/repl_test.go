@@ -0,0 +1,140 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunTests(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"people.csv": "name,age\nAnn,30\nBo,12\n",
+		"people_test.csl": `load "people.csv"
+let people = csv
+fn test_rows() { assert_rows(people, 2) }
+fn test_names() {
+  expect(people[0]["name"], "Bo")
+}`,
+		"plain_test.csl": "expect(1 + 1, 2)",
+		"helper.csl":     "expect(1, 2)", // not a test, it doesn't end with _test.csl
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if RunTests([]string{dir}, &out) {
+		t.Errorf("expected a failed test")
+	}
+
+	expected := []string{
+		"PASS " + filepath.Join(dir, "people_test.csl") + ": test_rows",
+		"FAIL " + filepath.Join(dir, "people_test.csl") + ": test_names",
+		"    assertion failed: expected Bo, got Ann at line 5, column 3",
+		"PASS " + filepath.Join(dir, "plain_test.csl"),
+		"",
+		"2 passed, 1 failed",
+	}
+	if got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, got)
+	}
+
+	out.Reset()
+	if !RunTests([]string{filepath.Join(dir, "plain_test.csl")}, &out) {
+		t.Errorf("expected the test to pass. got=%q", out.String())
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"raw/a.csv": "name,age\nAnn,30\nBo,12\n",
+		"raw/b.csv": "name,age\nCy,x\n",
+		"raw/c.txt": "not a CSV",
+		"raw/d.csv": "name,age\nDi,40\n",
+		"lib.csl":   "fn keep(rows) { rows }",
+		// every run imports the helper, the runs in parallel must not see each other's imports as a cycle
+		"clean.csl": `import "` + filepath.Join(dir, "lib.csl") + `"
+load "{input}"
+let adults = keep(read row * where age > 18)
+save adults as "{output}"`,
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	raw, clean := filepath.Join(dir, "raw"), filepath.Join(dir, "clean")
+
+	var out bytes.Buffer
+	if RunBatch(filepath.Join(dir, "clean.csl"), raw, clean, 4, &out) {
+		t.Errorf("expected a failed file")
+	}
+	expected := []string{
+		"ok   " + filepath.Join(raw, "a.csv") + " -> " + filepath.Join(clean, "a.csv"),
+		"FAIL " + filepath.Join(raw, "b.csv"),
+		`    invalid number "x" in column age at row 0 at line 3, column 19`,
+		"ok   " + filepath.Join(raw, "d.csv") + " -> " + filepath.Join(clean, "d.csv"),
+		"",
+		"2 processed, 1 failed",
+	}
+	if got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, got)
+	}
+	if content, _ := os.ReadFile(filepath.Join(clean, "a.csv")); string(content) != "name,age\nAnn,30\n" {
+		t.Errorf("wrong output file. got=%q", content)
+	}
+
+	out.Reset()
+	if RunBatch(filepath.Join(dir, "clean.csl"), raw, raw, 1, &out) {
+		t.Errorf("expected writing to the input directory to fail")
+	}
+}
+
+func TestUndo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "people.csv")
+	if err := os.WriteFile(path, []byte("name,age\nAnn,30\nBo,12\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lines := []string{
+		`:undo`,
+		`load "` + path + `"`,
+		`let adults = read row * where age > 17`,
+		`update csv set (age: 1)`,
+		`csv[0]["age"] = 2`,
+		`print(csv)`, // changes nothing, so it isn't taken back
+		`csv[0]["age"]`,
+		`:undo`,
+		`csv[0]["age"]`,
+		`:undo`,
+		`csv[1]["age"]`,
+		`:undo`,
+		`adults`,
+	}
+	var out bytes.Buffer
+	Start(strings.NewReader(strings.Join(lines, "\n")), &out)
+
+	expected := []string{
+		"nothing to undo",
+		"name age ", "---- --- ", "Ann  30  ", "Bo   12  ", "",
+		"2",
+		"2",
+		"null",
+		"2",
+		`undone: csv[0]["age"] = 2`,
+		"1",
+		"undone: update csv set (age: 1)",
+		"12",
+		"undone: let adults = read row * where age > 17",
+		"ERROR: identifier not found: adults",
+	}
+	if got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, got)
+	}
+}
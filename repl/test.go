@@ -0,0 +1,157 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Rishabh570/csvlang/ast"
+	"github.com/Rishabh570/csvlang/evaluator"
+	"github.com/Rishabh570/csvlang/lexer"
+	"github.com/Rishabh570/csvlang/object"
+	"github.com/Rishabh570/csvlang/parser"
+)
+
+// TestFileSuffix marks the scripts run by `csvlang test` when it is given a directory
+const TestFileSuffix = "_test.csl"
+
+// testResult is the outcome of one test, Err is empty when it passed
+type testResult struct {
+	Name string
+	Err  string
+}
+
+// RunTests runs test scripts and writes a PASS or FAIL line for every test, followed by a summary.
+// Paths can be scripts or directories, which are searched for files ending with _test.csl.
+//
+// The statements of a script run first, in the directory of the script so fixture CSVs can be loaded by relative paths.
+// Every function named test_* is then called as a test. A script without test functions is a single test.
+// It reports whether every test passed.
+func RunTests(paths []string, out io.Writer) bool {
+	files, err := findTestFiles(paths)
+	if err != nil {
+		fmt.Fprintf(out, "Error finding tests: %s\n", err)
+		return false
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(out, "no test files found\n")
+		return false
+	}
+
+	passed, failed := 0, 0
+	for _, file := range files {
+		for _, result := range runTestFile(file) {
+			if result.Err == "" {
+				passed++
+				fmt.Fprintf(out, "PASS %s\n", result.Name)
+				continue
+			}
+			failed++
+			fmt.Fprintf(out, "FAIL %s\n    %s\n", result.Name, strings.ReplaceAll(result.Err, "\n", "\n    "))
+		}
+	}
+	fmt.Fprintf(out, "\n%d passed, %d failed\n", passed, failed)
+	return failed == 0
+}
+
+// findTestFiles expands directories to the test scripts they contain, scripts given explicitly are kept as is
+func findTestFiles(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && strings.HasSuffix(file, TestFileSuffix) {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// runTestFile runs a test script in its own environment and returns the result of each of its tests
+func runTestFile(path string) []testResult {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return []testResult{{Name: path, Err: err.Error()}}
+	}
+	p := parser.New(lexer.New(string(content)))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		messages := make([]string, len(p.Errors))
+		for i, parserErr := range p.Errors {
+			messages[i] = parserErr.Diagnostic()
+		}
+		return []testResult{{Name: path, Err: strings.Join(messages, "\n")}}
+	}
+
+	// fixtures are relative to the script
+	wd, err := os.Getwd()
+	if err != nil {
+		return []testResult{{Name: path, Err: err.Error()}}
+	}
+	if err := os.Chdir(filepath.Dir(path)); err != nil {
+		return []testResult{{Name: path, Err: err.Error()}}
+	}
+	defer os.Chdir(wd)
+
+	env := object.NewEnvironment()
+	if message, stop := testOutcome(evaluator.EndRun(env, evaluator.Eval(program, env))); message != "" || stop {
+		return []testResult{{Name: path, Err: message}}
+	}
+
+	tests := testFunctions(program)
+	if len(tests) == 0 {
+		return []testResult{{Name: path}}
+	}
+	results := make([]testResult, len(tests))
+	for i, test := range tests {
+		call := &ast.CallExpression{Token: test.Token, Function: test.Name}
+		message, _ := testOutcome(evaluator.EndRun(env, evaluator.Eval(call, env)))
+		results[i] = testResult{Name: path + ": " + test.Name.Value, Err: message}
+	}
+	return results
+}
+
+// testFunctions returns the top level functions named test_*, in source order
+func testFunctions(program *ast.Program) []*ast.FunctionStatement {
+	tests := []*ast.FunctionStatement{}
+	for _, stmt := range program.Statements {
+		fn, ok := stmt.(*ast.FunctionStatement)
+		if ok && fn != nil && fn.Name != nil && strings.HasPrefix(fn.Name.Value, "test_") {
+			tests = append(tests, fn)
+		}
+	}
+	return tests
+}
+
+// testOutcome describes why a test failed, or returns "" if it passed.
+// stop is set when the script called exit(), the remaining tests of the script are skipped.
+func testOutcome(result object.Object) (message string, stop bool) {
+	switch result := result.(type) {
+	case *object.Error:
+		return result.Location(), false
+	case *object.Exit:
+		if result.Code != 0 {
+			return fmt.Sprintf("exited with code %d", result.Code), true
+		}
+		return "", true
+	}
+	return "", false
+}
@@ -0,0 +1,211 @@
+// server package runs csvlang scripts over HTTP, started with `csvlang serve --port 8080`.
+//
+// A request posts a CSV and either the script to run or the name of a script registered on the server:
+//
+//	POST /run              multipart form with a `script` field and a `csv` file
+//	POST /scripts/{name}   the CSV as body, runs {name}.csl from the scripts directory
+//	GET  /scripts          lists the registered scripts
+//
+// The CSV is bound to `csv` as if the script had loaded it, every request gets its own environment.
+// The response is the CSV the script evaluates to, or `csv` when its last statement isn't a CSV, written as CSV
+// or as JSON with ?format=json. Scripts should run with evaluator.FileAccess turned off, so they only see what they are sent.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Rishabh570/csvlang/evaluator"
+	"github.com/Rishabh570/csvlang/lexer"
+	"github.com/Rishabh570/csvlang/object"
+	"github.com/Rishabh570/csvlang/parser"
+)
+
+// scriptName is the format of registered script names, so a name can't reach outside the scripts directory
+var scriptName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// formMemory is how much of a posted form is kept in memory, larger files are buffered on disk
+const formMemory = 32 << 20
+
+// Server runs the scripts posted to it, or the scripts of a directory
+type Server struct {
+	Scripts  string        // directory of the scripts run by name, "" to only run posted scripts
+	Timeout  time.Duration // longest a script may run before it is stopped, 0 for no limit
+	MaxBytes int64         // largest request body accepted, 0 for no limit
+}
+
+// errorResponse is the body of failed requests, Line and Column point into the script when known
+type errorResponse struct {
+	Error  string `json:"error"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+// Handler returns the routes of the server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /run", s.handleRun)
+	mux.HandleFunc("POST /scripts/{name}", s.handleScript)
+	mux.HandleFunc("GET /scripts", s.handleList)
+	return mux
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	s.limitBody(w, r)
+	if err := r.ParseMultipartForm(formMemory); err != nil {
+		writeError(w, requestErrorStatus(err), errorResponse{Error: "invalid form: " + err.Error()})
+		return
+	}
+	script := r.FormValue("script")
+	if script == "" {
+		writeError(w, http.StatusBadRequest, errorResponse{Error: "missing script field"})
+		return
+	}
+	file, _, err := r.FormFile("csv")
+	if err != nil {
+		writeError(w, requestErrorStatus(err), errorResponse{Error: "missing csv file: " + err.Error()})
+		return
+	}
+	defer file.Close()
+	s.run(w, r, script, file)
+}
+
+func (s *Server) handleScript(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if s.Scripts == "" || !scriptName.MatchString(name) {
+		writeError(w, http.StatusNotFound, errorResponse{Error: "unknown script: " + name})
+		return
+	}
+	script, err := os.ReadFile(filepath.Join(s.Scripts, name+".csl"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, errorResponse{Error: "unknown script: " + name})
+		return
+	}
+	s.limitBody(w, r)
+	s.run(w, r, string(script), r.Body)
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	names := []string{}
+	if s.Scripts != "" {
+		files, err := filepath.Glob(filepath.Join(s.Scripts, "*.csl"))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+			return
+		}
+		for _, file := range files {
+			name := strings.TrimSuffix(filepath.Base(file), ".csl")
+			if scriptName.MatchString(name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"scripts": names})
+}
+
+func (s *Server) limitBody(w http.ResponseWriter, r *http.Request) {
+	if s.MaxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.MaxBytes)
+	}
+}
+
+// run evaluates a script on a CSV and writes the result
+func (s *Server) run(w http.ResponseWriter, r *http.Request, script string, input io.Reader) {
+	data, err := evaluator.ReadCSV(input, false, "")
+	if err != nil {
+		writeError(w, requestErrorStatus(err), errorResponse{Error: err.Error()})
+		return
+	}
+
+	p := parser.New(lexer.New(script))
+	program := p.ParseProgram()
+	if len(p.Errors) != 0 {
+		first := p.Errors[0]
+		writeError(w, http.StatusBadRequest, errorResponse{Error: first.Message, Line: first.Line, Column: first.Column})
+		return
+	}
+
+	// the script is stopped once the request is done or times out, see evaluator.EvalContext
+	ctx := r.Context()
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	// it runs aside so the response isn't held up by a builtin that doesn't check the context, eg. a slow read
+	done := make(chan object.Object, 1)
+	go func() {
+		env := object.NewEnvironment()
+		env.Set("csv", data)
+		result := evaluator.EvalContext(ctx, program, env)
+		// when the last statement is eg. a print or an assignment, the rows are in csv
+		exit, exited := result.(*object.Exit)
+		_, isCSV := result.(*object.CSV)
+		_, failed := result.(*object.Error)
+		if !isCSV && !failed && (!exited || exit.Code == 0) {
+			result, _ = env.Get("csv")
+		}
+		done <- result
+	}()
+
+	var result object.Object
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		writeError(w, http.StatusGatewayTimeout, errorResponse{Error: fmt.Sprintf("script timed out after %s", s.Timeout)})
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	switch result := result.(type) {
+	case *object.Error:
+		writeError(w, http.StatusBadRequest, errorResponse{Error: result.Message, Line: result.Pos.Line, Column: result.Pos.Column})
+	case *object.Exit:
+		writeError(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("script exited with code %d", result.Code)})
+	case *object.CSV:
+		writeCSV(w, r, result)
+	default:
+		writeError(w, http.StatusBadRequest, errorResponse{Error: "script did not produce a CSV"})
+	}
+}
+
+func writeCSV(w http.ResponseWriter, r *http.Request, data *object.CSV) {
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		evaluator.WriteJSON(w, data)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	evaluator.WriteCSV(w, data)
+}
+
+func writeError(w http.ResponseWriter, status int, body errorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// requestErrorStatus tells bodies over the size limit apart from malformed ones
+func requestErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
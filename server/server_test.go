@@ -0,0 +1,122 @@
+package server
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Rishabh570/csvlang/evaluator"
+)
+
+const people = "name,age\nAnn,30\nBo,12\n"
+
+// postScript posts a script and a CSV to /run
+func postScript(t *testing.T, handler http.Handler, target, script, data string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("script", script)
+	file, err := form.CreateFormFile("csv", "data.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte(data))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, target, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer(t *testing.T) {
+	evaluator.FileAccess = false
+	defer func() { evaluator.FileAccess = true }()
+
+	scripts := t.TempDir()
+	if err := os.WriteFile(filepath.Join(scripts, "adults.csl"), []byte("let adults = read row * where age > 17\nadults"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	handler := (&Server{Scripts: scripts, Timeout: time.Second, MaxBytes: 1 << 16}).Handler()
+
+	tests := []struct {
+		name     string
+		script   string
+		target   string
+		data     string
+		status   int
+		expected string
+	}{
+		{"result of the last statement", `csv |> sort("age")`, "/run", people, 200, "name,age\nBo,12\nAnn,30\n"},
+		{"csv when the last statement isn't a CSV", "let total = sum(csv, \"age\")", "/run", people, 200, people},
+		{"json output", "read row 0", "/run?format=json", people, 200, "{\n  \"headers\": [\n    \"name\",\n    \"age\"\n  ],\n  \"rows\": [\n    {\n      \"name\": \"Ann\",\n      \"age\": \"30\"\n    }\n  ],\n  \"schema\": [\n    {\n      \"name\": \"name\",\n      \"type\": \"STRING\",\n      \"nullable\": false\n    },\n    {\n      \"name\": \"age\",\n      \"type\": \"INTEGER\",\n      \"nullable\": false\n    }\n  ]\n}"},
+		{"runtime error", "let x = 1\nmissing(x)", "/run", people, 400, `{"error":"identifier not found: missing","line":2,"column":1}` + "\n"},
+		{"parse error", "let = 1", "/run", people, 400, `{"error":"expected next token to be IDENT, got = instead","line":1,"column":5}` + "\n"},
+		{"no file access", `load "/etc/passwd"`, "/run", people, 400, `{"error":"file access is disabled, cannot load /etc/passwd","line":1,"column":1}` + "\n"},
+		{"exit", "exit(3)", "/run", people, 400, `{"error":"script exited with code 3"}` + "\n"},
+		{"body too large", "csv", "/run", strings.Repeat("a", 1<<17), 413, ""},
+	}
+	for _, tt := range tests {
+		rec := postScript(t, handler, tt.target, tt.script, tt.data)
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got=%d: %s", tt.name, tt.status, rec.Code, rec.Body.String())
+			continue
+		}
+		if tt.expected != "" && rec.Body.String() != tt.expected {
+			t.Errorf("%s: wrong body.\nexpected=%q\ngot=%q", tt.name, tt.expected, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/scripts/adults", strings.NewReader(people)))
+	if rec.Code != 200 || rec.Body.String() != "name,age\nAnn,30\n" {
+		t.Errorf("named script failed. got=%d %q", rec.Code, rec.Body.String())
+	}
+	for _, target := range []string{"/scripts/missing", "/scripts/..%2Fadults"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(people)))
+		if rec.Code != 404 {
+			t.Errorf("%s: expected status 404, got=%d", target, rec.Code)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/scripts", nil))
+	if rec.Body.String() != `{"scripts":["adults"]}`+"\n" {
+		t.Errorf("wrong script list. got=%q", rec.Body.String())
+	}
+}
+
+func TestServerTimeout(t *testing.T) {
+	handler := (&Server{Timeout: 10 * time.Millisecond}).Handler()
+	rec := postScript(t, handler, "/run", "for i, x in range(2000000) { let y = x * 2 }", people)
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected a timeout. got=%d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServerRunaway(t *testing.T) {
+	handler := (&Server{Timeout: 30 * time.Second}).Handler()
+	rec := postScript(t, handler, "/run", "fn f(n) { f(n + 1) }\nprint(f(0))", people)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "maximum call depth") {
+		t.Errorf("expected the recursion to fail. got=%d %q", rec.Code, rec.Body.String())
+	}
+
+	// a script past the timeout is stopped, the next requests are answered
+	handler = (&Server{Timeout: 10 * time.Millisecond}).Handler()
+	rec = postScript(t, handler, "/run", "for i, x in sequence(1000000000000) { let y = x * 2 }", people)
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected a timeout. got=%d %q", rec.Code, rec.Body.String())
+	}
+	rec = postScript(t, handler, "/run", "read row * where age > 17", people)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the next script to run. got=%d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -11,6 +11,7 @@
 package token
 
 import (
+	"fmt"
 	"strings"
 )
 
@@ -23,23 +24,44 @@ const (
 	// Identifiers + literals
 	IDENT  = "IDENT"  // add, foobar, x, y, ...
 	INT    = "INT"    // 1343456
+	FLOAT  = "FLOAT"  // 3.14
 	STRING = "STRING" // "foobar"
 
 	// Operators
-	ASSIGN   = "="
-	PLUS     = "+"
-	MINUS    = "-"
-	BANG     = "!"
-	ASTERISK = "*"
-	SLASH    = "/"
-	LT       = "<"
-	GT       = ">"
-	EQ       = "=="
-	NOT_EQ   = "!="
+	ASSIGN          = "="
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+
+	PLUS        = "+"
+	MINUS       = "-"
+	BANG        = "!"
+	ASTERISK    = "*"
+	SLASH       = "/"
+	MODULO      = "%"
+	POWER       = "**"
+	RANGE       = ".."  // integer range, eg. 0..10
+	ELLIPSIS    = "..." // variadic parameter, eg. fn(...parts)
+	DOT         = "."   // method call on the result of a call or index, eg. csv.filter(f).head(10)
+	LT          = "<"
+	GT          = ">"
+	LT_EQ       = "<="
+	GT_EQ       = ">="
+	EQ          = "=="
+	ARROW       = "=>"
+	NOT_EQ      = "!="
+	EQ_FOLD     = "==*" // case-insensitive string equality, eg. name ==* "bob"
+	NOT_EQ_FOLD = "!=*"
+
+	LOGICAL_AND = "&&"
+	LOGICAL_OR  = "||"
+	PIPE        = "|>" // passes the left value as the first argument, eg. rows |> unique()
 
 	// Delimiters
 	COMMA     = "," // acts as a delimiter in arrays
 	SEMICOLON = ";"
+	COLON     = ":"   // separates slice bounds, eg. arr[1:3]
 	NEWLINE   = "\\n" // Add this line
 
 	LPAREN   = "("
@@ -59,8 +81,10 @@ const (
 	DELETE   = "DELETE"
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	NULL     = "NULL"
 	IF       = "IF"
 	FOR      = "FOR"
 	IN       = "IN"
@@ -68,7 +92,15 @@ const (
 	RETURN   = "RETURN"
 	SAVE     = "SAVE"
 	AS       = "AS" // used in "save rows as filtered.csv" statements
+	AND      = "AND"
+	OR       = "OR"
+	NOT      = "NOT"
+	MATCH    = "MATCH"
+	IMPORT   = "IMPORT" // evaluate another script, eg. import "lib/cleaning.cl"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
 
+	FROM  = "FROM"  // read from a CSV variable instead of the loaded csv file, eg. read from sales row *
 	ROW   = "ROW"   // read particular rows from the loaded csv file
 	COL   = "COL"   // read particular columns from the loaded csv rows
 	WHERE = "WHERE" // filter rows based on a condition
@@ -77,6 +109,17 @@ const (
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // where the token starts in the source
+}
+
+// Position is a location in the source, lines and columns start at 1 and columns count bytes
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
 }
 
 // keywords is a map of reserved keywords in csvlang
@@ -85,13 +128,16 @@ var keywords = map[string]TokenType{
 	"read":   READ,
 	"update": UPDATE,
 	"delete": DELETE,
+	"from":   FROM,
 	"row":    ROW,
 	"col":    COL,
 	"where":  "WHERE",
 	"fn":     FUNCTION,
 	"let":    LET,
+	"const":  CONST,
 	"true":   TRUE,
 	"false":  FALSE,
+	"null":   NULL,
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
@@ -99,6 +145,13 @@ var keywords = map[string]TokenType{
 	"as":     AS,
 	"for":    FOR,
 	"in":     IN,
+	"and":    AND,
+	"or":     OR,
+	"not":    NOT,
+	"match":  MATCH,
+	"import": IMPORT,
+	"try":    TRY,
+	"catch":  CATCH,
 }
 
 // LookupIdent checks if the given identifier is a keyword
@@ -9,11 +9,20 @@ func TestLookupIdent(t *testing.T) {
 	}{
 		{input: "fn", expected: FUNCTION},
 		{input: "let", expected: LET},
+		{input: "const", expected: CONST},
 		{input: "true", expected: TRUE},
 		{input: "false", expected: FALSE},
 		{input: "if", expected: IF},
 		{input: "else", expected: ELSE},
 		{input: "return", expected: RETURN},
+		{input: "and", expected: AND},
+		{input: "OR", expected: OR},
+		{input: "not", expected: NOT},
+		{input: "null", expected: NULL},
+		{input: "import", expected: IMPORT},
+		{input: "try", expected: TRY},
+		{input: "catch", expected: CATCH},
+		{input: "from", expected: FROM},
 		{input: "abc", expected: IDENT},
 	}
 